@@ -254,6 +254,28 @@ func (h *histogramImpl) distributions(clean bool) []Distribution {
 	return distributions
 }
 
+// sampleCompression is the compression of the t-digest computing the centroids of SampleCentroids.
+const sampleCompression = 100
+
+// SampleCentroids returns centroids approximating the distribution of raw samples, e.g. latency measurements.
+// The samples are added to a t-digest with a compression of 100: small sets of samples keep each distinct value
+// as its own centroid, sorted by value, while larger ones are merged in up to a few hundred centroids, sized so that the quantiles
+// near the extremes are more accurate than the median. NaN and infinite samples are ignored.
+func SampleCentroids(samples []float64) Centroids {
+	td, _ := tdigest.New(tdigest.Compression(sampleCompression))
+	for _, v := range samples {
+		if !math.IsNaN(v) && !math.IsInf(v, 0) {
+			td.Add(v)
+		}
+	}
+	var centroids Centroids
+	td.ForEachCentroid(func(mean float64, count uint64) bool {
+		centroids = append(centroids, Centroid{Value: mean, Count: int(count)})
+		return true
+	})
+	return centroids.Compact()
+}
+
 func (h *histogramImpl) rotateCurrentTDigestIfNeedIt() {
 	h.mutex.Lock()
 	defer h.mutex.Unlock()
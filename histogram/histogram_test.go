@@ -1,6 +1,7 @@
 package histogram
 
 import (
+	"math"
 	"math/rand"
 	"sort"
 	"testing"
@@ -47,6 +48,44 @@ func TestHistogram(t *testing.T) {
 	assert.Equal(t, len(distributions), 0, "Error on distributions number")
 }
 
+func TestAlignTimestamp(t *testing.T) {
+	ts := time.Date(2018, 8, 6, 4, 32, 57, 500, time.UTC)
+	assert.Equal(t, time.Date(2018, 8, 6, 4, 32, 0, 0, time.UTC).Unix(), AlignTimestamp(MINUTE, ts))
+	assert.Equal(t, time.Date(2018, 8, 6, 4, 0, 0, 0, time.UTC).Unix(), AlignTimestamp(HOUR, ts))
+	assert.Equal(t, time.Date(2018, 8, 6, 0, 0, 0, 0, time.UTC).Unix(), AlignTimestamp(DAY, ts))
+
+	// an aligned timestamp is its own bin
+	aligned := time.Date(2018, 8, 6, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, aligned.Unix(), AlignTimestamp(DAY, aligned))
+}
+
+func TestAlignTimestampDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone database not available:", err)
+	}
+
+	// clocks go from 01:59:59 EST to 03:00:00 EDT on 2019-03-10
+	before := time.Date(2019, 3, 10, 1, 59, 30, 0, loc)
+	after := before.Add(time.Minute)
+	assert.Equal(t, 3, after.Hour())
+	assert.Equal(t, before.Unix()-30, AlignTimestamp(MINUTE, before))
+	assert.Equal(t, after.Unix()-30, AlignTimestamp(MINUTE, after))
+	assert.Equal(t, time.Date(2019, 3, 10, 6, 0, 0, 0, time.UTC).Unix(), AlignTimestamp(HOUR, before))
+	assert.Equal(t, time.Date(2019, 3, 10, 7, 0, 0, 0, time.UTC).Unix(), AlignTimestamp(HOUR, after))
+
+	// day bins are UTC days whatever the location
+	assert.Equal(t, time.Date(2019, 3, 10, 0, 0, 0, 0, time.UTC).Unix(), AlignTimestamp(DAY, before))
+	assert.Equal(t, time.Date(2019, 3, 10, 0, 0, 0, 0, time.UTC).Unix(), AlignTimestamp(DAY, after))
+
+	// clocks go from 01:59:59 EDT back to 01:00:00 EST on 2019-11-03, the 01:xx hour happens twice
+	first := time.Date(2019, 11, 3, 1, 30, 0, 0, loc)
+	second := first.Add(time.Hour)
+	assert.Equal(t, first.Hour(), second.Hour())
+	assert.Equal(t, time.Date(2019, 11, 3, 5, 0, 0, 0, time.UTC).Unix(), AlignTimestamp(HOUR, first))
+	assert.Equal(t, time.Date(2019, 11, 3, 6, 0, 0, 0, time.UTC).Unix(), AlignTimestamp(HOUR, second))
+}
+
 func TestCompactHistoLine(t *testing.T) {
 	centroids := Centroids{
 		{Value: 30.0, Count: 20},
@@ -67,6 +106,70 @@ func TestCompactHistoLine(t *testing.T) {
 	assert.Equal(t, centroidsExp, vals, "Error on Centroids.Compact()")
 }
 
+func TestCompactOrder(t *testing.T) {
+	centroids := Centroids{{Value: 30.0, Count: 20}, {Value: 5.1, Count: 10}, {Value: 30.0, Count: 1}}
+	assert.Equal(t, Centroids{{Value: 30.0, Count: 21}, {Value: 5.1, Count: 10}}, centroids.Compact())
+}
+
 func (a Centroids) Len() int           { return len(a) }
 func (a Centroids) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a Centroids) Less(i, j int) bool { return a[i].Value < a[j].Value }
+
+func TestGranularities(t *testing.T) {
+	assert.Equal(t, map[Granularity]bool{MINUTE: true, DAY: true}, Granularities(MINUTE, DAY, MINUTE))
+	assert.Empty(t, Granularities())
+}
+
+func TestNewCentroid(t *testing.T) {
+	centroid, err := NewCentroid(30, 20)
+	assert.NoError(t, err)
+	assert.Equal(t, Centroid{Value: 30, Count: 20}, centroid)
+
+	_, err = NewCentroid(30, 0)
+	assert.EqualError(t, err, "invalid centroid count 0: count must be at least 1")
+	_, err = NewCentroid(30, -2)
+	assert.Error(t, err)
+	_, err = NewCentroid(math.NaN(), 1)
+	assert.EqualError(t, err, "invalid centroid value NaN: value must be finite")
+	_, err = NewCentroid(math.Inf(1), 1)
+	assert.Error(t, err)
+}
+
+// centroidsQuantile returns the value of the centroid holding the q quantile of the samples.
+func centroidsQuantile(centroids Centroids, q float64) float64 {
+	total := 0
+	for _, c := range centroids {
+		total += c.Count
+	}
+	rank := q * float64(total)
+	seen := 0
+	for _, c := range centroids {
+		seen += c.Count
+		if float64(seen) >= rank {
+			return c.Value
+		}
+	}
+	return centroids[len(centroids)-1].Value
+}
+
+func TestSampleCentroids(t *testing.T) {
+	samples := make([]float64, 10000)
+	for i := range samples {
+		samples[i] = float64(i + 1)
+	}
+	rand.Shuffle(len(samples), func(i, j int) { samples[i], samples[j] = samples[j], samples[i] })
+
+	centroids := SampleCentroids(samples)
+	assert.True(t, len(centroids) < 1000, "samples are merged")
+	count := 0
+	for _, c := range centroids {
+		count += c.Count
+	}
+	assert.Equal(t, len(samples), count)
+	for _, q := range []float64{0.5, 0.9, 0.99, 0.999} {
+		assert.InEpsilon(t, q*10000, centroidsQuantile(centroids, q), 0.02, q)
+	}
+
+	assert.Equal(t, Centroids{{Value: 1.5, Count: 2}, {Value: 3, Count: 1}}, SampleCentroids([]float64{3, 1.5, math.NaN(), 1.5}))
+	assert.Empty(t, SampleCentroids(nil))
+}
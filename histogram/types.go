@@ -1,6 +1,8 @@
 package histogram
 
 import (
+	"fmt"
+	"math"
 	"time"
 )
 
@@ -10,23 +12,42 @@ type Centroid struct {
 	Count int
 }
 
+// NewCentroid returns a centroid of count points with the given mean value,
+// or an error when the value is not finite or the count is lower than 1.
+func NewCentroid(value float64, count int) (Centroid, error) {
+	centroid := Centroid{Value: value, Count: count}
+	if err := centroid.Validate(); err != nil {
+		return Centroid{}, err
+	}
+	return centroid, nil
+}
+
+// Validate returns an error when the centroid would be rejected by Wavefront:
+// when its value is not finite or its count is lower than 1.
+func (c Centroid) Validate() error {
+	if math.IsNaN(c.Value) || math.IsInf(c.Value, 0) {
+		return fmt.Errorf("invalid centroid value %v: value must be finite", c.Value)
+	}
+	if c.Count < 1 {
+		return fmt.Errorf("invalid centroid count %d: count must be at least 1", c.Count)
+	}
+	return nil
+}
+
 type Centroids []Centroid
 
+// Compact merges the centroids sharing the same value, keeping the order in which the values first appear.
 func (centroids Centroids) Compact() Centroids {
-	tmp := make(map[float64]int)
+	idx := make(map[float64]int)
+	res := make(Centroids, 0, len(centroids))
 	for _, c := range centroids {
-		if _, ok := tmp[c.Value]; ok {
-			tmp[c.Value] += c.Count
+		if i, ok := idx[c.Value]; ok {
+			res[i].Count += c.Count
 		} else {
-			tmp[c.Value] = c.Count
+			idx[c.Value] = len(res)
+			res = append(res, c)
 		}
 	}
-	res := make(Centroids, len(tmp))
-	idx := 0
-	for v, c := range tmp {
-		res[idx] = Centroid{Value: v, Count: c}
-		idx++
-	}
 	return res
 }
 
@@ -39,6 +60,15 @@ const (
 	DAY
 )
 
+// Granularities returns the set of the given granularities, in the representation used by SendDistribution.
+func Granularities(hgs ...Granularity) map[Granularity]bool {
+	set := make(map[Granularity]bool, len(hgs))
+	for _, hg := range hgs {
+		set[hg] = true
+	}
+	return set
+}
+
 // Duration of the Granularity
 func (hg *Granularity) Duration() time.Duration {
 	switch *hg {
@@ -51,6 +81,13 @@ func (hg *Granularity) Duration() time.Duration {
 	}
 }
 
+// AlignTimestamp returns the timestamp (in seconds) of the start of the bin of the given granularity containing t.
+// Bins are aligned on UTC, so the result doesn't depend on the location of t or on daylight saving time changes.
+// Use it to get the timestamp of a distribution sent with SendDistribution.
+func AlignTimestamp(g Granularity, t time.Time) int64 {
+	return t.Truncate(g.Duration()).Unix()
+}
+
 func (hg *Granularity) String() string {
 	switch *hg {
 	case MINUTE:
@@ -13,8 +13,13 @@ func HasDeltaPrefix(name string) bool {
 
 // Gets a delta counter name prefixed with ∆.
 func DeltaCounterName(name string) string {
+	return PrefixedDeltaCounterName(name, DeltaPrefix)
+}
+
+// Gets a delta counter name prefixed with the given delta prefix, unless it already has one of the delta prefixes.
+func PrefixedDeltaCounterName(name, prefix string) string {
 	if HasDeltaPrefix(name) {
 		return name
 	}
-	return DeltaPrefix + name
+	return prefix + name
 }
@@ -0,0 +1,45 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ReportError is returned when a batch of data could not be reported to Wavefront, either because the
+// request failed (StatusCode is 0 and Err set) or because the server responded with an error status.
+type ReportError struct {
+	Format     string
+	StatusCode int
+	Err        error
+	// delay requested by the Retry-After header of a 429 response before reporting data again, 0 when none
+	RetryAfter time.Duration
+}
+
+func (e *ReportError) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("error reporting %s format data to Wavefront: %q", e.Format, e.Err)
+	}
+	return fmt.Sprintf("error reporting %s format data to Wavefront. status=%d", e.Format, e.StatusCode)
+}
+
+func (e *ReportError) Unwrap() error {
+	return e.Err
+}
+
+// Retryable reports whether reporting the data again may succeed: true for the network errors,
+// the 5xx statuses and the throttling ones (406 and 429), false for the other 4xx statuses,
+// denoting data rejected by the server.
+func (e *ReportError) Retryable() bool {
+	switch {
+	case e.StatusCode == 0, e.StatusCode >= 500, e.throttled():
+		return true
+	default:
+		return false
+	}
+}
+
+// throttled reports whether the server rejected the request as the rate of data is too high.
+func (e *ReportError) throttled() bool {
+	return e.StatusCode == http.StatusNotAcceptable || e.StatusCode == http.StatusTooManyRequests
+}
@@ -0,0 +1,279 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RotatingFile is a file appended to by several handlers, rotated when it reaches a max size or age:
+// the file is renamed with the rotation time as suffix (e.g. "metrics.log.20060102T150405.000000000")
+// and a new file is started. The suffixes sort in rotation order.
+type RotatingFile struct {
+	path     string
+	maxBytes int64
+	maxAge   time.Duration
+	now      func() time.Time
+
+	mtx    sync.Mutex
+	file   *os.File
+	writer *bufio.Writer
+	size   int64
+	opened time.Time
+	users  int
+}
+
+// NewRotatingFile returns a file at path rotated when writing would make it larger than maxBytes,
+// or when it was opened maxAge ago. A zero maxBytes or maxAge disables that rotation.
+func NewRotatingFile(path string, maxBytes int64, maxAge time.Duration) *RotatingFile {
+	return &RotatingFile{path: path, maxBytes: maxBytes, maxAge: maxAge, now: time.Now}
+}
+
+// Open opens the file if not already open, appending to an existing file.
+func (f *RotatingFile) Open() error {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return f.open()
+}
+
+func (f *RotatingFile) open() error {
+	if f.file != nil {
+		return nil
+	}
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to open file %s: %w", f.path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("unable to open file %s: %w", f.path, err)
+	}
+	f.file = file
+	f.writer = bufio.NewWriter(file)
+	f.size = info.Size()
+	f.opened = f.now()
+	return nil
+}
+
+// IsOpen returns whether the file is open.
+func (f *RotatingFile) IsOpen() bool {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return f.file != nil
+}
+
+// Write appends data to the file, rotating it first when due. data is never split across files.
+func (f *RotatingFile) Write(data string) error {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	if err := f.open(); err != nil {
+		return err
+	}
+	if f.size > 0 && f.rotationDue(int64(len(data))) {
+		if err := f.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := f.writer.WriteString(data)
+	f.size += int64(n)
+	return err
+}
+
+func (f *RotatingFile) rotationDue(n int64) bool {
+	return (f.maxBytes > 0 && f.size+n > f.maxBytes) || (f.maxAge > 0 && f.now().Sub(f.opened) >= f.maxAge)
+}
+
+// rotate closes the file, renames it with the current time as suffix and opens a new one.
+func (f *RotatingFile) rotate() error {
+	if err := f.close(); err != nil {
+		return err
+	}
+	rotated := f.path + "." + f.now().UTC().Format("20060102T150405.000000000")
+	if err := os.Rename(f.path, rotated); err != nil {
+		return fmt.Errorf("unable to rotate file %s: %w", f.path, err)
+	}
+	return f.open()
+}
+
+// Flush writes the buffered data to the file.
+func (f *RotatingFile) Flush() error {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	if f.writer == nil {
+		return nil
+	}
+	return f.writer.Flush()
+}
+
+func (f *RotatingFile) close() error {
+	if f.file == nil {
+		return nil
+	}
+	err := f.writer.Flush()
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	f.file = nil
+	f.writer = nil
+	return err
+}
+
+// retain registers a handler writing to the file.
+func (f *RotatingFile) retain() {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.users++
+}
+
+// release unregisters a handler writing to the file, closing it after the last one.
+func (f *RotatingFile) release() error {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.users--
+	if f.users > 0 {
+		return nil
+	}
+	return f.close()
+}
+
+// FileHandler is a ConnectionHandler appending the lines to a RotatingFile, possibly shared with other handlers,
+// e.g. to persist the data while the proxy is unreachable. The lines are buffered and flushed periodically.
+type FileHandler struct {
+	// keep this as first element of struct to guarantee 64-bit alignment on 32-bit machines.
+	failures    int64
+	file        *RotatingFile
+	flushTicker *time.Ticker
+	done        chan struct{}
+
+	mtx     sync.Mutex
+	pending int
+	closed  bool
+
+	writeErrors *DeltaCounter
+	bytesSent   *DeltaCounter
+}
+
+// NewFileHandler returns a handler writing to file, closed when the last of its handlers is closed.
+func NewFileHandler(file *RotatingFile, flushInterval time.Duration, prefix string, internalRegistry *MetricRegistry) ConnectionHandler {
+	file.retain()
+	return &FileHandler{
+		file:        file,
+		flushTicker: time.NewTicker(flushInterval),
+		writeErrors: internalRegistry.NewDeltaCounter(prefix + ".write.errors"),
+		bytesSent:   internalRegistry.NewDeltaCounter(prefix + ".bytes"),
+	}
+}
+
+func (handler *FileHandler) Start() {
+	done := make(chan struct{})
+	handler.mtx.Lock()
+	handler.done = done
+	handler.mtx.Unlock()
+
+	go func() {
+		for {
+			select {
+			case <-handler.flushTicker.C:
+				if err := handler.Flush(); err != nil {
+					log.Println(err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+}
+
+func (handler *FileHandler) Connect() error {
+	return handler.file.Open()
+}
+
+func (handler *FileHandler) Connected() bool {
+	return handler.file.IsOpen()
+}
+
+func (handler *FileHandler) SendData(lines string) error {
+	if err := handler.file.Write(lines); err != nil {
+		handler.writeErrors.Inc()
+		atomic.AddInt64(&handler.failures, 1)
+		return err
+	}
+	handler.bytesSent.Add(int64(len(lines)))
+	handler.mtx.Lock()
+	handler.pending += strings.Count(lines, "\n")
+	handler.mtx.Unlock()
+	return nil
+}
+
+// SendDataCtx writes the lines like SendData, ctx being only checked before writing to the local file.
+func (handler *FileHandler) SendDataCtx(ctx context.Context, lines string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return handler.SendData(lines)
+}
+
+func (handler *FileHandler) Flush() error {
+	if err := handler.file.Flush(); err != nil {
+		handler.writeErrors.Inc()
+		atomic.AddInt64(&handler.failures, 1)
+		return err
+	}
+	handler.mtx.Lock()
+	handler.pending = 0
+	handler.mtx.Unlock()
+	return nil
+}
+
+// FlushCtx flushes like Flush, ctx being only checked before flushing to the local file.
+func (handler *FileHandler) FlushCtx(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return handler.Flush()
+}
+
+// Close flushes the lines and releases the file, the handler being closed once: closing it again does nothing.
+func (handler *FileHandler) Close() error {
+	handler.mtx.Lock()
+	if handler.closed {
+		handler.mtx.Unlock()
+		return nil
+	}
+	handler.closed = true
+	done := handler.done
+	handler.done = nil
+	handler.mtx.Unlock()
+
+	handler.flushTicker.Stop()
+	if done != nil {
+		close(done)
+	}
+	err := handler.Flush()
+	if closeErr := handler.file.release(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (handler *FileHandler) PendingLines() int {
+	handler.mtx.Lock()
+	defer handler.mtx.Unlock()
+	return handler.pending
+}
+
+func (handler *FileHandler) GetFailureCount() int64 {
+	return atomic.LoadInt64(&handler.failures)
+}
+
+func (handler *FileHandler) ResetFailureCount() int64 {
+	return atomic.SwapInt64(&handler.failures, 0)
+}
@@ -0,0 +1,56 @@
+package internal
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotatingFileMaxAge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wavefront-rotating-file")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "telemetry.log")
+
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	file := NewRotatingFile(path, 0, time.Hour)
+	file.now = func() time.Time { return now }
+	handler := NewFileHandler(file, time.Hour, "points", NewMetricRegistry(nil))
+
+	require.NoError(t, handler.SendData("first\n"))
+	now = now.Add(time.Hour)
+	require.NoError(t, handler.SendData("second\n"))
+	require.NoError(t, handler.Close())
+	require.NoError(t, handler.Close(), "closing again does nothing")
+
+	rotated, err := ioutil.ReadFile(path + ".20200101T010000.000000000")
+	require.NoError(t, err)
+	assert.Equal(t, "first\n", string(rotated))
+	current, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "second\n", string(current))
+	assert.False(t, file.IsOpen(), "closed with its last handler")
+}
+
+func TestFileHandlerCloseTwice(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wavefront-rotating-file")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := NewRotatingFile(filepath.Join(dir, "telemetry.log"), 0, 0)
+	points := NewFileHandler(file, time.Hour, "points", NewMetricRegistry(nil))
+	spans := NewFileHandler(file, time.Hour, "spans", NewMetricRegistry(nil))
+	points.Start()
+	require.NoError(t, points.Connect())
+
+	require.NoError(t, points.Close())
+	require.NoError(t, points.Close())
+	assert.True(t, file.IsOpen(), "still used by the spans handler")
+	require.NoError(t, spans.Close())
+	assert.False(t, file.IsOpen())
+}
@@ -2,7 +2,10 @@
 // Interfaces within this package are not guaranteed to be backwards compatible between releases.
 package internal
 
-import "net/http"
+import (
+	"context"
+	"net/http"
+)
 
 // Reporter is an interface for reporting data to a Wavefront service.
 type Reporter interface {
@@ -10,6 +13,12 @@ type Reporter interface {
 	ReportEvent(event string) (*http.Response, error)
 }
 
+// ContextReporter is a Reporter whose requests are cancelled when a context is done.
+type ContextReporter interface {
+	ReportCtx(ctx context.Context, format string, pointLines string) (*http.Response, error)
+	ReportEventCtx(ctx context.Context, event string) (*http.Response, error)
+}
+
 type Flusher interface {
 	Flush() error
 	GetFailureCount() int64
@@ -19,8 +28,18 @@ type Flusher interface {
 type ConnectionHandler interface {
 	Connect() error
 	Connected() bool
-	Close()
+	// Close flushes the buffered data before closing the connection, returning the flush error if any.
+	Close() error
 	SendData(lines string) error
+	// SendDataCtx sends the lines like SendData, without blocking past the deadline of ctx. It returns the error of
+	// ctx without sending the lines when ctx is done.
+	SendDataCtx(ctx context.Context, lines string) error
+	// FlushCtx flushes like Flush, without blocking past the deadline of ctx.
+	FlushCtx(ctx context.Context) error
+	// PendingLines returns the number of lines buffered and not yet sent.
+	PendingLines() int
+	// ResetFailureCount returns the failure count and resets it to zero.
+	ResetFailureCount() int64
 
 	Flusher
 }
@@ -29,6 +48,7 @@ const (
 	contentType     = "Content-Type"
 	contentEncoding = "Content-Encoding"
 	authzHeader     = "Authorization"
+	userAgentHeader = "User-Agent"
 	bearer          = "Bearer "
 	gzipFormat      = "gzip"
 
@@ -39,6 +59,8 @@ const (
 	eventEndpoint  = "/api/v2/event"
 
 	formatKey = "f"
+
+	sdkUserAgent = "wavefront-sdk-go/"
 )
 
 const formatError stringError = "error: invalid Format or points"
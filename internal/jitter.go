@@ -0,0 +1,58 @@
+package internal
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var (
+	jitterMtx  sync.Mutex
+	jitterRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// jitterDelay returns a random delay in [0, jitter), 0 when jitter <= 0.
+// The source is seeded at startup so that the instances of a fleet get different delays.
+func jitterDelay(jitter time.Duration) time.Duration {
+	if jitter <= 0 {
+		return 0
+	}
+	jitterMtx.Lock()
+	defer jitterMtx.Unlock()
+	return time.Duration(jitterRand.Int63n(int64(jitter)))
+}
+
+// flushSchedule provides the ticks of the periodic flushes of a handler. With a jitter, the first flush is delayed
+// by a random duration up to the jitter and the periodic flushes start from it, so that the handlers started at
+// the same time across a fleet don't flush in sync.
+type flushSchedule struct {
+	interval time.Duration
+	ticks    <-chan time.Time
+	first    <-chan time.Time
+	ticker   *time.Ticker
+}
+
+func newFlushSchedule(ticker *time.Ticker, interval, jitter time.Duration, after func(time.Duration) <-chan time.Time) *flushSchedule {
+	if jitter <= 0 {
+		return &flushSchedule{ticks: ticker.C}
+	}
+	if after == nil {
+		after = time.After
+	}
+	// the periodic flushes are aligned on the jittered first flush
+	ticker.Stop()
+	return &flushSchedule{interval: interval, first: after(jitterDelay(jitter))}
+}
+
+// firstFlush must be called when the first flush channel fires, starting the periodic flushes.
+func (s *flushSchedule) firstFlush() {
+	s.first = nil
+	s.ticker = time.NewTicker(s.interval)
+	s.ticks = s.ticker.C
+}
+
+func (s *flushSchedule) stop() {
+	if s.ticker != nil {
+		s.ticker.Stop()
+	}
+}
@@ -1,10 +1,11 @@
 package internal
 
 import (
-	"errors"
+	"context"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -27,24 +28,65 @@ type LineHandler struct {
 	failures  int64
 	throttled int64
 
+	// set when the last attempt to report data failed to reach Wavefront
+	unreachable int32
+
 	Reporter      Reporter
 	BatchSize     int
 	MaxBufferSize int
 	Format        string
 	flushTicker   *time.Ticker
+	flushInterval time.Duration
+	flushJitter   time.Duration
+	// timer of the jittered first flush, time.After when nil
+	after func(time.Duration) <-chan time.Time
+	// flushes the handler instead of its own flush loop when set, and removes it from the scheduler
+	scheduler  *FlushScheduler
+	unschedule func()
 
 	internalRegistry *MetricRegistry
 	prefix           string
+	bytesSent        *DeltaCounter
+	bufferDropped    *DeltaCounter
+	reportErrors     *DeltaCounter
+	reportInvalid    *DeltaCounter
+	reportThrottled  *DeltaCounter
+	spilledLines     *DeltaCounter
+	replayedLines    *DeltaCounter
+
+	// what HandleLine does when the buffer is full
+	bufferFull BufferFullPolicy
+	// file the lines not buffered nor reported are spilled to, replayed after the successful reports. may be nil.
+	spool *Spool
+	// serializes the lines buffered by HandleLine and HandleLines, keeping the lines of a batch together.
+	// a channel rather than a mutex, so that waiting for it can be cancelled.
+	enqueueSem chan struct{}
 
 	mtx                sync.Mutex
 	lockOnErrThrottled bool
+	// time before which Flush doesn't report data, as requested by the Retry-After header of a 429 response
+	resumeAt time.Time
+
+	// called after each flush, without holding mtx
+	onFlush func(sent, failed int, err error)
 
 	buffer chan string
 	done   chan struct{}
 }
 
+// BufferFullPolicy controls what HandleLine does when the buffer of the handler is full.
+type BufferFullPolicy int
+
+const (
+	// BufferFullError drops the line and returns an error. This is the default.
+	BufferFullError BufferFullPolicy = iota
+	// BufferFullDrop drops the line without error, counting it by the <prefix>.buffer.dropped internal metric.
+	BufferFullDrop
+	// BufferFullBlock blocks until a flush frees space in the buffer.
+	BufferFullBlock
+)
+
 var throttledSleepDuration = time.Duration(time.Second * 30)
-var errThrottled = errors.New("error: throttled event creation")
 
 type LineHandlerOption func(*LineHandler)
 
@@ -66,14 +108,57 @@ func SetLockOnThrottledError(lock bool) LineHandlerOption {
 	}
 }
 
+// SetHandlerFlushJitter delays the first flush after Start by a random duration up to jitter,
+// the periodic flushes starting from it.
+func SetHandlerFlushJitter(jitter time.Duration) LineHandlerOption {
+	return func(handler *LineHandler) {
+		handler.flushJitter = jitter
+	}
+}
+
+// SetBufferFullPolicy sets what HandleLine does when the buffer is full: return an error (the default),
+// drop the line silently or block until there's space.
+func SetBufferFullPolicy(policy BufferFullPolicy) LineHandlerOption {
+	return func(handler *LineHandler) {
+		handler.bufferFull = policy
+	}
+}
+
+// SetHandlerFlushScheduler sets the scheduler flushing the handler periodically, instead of the flush loop of the
+// handler. The flush interval and jitter of the handler are then ignored.
+func SetHandlerFlushScheduler(scheduler *FlushScheduler) LineHandlerOption {
+	return func(handler *LineHandler) {
+		handler.scheduler = scheduler
+	}
+}
+
+// SetHandlerOnFlush sets a function called after each flush of buffered lines with the number of lines
+// reported and failed (buffered again), and the flush error. It is called without holding the handler lock.
+func SetHandlerOnFlush(f func(sent, failed int, err error)) LineHandlerOption {
+	return func(handler *LineHandler) {
+		handler.onFlush = f
+	}
+}
+
+// SetHandlerSpool sets a spool the lines are spilled to instead of being dropped: those not fitting in the full
+// buffer, unless the buffer full policy blocks, and those still buffered when the handler is stopped. They're
+// replayed a chunk at a time after the successful reports.
+func SetHandlerSpool(spool *Spool) LineHandlerOption {
+	return func(handler *LineHandler) {
+		handler.spool = spool
+	}
+}
+
 func NewLineHandler(reporter Reporter, format string, flushInterval time.Duration, batchSize, maxBufferSize int, setters ...LineHandlerOption) *LineHandler {
 	lh := &LineHandler{
 		Reporter:           reporter,
 		BatchSize:          batchSize,
 		MaxBufferSize:      maxBufferSize,
 		flushTicker:        time.NewTicker(flushInterval),
+		flushInterval:      flushInterval,
 		Format:             format,
 		lockOnErrThrottled: false,
+		enqueueSem:         make(chan struct{}, 1),
 	}
 
 	for _, setter := range setters {
@@ -87,31 +172,40 @@ func NewLineHandler(reporter Reporter, format string, flushInterval time.Duratio
 		lh.internalRegistry.NewGauge(lh.prefix+".queue.remaining_capacity", func() int64 {
 			return int64(lh.MaxBufferSize - len(lh.buffer))
 		})
+		lh.bytesSent = lh.internalRegistry.NewDeltaCounter(lh.prefix + ".bytes")
+		lh.bufferDropped = lh.internalRegistry.NewDeltaCounter(lh.prefix + ".buffer.dropped")
+		lh.reportErrors = lh.internalRegistry.NewDeltaCounter(lh.prefix + ".report.errors")
+		lh.reportInvalid = lh.internalRegistry.NewDeltaCounter(lh.prefix + ".report.invalid")
+		lh.reportThrottled = lh.internalRegistry.NewDeltaCounter(lh.prefix + ".report.throttled")
+		if lh.spool != nil {
+			lh.spilledLines = lh.internalRegistry.NewDeltaCounter(lh.prefix + ".spool.spilled")
+			lh.replayedLines = lh.internalRegistry.NewDeltaCounter(lh.prefix + ".spool.replayed")
+		}
 	}
 	return lh
 }
 
 func (lh *LineHandler) Start() {
 	lh.buffer = make(chan string, lh.MaxBufferSize)
+	if lh.scheduler != nil {
+		lh.flushTicker.Stop()
+		lh.unschedule = lh.scheduler.register(lh.periodicFlush)
+		return
+	}
 	lh.done = make(chan struct{})
+	schedule := newFlushSchedule(lh.flushTicker, lh.flushInterval, lh.flushJitter, lh.after)
 
 	go func() {
+		defer schedule.stop()
 		for {
 			select {
-			case <-lh.flushTicker.C:
-				err := lh.Flush()
-				if err != nil {
-					log.Println(lh.lockOnErrThrottled, "---", err)
-					if err == errThrottled && lh.lockOnErrThrottled {
-						go func() {
-							lh.mtx.Lock()
-							atomic.AddInt64(&lh.throttled, 1)
-							log.Printf("sleeping for %v, buffer size: %d\n", throttledSleepDuration, len(lh.buffer))
-							time.Sleep(throttledSleepDuration)
-							lh.mtx.Unlock()
-						}()
-					}
+			case <-schedule.first:
+				schedule.firstFlush()
+				if err := lh.Flush(); err != nil {
+					log.Println(err)
 				}
+			case <-schedule.ticks:
+				lh.periodicFlush()
 			case <-lh.done:
 				return
 			}
@@ -119,10 +213,124 @@ func (lh *LineHandler) Start() {
 	}()
 }
 
+// periodicFlush flushes the handler on a tick of its flush loop or scheduler.
+func (lh *LineHandler) periodicFlush() {
+	err := lh.Flush()
+	if err != nil {
+		log.Println(lh.lockOnErrThrottled, "---", err)
+		if reportErr, ok := err.(*ReportError); ok && reportErr.StatusCode == http.StatusNotAcceptable && lh.lockOnErrThrottled {
+			go func() {
+				lh.mtx.Lock()
+				atomic.AddInt64(&lh.throttled, 1)
+				log.Printf("sleeping for %v, buffer size: %d\n", throttledSleepDuration, len(lh.buffer))
+				time.Sleep(throttledSleepDuration)
+				lh.mtx.Unlock()
+			}()
+		}
+	}
+}
+
 func (lh *LineHandler) HandleLine(line string) error {
+	return lh.HandleLineCtx(context.Background(), line)
+}
+
+// HandleLineCtx buffers the line like HandleLine, the wait for space in the buffer when the buffer full policy blocks
+// being cancelled when ctx is done, returning its error.
+func (lh *LineHandler) HandleLineCtx(ctx context.Context, line string) error {
+	if err := lh.lockEnqueue(ctx); err != nil {
+		return err
+	}
+	defer lh.unlockEnqueue()
+	return lh.enqueue(ctx, line)
+}
+
+// HandleLines buffers a batch of lines one after the other, without lines of other calls in between. Unless the
+// buffer full policy blocks, a batch not fitting in the free space of the buffer is handled as a whole by the
+// policy: spilled, dropped or rejected with an error.
+func (lh *LineHandler) HandleLines(lines []string) error {
+	return lh.HandleLinesCtx(context.Background(), lines)
+}
+
+// HandleLinesCtx buffers the batch like HandleLines, the wait for space in the buffer when the buffer full policy
+// blocks being cancelled when ctx is done, returning its error. The lines buffered before are kept.
+func (lh *LineHandler) HandleLinesCtx(ctx context.Context, lines []string) error {
+	if err := lh.lockEnqueue(ctx); err != nil {
+		return err
+	}
+	defer lh.unlockEnqueue()
+
+	if lh.bufferFull != BufferFullBlock && cap(lh.buffer)-len(lh.buffer) < len(lines) {
+		switch {
+		case lh.spool != nil:
+			if dropped := lh.spill(strings.Join(lines, "")); dropped > 0 {
+				atomic.AddInt64(&lh.failures, 1)
+				return fmt.Errorf("buffer and spool full, dropping %d of %d lines", dropped, len(lines))
+			}
+			return nil
+		case lh.bufferFull == BufferFullDrop:
+			lh.bufferDropped.Add(int64(len(lines)))
+			return nil
+		default:
+			atomic.AddInt64(&lh.failures, 1)
+			return fmt.Errorf("buffer full, dropping %d lines", len(lines))
+		}
+	}
+	for _, line := range lines {
+		if err := lh.enqueue(ctx, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lockEnqueue waits for the other calls buffering lines, returning the error of ctx when it's done, before or while waiting.
+func (lh *LineHandler) lockEnqueue(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	select {
+	case lh.enqueueSem <- struct{}{}:
+		return nil
+	default:
+	}
+	select {
+	case lh.enqueueSem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (lh *LineHandler) unlockEnqueue() {
+	<-lh.enqueueSem
+}
+
+// enqueue buffers a line, applying the buffer full policy when there's no space.
+func (lh *LineHandler) enqueue(ctx context.Context, line string) error {
 	select {
 	case lh.buffer <- line:
 		return nil
+	default:
+	}
+
+	switch {
+	case lh.bufferFull == BufferFullBlock:
+		select {
+		case lh.buffer <- line:
+			return nil
+		case <-ctx.Done():
+			atomic.AddInt64(&lh.failures, 1)
+			return ctx.Err()
+		}
+	case lh.spool != nil:
+		if lh.spill(line) > 0 {
+			atomic.AddInt64(&lh.failures, 1)
+			return fmt.Errorf("buffer and spool full, dropping line: %s", line)
+		}
+		return nil
+	case lh.bufferFull == BufferFullDrop:
+		lh.bufferDropped.Inc()
+		return nil
 	default:
 		atomic.AddInt64(&lh.failures, 1)
 		return fmt.Errorf("buffer full, dropping line: %s", line)
@@ -130,8 +338,27 @@ func (lh *LineHandler) HandleLine(line string) error {
 }
 
 func (lh *LineHandler) Flush() error {
+	return lh.FlushCtx(context.Background())
+}
+
+// FlushCtx flushes like Flush, the report being cancelled when ctx is done, its lines being buffered again.
+// It returns the error of ctx without flushing when ctx is done.
+func (lh *LineHandler) FlushCtx(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	sent, failed, err := lh.flush(ctx)
+	lh.flushed(sent, failed, err)
+	return err
+}
+
+// flush reports a batch of the buffered lines, returning the number of lines reported and failed.
+func (lh *LineHandler) flush(ctx context.Context) (int, int, error) {
 	lh.mtx.Lock()
 	defer lh.mtx.Unlock()
+	if time.Now().Before(lh.resumeAt) {
+		return 0, 0, nil
+	}
 	bufLen := len(lh.buffer)
 	if bufLen > 0 {
 		size := min(bufLen, lh.BatchSize)
@@ -139,14 +366,28 @@ func (lh *LineHandler) Flush() error {
 		for i := 0; i < size; i++ {
 			lines[i] = <-lh.buffer
 		}
-		return lh.report(lines)
+		if err := lh.report(ctx, lines); err != nil {
+			return 0, size, err
+		}
+		lh.replay(ctx)
+		return size, 0, nil
 	}
-	return nil
+	lh.replay(ctx)
+	return 0, 0, nil
 }
 
 func (lh *LineHandler) FlushAll() error {
+	sent, failed, err := lh.flushAll()
+	lh.flushed(sent, failed, err)
+	return err
+}
+
+// flushAll reports all the buffered lines in batches, returning the number of lines reported and failed.
+// It stops at the first failed batch.
+func (lh *LineHandler) flushAll() (int, int, error) {
 	lh.mtx.Lock()
 	defer lh.mtx.Unlock()
+	sent := 0
 	bufLen := len(lh.buffer)
 	if bufLen > 0 {
 		var imod int
@@ -156,67 +397,195 @@ func (lh *LineHandler) FlushAll() error {
 			imod = i % size
 			lines[imod] = <-lh.buffer
 			if imod == size-1 { // report batch
-				if err := lh.report(lines); err != nil {
-					return err
+				if err := lh.report(context.Background(), lines); err != nil {
+					return sent, size, err
 				}
+				sent += size
 			}
 		}
 		if imod < size-1 { // report remaining
-			return lh.report(lines[0 : imod+1])
+			if err := lh.report(context.Background(), lines[0:imod+1]); err != nil {
+				return sent, imod + 1, err
+			}
+			sent += imod + 1
 		}
 	}
-	return nil
+	return sent, 0, nil
+}
+
+// flushed invokes the flush callback when lines were flushed, it must be called without holding mtx.
+func (lh *LineHandler) flushed(sent, failed int, err error) {
+	if lh.onFlush != nil && (sent > 0 || failed > 0) {
+		lh.onFlush(sent, failed, err)
+	}
+}
+
+func (lh *LineHandler) report(ctx context.Context, lines []string) error {
+	retry, err := lh.post(ctx, lines)
+	if err != nil && retry {
+		lh.bufferLines(lines)
+	}
+	return err
 }
 
-func (lh *LineHandler) report(lines []string) error {
+// post sends the lines to Wavefront, returning whether they can be sent again when it fails.
+func (lh *LineHandler) post(ctx context.Context, lines []string) (bool, error) {
 	strLines := strings.Join(lines, "")
 	var resp *http.Response
 	var err error
 
-	if lh.Format == EventFormat {
+	if reporter, ok := lh.Reporter.(ContextReporter); ok {
+		if lh.Format == EventFormat {
+			resp, err = reporter.ReportEventCtx(ctx, strLines)
+		} else {
+			resp, err = reporter.ReportCtx(ctx, lh.Format, strLines)
+		}
+	} else if lh.Format == EventFormat {
 		resp, err = lh.Reporter.ReportEvent(strLines)
 	} else {
 		resp, err = lh.Reporter.Report(lh.Format, strLines)
 	}
 
 	if err != nil {
-		lh.bufferLines(lines)
-		return fmt.Errorf("error reporting %s format data to Wavefront: %q", lh.Format, err)
+		atomic.StoreInt32(&lh.unreachable, 1)
+		lh.reportErrors.Inc()
+		return true, &ReportError{Format: lh.Format, Err: err}
 	}
+	atomic.StoreInt32(&lh.unreachable, 0)
 
+	if resp.StatusCode == http.StatusTooManyRequests {
+		// throttled rather than failed, the lines being reported again once the delay requested has elapsed
+		atomic.AddInt64(&lh.throttled, 1)
+		lh.reportThrottled.Inc()
+		reportErr := &ReportError{Format: lh.Format, StatusCode: resp.StatusCode,
+			RetryAfter: retryAfter(resp.Header.Get("Retry-After"), time.Now())}
+		lh.resumeAt = time.Now().Add(reportErr.RetryAfter)
+		return true, reportErr
+	}
 	if 400 <= resp.StatusCode && resp.StatusCode <= 599 {
 		atomic.AddInt64(&lh.failures, 1)
-		lh.bufferLines(lines)
-		if resp.StatusCode == 406 {
-			return errThrottled
+		reportErr := &ReportError{Format: lh.Format, StatusCode: resp.StatusCode}
+		if !reportErr.Retryable() {
+			// the data is rejected, sending it again would fail the same way
+			lh.reportInvalid.Add(int64(len(lines)))
+			return false, reportErr
 		}
-		return fmt.Errorf("error reporting %s format data to Wavefront. status=%d", lh.Format, resp.StatusCode)
+		lh.reportErrors.Inc()
+		return true, reportErr
+	}
+	if lh.bytesSent != nil {
+		lh.bytesSent.Add(int64(len(strLines)))
+	}
+	return false, nil
+}
+
+// retryAfter returns the delay requested by the value of a Retry-After header, either in seconds or
+// an HTTP date, 0 when the value is missing or invalid.
+func retryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
+
+// spill writes the lines to the spool, counting those that couldn't be spilled as dropped, and returns their number.
+func (lh *LineHandler) spill(lines string) (dropped int) {
+	dropped, err := lh.spool.Spill([]byte(lines))
+	if err != nil {
+		log.Println(err)
+	}
+	lh.spilledLines.Add(int64(strings.Count(lines, "\n") - dropped))
+	lh.bufferDropped.Add(int64(dropped))
+	return dropped
+}
+
+// replay reports a chunk of the spooled lines. The chunk is removed from the spool once reported, or rejected as
+// invalid, and stays spooled otherwise. A chunk is replayed on each flush not failing to report buffered lines.
+func (lh *LineHandler) replay(ctx context.Context) {
+	if lh.spool == nil {
+		return
+	}
+	chunk, err := lh.spool.Peek(spoolChunkBytes)
+	if err != nil {
+		log.Println(err)
+	}
+	if len(chunk) == 0 {
+		return
+	}
+	lines := strings.SplitAfter(string(chunk), "\n")
+	lines = lines[:len(lines)-1]
+	if retry, err := lh.post(ctx, lines); err != nil {
+		log.Println(err)
+		if retry {
+			return
+		}
+	} else {
+		lh.replayedLines.Add(int64(len(lines)))
+	}
+	if err := lh.spool.Advance(len(chunk)); err != nil {
+		log.Println(err)
 	}
-	return nil
 }
 
 func (lh *LineHandler) bufferLines(batch []string) {
 	log.Println("error reporting to Wavefront. buffering lines.")
 	for _, line := range batch {
-		lh.HandleLine(line)
+		lh.enqueue(context.Background(), line)
 	}
 }
 
+// PendingLines returns the number of buffered lines waiting to be reported.
+func (lh *LineHandler) PendingLines() int {
+	return len(lh.buffer)
+}
+
+// Connected returns false when the last attempt to report data did not reach Wavefront.
+func (lh *LineHandler) Connected() bool {
+	return atomic.LoadInt32(&lh.unreachable) == 0
+}
+
 func (lh *LineHandler) GetFailureCount() int64 {
 	return atomic.LoadInt64(&lh.failures)
 }
 
+// ResetFailureCount returns the failure count and resets it to zero.
+func (lh *LineHandler) ResetFailureCount() int64 {
+	return atomic.SwapInt64(&lh.failures, 0)
+}
+
 // GetThrottledCount returns the number of Throttled errors received.
 func (lh *LineHandler) GetThrottledCount() int64 {
 	return atomic.LoadInt64(&lh.throttled)
 }
 
-func (lh *LineHandler) Stop() {
+// Stop stops the flush loop and flushes all the buffered lines, returning the flush error if any.
+func (lh *LineHandler) Stop() error {
 	lh.flushTicker.Stop()
-	lh.done <- struct{}{} // block until goroutine exits
-	if err := lh.FlushAll(); err != nil {
-		log.Println(err)
+	if lh.unschedule != nil {
+		lh.unschedule()
+		lh.unschedule = nil
+	} else {
+		lh.done <- struct{}{} // block until goroutine exits
+	}
+	err := lh.FlushAll()
+	if lh.spool != nil {
+		// the lines buffered again by a failed flush
+		var lines strings.Builder
+		for len(lh.buffer) > 0 {
+			lines.WriteString(<-lh.buffer)
+		}
+		lh.spill(lines.String())
 	}
 	lh.done = nil
 	lh.buffer = nil
+	return err
 }
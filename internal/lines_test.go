@@ -1,16 +1,23 @@
 package internal
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type fakeReporter struct {
 	raiseError bool
 	errorCode  int
+	reported   []string
 }
 
 func (reporter *fakeReporter) Report(format string, pointLines string) (*http.Response, error) {
@@ -20,6 +27,7 @@ func (reporter *fakeReporter) Report(format string, pointLines string) (*http.Re
 	if reporter.errorCode != 0 {
 		return &http.Response{StatusCode: reporter.errorCode}, nil
 	}
+	reporter.reported = append(reporter.reported, pointLines)
 	return &http.Response{StatusCode: 200}, nil
 }
 
@@ -75,6 +83,42 @@ func TestFlush(t *testing.T) {
 	assert.Equal(t, 0, len(lh.buffer), "error flushing lines")
 }
 
+func TestFlushStatuses(t *testing.T) {
+	registry := NewMetricRegistry(nil)
+	lh := NewLineHandler(&fakeReporter{errorCode: 400}, MetricFormat, time.Hour, 10, 100,
+		SetHandlerPrefix("points"), SetRegistry(registry))
+	lh.buffer = make(chan string, 100)
+
+	addLines(lh, 5, 5, t)
+	err := lh.Flush()
+	assert.EqualError(t, err, "error reporting wavefront format data to Wavefront. status=400")
+	assert.False(t, err.(*ReportError).Retryable())
+	assert.Equal(t, 0, len(lh.buffer), "rejected lines are dropped")
+	assert.Equal(t, int64(5), lh.reportInvalid.Count())
+
+	lh.Reporter = &fakeReporter{errorCode: 503}
+	addLines(lh, 5, 5, t)
+	err = lh.Flush()
+	assert.True(t, err.(*ReportError).Retryable())
+	assert.Equal(t, 5, len(lh.buffer), "lines are buffered again")
+	assert.Equal(t, int64(1), lh.reportErrors.Count())
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2019, 3, 10, 1, 59, 30, 0, time.UTC)
+	tests := map[string]time.Duration{
+		"":                              0,
+		"120":                           2 * time.Minute,
+		"-1":                            0,
+		"Sun, 10 Mar 2019 02:00:00 GMT": 30 * time.Second,
+		"Sun, 10 Mar 2019 01:00:00 GMT": 0,
+		"soon":                          0,
+	}
+	for value, expected := range tests {
+		assert.Equal(t, expected, retryAfter(value, now), value)
+	}
+}
+
 func checkLength(buffer chan string, length int, msg string, t *testing.T) {
 	if len(buffer) != length {
 		t.Errorf("%s. expected: %d actual: %d", msg, length, len(buffer))
@@ -107,5 +151,268 @@ func makeLineHandler(bufSize, batchSize int) *LineHandler {
 		MaxBufferSize: bufSize,
 		BatchSize:     batchSize,
 		buffer:        make(chan string, bufSize),
+		enqueueSem:    make(chan struct{}, 1),
+	}
+}
+
+func TestConnected(t *testing.T) {
+	lh := makeLineHandler(100, 10) // cap: 100, batchSize: 10
+	assert.True(t, lh.Connected())
+
+	lh.Reporter = &fakeReporter{raiseError: true}
+	addLines(lh, 5, 5, t)
+	lh.Flush()
+	assert.False(t, lh.Connected())
+
+	lh.Reporter = &fakeReporter{}
+	lh.Flush()
+	assert.True(t, lh.Connected())
+}
+
+func TestBytesSent(t *testing.T) {
+	registry := NewMetricRegistry(nil)
+	lh := NewLineHandler(&fakeReporter{}, MetricFormat, time.Hour, 10, 100, SetRegistry(registry), SetHandlerPrefix("points"))
+	lh.buffer = make(chan string, 100)
+	bytesSent := registry.NewDeltaCounter("points.bytes")
+
+	addLines(lh, 5, 5, t)
+	lh.Reporter = &fakeReporter{raiseError: true}
+	lh.Flush()
+	assert.Equal(t, int64(0), bytesSent.Count())
+
+	lh.Reporter = &fakeReporter{}
+	lh.Flush()
+	assert.Equal(t, int64(5*len("dummyLine")), bytesSent.Count())
+}
+
+func TestPendingLines(t *testing.T) {
+	lh := makeLineHandler(100, 10) // cap: 100, batchSize: 10
+	assert.Equal(t, 0, lh.PendingLines())
+
+	addLines(lh, 15, 15, t)
+	assert.Equal(t, 15, lh.PendingLines())
+
+	lh.Flush()
+	assert.Equal(t, 5, lh.PendingLines())
+
+	lh.Reporter = &fakeReporter{raiseError: true}
+	lh.FlushAll()
+	assert.Equal(t, 5, lh.PendingLines(), "failed lines are buffered again")
+}
+
+func TestResetFailureCount(t *testing.T) {
+	lh := makeLineHandler(1, 10) // cap: 1, batchSize: 10
+	addLines(lh, 1, 1, t)
+
+	// each line added to the full buffer is a failure, none is lost by the concurrent resets
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 1000; i++ {
+			lh.HandleLine("dummyLine")
+		}
+		close(done)
+	}()
+
+	var total int64
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		total += lh.ResetFailureCount()
 	}
+	assert.Equal(t, int64(1000), total)
+	assert.Equal(t, int64(0), lh.GetFailureCount())
+}
+
+func TestFlushJitter(t *testing.T) {
+	lh := NewLineHandler(&fakeReporter{}, "wavefront", 10*time.Millisecond, 10, 100, SetHandlerFlushJitter(time.Minute))
+	var delay time.Duration
+	firstFlush := make(chan time.Time)
+	lh.after = func(d time.Duration) <-chan time.Time {
+		delay = d
+		return firstFlush
+	}
+	lh.Start()
+	assert.True(t, delay >= 0 && delay < time.Minute, "delay %v out of the jitter window", delay)
+
+	// the periodic flushes wait for the jittered first flush
+	assert.NoError(t, lh.HandleLine("dummyLine"))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 1, lh.PendingLines())
+
+	firstFlush <- time.Now()
+	assert.Eventually(t, func() bool { return lh.PendingLines() == 0 }, time.Second, time.Millisecond)
+
+	// then flush periodically
+	assert.NoError(t, lh.HandleLine("dummyLine"))
+	assert.Eventually(t, func() bool { return lh.PendingLines() == 0 }, time.Second, time.Millisecond)
+	assert.NoError(t, lh.Stop())
+}
+
+func TestJitterDelay(t *testing.T) {
+	assert.Equal(t, time.Duration(0), jitterDelay(0))
+	for i := 0; i < 1000; i++ {
+		delay := jitterDelay(time.Second)
+		assert.True(t, delay >= 0 && delay < time.Second, "delay %v out of the jitter window", delay)
+	}
+}
+
+func TestLineHandlerOnFlush(t *testing.T) {
+	type flush struct {
+		sent, failed int
+		err          bool
+	}
+	var flushes []flush
+	var lh *LineHandler
+	reporter := &fakeReporter{}
+	lh = NewLineHandler(reporter, "wavefront", time.Hour, 2, 100, SetHandlerOnFlush(func(sent, failed int, err error) {
+		// the handler lock is released
+		lh.mtx.Lock()
+		lh.mtx.Unlock()
+		flushes = append(flushes, flush{sent, failed, err != nil})
+	}))
+	lh.Start()
+
+	assert.NoError(t, lh.Flush())
+	assert.Empty(t, flushes, "empty flushes are not reported")
+
+	addLines(lh, 3, 3, t)
+	assert.NoError(t, lh.Flush())
+	assert.NoError(t, lh.FlushAll())
+	reporter.raiseError = true
+	addLines(lh, 1, 1, t)
+	assert.Error(t, lh.FlushAll())
+	reporter.raiseError = false
+
+	assert.Equal(t, []flush{{2, 0, false}, {1, 0, false}, {0, 1, true}}, flushes)
+	assert.NoError(t, lh.Stop())
+}
+
+func TestBufferFullPolicies(t *testing.T) {
+	lh := makeLineHandler(1, 1)
+	require.NoError(t, lh.HandleLine("first"))
+	assert.Error(t, lh.HandleLine("second"), "errors by default")
+	assert.Equal(t, int64(1), lh.GetFailureCount())
+
+	lh = makeLineHandler(1, 1)
+	lh.bufferFull = BufferFullDrop
+	lh.bufferDropped = NewMetricRegistry(nil).NewDeltaCounter("points.buffer.dropped")
+	require.NoError(t, lh.HandleLine("first"))
+	assert.NoError(t, lh.HandleLine("second"))
+	assert.Equal(t, int64(1), lh.bufferDropped.Count())
+	assert.Equal(t, int64(0), lh.GetFailureCount())
+	assert.Equal(t, "first", <-lh.buffer, "the newest line is dropped")
+
+	lh = makeLineHandler(1, 1)
+	lh.bufferFull = BufferFullBlock
+	require.NoError(t, lh.HandleLine("first"))
+	sent := make(chan error)
+	go func() {
+		sent <- lh.HandleLine("second")
+	}()
+	select {
+	case <-sent:
+		t.Fatal("the line was handled while the buffer is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+	require.NoError(t, lh.Flush())
+	assert.NoError(t, <-sent, "sent once the flush freed space")
+	assert.Equal(t, "second", <-lh.buffer)
+}
+
+func TestHandleLineCtx(t *testing.T) {
+	lh := makeLineHandler(1, 1)
+	lh.bufferFull = BufferFullBlock
+	require.NoError(t, lh.HandleLine("first"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := lh.HandleLineCtx(ctx, "second")
+	assert.Equal(t, context.DeadlineExceeded, err, "the wait for room stops with the context")
+	assert.Equal(t, int64(1), lh.GetFailureCount())
+	assert.Equal(t, 1, lh.PendingLines())
+	assert.Equal(t, "first", <-lh.buffer)
+
+	assert.Equal(t, context.DeadlineExceeded, lh.HandleLinesCtx(ctx, []string{"third"}))
+	assert.Equal(t, 0, lh.PendingLines(), "nothing is handled once the context is done")
+}
+
+func TestHandleLines(t *testing.T) {
+	lh := makeLineHandler(3, 3)
+	require.NoError(t, lh.HandleLine("first\n"))
+	require.NoError(t, lh.HandleLines([]string{"second\n", "third\n"}))
+	assert.EqualError(t, lh.HandleLines([]string{"fourth\n", "fifth\n"}), "buffer full, dropping 2 lines",
+		"a batch not fitting is not split")
+	assert.Equal(t, int64(1), lh.GetFailureCount())
+	assert.Equal(t, 3, lh.PendingLines())
+
+	lh = makeLineHandler(1, 1)
+	lh.bufferFull = BufferFullDrop
+	lh.bufferDropped = NewMetricRegistry(nil).NewDeltaCounter("points.buffer.dropped")
+	assert.NoError(t, lh.HandleLines([]string{"first\n", "second\n"}))
+	assert.Equal(t, int64(2), lh.bufferDropped.Count())
+	assert.Equal(t, 0, lh.PendingLines())
+}
+
+func TestLineHandlerSpool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wavefront-spool")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	registry := NewMetricRegistry(nil)
+	lh := NewLineHandler(&fakeReporter{raiseError: true}, MetricFormat, time.Hour, 10, 2, SetRegistry(registry),
+		SetHandlerPrefix("points"), SetHandlerSpool(NewSpool(dir, "points", 1024)))
+	lh.Start()
+
+	// the lines not fitting in the buffer are spilled, and those still buffered when stopped
+	require.NoError(t, lh.HandleLine("first\n"))
+	require.NoError(t, lh.HandleLine("second\n"))
+	require.NoError(t, lh.HandleLine("third\n"))
+	require.Error(t, lh.Stop())
+	assert.Equal(t, int64(3), registry.NewDeltaCounter("points.spool.spilled").Count())
+	assert.Equal(t, int64(0), registry.NewDeltaCounter("points.buffer.dropped").Count())
+
+	// the next process replays them after a successful report
+	reporter := &fakeReporter{}
+	lh = NewLineHandler(reporter, MetricFormat, time.Hour, 10, 10, SetHandlerSpool(NewSpool(dir, "points", 1024)))
+	lh.Start()
+	require.NoError(t, lh.Flush())
+	assert.Equal(t, []string{"third\nfirst\nsecond\n"}, reporter.reported, "replayed in a chunk")
+	assert.Equal(t, 0, lh.PendingLines())
+	require.NoError(t, lh.Stop())
+	_, err = os.Stat(filepath.Join(dir, "points.spool"))
+	assert.True(t, os.IsNotExist(err), "the spool is removed once replayed")
+}
+
+func TestLineHandlerSpoolChunks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wavefront-spool")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	defer func(chunkBytes int) {
+		spoolChunkBytes = chunkBytes
+	}(spoolChunkBytes)
+	spoolChunkBytes = 12
+
+	spool := NewSpool(dir, "points", 1024)
+	_, err = spool.Spill([]byte("first\nsecond\nthird\n"))
+	require.NoError(t, err)
+
+	// a chunk is replayed on each flush, and stays spooled when the report fails
+	reporter := &fakeReporter{}
+	lh := NewLineHandler(reporter, MetricFormat, time.Hour, 10, 10, SetHandlerSpool(spool))
+	lh.Start()
+	require.NoError(t, lh.Flush())
+	assert.Equal(t, []string{"first\n"}, reporter.reported)
+	reporter.raiseError = true
+	require.NoError(t, lh.Flush())
+	reporter.raiseError = false
+	require.NoError(t, lh.Flush())
+	require.NoError(t, lh.Flush())
+	assert.Equal(t, []string{"first\n", "second\n", "third\n"}, reporter.reported)
+	assert.Equal(t, 0, lh.PendingLines(), "the replayed lines aren't buffered")
+	require.NoError(t, lh.Stop())
+	_, err = os.Stat(filepath.Join(dir, "points.spool"))
+	assert.True(t, os.IsNotExist(err), "the spool is removed once replayed")
 }
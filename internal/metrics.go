@@ -3,14 +3,26 @@ package internal
 import "sync/atomic"
 
 // counter for internal metrics
+// the methods of a nil counter, created by a disabled registry, do nothing.
 type MetricCounter struct {
 	value int64
 }
 
 func (c *MetricCounter) Inc() {
+	if c == nil {
+		return
+	}
 	atomic.AddInt64(&c.value, 1)
 }
 
+// Add increments the counter by n.
+func (c *MetricCounter) Add(n int64) {
+	if c == nil {
+		return
+	}
+	atomic.AddInt64(&c.value, n)
+}
+
 func (c *MetricCounter) dec(n int64) {
 	atomic.AddInt64(&c.value, -n)
 }
@@ -19,10 +31,42 @@ func (c *MetricCounter) count() int64 {
 	return atomic.LoadInt64(&c.value)
 }
 
+// Count returns the current value of the counter.
+func (c *MetricCounter) Count() int64 {
+	if c == nil {
+		return 0
+	}
+	return c.count()
+}
+
 type DeltaCounter struct {
 	MetricCounter
 }
 
+// Inc increments the counter. Unlike the methods promoted from MetricCounter, the methods of DeltaCounter accept a nil counter.
+func (c *DeltaCounter) Inc() {
+	if c == nil {
+		return
+	}
+	c.MetricCounter.Inc()
+}
+
+// Add increments the counter by n.
+func (c *DeltaCounter) Add(n int64) {
+	if c == nil {
+		return
+	}
+	c.MetricCounter.Add(n)
+}
+
+// Count returns the current value of the counter.
+func (c *DeltaCounter) Count() int64 {
+	if c == nil {
+		return 0
+	}
+	return c.MetricCounter.Count()
+}
+
 // functional gauge for internal metrics
 type FunctionalGauge struct {
 	value func() int64
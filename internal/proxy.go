@@ -2,51 +2,302 @@ package internal
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
 	"fmt"
 	"log"
 	"net"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 )
 
+const (
+	// defaultWriteTimeout bounds the writes to the proxy when no write timeout is set.
+	defaultWriteTimeout = 10 * time.Second
+	// dialTimeout bounds the connections to the proxy, including their TLS handshake.
+	dialTimeout = 10 * time.Second
+)
+
 type ProxyConnectionHandler struct {
 	// keep this as first element of struct to guarantee 64-bit alignment ton 32-bit machines.
 	// atomic.* functions crash if the operand is not 64-bit aligned.
 	// See https://github.com/golang/go/issues/599
-	failures         int64
-	address          string
-	flushTicker      *time.Ticker
+	failures      int64
+	address       string
+	flushTicker   *time.Ticker
+	flushInterval time.Duration
+	flushJitter   time.Duration
+	// timer of the jittered first flush, time.After when nil
+	after func(time.Duration) <-chan time.Time
+	// flushes the handler instead of its own flush loop when set, and removes it from the scheduler
+	scheduler        *FlushScheduler
+	unschedule       func()
 	done             chan struct{}
 	mtx              sync.RWMutex
 	conn             net.Conn
 	writer           *bufio.Writer
+	pending          int // lines written to writer and not flushed yet
 	internalRegistry *MetricRegistry
+	greeting         string
+
+	// lines written after which the buffer is flushed without waiting for the flush ticker, 0 to only flush on ticks
+	flushBatchSize int
+
+	// TCP keep-alive period of the connections, 0 for the Go default
+	keepAlive time.Duration
+
+	// deadline of each write to the proxy, a negative value for no deadline
+	writeTimeout time.Duration
+	// dials the proxy, overridden in tests
+	dial func(network, address string) (net.Conn, error)
+	// TLS configuration of the connections, nil for plaintext
+	tlsConfig *tls.Config
+
+	// when set, the lines written back by the proxy are read, the error responses being counted (and logged)
+	readResponses     bool
+	logResponseErrors bool
+
+	// heartbeat line written when nothing was written for heartbeatInterval
+	heartbeat         string
+	heartbeatInterval time.Duration
+	heartbeatTicker   *time.Ticker
+	lastWrite         time.Time
+
+	// connection lifecycle callbacks, invoked without holding mtx
+	onConnect       func()
+	onDisconnect    func(err error)
+	onConnectFailed func(err error)
+	onFlush         func(sent, failed int, err error)
+
+	// set once a first connection was established, the next ones being reconnections
+	everConnected bool
+
+	// what is done with the lines of a failed flush, and how many of them are kept when re-queued
+	flushFailure     FlushFailurePolicy
+	maxRequeuedLines int
+	// lines written since the last successful flush, only kept to be re-queued
+	unflushed []byte
+	// lines of the failed flushes, written first on the next connection
+	requeued []byte
+	// file the undelivered lines are spilled to instead of being dropped, replayed after the successful flushes. may be nil.
+	spool *Spool
 
 	writeSuccesses *DeltaCounter
 	writeErrors    *DeltaCounter
+	bytesSent      *DeltaCounter
+	reconnects     *DeltaCounter
+	reportErrors   *DeltaCounter
+	droppedLines   *DeltaCounter
+	requeuedLines  *DeltaCounter
+	spilledLines   *DeltaCounter
+	replayedLines  *DeltaCounter
+}
+
+// FlushFailurePolicy controls what the proxy connection handler does with the lines written since the last successful
+// flush when a flush (or write) fails and the connection is reset.
+type FlushFailurePolicy int
+
+const (
+	// FlushFailureDrop discards the lines, counting them by the <prefix>.flush.dropped internal metric.
+	// Lines are sent at most once. This is the default.
+	FlushFailureDrop FlushFailurePolicy = iota
+	// FlushFailureRequeue keeps the lines and writes them first once reconnected, counting them by the
+	// <prefix>.flush.requeued internal metric. Lines are sent at least once, as some of them may have reached the
+	// proxy before the failure. The oldest lines beyond the max re-queued lines are dropped.
+	FlushFailureRequeue
+)
+
+type ProxyConnectionHandlerOption func(*ProxyConnectionHandler)
+
+// SetGreeting sets a line written to the proxy each time a connection is established,
+// e.g. to let the proxy attribute the traffic to a client version.
+func SetGreeting(greeting string) ProxyConnectionHandlerOption {
+	return func(handler *ProxyConnectionHandler) {
+		handler.greeting = greeting
+	}
+}
+
+// SetFlushBatchSize sets the number of buffered lines from which the data is flushed to the proxy right away,
+// bounding the latency and memory of bursts independently of the flush interval.
+func SetFlushBatchSize(size int) ProxyConnectionHandlerOption {
+	return func(handler *ProxyConnectionHandler) {
+		handler.flushBatchSize = size
+	}
+}
+
+// SetFlushJitter delays the first flush after Start by a random duration up to jitter,
+// the periodic flushes starting from it.
+func SetFlushJitter(jitter time.Duration) ProxyConnectionHandlerOption {
+	return func(handler *ProxyConnectionHandler) {
+		handler.flushJitter = jitter
+	}
+}
+
+// SetKeepAlive sets the TCP keep-alive period of the connections to the proxy, letting the OS detect
+// connections silently dropped by intermediaries. 0 uses the Go default, a negative value disables keep-alives.
+func SetKeepAlive(keepAlive time.Duration) ProxyConnectionHandlerOption {
+	return func(handler *ProxyConnectionHandler) {
+		handler.keepAlive = keepAlive
+	}
+}
+
+// SetWriteTimeout sets the deadline of each write (and flush) to the proxy, so that a write blocked on a
+// half-open connection fails and resets the connection instead of blocking the sender. A negative value
+// disables the deadline.
+func SetWriteTimeout(timeout time.Duration) ProxyConnectionHandlerOption {
+	return func(handler *ProxyConnectionHandler) {
+		handler.writeTimeout = timeout
+	}
 }
 
-func NewProxyConnectionHandler(address string, flushInterval time.Duration, prefix string, internalRegistry *MetricRegistry) ConnectionHandler {
+// SetReadResponses reads the lines written back by the proxy on each connection, in a separate goroutine
+// not blocking the writes. The error responses are counted as report errors, and logged when logErrors is set.
+// Proxies not responding are unaffected.
+func SetReadResponses(logErrors bool) ProxyConnectionHandlerOption {
+	return func(handler *ProxyConnectionHandler) {
+		handler.readResponses = true
+		handler.logResponseErrors = logErrors
+	}
+}
+
+// SetHeartbeat sets a line written to the proxy when nothing was written for the given interval,
+// keeping idle connections open and detecting the broken ones before the next data is sent.
+func SetHeartbeat(heartbeat string, interval time.Duration) ProxyConnectionHandlerOption {
+	return func(handler *ProxyConnectionHandler) {
+		handler.heartbeat = heartbeat
+		handler.heartbeatInterval = interval
+	}
+}
+
+// SetFlushFailurePolicy sets what is done with the lines of a failed flush: dropped (the default) or re-queued
+// for the next connection, keeping up to maxLines of them.
+func SetFlushFailurePolicy(policy FlushFailurePolicy, maxLines int) ProxyConnectionHandlerOption {
+	return func(handler *ProxyConnectionHandler) {
+		handler.flushFailure = policy
+		handler.maxRequeuedLines = maxLines
+	}
+}
+
+// SetSpool sets a spool the undelivered lines are spilled to instead of being dropped: the lines of the failed
+// flushes (or those re-queued beyond the max), the lines sent while the proxy is unreachable and those re-queued when
+// the handler is closed. They're replayed in chunks after the successful flushes. Connect doesn't fail with a spool.
+func SetSpool(spool *Spool) ProxyConnectionHandlerOption {
+	return func(handler *ProxyConnectionHandler) {
+		handler.spool = spool
+	}
+}
+
+// SetOnConnect sets a function called each time a connection to the proxy is established.
+func SetOnConnect(f func()) ProxyConnectionHandlerOption {
+	return func(handler *ProxyConnectionHandler) {
+		handler.onConnect = f
+	}
+}
+
+// SetOnDisconnect sets a function called each time the connection to the proxy is reset after an error,
+// or closed (with a nil error) when the handler is closed.
+func SetOnDisconnect(f func(err error)) ProxyConnectionHandlerOption {
+	return func(handler *ProxyConnectionHandler) {
+		handler.onDisconnect = f
+	}
+}
+
+// SetFlushScheduler sets the scheduler flushing the handler periodically, instead of the flush loop of the handler.
+// The flush interval and jitter of the handler are then ignored. Without heartbeats, the handler runs no goroutine.
+func SetFlushScheduler(scheduler *FlushScheduler) ProxyConnectionHandlerOption {
+	return func(handler *ProxyConnectionHandler) {
+		handler.scheduler = scheduler
+	}
+}
+
+// SetConnectionTLSConfig encrypts the connections to the proxy with TLS, e.g. to a proxy behind a TLS-terminating load balancer.
+// The certificate of the proxy is verified against the host of its address unless the config sets a ServerName.
+func SetConnectionTLSConfig(config *tls.Config) ProxyConnectionHandlerOption {
+	return func(handler *ProxyConnectionHandler) {
+		handler.tlsConfig = config
+	}
+}
+
+// SetOnConnectFailed sets a function called each time an attempt to connect to the proxy fails.
+func SetOnConnectFailed(f func(err error)) ProxyConnectionHandlerOption {
+	return func(handler *ProxyConnectionHandler) {
+		handler.onConnectFailed = f
+	}
+}
+
+// SetOnFlush sets a function called after each flush of buffered lines to the proxy with the number of lines
+// written and failed (lost with the connection), and the flush error.
+func SetOnFlush(f func(sent, failed int, err error)) ProxyConnectionHandlerOption {
+	return func(handler *ProxyConnectionHandler) {
+		handler.onFlush = f
+	}
+}
+
+// NewProxyConnectionHandler returns a handler writing to the proxy at address, either "host:port" or the URL of a
+// Unix domain socket, e.g. "unix:///var/run/wavefront.sock".
+func NewProxyConnectionHandler(address string, flushInterval time.Duration, prefix string, internalRegistry *MetricRegistry, setters ...ProxyConnectionHandlerOption) ConnectionHandler {
 	proxyConnectionHandler := &ProxyConnectionHandler{
 		address:          address,
 		flushTicker:      time.NewTicker(flushInterval),
+		flushInterval:    flushInterval,
 		internalRegistry: internalRegistry,
+		writeTimeout:     defaultWriteTimeout,
+	}
+	for _, setter := range setters {
+		setter(proxyConnectionHandler)
 	}
 	proxyConnectionHandler.writeSuccesses = internalRegistry.NewDeltaCounter(prefix + ".write.success")
 	proxyConnectionHandler.writeErrors = internalRegistry.NewDeltaCounter(prefix + ".write.errors")
+	proxyConnectionHandler.bytesSent = internalRegistry.NewDeltaCounter(prefix + ".bytes")
+	proxyConnectionHandler.reconnects = internalRegistry.NewDeltaCounter(prefix + ".connection.reconnects")
+	proxyConnectionHandler.reportErrors = internalRegistry.NewDeltaCounter(prefix + ".report.errors")
+	proxyConnectionHandler.droppedLines = internalRegistry.NewDeltaCounter(prefix + ".flush.dropped")
+	proxyConnectionHandler.requeuedLines = internalRegistry.NewDeltaCounter(prefix + ".flush.requeued")
+	if proxyConnectionHandler.spool != nil {
+		proxyConnectionHandler.spilledLines = internalRegistry.NewDeltaCounter(prefix + ".spool.spilled")
+		proxyConnectionHandler.replayedLines = internalRegistry.NewDeltaCounter(prefix + ".spool.replayed")
+	}
 	return proxyConnectionHandler
 }
 
 func (handler *ProxyConnectionHandler) Start() {
+	// a nil channel never fires when heartbeats are disabled
+	var heartbeats <-chan time.Time
+	if handler.heartbeat != "" && handler.heartbeatInterval > 0 {
+		handler.heartbeatTicker = time.NewTicker(handler.heartbeatInterval)
+		heartbeats = handler.heartbeatTicker.C
+	}
+
+	var schedule *flushSchedule
+	if handler.scheduler != nil {
+		handler.flushTicker.Stop()
+		handler.unschedule = handler.scheduler.register(handler.periodicFlush)
+		if heartbeats == nil {
+			return
+		}
+		// the loop only writes the heartbeats, its flush channels being nil
+		schedule = &flushSchedule{}
+	} else {
+		schedule = newFlushSchedule(handler.flushTicker, handler.flushInterval, handler.flushJitter, handler.after)
+	}
 	handler.done = make(chan struct{})
 
 	go func() {
+		defer schedule.stop()
 		for {
 			select {
-			case <-handler.flushTicker.C:
-				err := handler.Flush()
-				if err != nil {
+			case <-schedule.first:
+				schedule.firstFlush()
+				if err := handler.Flush(); err != nil {
+					log.Println(err)
+				}
+			case <-schedule.ticks:
+				handler.periodicFlush()
+			case <-heartbeats:
+				if err := handler.sendHeartbeat(); err != nil {
 					log.Println(err)
 				}
 			case <-handler.done:
@@ -56,24 +307,118 @@ func (handler *ProxyConnectionHandler) Start() {
 	}()
 }
 
+// periodicFlush flushes the handler on a tick of its flush loop or scheduler.
+func (handler *ProxyConnectionHandler) periodicFlush() {
+	if err := handler.Flush(); err != nil {
+		log.Println(err)
+	}
+}
+
 func (handler *ProxyConnectionHandler) Connect() error {
+	connected, err := handler.connect()
+	if err != nil {
+		if handler.onConnectFailed != nil {
+			handler.onConnectFailed(err)
+		}
+		if handler.spool != nil {
+			// the lines sent until connected are spilled
+			log.Println(err)
+			return nil
+		}
+	} else if connected && handler.onConnect != nil {
+		handler.onConnect()
+	}
+	return err
+}
+
+// connect connects to the proxy if not already connected, returning whether a new connection was established.
+func (handler *ProxyConnectionHandler) connect() (bool, error) {
 	handler.mtx.Lock()
 	defer handler.mtx.Unlock()
 
 	// Skip if already connected
 	if handler.conn != nil {
-		return nil
+		return false, nil
 	}
 
-	var err error
-	handler.conn, err = net.DialTimeout("tcp", handler.address, time.Second*10)
+	dial := handler.dial
+	if dial == nil {
+		dialer := net.Dialer{Timeout: dialTimeout, KeepAlive: handler.keepAlive}
+		dial = dialer.Dial
+	}
+	conn, err := dial(dialAddress(handler.address))
+	if err == nil && handler.tlsConfig != nil {
+		conn, err = tlsClient(conn, handler.address, handler.tlsConfig)
+	}
 	if err != nil {
-		handler.conn = nil
-		return fmt.Errorf("unable to connect to Wavefront proxy at address: %s, err: %q", handler.address, err)
+		return false, fmt.Errorf("unable to connect to Wavefront proxy at address: %s, err: %q", handler.address, err)
 	}
+	handler.conn = conn
 	log.Printf("connected to Wavefront proxy at address: %s", handler.address)
 	handler.writer = bufio.NewWriter(handler.conn)
-	return nil
+	handler.lastWrite = time.Now()
+	if handler.readResponses {
+		go handler.readLoop(handler.conn)
+	}
+
+	if handler.greeting != "" {
+		handler.setWriteDeadline(context.Background())
+		if _, err = handler.writer.WriteString(handler.greeting); err == nil {
+			err = handler.writer.Flush()
+		}
+		if err != nil {
+			handler.resetConnection()
+			return false, fmt.Errorf("unable to greet Wavefront proxy at address: %s, err: %q", handler.address, err)
+		}
+	}
+	if len(handler.requeued) > 0 {
+		requeued := handler.requeued
+		handler.requeued = nil
+		handler.unflushed = append(handler.unflushed, requeued...)
+		handler.pending += countLines(requeued)
+		handler.setWriteDeadline(context.Background())
+		if _, err = handler.writer.Write(requeued); err != nil {
+			handler.resetConnection()
+			return false, fmt.Errorf("unable to write re-queued lines to Wavefront proxy at address: %s, err: %q", handler.address, err)
+		}
+	}
+	if handler.everConnected {
+		handler.reconnects.Inc()
+	}
+	handler.everConnected = true
+	return true, nil
+}
+
+// unixScheme prefixes the addresses of the proxies listening on a Unix domain socket.
+const unixScheme = "unix://"
+
+// dialAddress returns the network and the address dialed to connect to a proxy at address, either "host:port" or
+// the URL of a Unix domain socket, e.g. "unix:///var/run/wavefront.sock".
+func dialAddress(address string) (network, addr string) {
+	if strings.HasPrefix(address, unixScheme) {
+		return "unix", strings.TrimPrefix(address, unixScheme)
+	}
+	return "tcp", address
+}
+
+// tlsClient runs the TLS handshake over conn, closing it when the handshake fails. Without ServerName in config,
+// the certificate of the proxy is verified against the host of address. The address of a Unix domain socket has no
+// host, the ServerName must be set then, unless the certificate isn't verified.
+func tlsClient(conn net.Conn, address string, config *tls.Config) (net.Conn, error) {
+	if config.ServerName == "" {
+		if host, _, err := net.SplitHostPort(address); err == nil {
+			config = config.Clone()
+			config.ServerName = host
+		}
+	}
+	tlsConn := tls.Client(conn, config)
+	tlsConn.SetDeadline(time.Now().Add(dialTimeout))
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	tlsConn.SetDeadline(time.Time{})
+	return tlsConn, nil
 }
 
 func (handler *ProxyConnectionHandler) Connected() bool {
@@ -82,45 +427,213 @@ func (handler *ProxyConnectionHandler) Connected() bool {
 	return handler.conn != nil
 }
 
-func (handler *ProxyConnectionHandler) Close() {
-	handler.flushTicker.Stop()
-	handler.done <- struct{}{} // block until goroutine exits
+// sendHeartbeat writes and flushes the heartbeat line when the connection has been idle for the heartbeat interval.
+// The connection is reset when the write fails.
+func (handler *ProxyConnectionHandler) sendHeartbeat() error {
+	handler.mtx.Lock()
+	if handler.conn == nil || time.Since(handler.lastWrite) < handler.heartbeatInterval {
+		handler.mtx.Unlock()
+		return nil
+	}
+	flushed := handler.pending
+	handler.setWriteDeadline(context.Background())
+	_, err := handler.writer.WriteString(handler.heartbeat)
+	if err == nil {
+		err = handler.writer.Flush()
+	}
+	if err != nil {
+		handler.resetConnection()
+	} else {
+		handler.lastWrite = time.Now()
+		handler.flushSucceeded()
+	}
+	handler.mtx.Unlock()
 
-	err := handler.Flush()
 	if err != nil {
-		log.Println(err)
+		err = fmt.Errorf("unable to send heartbeat to Wavefront proxy at address: %s, err: %q", handler.address, err)
+		handler.disconnected(err)
 	}
+	handler.flushed(flushed, err)
+	return err
+}
 
-	handler.mtx.Lock()
-	defer handler.mtx.Unlock()
+func (handler *ProxyConnectionHandler) Close() error {
+	handler.flushTicker.Stop()
+	if handler.heartbeatTicker != nil {
+		handler.heartbeatTicker.Stop()
+	}
+	if handler.unschedule != nil {
+		handler.unschedule()
+		handler.unschedule = nil
+	}
+	if handler.done != nil {
+		handler.done <- struct{}{} // block until goroutine exits
+	}
+
+	// flush the buffered data before closing the connection, the spooled lines being left for the next process
+	err := handler.flush(context.Background())
 
+	handler.mtx.Lock()
 	handler.done = nil
-	if handler.conn != nil {
+	wasConnected := handler.conn != nil
+	if wasConnected {
 		handler.conn.Close()
 		handler.conn = nil
 		handler.writer = nil
+		handler.pending = 0
+	}
+	// the lines re-queued without reconnecting since are lost, unless spilled
+	handler.spill(handler.requeued)
+	handler.requeued = nil
+	handler.unflushed = nil
+	handler.mtx.Unlock()
+
+	if wasConnected {
+		handler.disconnected(nil)
 	}
+	return err
 }
 
 func (handler *ProxyConnectionHandler) Flush() error {
-	handler.mtx.Lock()
-	defer handler.mtx.Unlock()
+	return handler.FlushCtx(context.Background())
+}
+
+// FlushCtx flushes like Flush, the writes being bounded by the deadline of ctx when it's before the write timeout.
+// It returns the error of ctx without flushing when ctx is done.
+func (handler *ProxyConnectionHandler) FlushCtx(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := handler.flush(ctx); err != nil {
+		return err
+	}
+	if handler.spool == nil {
+		return nil
+	}
+	err := handler.replay(ctx)
+	if err != nil {
+		handler.disconnected(err)
+	}
+	return err
+}
 
+// flush writes the buffered lines to the proxy.
+func (handler *ProxyConnectionHandler) flush(ctx context.Context) error {
+	handler.mtx.Lock()
+	var err error
+	flushed := handler.pending
 	if handler.writer != nil {
-		err := handler.writer.Flush()
+		handler.setWriteDeadline(ctx)
+		err = handler.writer.Flush()
 		if err != nil {
+			handler.writeFailed()
 			handler.resetConnection()
+			err = contextError(ctx, err)
+		} else {
+			handler.flushSucceeded()
+		}
+	}
+	handler.mtx.Unlock()
+
+	if err != nil {
+		handler.disconnected(err)
+	}
+	handler.flushed(flushed, err)
+	return err
+}
+
+// replay writes the spooled lines to the proxy in chunks, each flushed and removed from the spool before the next one,
+// without holding the lock in between. It stops once ctx is done, or at the first failed write, resetting the
+// connection and keeping the chunk spooled.
+func (handler *ProxyConnectionHandler) replay(ctx context.Context) error {
+	for ctx.Err() == nil {
+		more, flushed, err := handler.replayChunk(ctx)
+		handler.flushed(flushed, err)
+		if !more || err != nil {
+			return err
 		}
-		return err
 	}
 	return nil
 }
 
+// replayChunk writes and flushes a chunk of the spooled lines, returning whether there may be more to replay and
+// the number of lines sent since the last flush, flushed along.
+func (handler *ProxyConnectionHandler) replayChunk(ctx context.Context) (more bool, flushed int, err error) {
+	handler.mtx.Lock()
+	defer handler.mtx.Unlock()
+
+	if handler.conn == nil {
+		return false, 0, nil
+	}
+	chunk, err := handler.spool.Peek(spoolChunkBytes)
+	if err != nil {
+		log.Println(err)
+		return false, 0, nil
+	}
+	if len(chunk) == 0 {
+		return false, 0, nil
+	}
+	flushed = handler.pending
+	handler.setWriteDeadline(ctx)
+	if _, err = handler.writer.Write(chunk); err == nil {
+		err = handler.writer.Flush()
+	}
+	if err != nil {
+		handler.writeFailed()
+		handler.resetConnection()
+		err = fmt.Errorf("unable to write spooled lines to Wavefront proxy at address: %s, err: %q", handler.address, err)
+		return false, flushed, contextError(ctx, err)
+	}
+	handler.flushSucceeded()
+	handler.writeSuccesses.Inc()
+	handler.bytesSent.Add(int64(len(chunk)))
+	handler.lastWrite = time.Now()
+	handler.replayedLines.Add(int64(countLines(chunk)))
+	if err := handler.spool.Advance(len(chunk)); err != nil {
+		log.Println(err)
+		return false, flushed, nil
+	}
+	return true, flushed, nil
+}
+
+// flushed invokes the flush callback when lines were flushed, it must be called without holding mtx.
+func (handler *ProxyConnectionHandler) flushed(lines int, err error) {
+	if handler.onFlush == nil || lines == 0 {
+		return
+	}
+	if err != nil {
+		handler.onFlush(0, lines, err)
+	} else {
+		handler.onFlush(lines, 0, nil)
+	}
+}
+
+// disconnected invokes the disconnection callback, it must be called without holding mtx.
+func (handler *ProxyConnectionHandler) disconnected(err error) {
+	if handler.onDisconnect != nil {
+		handler.onDisconnect(err)
+	}
+}
+
 func (handler *ProxyConnectionHandler) GetFailureCount() int64 {
 	return atomic.LoadInt64(&handler.failures)
 }
 
+// ResetFailureCount returns the failure count and resets it to zero.
+func (handler *ProxyConnectionHandler) ResetFailureCount() int64 {
+	return atomic.SwapInt64(&handler.failures, 0)
+}
+
 func (handler *ProxyConnectionHandler) SendData(lines string) error {
+	return handler.SendDataCtx(context.Background(), lines)
+}
+
+// SendDataCtx sends the lines like SendData, the writes being bounded by the deadline of ctx when it's before the
+// write timeout. It returns the error of ctx without sending the lines when ctx is done.
+func (handler *ProxyConnectionHandler) SendDataCtx(ctx context.Context, lines string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	// if the connection was closed or interrupted - don't cause a panic (we'll retry at next interval)
 	defer func() {
 		if r := recover(); r != nil {
@@ -129,26 +642,100 @@ func (handler *ProxyConnectionHandler) SendData(lines string) error {
 			handler.mtx.Lock()
 			handler.resetConnection()
 			handler.mtx.Unlock()
+			handler.disconnected(fmt.Errorf("error sending data: %v", r))
 		}
 	}()
 
-	// bufio.Writer isn't thread safe
+	err, flushed, flushErr := handler.sendData(ctx, lines)
+	if flushErr != nil {
+		handler.disconnected(flushErr)
+	}
+	handler.flushed(flushed, flushErr)
+	if flushErr != nil {
+		return flushErr
+	}
+	return err
+}
+
+// sendData writes the lines to the buffer, flushing it when it holds flushBatchSize lines. A failed flush resets
+// the connection and is returned separately, with the number of lines flushed, for the caller to report it.
+func (handler *ProxyConnectionHandler) sendData(ctx context.Context, lines string) (err error, flushed int, flushErr error) {
+	// bufio.Writer isn't thread safe, the flush ticker shares the lock
 	handler.mtx.Lock()
 	defer handler.mtx.Unlock()
 
+	if err := ctx.Err(); err != nil {
+		// done while waiting for the lock
+		return err, 0, nil
+	}
 	if handler.conn != nil {
-		// Set a generous timeout to the write
-		handler.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		if handler.flushFailure == FlushFailureRequeue || handler.spool != nil {
+			handler.unflushed = append(handler.unflushed, lines...)
+		}
+		handler.setWriteDeadline(ctx)
 		_, err := fmt.Fprint(handler.writer, lines)
 		if err != nil {
-			handler.writeErrors.Inc()
-			atomic.AddInt64(&handler.failures, 1)
+			handler.writeFailed()
+			err = contextError(ctx, err)
 		} else {
 			handler.writeSuccesses.Inc()
+			handler.bytesSent.Add(int64(len(lines)))
+			handler.lastWrite = time.Now()
+			if handler.writer.Buffered() == 0 {
+				handler.flushSucceeded()
+			} else {
+				handler.pending += strings.Count(lines, "\n")
+			}
+			if handler.flushBatchSize > 0 && handler.pending >= handler.flushBatchSize {
+				flushed = handler.pending
+				if flushErr = handler.writer.Flush(); flushErr != nil {
+					handler.writeFailed()
+					handler.resetConnection()
+					flushErr = contextError(ctx, flushErr)
+				} else {
+					handler.flushSucceeded()
+				}
+			}
+		}
+		return err, flushed, flushErr
+	}
+	if handler.spool != nil {
+		if dropped := handler.spill([]byte(lines)); dropped > 0 {
+			return fmt.Errorf("failed to send data: spool full"), 0, nil
+		}
+		return nil, 0, nil
+	}
+	return fmt.Errorf("failed to send data: invalid wavefront proxy connection"), 0, nil
+}
+
+// setWriteDeadline bounds the next writes to the connection by the write timeout, or by the deadline of ctx when
+// it's earlier.
+func (handler *ProxyConnectionHandler) setWriteDeadline(ctx context.Context) {
+	deadline, ok := ctx.Deadline()
+	if handler.writeTimeout > 0 {
+		if timeout := time.Now().Add(handler.writeTimeout); !ok || timeout.Before(deadline) {
+			deadline = timeout
 		}
-		return err
 	}
-	return fmt.Errorf("failed to send data: invalid wavefront proxy connection")
+	handler.conn.SetWriteDeadline(deadline)
+}
+
+// contextError returns the error of ctx when a write failed with err because ctx is done or past its deadline,
+// err otherwise.
+func contextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+		return context.DeadlineExceeded
+	}
+	return err
+}
+
+// writeFailed counts a failed write (or flush) to the proxy.
+func (handler *ProxyConnectionHandler) writeFailed() {
+	handler.writeErrors.Inc()
+	atomic.AddInt64(&handler.failures, 1)
 }
 
 func (handler *ProxyConnectionHandler) resetConnection() {
@@ -156,4 +743,92 @@ func (handler *ProxyConnectionHandler) resetConnection() {
 	handler.conn.Close()
 	handler.conn = nil
 	handler.writer = nil
+	handler.flushFailed()
+}
+
+// flushSucceeded forgets the lines written since the last flush, now sent.
+func (handler *ProxyConnectionHandler) flushSucceeded() {
+	handler.pending = 0
+	handler.unflushed = handler.unflushed[:0]
+}
+
+// flushFailed drops or re-queues the lines written since the last successful flush, per the flush failure policy.
+func (handler *ProxyConnectionHandler) flushFailed() {
+	if handler.flushFailure != FlushFailureRequeue {
+		if handler.spool != nil {
+			handler.spill(handler.unflushed)
+			handler.unflushed = handler.unflushed[:0]
+		} else {
+			handler.droppedLines.Add(int64(handler.pending))
+		}
+		handler.pending = 0
+		return
+	}
+	handler.requeuedLines.Add(int64(countLines(handler.unflushed)))
+	handler.requeued = append(handler.requeued, handler.unflushed...)
+	handler.unflushed = nil
+	handler.pending = 0
+
+	// keep the most recent lines
+	if excess := countLines(handler.requeued) - handler.maxRequeuedLines; excess > 0 {
+		cut := 0
+		for i := 0; i < excess; i++ {
+			cut += bytes.IndexByte(handler.requeued[cut:], '\n') + 1
+		}
+		handler.spill(handler.requeued[:cut])
+		handler.requeued = append([]byte(nil), handler.requeued[cut:]...)
+	}
+}
+
+// spill writes the lines to the spool, counting those that couldn't be spilled as dropped, and returns their number.
+// Without spool, all the lines are dropped.
+func (handler *ProxyConnectionHandler) spill(lines []byte) (dropped int) {
+	if handler.spool == nil {
+		dropped = countLines(lines)
+	} else if len(lines) > 0 {
+		var err error
+		if dropped, err = handler.spool.Spill(lines); err != nil {
+			log.Println(err)
+		}
+		handler.spilledLines.Add(int64(countLines(lines) - dropped))
+	}
+	handler.droppedLines.Add(int64(dropped))
+	return dropped
+}
+
+// countLines returns the number of newline terminated lines of data.
+func countLines(data []byte) int {
+	return bytes.Count(data, []byte{'\n'})
+}
+
+// PendingLines returns the number of lines buffered and not yet written to the proxy.
+// Lines partially written when the buffer filled up are counted until the next flush.
+func (handler *ProxyConnectionHandler) PendingLines() int {
+	handler.mtx.RLock()
+	defer handler.mtx.RUnlock()
+	return handler.pending
+}
+
+// readLoop reads the lines written back by the proxy on conn until it's closed, counting the error responses.
+func (handler *ProxyConnectionHandler) readLoop(conn net.Conn) {
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		response := scanner.Text()
+		if !isErrorResponse(response) {
+			continue
+		}
+		handler.reportErrors.Inc()
+		atomic.AddInt64(&handler.failures, 1)
+		if handler.logResponseErrors {
+			log.Printf("error response from Wavefront proxy at address: %s: %s", handler.address, response)
+		}
+	}
+}
+
+// isErrorResponse returns whether a line written back by the proxy reports an error,
+// e.g. "ERROR: invalid metric" or "rejected: unknown format".
+func isErrorResponse(response string) bool {
+	response = strings.ToLower(strings.TrimSpace(response))
+	return strings.HasPrefix(response, "error") || strings.HasPrefix(response, "err ") ||
+		strings.HasPrefix(response, "rejected")
 }
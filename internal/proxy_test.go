@@ -0,0 +1,624 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxyCloseFlushes(t *testing.T) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer lis.Close()
+
+	received := make(chan string)
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			close(received)
+			return
+		}
+		data, _ := ioutil.ReadAll(conn)
+		received <- string(data)
+	}()
+
+	registry := NewMetricRegistry(nil)
+	handler := NewProxyConnectionHandler(lis.Addr().String(), time.Hour, "points", registry)
+	handler.Start()
+	require.NoError(t, handler.Connect())
+	require.NoError(t, handler.SendData("\"foo.metric\" 1.2 source=\"test\"\n"))
+	assert.Equal(t, 1, handler.PendingLines())
+	assert.Equal(t, int64(len("\"foo.metric\" 1.2 source=\"test\"\n")), registry.NewDeltaCounter("points.bytes").Count())
+
+	require.NoError(t, handler.Flush())
+	assert.Equal(t, 0, handler.PendingLines())
+
+	assert.NoError(t, handler.Close())
+	assert.Equal(t, "\"foo.metric\" 1.2 source=\"test\"\n", <-received)
+}
+
+func TestProxyGreeting(t *testing.T) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer lis.Close()
+
+	received := make(chan string)
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			close(received)
+			return
+		}
+		data, _ := ioutil.ReadAll(conn)
+		received <- string(data)
+	}()
+
+	handler := NewProxyConnectionHandler(lis.Addr().String(), time.Hour, "points", NewMetricRegistry(nil), SetGreeting("#wavefront-sdk-go 1.0.0\n"))
+	handler.Start()
+	require.NoError(t, handler.Connect())
+	require.NoError(t, handler.SendData("\"foo.metric\" 1.2 source=\"test\"\n"))
+
+	assert.NoError(t, handler.Close())
+	assert.Equal(t, "#wavefront-sdk-go 1.0.0\n\"foo.metric\" 1.2 source=\"test\"\n", <-received)
+}
+
+func TestProxyTLS(t *testing.T) {
+	// borrow the self-signed certificate of httptest, valid for 127.0.0.1
+	server := httptest.NewTLSServer(nil)
+	server.Close()
+	lis, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: server.TLS.Certificates})
+	require.NoError(t, err)
+	defer lis.Close()
+
+	received := make(chan string)
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				close(received)
+				return
+			}
+			data, _ := ioutil.ReadAll(conn)
+			received <- string(data)
+		}
+	}()
+
+	// the certificate isn't trusted by default
+	untrusted := NewProxyConnectionHandler(lis.Addr().String(), time.Hour, "points", NewMetricRegistry(nil), SetConnectionTLSConfig(&tls.Config{}))
+	err = untrusted.Connect()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "certificate")
+	assert.False(t, untrusted.Connected())
+	<-received
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	handler := NewProxyConnectionHandler(lis.Addr().String(), time.Hour, "points", NewMetricRegistry(nil), SetConnectionTLSConfig(&tls.Config{RootCAs: pool}))
+	handler.Start()
+	require.NoError(t, handler.Connect())
+	require.NoError(t, handler.SendData("\"foo.metric\" 1.2 source=\"test\"\n"))
+
+	assert.NoError(t, handler.Close())
+	assert.Equal(t, "\"foo.metric\" 1.2 source=\"test\"\n", <-received)
+}
+
+func TestProxyConnectionCallbacks(t *testing.T) {
+	closed, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	closedAddr := closed.Addr().String()
+	closed.Close()
+
+	var failures []error
+	handler := NewProxyConnectionHandler(closedAddr, time.Hour, "points", NewMetricRegistry(nil),
+		SetOnConnectFailed(func(err error) {
+			failures = append(failures, err)
+		}))
+	assert.Error(t, handler.Connect())
+	require.Len(t, failures, 1)
+	assert.Contains(t, failures[0].Error(), "unable to connect")
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer lis.Close()
+
+	received := make(chan string)
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			close(received)
+			return
+		}
+		data, _ := ioutil.ReadAll(conn)
+		received <- string(data)
+	}()
+
+	connects := 0
+	var disconnects []error
+	handler = NewProxyConnectionHandler(lis.Addr().String(), time.Hour, "points", NewMetricRegistry(nil),
+		SetOnConnect(func() {
+			connects++
+			// callbacks are invoked without holding the handler lock, sending would deadlock otherwise
+			assert.NoError(t, handler.SendData("\"connected\" 1 source=\"test\"\n"))
+		}),
+		SetOnDisconnect(func(err error) {
+			disconnects = append(disconnects, err)
+		}))
+	handler.Start()
+	require.NoError(t, handler.Connect())
+	require.NoError(t, handler.Connect())
+	assert.Equal(t, 1, connects, "already connected")
+
+	assert.NoError(t, handler.Close())
+	assert.Equal(t, []error{nil}, disconnects)
+	assert.Equal(t, "\"connected\" 1 source=\"test\"\n", <-received)
+}
+
+func TestProxyHeartbeat(t *testing.T) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer lis.Close()
+
+	received := make(chan string)
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			close(received)
+			return
+		}
+		data, _ := ioutil.ReadAll(conn)
+		received <- string(data)
+	}()
+
+	handler := NewProxyConnectionHandler(lis.Addr().String(), time.Hour, "points", NewMetricRegistry(nil),
+		SetKeepAlive(time.Minute), SetHeartbeat("#heartbeat\n", 20*time.Millisecond))
+	handler.Start()
+	require.NoError(t, handler.Connect())
+	require.NoError(t, handler.SendData("\"foo.metric\" 1.2 source=\"test\"\n"))
+	require.NoError(t, handler.Flush())
+
+	// the connection is idle, heartbeats are sent
+	time.Sleep(100 * time.Millisecond)
+	assert.NoError(t, handler.Close())
+
+	data := <-received
+	assert.True(t, strings.HasPrefix(data, "\"foo.metric\" 1.2 source=\"test\"\n#heartbeat\n"), data)
+	assert.Equal(t, "", strings.Replace(strings.TrimPrefix(data, "\"foo.metric\" 1.2 source=\"test\"\n"), "#heartbeat\n", "", -1))
+}
+
+func TestProxyFlushBatchSize(t *testing.T) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer lis.Close()
+
+	received := make(chan string)
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			close(received)
+			return
+		}
+		defer conn.Close()
+		buf := make([]byte, 1024)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				close(received)
+				return
+			}
+			received <- string(buf[:n])
+		}
+	}()
+
+	handler := NewProxyConnectionHandler(lis.Addr().String(), time.Hour, "points", NewMetricRegistry(nil), SetFlushBatchSize(3))
+	handler.Start()
+	require.NoError(t, handler.Connect())
+
+	require.NoError(t, handler.SendData("\"foo.metric\" 1 source=\"test\"\n"))
+	require.NoError(t, handler.SendData("\"foo.metric\" 2 source=\"test\"\n"))
+	assert.Equal(t, 2, handler.PendingLines())
+	select {
+	case data := <-received:
+		t.Fatalf("unexpected flush before the batch size is reached: %q", data)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// the third line reaches the batch size and flushes the buffer without waiting for the flush ticker
+	require.NoError(t, handler.SendData("\"foo.metric\" 3 source=\"test\"\n"))
+	assert.Equal(t, 0, handler.PendingLines())
+	var data string
+	for strings.Count(data, "\n") < 3 {
+		select {
+		case chunk := <-received:
+			data += chunk
+		case <-time.After(time.Second):
+			t.Fatalf("the batch was not flushed: %q", data)
+		}
+	}
+	assert.Equal(t, "\"foo.metric\" 1 source=\"test\"\n\"foo.metric\" 2 source=\"test\"\n\"foo.metric\" 3 source=\"test\"\n", data)
+	assert.NoError(t, handler.Close())
+}
+
+func TestProxyFlushJitter(t *testing.T) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer lis.Close()
+	go func() {
+		conn, err := lis.Accept()
+		if err == nil {
+			ioutil.ReadAll(conn)
+		}
+	}()
+
+	handler := NewProxyConnectionHandler(lis.Addr().String(), 10*time.Millisecond, "points", NewMetricRegistry(nil),
+		SetFlushJitter(time.Minute)).(*ProxyConnectionHandler)
+	var delay time.Duration
+	firstFlush := make(chan time.Time)
+	handler.after = func(d time.Duration) <-chan time.Time {
+		delay = d
+		return firstFlush
+	}
+	handler.Start()
+	assert.True(t, delay >= 0 && delay < time.Minute, "delay %v out of the jitter window", delay)
+
+	require.NoError(t, handler.Connect())
+	require.NoError(t, handler.SendData("\"foo.metric\" 1.2 source=\"test\"\n"))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 1, handler.PendingLines())
+
+	firstFlush <- time.Now()
+	assert.Eventually(t, func() bool { return handler.PendingLines() == 0 }, time.Second, time.Millisecond)
+	require.NoError(t, handler.SendData("\"foo.metric\" 1.2 source=\"test\"\n"))
+	assert.Eventually(t, func() bool { return handler.PendingLines() == 0 }, time.Second, time.Millisecond)
+	assert.NoError(t, handler.Close())
+}
+
+// recordingSender records the last value reported for each internal metric.
+type recordingSender struct {
+	values map[string]float64
+}
+
+func (s *recordingSender) SendMetric(name string, value float64, ts int64, source string, tags map[string]string) error {
+	s.values[name] = value
+	return nil
+}
+
+func (s *recordingSender) SendDeltaCounter(name string, value float64, source string, tags map[string]string) error {
+	s.values[name] = value
+	return nil
+}
+
+func TestProxyReconnects(t *testing.T) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer lis.Close()
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go ioutil.ReadAll(conn)
+		}
+	}()
+
+	sender := &recordingSender{values: make(map[string]float64)}
+	registry := NewMetricRegistry(sender, SetPrefix("~sdk.go.core.sender.proxy"))
+	handler := NewProxyConnectionHandler(lis.Addr().String(), time.Hour, "points", registry).(*ProxyConnectionHandler)
+	handler.Start()
+
+	// the first connection is not a reconnection
+	require.NoError(t, handler.Connect())
+	for i := 0; i < 2; i++ {
+		handler.mtx.Lock()
+		handler.resetConnection()
+		handler.mtx.Unlock()
+		require.NoError(t, handler.Connect())
+	}
+	require.NoError(t, handler.Connect(), "connecting while connected is a no-op")
+
+	registry.report()
+	assert.Equal(t, float64(2), sender.values["~sdk.go.core.sender.proxy.points.connection.reconnects"])
+	assert.NoError(t, handler.Close())
+}
+
+func TestProxyOnFlush(t *testing.T) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer lis.Close()
+	go func() {
+		conn, err := lis.Accept()
+		if err == nil {
+			ioutil.ReadAll(conn)
+		}
+	}()
+
+	type flush struct {
+		sent, failed int
+		err          bool
+	}
+	var flushes []flush
+	var handler *ProxyConnectionHandler
+	handler = NewProxyConnectionHandler(lis.Addr().String(), time.Hour, "points", NewMetricRegistry(nil),
+		SetFlushBatchSize(3), SetOnFlush(func(sent, failed int, err error) {
+			// the handler lock is released
+			handler.mtx.Lock()
+			handler.mtx.Unlock()
+			flushes = append(flushes, flush{sent, failed, err != nil})
+		})).(*ProxyConnectionHandler)
+	handler.Start()
+	require.NoError(t, handler.Connect())
+
+	require.NoError(t, handler.Flush())
+	assert.Empty(t, flushes, "empty flushes are not reported")
+
+	require.NoError(t, handler.SendData("\"foo.metric\" 1 source=\"test\"\n\"foo.metric\" 2 source=\"test\"\n"))
+	require.NoError(t, handler.Flush())
+	require.NoError(t, handler.SendData("\"foo.metric\" 1 source=\"test\"\n\"foo.metric\" 2 source=\"test\"\n\"foo.metric\" 3 source=\"test\"\n"))
+	assert.NoError(t, handler.Close())
+
+	assert.Equal(t, []flush{{2, 0, false}, {3, 0, false}}, flushes)
+}
+
+func TestProxyWriteTimeout(t *testing.T) {
+	// the proxy end of the pipe is never read, blocking the writes
+	client, proxy := net.Pipe()
+	defer proxy.Close()
+
+	var failed int
+	registry := NewMetricRegistry(nil)
+	handler := NewProxyConnectionHandler("proxy:2878", time.Hour, "points", registry,
+		SetWriteTimeout(50*time.Millisecond), SetOnFlush(func(sent, f int, err error) {
+			failed += f
+		})).(*ProxyConnectionHandler)
+	handler.dial = func(network, address string) (net.Conn, error) {
+		return client, nil
+	}
+	handler.Start()
+	require.NoError(t, handler.Connect())
+	require.NoError(t, handler.SendData("\"foo.metric\" 1.2 source=\"test\"\n"))
+
+	start := time.Now()
+	err := handler.Flush()
+	require.Error(t, err)
+	assert.True(t, err.(net.Error).Timeout())
+	assert.True(t, time.Since(start) < 5*time.Second, "the flush doesn't block")
+
+	assert.False(t, handler.Connected(), "the connection is reset")
+	assert.Equal(t, 1, failed)
+	assert.Equal(t, int64(1), handler.GetFailureCount())
+	assert.Equal(t, int64(1), registry.NewDeltaCounter("points.write.errors").Count())
+	assert.NoError(t, handler.Close())
+}
+
+func TestProxyContextDeadline(t *testing.T) {
+	// the proxy end of the pipe is never read, blocking the writes
+	client, proxy := net.Pipe()
+	defer proxy.Close()
+
+	handler := NewProxyConnectionHandler("proxy:2878", time.Hour, "points", NewMetricRegistry(nil),
+		SetWriteTimeout(time.Hour)).(*ProxyConnectionHandler)
+	handler.dial = func(network, address string) (net.Conn, error) {
+		return client, nil
+	}
+	handler.Start()
+	require.NoError(t, handler.Connect())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.NoError(t, handler.SendDataCtx(ctx, "\"foo.metric\" 1.2 source=\"test\"\n"))
+
+	start := time.Now()
+	err := handler.FlushCtx(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err, "the deadline of the context bounds the write")
+	assert.True(t, time.Since(start) < 5*time.Second, "the flush doesn't wait for the write timeout")
+	assert.False(t, handler.Connected(), "the connection is reset")
+
+	assert.Equal(t, context.DeadlineExceeded, handler.SendDataCtx(ctx, "\"foo.metric\" 1.2 source=\"test\"\n"))
+	assert.NoError(t, handler.Close())
+}
+
+func TestProxyReadResponses(t *testing.T) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer lis.Close()
+
+	received := make(chan string)
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			close(received)
+			return
+		}
+		conn.Write([]byte("OK\nERROR: invalid metric\n"))
+		data, _ := ioutil.ReadAll(conn)
+		received <- string(data)
+	}()
+
+	registry := NewMetricRegistry(nil)
+	handler := NewProxyConnectionHandler(lis.Addr().String(), time.Hour, "points", registry, SetReadResponses(false))
+	handler.Start()
+	require.NoError(t, handler.Connect())
+
+	reportErrors := registry.NewDeltaCounter("points.report.errors")
+	assert.Eventually(t, func() bool {
+		return reportErrors.Count() == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, int64(1), handler.GetFailureCount())
+
+	require.NoError(t, handler.SendData("\"foo.metric\" 1.2 source=\"test\"\n"))
+	assert.NoError(t, handler.Close())
+	assert.Equal(t, "\"foo.metric\" 1.2 source=\"test\"\n", <-received)
+}
+
+func TestIsErrorResponse(t *testing.T) {
+	assert.True(t, isErrorResponse("ERROR: invalid metric"))
+	assert.True(t, isErrorResponse("Rejected: unknown format"))
+	assert.False(t, isErrorResponse("OK"))
+	assert.False(t, isErrorResponse(""))
+}
+
+// scriptedConn is a connection recording the data written to it, or failing the writes when failing is set,
+// or those beyond the first failAfter when set.
+type scriptedConn struct {
+	net.Conn
+	failing   bool
+	failAfter int
+	writes    int
+	written   bytes.Buffer
+}
+
+func (c *scriptedConn) Write(b []byte) (int, error) {
+	c.writes++
+	if c.failing || (c.failAfter > 0 && c.writes > c.failAfter) {
+		return 0, errors.New("broken pipe")
+	}
+	return c.written.Write(b)
+}
+
+func (c *scriptedConn) Close() error {
+	return nil
+}
+
+func (c *scriptedConn) SetWriteDeadline(time.Time) error {
+	return nil
+}
+
+func TestProxyFlushFailurePolicy(t *testing.T) {
+	tests := map[FlushFailurePolicy]struct {
+		resent            string
+		dropped, requeued int64
+	}{
+		FlushFailureDrop: {"\"foo.metric\" 4 source=\"test\"\n", 3, 0},
+		// the oldest line beyond the max of 2 is dropped
+		FlushFailureRequeue: {"\"foo.metric\" 2 source=\"test\"\n\"foo.metric\" 3 source=\"test\"\n\"foo.metric\" 4 source=\"test\"\n", 1, 3},
+	}
+
+	for policy, test := range tests {
+		conns := []*scriptedConn{{failing: true}, {}}
+		registry := NewMetricRegistry(nil)
+		handler := NewProxyConnectionHandler("proxy:2878", time.Hour, "points", registry,
+			SetFlushFailurePolicy(policy, 2)).(*ProxyConnectionHandler)
+		handler.dial = func(network, address string) (net.Conn, error) {
+			conn := conns[0]
+			conns = conns[1:]
+			return conn, nil
+		}
+		handler.Start()
+
+		require.NoError(t, handler.Connect())
+		require.NoError(t, handler.SendData("\"foo.metric\" 1 source=\"test\"\n\"foo.metric\" 2 source=\"test\"\n\"foo.metric\" 3 source=\"test\"\n"))
+		require.Error(t, handler.Flush())
+		assert.False(t, handler.Connected())
+
+		second := conns[0]
+		require.NoError(t, handler.Connect())
+		require.NoError(t, handler.SendData("\"foo.metric\" 4 source=\"test\"\n"))
+		require.NoError(t, handler.Flush())
+		assert.Equal(t, test.resent, second.written.String(), "policy %d", policy)
+		assert.Equal(t, test.dropped, registry.NewDeltaCounter("points.flush.dropped").Count(), "policy %d", policy)
+		assert.Equal(t, test.requeued, registry.NewDeltaCounter("points.flush.requeued").Count(), "policy %d", policy)
+		assert.NoError(t, handler.Close())
+	}
+}
+
+func TestProxySpool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wavefront-spool")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	conns := []*scriptedConn{nil, {failing: true}}
+	registry := NewMetricRegistry(nil)
+	handler := NewProxyConnectionHandler("proxy:2878", time.Hour, "points", registry,
+		SetSpool(NewSpool(dir, "points", 1024))).(*ProxyConnectionHandler)
+	handler.dial = func(network, address string) (net.Conn, error) {
+		conn := conns[0]
+		conns = conns[1:]
+		if conn == nil {
+			return nil, errors.New("connection refused")
+		}
+		return conn, nil
+	}
+
+	// the lines sent while the proxy is unreachable are spilled
+	require.NoError(t, handler.Connect())
+	assert.False(t, handler.Connected())
+	require.NoError(t, handler.SendData("\"foo.metric\" 1 source=\"test\"\n"))
+
+	// so are the lines of a failed flush, the spooled ones not being replayed
+	require.NoError(t, handler.Connect())
+	require.NoError(t, handler.SendData("\"foo.metric\" 2 source=\"test\"\n"))
+	require.Error(t, handler.Flush())
+	assert.NoError(t, handler.Close())
+	assert.Equal(t, int64(2), registry.NewDeltaCounter("points.spool.spilled").Count())
+	assert.Equal(t, int64(0), registry.NewDeltaCounter("points.spool.replayed").Count())
+	assert.Equal(t, int64(0), registry.NewDeltaCounter("points.flush.dropped").Count())
+
+	// the next process replays them after a successful flush
+	conn := &scriptedConn{}
+	registry = NewMetricRegistry(nil)
+	handler = NewProxyConnectionHandler("proxy:2878", time.Hour, "points", registry,
+		SetSpool(NewSpool(dir, "points", 1024))).(*ProxyConnectionHandler)
+	handler.dial = func(network, address string) (net.Conn, error) {
+		return conn, nil
+	}
+	require.NoError(t, handler.Connect())
+	require.NoError(t, handler.SendData("\"foo.metric\" 3 source=\"test\"\n"))
+	require.NoError(t, handler.Flush())
+	assert.Equal(t, "\"foo.metric\" 3 source=\"test\"\n\"foo.metric\" 1 source=\"test\"\n\"foo.metric\" 2 source=\"test\"\n", conn.written.String())
+	assert.Equal(t, int64(2), registry.NewDeltaCounter("points.spool.replayed").Count())
+	assert.NoError(t, handler.Close())
+	_, err = os.Stat(filepath.Join(dir, "points.spool"))
+	assert.True(t, os.IsNotExist(err), "the spool is removed once replayed")
+}
+
+func TestProxySpoolChunks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wavefront-spool")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	defer func(chunkBytes int) {
+		spoolChunkBytes = chunkBytes
+	}(spoolChunkBytes)
+	spoolChunkBytes = 40
+
+	spool := NewSpool(dir, "points", 1024)
+	_, err = spool.Spill([]byte("\"foo.metric\" 1 source=\"test\"\n\"foo.metric\" 2 source=\"test\"\n\"foo.metric\" 3 source=\"test\"\n"))
+	require.NoError(t, err)
+
+	// the chunk failing to be written stays spooled, the ones before not being replayed again
+	first, second := &scriptedConn{failAfter: 1}, &scriptedConn{}
+	conns := []*scriptedConn{first, second}
+	registry := NewMetricRegistry(nil)
+	handler := NewProxyConnectionHandler("proxy:2878", time.Hour, "points", registry,
+		SetSpool(spool)).(*ProxyConnectionHandler)
+	handler.dial = func(network, address string) (net.Conn, error) {
+		conn := conns[0]
+		conns = conns[1:]
+		return conn, nil
+	}
+	require.NoError(t, handler.Connect())
+	require.Error(t, handler.Flush())
+	assert.False(t, handler.Connected())
+	require.NoError(t, handler.Connect())
+	require.NoError(t, handler.Flush())
+	assert.Equal(t, "\"foo.metric\" 1 source=\"test\"\n", first.written.String())
+	assert.Equal(t, "\"foo.metric\" 2 source=\"test\"\n\"foo.metric\" 3 source=\"test\"\n", second.written.String())
+
+	assert.Equal(t, int64(3), registry.NewDeltaCounter("points.spool.replayed").Count())
+	assert.Equal(t, int64(0), registry.NewDeltaCounter("points.spool.spilled").Count())
+	assert.NoError(t, handler.Close())
+	_, err = os.Stat(filepath.Join(dir, "points.spool"))
+	assert.True(t, os.IsNotExist(err), "the spool is removed once replayed")
+}
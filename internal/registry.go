@@ -16,12 +16,14 @@ type internalSender interface {
 }
 
 // metric registry for internal metrics
+// a nil registry is disabled: it creates nil metrics and reports nothing.
 type MetricRegistry struct {
 	source       string
 	prefix       string
 	tags         map[string]string
 	reportTicker *time.Ticker
 	sender       internalSender
+	timeSupplier func() time.Time
 	done         chan struct{}
 
 	mtx     sync.Mutex
@@ -57,6 +59,14 @@ func SetTag(key, value string) RegistryOption {
 	}
 }
 
+// SetTimeSupplier sets the function used to timestamp reported metrics.
+// By default metrics are reported without timestamp and get the time of the server.
+func SetTimeSupplier(supplier func() time.Time) RegistryOption {
+	return func(registry *MetricRegistry) {
+		registry.timeSupplier = supplier
+	}
+}
+
 func SetPrefix(prefix string) RegistryOption {
 	return func(registry *MetricRegistry) {
 		registry.prefix = prefix
@@ -77,22 +87,37 @@ func NewMetricRegistry(sender internalSender, setters ...RegistryOption) *Metric
 }
 
 func (registry *MetricRegistry) NewCounter(name string) *MetricCounter {
+	if registry == nil {
+		return nil
+	}
 	return registry.getOrAdd(name, &MetricCounter{}).(*MetricCounter)
 }
 
 func (registry *MetricRegistry) NewDeltaCounter(name string) *DeltaCounter {
+	if registry == nil {
+		return nil
+	}
 	return registry.getOrAdd(name, &DeltaCounter{MetricCounter{}}).(*DeltaCounter)
 }
 
 func (registry *MetricRegistry) NewGauge(name string, f func() int64) *FunctionalGauge {
+	if registry == nil {
+		return nil
+	}
 	return registry.getOrAdd(name, &FunctionalGauge{value: f}).(*FunctionalGauge)
 }
 
 func (registry *MetricRegistry) NewGaugeFloat64(name string, f func() float64) *FunctionalGaugeFloat64 {
+	if registry == nil {
+		return nil
+	}
 	return registry.getOrAdd(name, &FunctionalGaugeFloat64{value: f}).(*FunctionalGaugeFloat64)
 }
 
 func (registry *MetricRegistry) Start() {
+	if registry == nil {
+		return
+	}
 	go registry.start()
 }
 
@@ -108,28 +133,51 @@ func (registry *MetricRegistry) start() {
 }
 
 func (registry *MetricRegistry) Stop() {
+	if registry == nil {
+		return
+	}
 	registry.reportTicker.Stop()
 	registry.done <- struct{}{}
 }
 
-func (registry *MetricRegistry) report() {
+// Report sends the current values of the metrics immediately, returning the first send error.
+// It does nothing on a nil registry.
+func (registry *MetricRegistry) Report() error {
+	if registry == nil {
+		return nil
+	}
+	return registry.report()
+}
+
+func (registry *MetricRegistry) report() error {
 	registry.mtx.Lock()
 	defer registry.mtx.Unlock()
 
+	var ts int64
+	if registry.timeSupplier != nil {
+		ts = registry.timeSupplier().Unix()
+	}
+
+	var firstErr error
 	for k, metric := range registry.metrics {
+		var err error
 		switch metric.(type) {
 		case *DeltaCounter:
 			deltaCount := metric.(*DeltaCounter).count()
-			registry.sender.SendDeltaCounter(registry.prefix+"."+k, float64(deltaCount), "", registry.tags)
+			err = registry.sender.SendDeltaCounter(registry.prefix+"."+k, float64(deltaCount), "", registry.tags)
 			metric.(*DeltaCounter).dec(deltaCount)
 		case *MetricCounter:
-			registry.sender.SendMetric(registry.prefix+"."+k, float64(metric.(*MetricCounter).count()), 0, "", registry.tags)
+			err = registry.sender.SendMetric(registry.prefix+"."+k, float64(metric.(*MetricCounter).count()), ts, "", registry.tags)
 		case *FunctionalGauge:
-			registry.sender.SendMetric(registry.prefix+"."+k, float64(metric.(*FunctionalGauge).instantValue()), 0, "", registry.tags)
+			err = registry.sender.SendMetric(registry.prefix+"."+k, float64(metric.(*FunctionalGauge).instantValue()), ts, "", registry.tags)
 		case *FunctionalGaugeFloat64:
-			registry.sender.SendMetric(registry.prefix+"."+k, metric.(*FunctionalGaugeFloat64).instantValue(), 0, "", registry.tags)
+			err = registry.sender.SendMetric(registry.prefix+"."+k, metric.(*FunctionalGaugeFloat64).instantValue(), ts, "", registry.tags)
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
 		}
 	}
+	return firstErr
 }
 
 func (registry *MetricRegistry) getOrAdd(name string, metric interface{}) interface{} {
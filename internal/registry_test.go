@@ -3,6 +3,7 @@ package internal
 import (
 	"strings"
 	"testing"
+	"time"
 )
 
 type fakeSender struct {
@@ -11,6 +12,7 @@ type fakeSender struct {
 	prefix string
 	name   string
 	tags   []string
+	ts     int64
 }
 
 func (f *fakeSender) SendDeltaCounter(name string, value float64, source string, tags map[string]string) error {
@@ -19,6 +21,7 @@ func (f *fakeSender) SendDeltaCounter(name string, value float64, source string,
 
 func (f *fakeSender) SendMetric(name string, value float64, ts int64, source string, tags map[string]string) error {
 	f.count = f.count + 1
+	f.ts = ts
 	if f.prefix != "" && !strings.HasPrefix(name, f.prefix) {
 		f.errors = f.errors + 1
 	}
@@ -103,3 +106,50 @@ func TestTagging(t *testing.T) {
 		t.Error("tags do not match")
 	}
 }
+
+func TestTimeSupplier(t *testing.T) {
+	sender := &fakeSender{}
+	registry := NewMetricRegistry(sender)
+	registry.NewCounter("counter")
+
+	registry.report()
+	if sender.ts != 0 {
+		t.Error("unexpected timestamp without time supplier")
+	}
+
+	now := time.Date(2019, 3, 10, 1, 59, 30, 0, time.UTC)
+	registry = NewMetricRegistry(sender, SetTimeSupplier(func() time.Time { return now }))
+	registry.NewCounter("counter")
+
+	registry.report()
+	if sender.ts != now.Unix() {
+		t.Errorf("expected timestamp %d, got %d", now.Unix(), sender.ts)
+	}
+}
+
+func TestDisabledRegistry(t *testing.T) {
+	var registry *MetricRegistry
+	registry.Start()
+
+	counter := registry.NewCounter("counter")
+	counter.Inc()
+	counter.Add(2)
+	if counter.Count() != 0 {
+		t.Error("unexpected counter value")
+	}
+
+	delta := registry.NewDeltaCounter("delta")
+	delta.Inc()
+	delta.Add(2)
+	if delta.Count() != 0 {
+		t.Error("unexpected delta counter value")
+	}
+
+	if registry.NewGauge("gauge", func() int64 { return 1 }) != nil {
+		t.Error("unexpected gauge")
+	}
+	if registry.NewGaugeFloat64("gauge", func() float64 { return 1 }) != nil {
+		t.Error("unexpected gauge")
+	}
+	registry.Stop()
+}
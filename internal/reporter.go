@@ -3,30 +3,90 @@ package internal
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
+	"crypto/tls"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
+
+	"github.com/wavefronthq/wavefront-sdk-go/version"
 )
 
 // The implementation of a Reporter that reports points directly to a Wavefront server.
 type reporter struct {
 	serverURL string
 	token     string
+	userAgent string
 	client    *http.Client
 }
 
+type ReporterOption func(*reporter)
+
+// SetUserAgentSuffix appends an application supplied identifier to the User-Agent of the requests,
+// after the SDK name and version.
+func SetUserAgentSuffix(suffix string) ReporterOption {
+	return func(reporter *reporter) {
+		if suffix != "" {
+			reporter.userAgent += " " + suffix
+		}
+	}
+}
+
+// SetTLSConfig sets the TLS configuration of the HTTPS requests.
+func SetTLSConfig(config *tls.Config) ReporterOption {
+	return func(reporter *reporter) {
+		reporter.transport().TLSClientConfig = config
+	}
+}
+
+// SetHTTPProxy sets the forward proxy of the requests, e.g. http.ProxyURL, HTTPS requests being tunneled with
+// HTTP CONNECT. The default transport uses the proxy of the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+func SetHTTPProxy(proxy func(*http.Request) (*url.URL, error)) ReporterOption {
+	return func(reporter *reporter) {
+		reporter.transport().Proxy = proxy
+	}
+}
+
+// SetHTTPClient sets the client sending the requests, e.g. to add tracing or custom middleware.
+// It replaces the TLS configuration and the proxy set by SetTLSConfig and SetHTTPProxy.
+func SetHTTPClient(client *http.Client) ReporterOption {
+	return func(reporter *reporter) {
+		reporter.client = client
+	}
+}
+
 // Newreporter create a metrics Reporter
-func NewReporter(server string, token string) Reporter {
-	return &reporter{
+func NewReporter(server string, token string, setters ...ReporterOption) Reporter {
+	r := &reporter{
 		serverURL: server,
 		token:     token,
+		userAgent: sdkUserAgent + version.Version,
 		client:    &http.Client{Timeout: time.Second * 10},
 	}
+	for _, setter := range setters {
+		setter(r)
+	}
+	return r
+}
+
+// transport returns the transport of the client, replacing the default one by a copy the first time.
+func (reporter *reporter) transport() *http.Transport {
+	if transport, ok := reporter.client.Transport.(*http.Transport); ok {
+		return transport
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	reporter.client.Transport = transport
+	return transport
 }
 
 func (reporter reporter) Report(format string, pointLines string) (*http.Response, error) {
+	return reporter.ReportCtx(context.Background(), format, pointLines)
+}
+
+func (reporter reporter) ReportCtx(ctx context.Context, format string, pointLines string) (*http.Response, error) {
 	if format == "" || pointLines == "" {
 		return nil, formatError
 	}
@@ -44,7 +104,7 @@ func (reporter reporter) Report(format string, pointLines string) (*http.Respons
 	}
 
 	apiURL := reporter.serverURL + reportEndpoint
-	req, err := http.NewRequest("POST", apiURL, &buf)
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, &buf)
 	if err != nil {
 		return &http.Response{}, err
 	}
@@ -63,12 +123,16 @@ func (reporter reporter) Report(format string, pointLines string) (*http.Respons
 }
 
 func (reporter reporter) ReportEvent(event string) (*http.Response, error) {
+	return reporter.ReportEventCtx(context.Background(), event)
+}
+
+func (reporter reporter) ReportEventCtx(ctx context.Context, event string) (*http.Response, error) {
 	if event == "" {
 		return nil, formatError
 	}
 
 	apiURL := reporter.serverURL + eventEndpoint
-	req, err := http.NewRequest("POST", apiURL, strings.NewReader(event))
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, strings.NewReader(event))
 	if err != nil {
 		return &http.Response{}, err
 	}
@@ -83,6 +147,7 @@ func (reporter reporter) ReportEvent(event string) (*http.Response, error) {
 }
 
 func (reporter reporter) execute(req *http.Request) (*http.Response, error) {
+	req.Header.Set(userAgentHeader, reporter.userAgent)
 	resp, err := reporter.client.Do(req)
 	if err != nil {
 		return resp, err
@@ -0,0 +1,28 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wavefronthq/wavefront-sdk-go/version"
+)
+
+func TestReporterUserAgent(t *testing.T) {
+	userAgents := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgents <- r.Header.Get("User-Agent")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	_, err := NewReporter(server.URL, "DUMMY_TOKEN").Report(MetricFormat, "\"foo.metric\" 1.2\n")
+	require.NoError(t, err)
+	assert.Equal(t, "wavefront-sdk-go/"+version.Version, <-userAgents)
+
+	_, err = NewReporter(server.URL, "DUMMY_TOKEN", SetUserAgentSuffix("my-app/1.2.3")).ReportEvent("{}")
+	require.NoError(t, err)
+	assert.Equal(t, "wavefront-sdk-go/"+version.Version+" my-app/1.2.3", <-userAgents)
+}
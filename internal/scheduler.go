@@ -0,0 +1,103 @@
+package internal
+
+import (
+	"sync"
+	"time"
+)
+
+// FlushScheduler flushes the handlers registered to it from a single goroutine and ticker, instead of each handler
+// running its own flush loop. The handlers are flushed one after the other on each tick, so a slow flush (e.g. a
+// report waiting for its timeout) delays the flushes of the next handlers. The goroutine runs while at least one
+// handler is registered.
+type FlushScheduler struct {
+	interval time.Duration
+
+	mtx     sync.Mutex
+	flushes []*scheduledFlush
+	ticker  *time.Ticker
+	done    chan struct{}
+
+	// held during each round of flushes, so that a handler isn't flushed once unregistered
+	round sync.Mutex
+}
+
+type scheduledFlush struct {
+	flush func()
+}
+
+// NewFlushScheduler returns a FlushScheduler flushing the registered handlers every interval.
+func NewFlushScheduler(interval time.Duration) *FlushScheduler {
+	return &FlushScheduler{interval: interval}
+}
+
+// register adds a flush function called on each tick, returning a function removing it. Once the returned function
+// returns, the flush function isn't called anymore.
+func (s *FlushScheduler) register(flush func()) (unregister func()) {
+	f := &scheduledFlush{flush: flush}
+
+	s.mtx.Lock()
+	s.flushes = append(s.flushes, f)
+	if s.ticker == nil {
+		s.ticker = time.NewTicker(s.interval)
+		s.done = make(chan struct{})
+		go s.run(s.ticker, s.done)
+	}
+	s.mtx.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { s.unregister(f) })
+	}
+}
+
+func (s *FlushScheduler) unregister(f *scheduledFlush) {
+	s.mtx.Lock()
+	for i, scheduled := range s.flushes {
+		if scheduled == f {
+			s.flushes = append(s.flushes[:i:i], s.flushes[i+1:]...)
+			break
+		}
+	}
+	if len(s.flushes) == 0 && s.ticker != nil {
+		s.ticker.Stop()
+		close(s.done)
+		s.ticker, s.done = nil, nil
+	}
+	s.mtx.Unlock()
+
+	// wait for the current round, which could still flush f
+	s.round.Lock()
+	s.round.Unlock()
+}
+
+func (s *FlushScheduler) run(ticker *time.Ticker, done chan struct{}) {
+	for {
+		select {
+		case <-ticker.C:
+			s.flushAll(done)
+		case <-done:
+			return
+		}
+	}
+}
+
+// flushAll flushes the registered handlers, unless the scheduler was stopped since the tick.
+func (s *FlushScheduler) flushAll(done chan struct{}) {
+	s.round.Lock()
+	defer s.round.Unlock()
+
+	s.mtx.Lock()
+	select {
+	case <-done:
+		s.mtx.Unlock()
+		return
+	default:
+	}
+	flushes := make([]*scheduledFlush, len(s.flushes))
+	copy(flushes, s.flushes)
+	s.mtx.Unlock()
+
+	for _, f := range flushes {
+		f.flush()
+	}
+}
@@ -0,0 +1,84 @@
+package internal
+
+import (
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlushSchedulerLineHandlers(t *testing.T) {
+	scheduler := NewFlushScheduler(10 * time.Millisecond)
+	handlers := make([]*LineHandler, 3)
+	for i := range handlers {
+		// the interval of the handlers is ignored
+		handlers[i] = NewLineHandler(&fakeReporter{}, "wavefront", time.Hour, 10, 100, SetHandlerFlushScheduler(scheduler))
+		handlers[i].Start()
+		require.NoError(t, handlers[i].HandleLine("dummyLine"))
+	}
+	for _, lh := range handlers {
+		assert.Eventually(t, func() bool { return lh.PendingLines() == 0 }, time.Second, time.Millisecond)
+	}
+
+	assert.NoError(t, handlers[0].Stop())
+	require.NoError(t, handlers[1].HandleLine("dummyLine"))
+	assert.Eventually(t, func() bool { return handlers[1].PendingLines() == 0 }, time.Second, time.Millisecond,
+		"the other handlers are still flushed")
+
+	assert.NoError(t, handlers[1].Stop())
+	assert.NoError(t, handlers[2].Stop())
+	scheduler.mtx.Lock()
+	defer scheduler.mtx.Unlock()
+	assert.Empty(t, scheduler.flushes)
+	assert.Nil(t, scheduler.ticker, "the loop stops with the last handler")
+}
+
+func TestFlushSchedulerProxyHandler(t *testing.T) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer lis.Close()
+	go func() {
+		conn, err := lis.Accept()
+		if err == nil {
+			ioutil.ReadAll(conn)
+		}
+	}()
+
+	scheduler := NewFlushScheduler(10 * time.Millisecond)
+	handler := NewProxyConnectionHandler(lis.Addr().String(), time.Hour, "points", NewMetricRegistry(nil),
+		SetFlushScheduler(scheduler)).(*ProxyConnectionHandler)
+	handler.Start()
+	assert.Nil(t, handler.done, "no flush loop without heartbeats")
+
+	require.NoError(t, handler.Connect())
+	require.NoError(t, handler.SendData("\"foo.metric\" 1.2 source=\"test\"\n"))
+	assert.Eventually(t, func() bool { return handler.PendingLines() == 0 }, time.Second, time.Millisecond)
+	assert.NoError(t, handler.Close())
+}
+
+func TestFlushSchedulerRestarts(t *testing.T) {
+	scheduler := NewFlushScheduler(10 * time.Millisecond)
+	flushed := make(chan struct{}, 1)
+	flush := func() {
+		select {
+		case flushed <- struct{}{}:
+		default:
+		}
+	}
+
+	scheduler.register(flush)()
+	select {
+	case <-flushed:
+	default:
+	}
+	unregister := scheduler.register(flush)
+	defer unregister()
+	select {
+	case <-flushed:
+	case <-time.After(time.Second):
+		t.Fatal("not flushed once registered again")
+	}
+}
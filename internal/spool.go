@@ -0,0 +1,178 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// spoolChunkBytes bounds the chunks of spooled lines replayed at once.
+var spoolChunkBytes = 64 * 1024
+
+// Spool is a file holding the lines a handler couldn't deliver, kept across restarts to be replayed once the data
+// can be sent again. Its size is bounded, the lines spilled once it's full being dropped.
+//
+// The lines are replayed in chunks: Peek returns the next chunk, Advance removes it once delivered. The offset of the
+// next chunk is kept in a file next to the spool, so that a process stopped while replaying resumes from it, the
+// chunk being replayed then being sent again. The replayed lines count in the size of the spool until it's all
+// replayed and removed.
+type Spool struct {
+	path     string
+	maxBytes int64
+
+	mtx sync.Mutex
+	// size of the file, -1 until it's known
+	size int64
+	// offset of the lines not yet replayed
+	offset int64
+}
+
+// NewSpool returns the spool of the handler named name in dir, e.g. "points.spool", holding up to maxBytes.
+// The lines spilled by a previous process are replayed by the handler.
+func NewSpool(dir, name string, maxBytes int64) *Spool {
+	return &Spool{path: filepath.Join(dir, name+".spool"), maxBytes: maxBytes, size: -1}
+}
+
+// load reads the size of the spool and the offset of the lines not yet replayed, left by a previous process.
+func (s *Spool) load() error {
+	if s.size >= 0 {
+		return nil
+	}
+	info, err := os.Stat(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("unable to open spool %s: %w", s.path, err)
+	}
+	s.size, s.offset = 0, 0
+	if err != nil {
+		// an offset left without spool is stale
+		os.Remove(s.offsetPath())
+		return nil
+	}
+	s.size = info.Size()
+	if data, err := ioutil.ReadFile(s.offsetPath()); err == nil {
+		offset, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+		if err == nil && 0 <= offset && offset <= s.size {
+			s.offset = offset
+		}
+	}
+	return nil
+}
+
+func (s *Spool) offsetPath() string {
+	return s.path + ".offset"
+}
+
+// Spill appends the newline terminated lines of data to the spool, returning the number of lines dropped
+// because the spool is full or the write failed.
+func (s *Spool) Spill(data []byte) (dropped int, err error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if err := s.load(); err != nil {
+		return countLines(data), err
+	}
+	// keep the first lines fitting in the spool
+	fits := 0
+	for fits < len(data) {
+		end := bytes.IndexByte(data[fits:], '\n')
+		if end < 0 || s.size+int64(fits+end+1) > s.maxBytes {
+			break
+		}
+		fits += end + 1
+	}
+	dropped = countLines(data[fits:])
+	if fits == 0 {
+		return dropped, nil
+	}
+
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return countLines(data), fmt.Errorf("unable to open spool %s: %w", s.path, err)
+	}
+	n, err := file.Write(data[:fits])
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	s.size += int64(n)
+	if err != nil {
+		return countLines(data), fmt.Errorf("unable to write spool %s: %w", s.path, err)
+	}
+	return dropped, nil
+}
+
+// Peek returns the next lines to replay, up to maxBytes unless the first line is longer, nil when they're all
+// replayed. The lines stay spooled until Advance is called.
+func (s *Spool) Peek(maxBytes int) ([]byte, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if s.offset >= s.size {
+		return nil, nil
+	}
+	file, err := os.Open(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read spool %s: %w", s.path, err)
+	}
+	defer file.Close()
+	if _, err := file.Seek(s.offset, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("unable to read spool %s: %w", s.path, err)
+	}
+
+	var chunk []byte
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// a partially written last line is skipped
+			return chunk, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read spool %s: %w", s.path, err)
+		}
+		if len(chunk) > 0 && len(chunk)+len(line) > maxBytes {
+			return chunk, nil
+		}
+		chunk = append(chunk, line...)
+	}
+}
+
+// Advance removes the n bytes of lines returned by Peek, once replayed. The spool is removed once it's all replayed.
+func (s *Spool) Advance(n int) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.offset += int64(n)
+	if s.offset < s.size {
+		if err := writeFileAtomic(s.offsetPath(), []byte(strconv.FormatInt(s.offset, 10))); err != nil {
+			return fmt.Errorf("unable to write spool offset %s: %w", s.offsetPath(), err)
+		}
+		return nil
+	}
+	s.size, s.offset = 0, 0
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("unable to remove spool %s: %w", s.path, err)
+	}
+	if err := os.Remove(s.offsetPath()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("unable to remove spool offset %s: %w", s.offsetPath(), err)
+	}
+	return nil
+}
+
+// writeFileAtomic replaces the file at path with data, a crash leaving either the previous or the new contents.
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
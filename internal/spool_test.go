@@ -0,0 +1,76 @@
+package internal
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wavefront-spool")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	spool := NewSpool(dir, "points", 20)
+	data, err := spool.Peek(100)
+	require.NoError(t, err)
+	assert.Nil(t, data)
+
+	dropped, err := spool.Spill([]byte("first\nsecond\n"))
+	require.NoError(t, err)
+	assert.Equal(t, 0, dropped)
+	// the lines beyond the max size are dropped
+	dropped, err = spool.Spill([]byte("third\nfourth\n"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, dropped)
+
+	// the lines are kept across processes
+	contents, err := ioutil.ReadFile(filepath.Join(dir, "points.spool"))
+	require.NoError(t, err)
+	assert.Equal(t, "first\nsecond\nthird\n", string(contents))
+	spool = NewSpool(dir, "points", 20)
+	dropped, err = spool.Spill([]byte("fourth\n"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, dropped)
+
+	data, err = spool.Peek(100)
+	require.NoError(t, err)
+	assert.Equal(t, "first\nsecond\nthird\n", string(data))
+	data, err = spool.Peek(2)
+	require.NoError(t, err)
+	assert.Equal(t, "first\n", string(data), "the first line is returned even when longer than the max")
+	data, err = spool.Peek(13)
+	require.NoError(t, err)
+	assert.Equal(t, "first\nsecond\n", string(data))
+	data, err = spool.Peek(13)
+	require.NoError(t, err)
+	assert.Equal(t, "first\nsecond\n", string(data), "the lines stay spooled until advanced")
+
+	// the lines replayed are skipped across processes
+	require.NoError(t, spool.Advance(len(data)))
+	spool = NewSpool(dir, "points", 20)
+	data, err = spool.Peek(100)
+	require.NoError(t, err)
+	assert.Equal(t, "third\n", string(data))
+
+	// the spool is removed once replayed
+	require.NoError(t, spool.Advance(len(data)))
+	for _, name := range []string{"points.spool", "points.spool.offset"} {
+		_, err = os.Stat(filepath.Join(dir, name))
+		assert.True(t, os.IsNotExist(err), name)
+	}
+	data, err = spool.Peek(100)
+	require.NoError(t, err)
+	assert.Nil(t, data)
+
+	dropped, err = spool.Spill([]byte("fourth\n"))
+	require.NoError(t, err)
+	assert.Equal(t, 0, dropped)
+	data, err = spool.Peek(100)
+	require.NoError(t, err)
+	assert.Equal(t, "fourth\n", string(data))
+}
@@ -0,0 +1,203 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// UDPHandler is a ConnectionHandler sending the lines to the proxy in UDP datagrams, fire-and-forget: the lines
+// of the datagrams lost on the way are not detected. When packing, the lines are buffered in a datagram sent once
+// full or flushed, otherwise each line is sent right away in its own datagram.
+type UDPHandler struct {
+	// keep this as first element of struct to guarantee 64-bit alignment on 32-bit machines.
+	failures         int64
+	address          string
+	maxDatagramBytes int
+	pack             bool
+	flushTicker      *time.Ticker
+	done             chan struct{}
+
+	mtx      sync.Mutex
+	conn     net.Conn
+	datagram []byte
+	pending  int
+
+	datagrams   *DeltaCounter
+	writeErrors *DeltaCounter
+	bytesSent   *DeltaCounter
+}
+
+// NewUDPHandler returns a handler sending the lines to the proxy at address ("host:port") in datagrams of at most
+// maxDatagramBytes, several lines being packed in each datagram when pack is set.
+func NewUDPHandler(address string, maxDatagramBytes int, pack bool, flushInterval time.Duration, prefix string, internalRegistry *MetricRegistry) ConnectionHandler {
+	return &UDPHandler{
+		address:          address,
+		maxDatagramBytes: maxDatagramBytes,
+		pack:             pack,
+		flushTicker:      time.NewTicker(flushInterval),
+		datagrams:        internalRegistry.NewDeltaCounter(prefix + ".datagrams"),
+		writeErrors:      internalRegistry.NewDeltaCounter(prefix + ".write.errors"),
+		bytesSent:        internalRegistry.NewDeltaCounter(prefix + ".bytes"),
+	}
+}
+
+func (handler *UDPHandler) Start() {
+	done := make(chan struct{})
+	handler.mtx.Lock()
+	handler.done = done
+	handler.mtx.Unlock()
+
+	go func() {
+		for {
+			select {
+			case <-handler.flushTicker.C:
+				if err := handler.Flush(); err != nil {
+					log.Println(err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+}
+
+// Connect resolves the address of the proxy. No packet is exchanged, an unreachable proxy going unnoticed.
+func (handler *UDPHandler) Connect() error {
+	handler.mtx.Lock()
+	defer handler.mtx.Unlock()
+
+	if handler.conn != nil {
+		return nil
+	}
+	conn, err := net.Dial("udp", handler.address)
+	if err != nil {
+		return fmt.Errorf("unable to connect to Wavefront proxy at address: %s, err: %q", handler.address, err)
+	}
+	handler.conn = conn
+	return nil
+}
+
+func (handler *UDPHandler) Connected() bool {
+	handler.mtx.Lock()
+	defer handler.mtx.Unlock()
+	return handler.conn != nil
+}
+
+// SendData sends or packs each of the newline terminated lines. The lines longer than a datagram are dropped,
+// returning an error.
+func (handler *UDPHandler) SendData(lines string) error {
+	handler.mtx.Lock()
+	defer handler.mtx.Unlock()
+
+	if handler.conn == nil {
+		return fmt.Errorf("not connected to Wavefront proxy at address: %s", handler.address)
+	}
+	var err error
+	for _, line := range strings.SplitAfter(lines, "\n") {
+		if line == "" {
+			continue
+		}
+		if len(line) > handler.maxDatagramBytes {
+			if err == nil {
+				err = fmt.Errorf("line of %d bytes exceeds the max datagram size of %d bytes", len(line), handler.maxDatagramBytes)
+			}
+			continue
+		}
+		if !handler.pack {
+			if writeErr := handler.write([]byte(line)); err == nil {
+				err = writeErr
+			}
+			continue
+		}
+		if len(handler.datagram)+len(line) > handler.maxDatagramBytes {
+			if writeErr := handler.write(handler.datagram); err == nil {
+				err = writeErr
+			}
+			handler.datagram, handler.pending = handler.datagram[:0], 0
+		}
+		handler.datagram = append(handler.datagram, line...)
+		handler.pending++
+	}
+	return err
+}
+
+// SendDataCtx sends the lines like SendData, which doesn't block: ctx is only checked before sending.
+func (handler *UDPHandler) SendDataCtx(ctx context.Context, lines string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return handler.SendData(lines)
+}
+
+// write sends a datagram, counting the write errors, e.g. when a previous datagram was refused by the host.
+func (handler *UDPHandler) write(datagram []byte) error {
+	if _, err := handler.conn.Write(datagram); err != nil {
+		handler.writeErrors.Inc()
+		atomic.AddInt64(&handler.failures, 1)
+		return fmt.Errorf("unable to send datagram to Wavefront proxy at address: %s, err: %q", handler.address, err)
+	}
+	handler.datagrams.Inc()
+	handler.bytesSent.Add(int64(len(datagram)))
+	return nil
+}
+
+// Flush sends the datagram of the packed lines, if any. Its lines are discarded when sending fails.
+func (handler *UDPHandler) Flush() error {
+	handler.mtx.Lock()
+	defer handler.mtx.Unlock()
+
+	if handler.conn == nil || len(handler.datagram) == 0 {
+		return nil
+	}
+	err := handler.write(handler.datagram)
+	handler.datagram, handler.pending = handler.datagram[:0], 0
+	return err
+}
+
+// FlushCtx flushes like Flush, which doesn't block: ctx is only checked before flushing.
+func (handler *UDPHandler) FlushCtx(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return handler.Flush()
+}
+
+func (handler *UDPHandler) Close() error {
+	handler.flushTicker.Stop()
+	handler.mtx.Lock()
+	done := handler.done
+	handler.done = nil
+	handler.mtx.Unlock()
+	if done != nil {
+		close(done)
+	}
+	err := handler.Flush()
+
+	handler.mtx.Lock()
+	defer handler.mtx.Unlock()
+	if handler.conn != nil {
+		handler.conn.Close()
+		handler.conn = nil
+	}
+	return err
+}
+
+func (handler *UDPHandler) PendingLines() int {
+	handler.mtx.Lock()
+	defer handler.mtx.Unlock()
+	return handler.pending
+}
+
+func (handler *UDPHandler) GetFailureCount() int64 {
+	return atomic.LoadInt64(&handler.failures)
+}
+
+func (handler *UDPHandler) ResetFailureCount() int64 {
+	return atomic.SwapInt64(&handler.failures, 0)
+}
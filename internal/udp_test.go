@@ -0,0 +1,77 @@
+package internal
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readDatagram(t *testing.T, conn net.PacketConn) string {
+	buf := make([]byte, 1024)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	return string(buf[:n])
+}
+
+func TestUDPHandlerPacking(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	registry := NewMetricRegistry(nil)
+	handler := NewUDPHandler(conn.LocalAddr().String(), 40, true, time.Hour, "points", registry)
+	require.NoError(t, handler.Connect())
+	assert.True(t, handler.Connected())
+
+	// the third line doesn't fit in the datagram of the first two, sent before packing it
+	require.NoError(t, handler.SendData("\"first.metric\" 1\n\"second.metric\" 2\n"))
+	require.NoError(t, handler.SendData("\"third.metric\" 3\n"))
+	assert.Equal(t, "\"first.metric\" 1\n\"second.metric\" 2\n", readDatagram(t, conn))
+	assert.Equal(t, 1, handler.PendingLines())
+
+	require.NoError(t, handler.Flush())
+	assert.Equal(t, "\"third.metric\" 3\n", readDatagram(t, conn))
+	assert.Equal(t, 0, handler.PendingLines())
+	assert.Equal(t, int64(2), registry.NewDeltaCounter("points.datagrams").Count())
+
+	err = handler.SendData("\"metric.with.a.very.long.name.exceeding.the.datagram\" 1\n")
+	assert.EqualError(t, err, "line of 56 bytes exceeds the max datagram size of 40 bytes")
+	assert.Equal(t, 0, handler.PendingLines())
+	assert.NoError(t, handler.Close())
+	assert.False(t, handler.Connected())
+}
+
+func TestUDPHandlerWithoutPacking(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	handler := NewUDPHandler(conn.LocalAddr().String(), 40, false, time.Hour, "points", NewMetricRegistry(nil))
+	assert.Error(t, handler.SendData("\"first.metric\" 1\n"), "not connected")
+	require.NoError(t, handler.Connect())
+
+	require.NoError(t, handler.SendData("\"first.metric\" 1\n\"second.metric\" 2\n"))
+	assert.Equal(t, 0, handler.PendingLines())
+	assert.Equal(t, "\"first.metric\" 1\n", readDatagram(t, conn))
+	assert.Equal(t, "\"second.metric\" 2\n", readDatagram(t, conn))
+	assert.NoError(t, handler.Close())
+}
+
+func TestUDPHandlerCloseTwice(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	handler := NewUDPHandler(conn.LocalAddr().String(), 40, true, time.Hour, "points", NewMetricRegistry(nil))
+	handler.Start()
+	require.NoError(t, handler.Connect())
+	require.NoError(t, handler.SendData("\"first.metric\" 1\n"))
+	assert.NoError(t, handler.Close())
+	assert.Equal(t, "\"first.metric\" 1\n", readDatagram(t, conn))
+	assert.NoError(t, handler.Close(), "closing again is a no-op")
+	assert.False(t, handler.Connected())
+}
@@ -0,0 +1,44 @@
+package senders
+
+import (
+	"strings"
+
+	"github.com/wavefronthq/wavefront-sdk-go/internal"
+)
+
+// deltaCounterMatcher tells from their names the metrics sent with SendMetric that are delta counters.
+// A nil matcher matches no metric.
+type deltaCounterMatcher struct {
+	suffixes []string
+	names    map[string]bool
+}
+
+// newDeltaCounterMatcher returns a matcher of the metric names ending with one of the suffixes or listed in names,
+// nil when there are neither.
+func newDeltaCounterMatcher(suffixes, names []string) *deltaCounterMatcher {
+	if len(suffixes) == 0 && len(names) == 0 {
+		return nil
+	}
+	m := &deltaCounterMatcher{suffixes: suffixes, names: make(map[string]bool, len(names))}
+	for _, name := range names {
+		m.names[name] = true
+	}
+	return m
+}
+
+// match returns whether the metric is sent as a delta counter. Names already having the delta prefix
+// are sent as is.
+func (m *deltaCounterMatcher) match(name string) bool {
+	if m == nil || internal.HasDeltaPrefix(name) {
+		return false
+	}
+	if m.names[name] {
+		return true
+	}
+	for _, suffix := range m.suffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+package senders
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeltaCounterSuffixes(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{
+		Host:                 "localhost",
+		MetricsPort:          50000,
+		DeltaCounterSuffixes: []string{".count"},
+		DeltaCounterNames:    []string{"errors"},
+	})
+	defer sender.Close()
+
+	require.NoError(t, sender.SendMetric("requests.count", 10, 1533529977, "test", nil))
+	require.NoError(t, sender.SendMetricNow("errors", 2, "test", nil))
+	require.NoError(t, sender.SendMetricNow("∆retries.count", 1, "test", nil))
+	require.NoError(t, sender.SendMetric("requests.latency", 42, 1533529977, "test", nil))
+	assert.Equal(t, "\"∆requests.count\" 10 source=\"test\"\n"+
+		"\"∆errors\" 2 source=\"test\"\n"+
+		"\"∆retries.count\" 1 source=\"test\"\n"+
+		"\"requests.latency\" 42 1533529977 source=\"test\"\n", handlers[metricHandler].data())
+
+	assert.False(t, (*deltaCounterMatcher)(nil).match("requests.count"))
+	assert.Nil(t, newDeltaCounterMatcher(nil, nil))
+}
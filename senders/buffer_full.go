@@ -0,0 +1,31 @@
+package senders
+
+import "github.com/wavefronthq/wavefront-sdk-go/internal"
+
+// BufferFullPolicy controls what a direct sender does with the data sent while the buffer of its signal is full,
+// e.g. when Wavefront is slow or unreachable.
+type BufferFullPolicy int
+
+const (
+	// ErrorWhenBufferFull drops the data and returns an error, counted by the "dropped" internal metric
+	// of the signal. This is the default.
+	ErrorWhenBufferFull BufferFullPolicy = iota
+	// DropWhenBufferFull drops the data without error, counted by the "buffer.dropped" internal metric
+	// of the signal. Suited to latency sensitive callers.
+	DropWhenBufferFull
+	// BlockWhenBufferFull blocks the send until a flush frees space in the buffer. Suited to batch jobs
+	// that can't lose data, the sends blocking as long as Wavefront can't be reached.
+	BlockWhenBufferFull
+)
+
+// handlerPolicy returns the line handler policy implementing the policy.
+func (policy BufferFullPolicy) handlerPolicy() internal.BufferFullPolicy {
+	switch policy {
+	case DropWhenBufferFull:
+		return internal.BufferFullDrop
+	case BlockWhenBufferFull:
+		return internal.BufferFullBlock
+	default:
+		return internal.BufferFullError
+	}
+}
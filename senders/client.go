@@ -1,7 +1,10 @@
 package senders
 
 import (
+	"context"
 	"fmt"
+	"log"
+	"net/http"
 	"os"
 	"strconv"
 	"time"
@@ -9,15 +12,23 @@ import (
 	"github.com/wavefronthq/wavefront-sdk-go/event"
 	"github.com/wavefronthq/wavefront-sdk-go/histogram"
 	"github.com/wavefronthq/wavefront-sdk-go/internal"
+	"github.com/wavefronthq/wavefront-sdk-go/version"
 )
 
-// Sender Interface for sending metrics, distributions and spans to Wavefront
+// Sender Interface for sending metrics, distributions and spans to Wavefront.
+//
+// The senders created by this package also implement optional interfaces, e.g. SignalFlusher or Drainer, that
+// callers type-assert, so that adding features doesn't break the other implementations of Sender.
 type Sender interface {
 	MetricSender
 	DistributionSender
 	SpanSender
 	EventSender
 	internal.Flusher
+
+	// Close stops the sender. The internal metrics registry is stopped first, then each handler
+	// stops its flush loop and flushes all its buffered data before its connection is closed.
+	// Errors from that final flush are logged, use CloseWithError (see ErrorCloser) to get them instead.
 	Close()
 }
 
@@ -31,27 +42,57 @@ type wavefrontSender struct {
 	eventHandler     *internal.LineHandler
 	internalRegistry *internal.MetricRegistry
 
-	pointsValid   *internal.DeltaCounter
-	pointsInvalid *internal.DeltaCounter
-	pointsDropped *internal.DeltaCounter
-
-	histogramsValid   *internal.DeltaCounter
-	histogramsInvalid *internal.DeltaCounter
-	histogramsDropped *internal.DeltaCounter
-
-	spansValid   *internal.DeltaCounter
-	spansInvalid *internal.DeltaCounter
-	spansDropped *internal.DeltaCounter
-
-	spanLogsValid   *internal.DeltaCounter
-	spanLogsInvalid *internal.DeltaCounter
-	spanLogsDropped *internal.DeltaCounter
-
-	eventsValid   *internal.DeltaCounter
-	eventsInvalid *internal.DeltaCounter
-	eventsDropped *internal.DeltaCounter
-
-	proxy bool
+	pointsValid               *internal.DeltaCounter
+	pointsInvalid             *internal.DeltaCounter
+	pointsDropped             *internal.DeltaCounter
+	pointsSuppressed          *internal.DeltaCounter
+	pointsTruncated           *internal.DeltaCounter
+	pointsReservedTagsDropped *internal.DeltaCounter
+	pointsClockSkew           *internal.DeltaCounter
+
+	histogramsValid      *internal.DeltaCounter
+	histogramsInvalid    *internal.DeltaCounter
+	histogramsDropped    *internal.DeltaCounter
+	histogramsSuppressed *internal.DeltaCounter
+
+	spansValid      *internal.DeltaCounter
+	spansInvalid    *internal.DeltaCounter
+	spansClockSkew  *internal.DeltaCounter
+	spansDropped    *internal.DeltaCounter
+	spansSampled    *internal.DeltaCounter
+	spansSuppressed *internal.DeltaCounter
+
+	spanLogsValid      *internal.DeltaCounter
+	spanLogsInvalid    *internal.DeltaCounter
+	spanLogsDropped    *internal.DeltaCounter
+	spanLogsSuppressed *internal.DeltaCounter
+	spanLogsSampled    *internal.DeltaCounter
+
+	eventsValid      *internal.DeltaCounter
+	eventsInvalid    *internal.DeltaCounter
+	eventsDropped    *internal.DeltaCounter
+	eventsSuppressed *internal.DeltaCounter
+	eventsThrottled  *internal.DeltaCounter
+
+	proxy           bool
+	disableSpanLogs bool
+	traceSampleRate float64
+	spanLogRate     float64
+	signals         signalSwitch
+	spanTagDedup    SpanTagDedupPolicy
+	deltaPrefix     DeltaPrefixVariant
+	autoDelta       *deltaCounterMatcher
+	clock           Clock
+	serializer      Serializer
+	skipInvalidTags bool
+	defaultHgs      map[histogram.Granularity]bool
+
+	timestampHorizon time.Duration
+	deltaAggregator  *deltaAggregator
+	lastValues       *lastValueAggregator
+	flushAges        *flushAges
+	eventLimiter     *eventLimiter
+	clockSkew        *clockSkewGuard
 }
 
 // newWavefrontClient creates and returns a Wavefront Client instance
@@ -65,56 +106,173 @@ func newWavefrontClient(cfg *configuration) (Sender, error) {
 	if cfg.FlushIntervalSeconds == 0 {
 		cfg.FlushIntervalSeconds = defaultFlushInterval
 	}
+	cfg.FlushScheduler = flushScheduler(cfg.FlushScheduler, cfg.SharedFlushLoop, time.Second*time.Duration(cfg.FlushIntervalSeconds))
+	if err := createSpoolDir(cfg.SpoolDir); err != nil {
+		return nil, err
+	}
 
-	reporter := internal.NewReporter(cfg.Server, cfg.Token)
+	reporterOptions := []internal.ReporterOption{internal.SetUserAgentSuffix(cfg.UserAgentSuffix)}
+	if cfg.HTTPClient != nil {
+		reporterOptions = append(reporterOptions, internal.SetHTTPClient(cfg.HTTPClient))
+	} else {
+		if tlsCfg := tlsConfig(cfg); tlsCfg != nil {
+			reporterOptions = append(reporterOptions, internal.SetTLSConfig(tlsCfg))
+		}
+		if cfg.HTTPProxy != nil {
+			reporterOptions = append(reporterOptions, internal.SetHTTPProxy(http.ProxyURL(cfg.HTTPProxy)))
+		}
+	}
+	reporter := internal.NewReporter(cfg.Server, cfg.Token, reporterOptions...)
 
 	sender := &wavefrontSender{
-		defaultSource: internal.GetHostname("wavefront_direct_sender"),
-		proxy:         len(cfg.Token) == 0,
+		defaultSource:   resolveSource(cfg.Source, cfg.SourceFunc, "wavefront_direct_sender"),
+		proxy:           len(cfg.Token) == 0,
+		disableSpanLogs: cfg.DisableSpanLogs,
+		traceSampleRate: traceSampleRate(cfg.TraceSampleRate, cfg.DropAllTraces),
+		spanLogRate:     sampleRate(cfg.SpanLogSampleRate),
+		spanTagDedup:    cfg.SpanTagDedup,
+		deltaPrefix:     cfg.DeltaPrefix,
+		autoDelta:       newDeltaCounterMatcher(cfg.DeltaCounterSuffixes, cfg.DeltaCounterNames),
+		clock:           cfg.Clock,
+		serializer:      cfg.Serializer,
+		skipInvalidTags: cfg.SkipInvalidTags,
+		defaultHgs:      cfg.DefaultHistogramGranularities,
 	}
-	sender.internalRegistry = internal.NewMetricRegistry(
-		sender,
+	if sender.clock == nil {
+		sender.clock = systemClock{}
+	}
+	sender.timestampHorizon = cfg.TimestampHorizon
+	if sender.timestampHorizon == 0 {
+		sender.timestampHorizon = defaultTimestampHorizon
+	}
+	if sender.serializer == nil {
+		sender.serializer = &lineSerializer{
+			histo: histoLineOptions{
+				alignTimestamps: cfg.AlignDistributionTimestamps,
+				maxLineBytes:    cfg.MaxHistogramLineBytes,
+				precision:       cfg.ValuePrecision,
+				maxCentroids:    cfg.MaxCentroidsPerDistribution,
+			},
+			eventsJSON:         !sender.proxy,
+			metricName:         cfg.MetricNameSanitizer,
+			maxMetricLineBytes: maxMetricLineBytes(cfg.MaxMetricLineBytes),
+			oversizedLines:     cfg.OversizedLines,
+			tags:               newTagCache(cfg.MaxInternedTags),
+			precision:          cfg.ValuePrecision,
+			spanSourceTag:      cfg.SpanSourceTag,
+			trimWhitespace:     cfg.TrimWhitespace,
+			maxSpanDuration:    cfg.MaxSpanDuration,
+			reservedTags:       cfg.ReservedTags,
+			truncated: func() {
+				sender.pointsTruncated.Inc()
+			},
+			reservedTagsDropped: func() {
+				sender.pointsReservedTagsDropped.Inc()
+			},
+		}
+	}
+	if cfg.TagValidator != nil {
+		sender.serializer = &tagValidatingSerializer{Serializer: sender.serializer, validate: cfg.TagValidator}
+	}
+	registryOptions := []internal.RegistryOption{
 		internal.SetPrefix("~sdk.go.core.sender.direct"),
 		internal.SetTag("pid", strconv.Itoa(os.Getpid())),
-	)
-	sender.pointHandler = newLineHandler(reporter, cfg, internal.MetricFormat, "points", sender.internalRegistry)
-	sender.histoHandler = newLineHandler(reporter, cfg, internal.HistogramFormat, "histograms", sender.internalRegistry)
-	sender.spanHandler = newLineHandler(reporter, cfg, internal.TraceFormat, "spans", sender.internalRegistry)
-	sender.spanLogHandler = newLineHandler(reporter, cfg, internal.SpanLogsFormat, "span_logs", sender.internalRegistry)
-	sender.eventHandler = newLineHandler(reporter, cfg, internal.EventFormat, "events", sender.internalRegistry)
+	}
+	for key, value := range cfg.RegistryTags {
+		registryOptions = append(registryOptions, internal.SetTag(key, value))
+	}
+	if cfg.Clock != nil {
+		registryOptions = append(registryOptions, internal.SetTimeSupplier(cfg.Clock.Now))
+	}
+	if !cfg.DisableInternalMetrics {
+		sender.internalRegistry = internal.NewMetricRegistry(sender, registryOptions...)
+	}
+
+	if sdkVersion, e := internal.GetSemVer(version.Version); e == nil {
+		sender.internalRegistry.NewGaugeFloat64("version", func() float64 {
+			return sdkVersion
+		})
+	}
+	sender.internalRegistry.NewGaugeFloat64("uptime.seconds", uptime(sender.clock))
+
+	ages := newFlushAges(sender.clock)
+	sender.flushAges = ages
+	for i := 0; i < handlersCount; i++ {
+		ages.register(sender.internalRegistry, SignalType(i))
+	}
+	sender.pointHandler = newLineHandler(reporter, cfg, MetricSignal, internal.MetricFormat, "points", sender.internalRegistry, ages)
+	sender.histoHandler = newLineHandler(reporter, cfg, HistogramSignal, internal.HistogramFormat, "histograms", sender.internalRegistry, ages)
+	sender.spanHandler = newLineHandler(reporter, cfg, SpanSignal, internal.TraceFormat, "spans", sender.internalRegistry, ages)
+	sender.spanLogHandler = newLineHandler(reporter, cfg, SpanSignal, internal.SpanLogsFormat, "span_logs", sender.internalRegistry, ages)
+	sender.eventHandler = newLineHandler(reporter, cfg, EventSignal, internal.EventFormat, "events", sender.internalRegistry, ages)
 
 	sender.pointsValid = sender.internalRegistry.NewDeltaCounter("points.valid")
 	sender.pointsInvalid = sender.internalRegistry.NewDeltaCounter("points.invalid")
 	sender.pointsDropped = sender.internalRegistry.NewDeltaCounter("points.dropped")
+	sender.pointsTruncated = sender.internalRegistry.NewDeltaCounter("points.truncated")
+	sender.pointsReservedTagsDropped = sender.internalRegistry.NewDeltaCounter("points.reserved_tags.dropped")
+	sender.pointsClockSkew = sender.internalRegistry.NewDeltaCounter("points.clock_skew")
+	sender.pointsSuppressed = sender.internalRegistry.NewDeltaCounter("points.suppressed")
 
 	sender.histogramsValid = sender.internalRegistry.NewDeltaCounter("histograms.valid")
 	sender.histogramsInvalid = sender.internalRegistry.NewDeltaCounter("histograms.invalid")
 	sender.histogramsDropped = sender.internalRegistry.NewDeltaCounter("histograms.dropped")
+	sender.histogramsSuppressed = sender.internalRegistry.NewDeltaCounter("histograms.suppressed")
 
 	sender.spansValid = sender.internalRegistry.NewDeltaCounter("spans.valid")
 	sender.spansInvalid = sender.internalRegistry.NewDeltaCounter("spans.invalid")
+	sender.spansClockSkew = sender.internalRegistry.NewDeltaCounter("spans.clock_skew")
 	sender.spansDropped = sender.internalRegistry.NewDeltaCounter("spans.dropped")
+	sender.spansSampled = sender.internalRegistry.NewDeltaCounter("spans.sampled_out")
+	sender.spansSuppressed = sender.internalRegistry.NewDeltaCounter("spans.suppressed")
 
 	sender.spanLogsValid = sender.internalRegistry.NewDeltaCounter("span_logs.valid")
 	sender.spanLogsInvalid = sender.internalRegistry.NewDeltaCounter("span_logs.invalid")
 	sender.spanLogsDropped = sender.internalRegistry.NewDeltaCounter("span_logs.dropped")
+	sender.spanLogsSuppressed = sender.internalRegistry.NewDeltaCounter("span_logs.suppressed")
+	sender.spanLogsSampled = sender.internalRegistry.NewDeltaCounter("span_logs.sampled_out")
 
 	sender.eventsValid = sender.internalRegistry.NewDeltaCounter("events.valid")
 	sender.eventsInvalid = sender.internalRegistry.NewDeltaCounter("events.invalid")
 	sender.eventsDropped = sender.internalRegistry.NewDeltaCounter("events.dropped")
+	sender.eventsSuppressed = sender.internalRegistry.NewDeltaCounter("events.suppressed")
+	sender.eventsThrottled = sender.internalRegistry.NewDeltaCounter("events.throttled")
+
+	if cfg.AggregateDeltaCounters {
+		sender.deltaAggregator = newDeltaAggregator(time.Second*time.Duration(cfg.FlushIntervalSeconds), sender.sendDelta)
+	}
+	sender.eventLimiter = newEventLimiter(cfg.MaxEventsPerMinute, sender.clock)
+	sender.clockSkew = newClockSkewGuard(cfg.MaxClockSkew, cfg.ClockSkew, sender.clock)
+	if cfg.DedupMetrics {
+		sender.lastValues = newLastValueAggregator(time.Second*time.Duration(cfg.FlushIntervalSeconds), sender.sendLastValue)
+	}
 
 	sender.Start()
+	if cfg.Registry != nil {
+		cfg.Registry.Register(sender)
+	}
 	return sender, nil
 }
 
-func newLineHandler(reporter internal.Reporter, cfg *configuration, format, prefix string, registry *internal.MetricRegistry) *internal.LineHandler {
+func newLineHandler(reporter internal.Reporter, cfg *configuration, signal SignalType, format, prefix string, registry *internal.MetricRegistry,
+	ages *flushAges) *internal.LineHandler {
 	flushInterval := time.Second * time.Duration(cfg.FlushIntervalSeconds)
 
-	opts := []internal.LineHandlerOption{internal.SetHandlerPrefix(prefix), internal.SetRegistry(registry)}
+	opts := []internal.LineHandlerOption{internal.SetHandlerPrefix(prefix), internal.SetRegistry(registry),
+		internal.SetBufferFullPolicy(cfg.BufferFull.handlerPolicy()), internal.SetHandlerOnFlush(ages.onFlush(signal, cfg.OnFlush))}
+	if cfg.FlushJitter {
+		opts = append(opts, internal.SetHandlerFlushJitter(flushInterval))
+	}
+	if cfg.FlushScheduler != nil {
+		opts = append(opts, internal.SetHandlerFlushScheduler(cfg.FlushScheduler.scheduler))
+	}
 	batchSize := cfg.BatchSize
 	if format == internal.EventFormat {
 		batchSize = 1
 		opts = append(opts, internal.SetLockOnThrottledError(true))
+	} else if spool := newSpool(cfg.SpoolDir, cfg.SpoolMaxBytes, prefix); spool != nil {
+		// the JSON events aren't newline terminated, they're not spooled
+		opts = append(opts, internal.SetHandlerSpool(spool))
 	}
 
 	return internal.NewLineHandler(reporter, format, flushInterval, batchSize, cfg.MaxBufferSize, opts...)
@@ -127,17 +285,94 @@ func (sender *wavefrontSender) Start() {
 	sender.spanLogHandler.Start()
 	sender.internalRegistry.Start()
 	sender.eventHandler.Start()
+	if sender.deltaAggregator != nil {
+		sender.deltaAggregator.start()
+	}
+	if sender.lastValues != nil {
+		sender.lastValues.start()
+	}
 }
 
 func (sender *wavefrontSender) SendMetric(name string, value float64, ts int64, source string, tags map[string]string) error {
-	line, err := MetricLine(name, value, ts, source, tags, sender.defaultSource)
+	return sender.SendMetricCtx(context.Background(), name, value, ts, source, tags)
+}
+
+func (sender *wavefrontSender) SendMetricCtx(ctx context.Context, name string, value float64, ts int64, source string, tags map[string]string) error {
+	if sender.autoDelta.match(name) {
+		return sender.SendDeltaCounterCtx(ctx, name, value, source, tags)
+	}
+	if err := sender.clockSkew.checkMetric(name, ts, sender.pointsClockSkew); err != nil {
+		sender.pointsInvalid.Inc()
+		return invalidData(err)
+	}
+	ts, err := metricTimestamp(ts, sender.clock.Now(), sender.timestampHorizon)
 	if err != nil {
 		sender.pointsInvalid.Inc()
-		return err
+		return invalidData(err)
+	}
+	return sender.dedupMetric(ctx, name, floatValue(value), ts, source, tags)
+}
+
+func (sender *wavefrontSender) SendIntMetric(name string, value int64, ts int64, source string, tags map[string]string) error {
+	return sender.SendIntMetricCtx(context.Background(), name, value, ts, source, tags)
+}
+
+func (sender *wavefrontSender) SendIntMetricCtx(ctx context.Context, name string, value int64, ts int64, source string, tags map[string]string) error {
+	if sender.autoDelta.match(name) {
+		return sender.SendDeltaCounterCtx(ctx, name, float64(value), source, tags)
+	}
+	if err := sender.clockSkew.checkMetric(name, ts, sender.pointsClockSkew); err != nil {
+		sender.pointsInvalid.Inc()
+		return invalidData(err)
+	}
+	ts, err := metricTimestamp(ts, sender.clock.Now(), sender.timestampHorizon)
+	if err != nil {
+		sender.pointsInvalid.Inc()
+		return invalidData(err)
+	}
+	return sender.dedupMetric(ctx, name, intValue(value), ts, source, tags)
+}
+
+func (sender *wavefrontSender) SendMetricNow(name string, value float64, source string, tags map[string]string) error {
+	return sender.SendMetricNowCtx(context.Background(), name, value, source, tags)
+}
+
+func (sender *wavefrontSender) SendMetricNowCtx(ctx context.Context, name string, value float64, source string, tags map[string]string) error {
+	if sender.autoDelta.match(name) {
+		return sender.SendDeltaCounterCtx(ctx, name, value, source, tags)
+	}
+	return sender.dedupMetric(ctx, name, floatValue(value), 0, source, tags)
+}
+
+// dedupMetric keeps the metric in the last value aggregator until the next flush when enabled, sends it otherwise.
+func (sender *wavefrontSender) dedupMetric(ctx context.Context, name string, value metricValue, ts int64, source string, tags map[string]string) error {
+	if sender.lastValues == nil {
+		return sender.sendMetric(ctx, name, value, ts, source, tags)
+	}
+	if sender.suppressed(MetricSignal, 1) {
+		return nil
+	}
+	if _, err := serializeMetric(sender.serializer, name, value, ts, source, tags, sender.defaultSource); err != nil {
+		sender.pointsInvalid.Inc()
+		return invalidResult(err, sender.skipInvalidTags)
+	}
+	sender.lastValues.add(name, value, ts, source, tags)
+	return nil
+}
+
+// sendMetric sends a metric with the given timestamp, 0 letting the server assign it.
+func (sender *wavefrontSender) sendMetric(ctx context.Context, name string, value metricValue, ts int64, source string, tags map[string]string) error {
+	if sender.suppressed(MetricSignal, 1) {
+		return nil
+	}
+	line, err := serializeMetric(sender.serializer, name, value, ts, source, tags, sender.defaultSource)
+	if err != nil {
+		sender.pointsInvalid.Inc()
+		return invalidResult(err, sender.skipInvalidTags)
 	} else {
 		sender.pointsValid.Inc()
 	}
-	err = sender.pointHandler.HandleLine(line)
+	err = sender.pointHandler.HandleLineCtx(ctx, line)
 	if err != nil {
 		sender.pointsDropped.Inc()
 	}
@@ -145,59 +380,169 @@ func (sender *wavefrontSender) SendMetric(name string, value float64, ts int64,
 }
 
 func (sender *wavefrontSender) SendDeltaCounter(name string, value float64, source string, tags map[string]string) error {
-	if name == "" {
+	return sender.SendDeltaCounterCtx(context.Background(), name, value, source, tags)
+}
+
+func (sender *wavefrontSender) SendDeltaCounterCtx(ctx context.Context, name string, value float64, source string, tags map[string]string) error {
+	if sender.suppressed(MetricSignal, 1) {
+		return nil
+	}
+	if err := checkName("metric", name); err != nil {
 		sender.pointsInvalid.Inc()
-		return fmt.Errorf("empty metric name")
+		return invalidData(err)
 	}
-	if !internal.HasDeltaPrefix(name) {
-		name = internal.DeltaCounterName(name)
+	name = deltaCounterName(name, sender.deltaPrefix)
+	if value <= 0 {
+		return nil
 	}
-	if value > 0 {
-		return sender.SendMetric(name, value, 0, source, tags)
+	if sender.deltaAggregator != nil {
+		if _, err := sender.serializer.MetricLine(name, value, 0, source, tags, sender.defaultSource); err != nil {
+			sender.pointsInvalid.Inc()
+			return invalidResult(err, sender.skipInvalidTags)
+		}
+		sender.deltaAggregator.add(name, value, source, tags)
+		return nil
 	}
-	return nil
+	return sender.sendMetric(ctx, name, floatValue(value), 0, source, tags)
+}
+
+// sendDelta sends a delta counter summed by the delta aggregator.
+func (sender *wavefrontSender) sendDelta(name string, value float64, source string, tags map[string]string) error {
+	return sender.sendMetric(context.Background(), name, floatValue(value), 0, source, tags)
+}
+
+// sendLastValue sends a metric kept by the last value aggregator.
+func (sender *wavefrontSender) sendLastValue(name string, value metricValue, ts int64, source string, tags map[string]string) error {
+	return sender.sendMetric(context.Background(), name, value, ts, source, tags)
+}
+
+func (sender *wavefrontSender) SendRawLine(line string) error {
+	return sender.SendRawLineCtx(context.Background(), line)
+}
+
+func (sender *wavefrontSender) SendRawLineCtx(ctx context.Context, line string) error {
+	return sender.SendRawLinesCtx(ctx, []string{line})
+}
+
+func (sender *wavefrontSender) SendRawLines(lines []string) error {
+	return sender.SendRawLinesCtx(context.Background(), lines)
+}
+
+func (sender *wavefrontSender) SendRawLinesCtx(ctx context.Context, lines []string) error {
+	if sender.suppressed(MetricSignal, len(lines)) {
+		return nil
+	}
+	checked := make([]string, len(lines))
+	for i, line := range lines {
+		l, err := rawLine(line)
+		if err != nil {
+			sender.pointsInvalid.Add(int64(len(lines)))
+			return invalidData(err)
+		}
+		checked[i] = l
+	}
+	sender.pointsValid.Add(int64(len(lines)))
+
+	var errors multiError
+	for _, line := range checked {
+		if err := sender.pointHandler.HandleLineCtx(ctx, line); err != nil {
+			sender.pointsDropped.Inc()
+			errors.add(err)
+		}
+	}
+	return errors.get()
+}
+
+func (sender *wavefrontSender) SendDistributionG(name string, centroids []histogram.Centroid, ts int64, source string, tags map[string]string, granularities ...histogram.Granularity) error {
+	return sender.SendDistributionGCtx(context.Background(), name, centroids, ts, source, tags, granularities...)
+}
+
+func (sender *wavefrontSender) SendDistributionGCtx(ctx context.Context, name string, centroids []histogram.Centroid, ts int64, source string, tags map[string]string, granularities ...histogram.Granularity) error {
+	return sender.SendDistributionCtx(ctx, name, centroids, histogram.Granularities(granularities...), ts, source, tags)
 }
 
 func (sender *wavefrontSender) SendDistribution(name string, centroids []histogram.Centroid,
 	hgs map[histogram.Granularity]bool, ts int64, source string, tags map[string]string) error {
-	line, err := HistoLine(name, centroids, hgs, ts, source, tags, sender.defaultSource)
+	return sender.SendDistributionCtx(context.Background(), name, centroids, hgs, ts, source, tags)
+}
+
+func (sender *wavefrontSender) SendDistributionCtx(ctx context.Context, name string, centroids []histogram.Centroid,
+	hgs map[histogram.Granularity]bool, ts int64, source string, tags map[string]string) error {
+	if sender.suppressed(HistogramSignal, 1) {
+		return nil
+	}
+	if len(hgs) == 0 {
+		hgs = sender.defaultHgs
+	}
+	line, err := sender.serializer.HistoLine(name, centroids, hgs, ts, source, tags, sender.defaultSource)
 	if err != nil {
 		sender.histogramsInvalid.Inc()
-		return err
+		return invalidResult(err, sender.skipInvalidTags)
 	} else {
 		sender.histogramsValid.Inc()
 	}
-	err = sender.histoHandler.HandleLine(line)
+	err = sender.histoHandler.HandleLineCtx(ctx, line)
 	if err != nil {
 		sender.histogramsDropped.Inc()
 	}
 	return err
 }
 
+func (sender *wavefrontSender) SendDistributions(dists []DistributionPoint) []error {
+	return sender.SendDistributionsCtx(context.Background(), dists)
+}
+
+func (sender *wavefrontSender) SendDistributionsCtx(ctx context.Context, dists []DistributionPoint) []error {
+	errs := make([]error, len(dists))
+	for i, dist := range dists {
+		errs[i] = sender.SendDistributionCtx(ctx, dist.Name, dist.Centroids, dist.Granularities, dist.Timestamp, dist.Source, dist.Tags)
+	}
+	return errs
+}
+
 func (sender *wavefrontSender) SendSpan(name string, startMillis, durationMillis int64, source, traceId, spanId string,
 	parents, followsFrom []string, tags []SpanTag, spanLogs []SpanLog) error {
-	line, err := SpanLine(name, startMillis, durationMillis, source, traceId, spanId, parents, followsFrom, tags, spanLogs, sender.defaultSource)
+	return sender.SendSpanCtx(context.Background(), name, startMillis, durationMillis, source, traceId, spanId, parents, followsFrom, tags, spanLogs)
+}
+
+func (sender *wavefrontSender) SendSpanCtx(ctx context.Context, name string, startMillis, durationMillis int64, source, traceId, spanId string,
+	parents, followsFrom []string, tags []SpanTag, spanLogs []SpanLog) error {
+	if sender.suppressed(SpanSignal, 1) {
+		if len(spanLogs) > 0 {
+			sender.spanLogsSuppressed.Inc()
+		}
+		return nil
+	}
+	if !sender.sampleSpan(traceId, spanLogs) {
+		return nil
+	}
+	spanLogs = sender.spanLogsToSend(spanId, spanLogs)
+	tags = dedupSpanTags(tags, sender.spanTagDedup)
+	line, err := sender.serializer.SpanLine(name, startMillis, durationMillis, source, traceId, spanId, parents, followsFrom, tags, spanLogs, sender.defaultSource)
+	if err == nil {
+		err = sender.clockSkew.checkSpan(name, startMillis, sender.spansClockSkew)
+	}
 	if err != nil {
 		sender.spansInvalid.Inc()
-		return err
+		return invalidResult(err, sender.skipInvalidTags)
 	} else {
 		sender.spansValid.Inc()
 	}
-	err = sender.spanHandler.HandleLine(line)
+	err = sender.spanHandler.HandleLineCtx(ctx, line)
 	if err != nil {
 		sender.spansDropped.Inc()
 		return err
 	}
 
 	if len(spanLogs) > 0 {
-		logs, err := SpanLogJSON(traceId, spanId, spanLogs)
+		logs, err := sender.serializer.SpanLogs(traceId, spanId, spanLogs)
 		if err != nil {
 			sender.spanLogsInvalid.Inc()
-			return err
+			return invalidData(err)
 		} else {
 			sender.spanLogsValid.Inc()
 		}
-		err = sender.spanLogHandler.HandleLine(logs)
+		err = sender.spanLogHandler.HandleLineCtx(ctx, logs)
 		if err != nil {
 			sender.spanLogsDropped.Inc()
 		}
@@ -206,21 +551,130 @@ func (sender *wavefrontSender) SendSpan(name string, startMillis, durationMillis
 	return nil
 }
 
+// sampleSpan applies head sampling to a span, counting it as dropped when its trace is not sampled.
+// The span logs of a dropped span are dropped along with it.
+func (sender *wavefrontSender) sampleSpan(traceId string, spanLogs []SpanLog) bool {
+	if traceSampled(traceId, sender.traceSampleRate) {
+		return true
+	}
+	sender.spansDropped.Inc()
+	sender.spansSampled.Inc()
+	if len(spanLogs) > 0 {
+		sender.spanLogsSampled.Inc()
+	}
+	return false
+}
+
+// spanLogsToSend drops the given span logs of a kept span when span logs are disabled or not sampled.
+func (sender *wavefrontSender) spanLogsToSend(spanId string, spanLogs []SpanLog) []SpanLog {
+	if len(spanLogs) == 0 {
+		return spanLogs
+	}
+	if sender.disableSpanLogs {
+		sender.spanLogsSuppressed.Inc()
+		return nil
+	}
+	if !spanLogsSampled(spanId, sender.spanLogRate) {
+		sender.spanLogsSampled.Inc()
+		return nil
+	}
+	return spanLogs
+}
+
+// SendSpans formats the lines of all the spans, then buffers them, and their span logs, as one batch of each.
+func (sender *wavefrontSender) SendSpans(spans []Span) []error {
+	return sender.SendSpansCtx(context.Background(), spans)
+}
+
+func (sender *wavefrontSender) SendSpansCtx(ctx context.Context, spans []Span) []error {
+	errs := make([]error, len(spans))
+	if sender.suppressed(SpanSignal, len(spans)) {
+		for _, span := range spans {
+			if len(span.SpanLogs) > 0 {
+				sender.spanLogsSuppressed.Inc()
+			}
+		}
+		return errs
+	}
+
+	// the lines to buffer, and the indexes of the spans they belong to
+	var spanLines, logLines []string
+	var written, withLogs []int
+	for i, span := range spans {
+		if !sender.sampleSpan(span.TraceId, span.SpanLogs) {
+			continue
+		}
+		spanLogs := sender.spanLogsToSend(span.SpanId, span.SpanLogs)
+		line, err := sender.serializer.SpanLine(span.Name, span.StartMillis, span.DurationMillis, span.Source, span.TraceId, span.SpanId,
+			span.Parents, span.FollowsFrom, dedupSpanTags(span.Tags, sender.spanTagDedup), spanLogs, sender.defaultSource)
+		if err == nil {
+			err = sender.clockSkew.checkSpan(span.Name, span.StartMillis, sender.spansClockSkew)
+		}
+		if err != nil {
+			sender.spansInvalid.Inc()
+			errs[i] = invalidResult(err, sender.skipInvalidTags)
+			continue
+		}
+		// the span logs are formatted first, a span whose span logs are invalid being rejected with them
+		var logs string
+		if len(spanLogs) > 0 {
+			if logs, err = sender.serializer.SpanLogs(span.TraceId, span.SpanId, spanLogs); err != nil {
+				sender.spansInvalid.Inc()
+				sender.spanLogsInvalid.Inc()
+				errs[i] = invalidData(err)
+				continue
+			}
+			sender.spanLogsValid.Inc()
+			logLines = append(logLines, logs)
+			withLogs = append(withLogs, i)
+		}
+		sender.spansValid.Inc()
+		spanLines = append(spanLines, line)
+		written = append(written, i)
+	}
+	if len(spanLines) == 0 {
+		return errs
+	}
+
+	if err := sender.spanHandler.HandleLinesCtx(ctx, spanLines); err != nil {
+		// the span logs are dropped along with their spans
+		sender.spansDropped.Add(int64(len(spanLines)))
+		sender.spanLogsDropped.Add(int64(len(logLines)))
+		for _, i := range written {
+			errs[i] = err
+		}
+		return errs
+	}
+	if len(logLines) > 0 {
+		if err := sender.spanLogHandler.HandleLinesCtx(ctx, logLines); err != nil {
+			sender.spanLogsDropped.Add(int64(len(logLines)))
+			for _, i := range withLogs {
+				errs[i] = err
+			}
+		}
+	}
+	return errs
+}
+
 func (sender *wavefrontSender) SendEvent(name string, startMillis, endMillis int64, source string, tags map[string]string, setters ...event.Option) error {
-	var line string
-	var err error
-	if sender.proxy {
-		line, err = EventLine(name, startMillis, endMillis, source, tags, setters...)
-	} else {
-		line, err = EventLineJSON(name, startMillis, endMillis, source, tags, setters...)
+	return sender.SendEventCtx(context.Background(), name, startMillis, endMillis, source, tags, setters...)
+}
+
+func (sender *wavefrontSender) SendEventCtx(ctx context.Context, name string, startMillis, endMillis int64, source string, tags map[string]string, setters ...event.Option) error {
+	if sender.suppressed(EventSignal, 1) {
+		return nil
 	}
+	line, err := sender.serializer.EventLine(name, startMillis, endMillis, source, tags, setters...)
 	if err != nil {
 		sender.eventsInvalid.Inc()
-		return err
-	} else {
-		sender.eventsValid.Inc()
+		return invalidResult(err, sender.skipInvalidTags)
+	}
+	if !sender.eventLimiter.allow() {
+		sender.eventsThrottled.Inc()
+		return nil
 	}
-	err = sender.eventHandler.HandleLine(line)
+	sender.eventsValid.Inc()
+	err = sender.eventHandler.HandleLineCtx(ctx, line)
 	if err != nil {
 		sender.eventsDropped.Inc()
 	}
@@ -228,33 +682,172 @@ func (sender *wavefrontSender) SendEvent(name string, startMillis, endMillis int
 }
 
 func (sender *wavefrontSender) Close() {
-	sender.pointHandler.Stop()
-	sender.histoHandler.Stop()
-	sender.spanHandler.Stop()
-	sender.spanLogHandler.Stop()
+	if err := sender.CloseWithError(); err != nil {
+		log.Println(err)
+	}
+}
+
+func (sender *wavefrontSender) CloseWithError() error {
 	sender.internalRegistry.Stop()
-	sender.eventHandler.Stop()
+
+	var errors multiError
+	if sender.deltaAggregator != nil {
+		if err := sender.deltaAggregator.stop(); err != nil {
+			errors.add(err)
+		}
+	}
+	if sender.lastValues != nil {
+		if err := sender.lastValues.stop(); err != nil {
+			errors.add(err)
+		}
+	}
+	handlers := []*internal.LineHandler{sender.pointHandler, sender.histoHandler, sender.spanHandler,
+		sender.spanLogHandler, sender.eventHandler}
+	for _, h := range handlers {
+		if err := h.Stop(); err != nil {
+			errors.add(err)
+		}
+	}
+	return errors.get()
+}
+
+// CloseCtx flushes the pending data within ctx before closing the sender.
+func (sender *wavefrontSender) CloseCtx(ctx context.Context) error {
+	var errors multiError
+	if err := sender.FlushCtx(ctx); err != nil {
+		errors.add(err)
+	}
+	if err := sender.CloseWithError(); err != nil {
+		errors.add(err)
+	}
+	return errors.get()
+}
+
+func (sender *wavefrontSender) SetSignalEnabled(signal SignalType, enabled bool) {
+	sender.signals.set(signal, enabled)
+}
+
+// suppressed returns whether the given signal is disabled, counting its count data items as suppressed if so.
+func (sender *wavefrontSender) suppressed(signal SignalType, count int) bool {
+	if sender.signals.enabled(signal) {
+		return false
+	}
+	switch signal {
+	case MetricSignal:
+		sender.pointsSuppressed.Add(int64(count))
+	case HistogramSignal:
+		sender.histogramsSuppressed.Add(int64(count))
+	case SpanSignal:
+		sender.spansSuppressed.Add(int64(count))
+	case EventSignal:
+		sender.eventsSuppressed.Add(int64(count))
+	}
+	return true
+}
+
+func (sender *wavefrontSender) FlushSignal(signal SignalType) error {
+	return sender.FlushSignalCtx(context.Background(), signal)
+}
+
+func (sender *wavefrontSender) FlushSignalCtx(ctx context.Context, signal SignalType) error {
+	switch signal {
+	case MetricSignal:
+		if sender.deltaAggregator != nil {
+			if err := sender.deltaAggregator.flush(); err != nil {
+				return err
+			}
+		}
+		if sender.lastValues != nil {
+			if err := sender.lastValues.flush(); err != nil {
+				return err
+			}
+		}
+		return sender.pointHandler.FlushCtx(ctx)
+	case HistogramSignal:
+		return sender.histoHandler.FlushCtx(ctx)
+	case SpanSignal:
+		var errors multiError
+		if err := sender.spanHandler.FlushCtx(ctx); err != nil {
+			errors.add(err)
+		}
+		if err := sender.spanLogHandler.FlushCtx(ctx); err != nil {
+			errors.add(err)
+		}
+		return errors.get()
+	case EventSignal:
+		return sender.eventHandler.FlushCtx(ctx)
+	default:
+		return fmt.Errorf("unknown signal type %v", signal)
+	}
+}
+
+func (sender *wavefrontSender) Drain(ctx context.Context) error {
+	return drain(ctx, func() error {
+		var errors multiError
+		if sender.deltaAggregator != nil {
+			if err := sender.deltaAggregator.flush(); err != nil {
+				errors.add(err)
+			}
+		}
+		if sender.lastValues != nil {
+			if err := sender.lastValues.flush(); err != nil {
+				errors.add(err)
+			}
+		}
+		handlers := []*internal.LineHandler{sender.pointHandler, sender.histoHandler, sender.spanHandler,
+			sender.spanLogHandler, sender.eventHandler}
+		for _, h := range handlers {
+			if err := h.FlushAll(); err != nil {
+				errors.add(err)
+			}
+		}
+		return errors.get()
+	})
+}
+
+func (sender *wavefrontSender) ReportInternalMetricsNow() error {
+	if sender.internalRegistry == nil {
+		return nil
+	}
+	if err := sender.internalRegistry.Report(); err != nil {
+		return err
+	}
+	return sender.FlushSignal(MetricSignal)
 }
 
 func (sender *wavefrontSender) Flush() error {
+	return sender.FlushCtx(context.Background())
+}
+
+func (sender *wavefrontSender) FlushCtx(ctx context.Context) error {
 	errStr := ""
-	err := sender.pointHandler.Flush()
+	if sender.deltaAggregator != nil {
+		if err := sender.deltaAggregator.flush(); err != nil {
+			errStr = errStr + err.Error() + "\n"
+		}
+	}
+	if sender.lastValues != nil {
+		if err := sender.lastValues.flush(); err != nil {
+			errStr = errStr + err.Error() + "\n"
+		}
+	}
+	err := sender.pointHandler.FlushCtx(ctx)
 	if err != nil {
 		errStr = errStr + err.Error() + "\n"
 	}
-	err = sender.histoHandler.Flush()
+	err = sender.histoHandler.FlushCtx(ctx)
 	if err != nil {
 		errStr = errStr + err.Error() + "\n"
 	}
-	err = sender.spanHandler.Flush()
+	err = sender.spanHandler.FlushCtx(ctx)
 	if err != nil {
 		errStr = errStr + err.Error()
 	}
-	err = sender.spanLogHandler.Flush()
+	err = sender.spanLogHandler.FlushCtx(ctx)
 	if err != nil {
 		errStr = errStr + err.Error()
 	}
-	err = sender.eventHandler.Flush()
+	err = sender.eventHandler.FlushCtx(ctx)
 	if err != nil {
 		errStr = errStr + err.Error()
 	}
@@ -264,6 +857,10 @@ func (sender *wavefrontSender) Flush() error {
 	return nil
 }
 
+func (sender *wavefrontSender) FlushWithStats() (FlushStats, error) {
+	return flushWithStats(sender.flushAges, sender.Flush, sender.PendingLines)
+}
+
 func (sender *wavefrontSender) GetFailureCount() int64 {
 	return sender.pointHandler.GetFailureCount() +
 		sender.histoHandler.GetFailureCount() +
@@ -271,3 +868,41 @@ func (sender *wavefrontSender) GetFailureCount() int64 {
 		sender.spanLogHandler.GetFailureCount() +
 		sender.eventHandler.GetFailureCount()
 }
+
+// FailureCountDelta returns the failure counts of the handlers and resets them.
+func (sender *wavefrontSender) FailureCountDelta() int64 {
+	return sender.pointHandler.ResetFailureCount() +
+		sender.histoHandler.ResetFailureCount() +
+		sender.spanHandler.ResetFailureCount() +
+		sender.spanLogHandler.ResetFailureCount() +
+		sender.eventHandler.ResetFailureCount()
+}
+
+func (sender *wavefrontSender) Source() string {
+	return sender.defaultSource
+}
+
+func (sender *wavefrontSender) PendingLines() map[string]int {
+	return map[string]int{
+		"points":     sender.pointHandler.PendingLines(),
+		"histograms": sender.histoHandler.PendingLines(),
+		"spans":      sender.spanHandler.PendingLines(),
+		"span_logs":  sender.spanLogHandler.PendingLines(),
+		"events":     sender.eventHandler.PendingLines(),
+	}
+}
+
+// ConnectionStatus reports a handler as disconnected when its last attempt to report data failed to reach Wavefront.
+func (sender *wavefrontSender) ConnectionStatus() map[string]bool {
+	return map[string]bool{
+		"points":     sender.pointHandler.Connected(),
+		"histograms": sender.histoHandler.Connected(),
+		"spans":      sender.spanHandler.Connected(),
+		"span_logs":  sender.spanLogHandler.Connected(),
+		"events":     sender.eventHandler.Connected(),
+	}
+}
+
+func (sender *wavefrontSender) now() time.Time {
+	return sender.clock.Now()
+}
@@ -0,0 +1,168 @@
+package senders
+
+import (
+	"context"
+
+	"github.com/wavefronthq/wavefront-sdk-go/event"
+	"github.com/wavefronthq/wavefront-sdk-go/histogram"
+)
+
+// ContextSender is a Sender whose sends and flushes can also be bounded by a context, e.g. to enforce a deadline
+// while a proxy connection is slow to accept writes, or while the buffer is full with BlockWhenBufferFull.
+//
+// The senders created by NewSender and NewProxySender pass the context down: its deadline bounds the writes to the proxy connections,
+// and the wait for room in a full buffer stops once it's done. The Ctx variants return the error of the context
+// without sending anything when it's already done.
+type ContextSender interface {
+	Sender
+
+	SendMetricCtx(ctx context.Context, name string, value float64, ts int64, source string, tags map[string]string) error
+	SendMetricNowCtx(ctx context.Context, name string, value float64, source string, tags map[string]string) error
+	SendIntMetricCtx(ctx context.Context, name string, value int64, ts int64, source string, tags map[string]string) error
+	SendDeltaCounterCtx(ctx context.Context, name string, value float64, source string, tags map[string]string) error
+	SendDistributionCtx(ctx context.Context, name string, centroids []histogram.Centroid, hgs map[histogram.Granularity]bool, ts int64, source string, tags map[string]string) error
+	SendDistributionGCtx(ctx context.Context, name string, centroids []histogram.Centroid, ts int64, source string, tags map[string]string, granularities ...histogram.Granularity) error
+	SendDistributionsCtx(ctx context.Context, dists []DistributionPoint) []error
+	SendSpanCtx(ctx context.Context, name string, startMillis, durationMillis int64, source, traceId, spanId string, parents, followsFrom []string, tags []SpanTag, spanLogs []SpanLog) error
+	SendSpansCtx(ctx context.Context, spans []Span) []error
+	SendRawLineCtx(ctx context.Context, line string) error
+	SendRawLinesCtx(ctx context.Context, lines []string) error
+	SendEventCtx(ctx context.Context, name string, startMillis, endMillis int64, source string, tags map[string]string, setters ...event.Option) error
+
+	FlushCtx(ctx context.Context) error
+	FlushSignalCtx(ctx context.Context, signal SignalType) error
+	// CloseCtx flushes the pending data within ctx, then closes the sender like CloseWithError.
+	CloseCtx(ctx context.Context) error
+}
+
+type contextSender struct {
+	wrappedSender
+}
+
+// NewContextSender adds the context variants of the methods of the given sender, see ContextSender.
+// The senders created by NewSender and NewProxySender are returned as is. Other senders, e.g. the ones created
+// by NewDirectSender or wrapped with Chain, only have the context checked before each call.
+func NewContextSender(inner Sender) ContextSender {
+	if cs, ok := inner.(ContextSender); ok {
+		return cs
+	}
+	return &contextSender{wrappedSender: wrappedSender{inner}}
+}
+
+// contextErrors returns the error of ctx for each of n items, nil when ctx isn't done.
+func contextErrors(ctx context.Context, n int) []error {
+	err := ctx.Err()
+	if err == nil {
+		return nil
+	}
+	errs := make([]error, n)
+	for i := range errs {
+		errs[i] = err
+	}
+	return errs
+}
+
+func (cs *contextSender) SendMetricCtx(ctx context.Context, name string, value float64, ts int64, source string, tags map[string]string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return cs.wrappedSender.SendMetric(name, value, ts, source, tags)
+}
+
+func (cs *contextSender) SendMetricNowCtx(ctx context.Context, name string, value float64, source string, tags map[string]string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return cs.wrappedSender.SendMetricNow(name, value, source, tags)
+}
+
+func (cs *contextSender) SendIntMetricCtx(ctx context.Context, name string, value int64, ts int64, source string, tags map[string]string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return cs.wrappedSender.SendIntMetric(name, value, ts, source, tags)
+}
+
+func (cs *contextSender) SendDeltaCounterCtx(ctx context.Context, name string, value float64, source string, tags map[string]string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return cs.wrappedSender.SendDeltaCounter(name, value, source, tags)
+}
+
+func (cs *contextSender) SendDistributionCtx(ctx context.Context, name string, centroids []histogram.Centroid, hgs map[histogram.Granularity]bool, ts int64, source string, tags map[string]string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return cs.wrappedSender.SendDistribution(name, centroids, hgs, ts, source, tags)
+}
+
+func (cs *contextSender) SendDistributionGCtx(ctx context.Context, name string, centroids []histogram.Centroid, ts int64, source string, tags map[string]string, granularities ...histogram.Granularity) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return cs.wrappedSender.SendDistributionG(name, centroids, ts, source, tags, granularities...)
+}
+
+func (cs *contextSender) SendDistributionsCtx(ctx context.Context, dists []DistributionPoint) []error {
+	if errs := contextErrors(ctx, len(dists)); errs != nil {
+		return errs
+	}
+	return cs.wrappedSender.SendDistributions(dists)
+}
+
+func (cs *contextSender) SendSpanCtx(ctx context.Context, name string, startMillis, durationMillis int64, source, traceId, spanId string, parents, followsFrom []string, tags []SpanTag, spanLogs []SpanLog) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return cs.wrappedSender.SendSpan(name, startMillis, durationMillis, source, traceId, spanId, parents, followsFrom, tags, spanLogs)
+}
+
+func (cs *contextSender) SendSpansCtx(ctx context.Context, spans []Span) []error {
+	if errs := contextErrors(ctx, len(spans)); errs != nil {
+		return errs
+	}
+	return cs.wrappedSender.SendSpans(spans)
+}
+
+func (cs *contextSender) SendRawLineCtx(ctx context.Context, line string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return cs.wrappedSender.SendRawLine(line)
+}
+
+func (cs *contextSender) SendRawLinesCtx(ctx context.Context, lines []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return cs.wrappedSender.SendRawLines(lines)
+}
+
+func (cs *contextSender) SendEventCtx(ctx context.Context, name string, startMillis, endMillis int64, source string, tags map[string]string, setters ...event.Option) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return cs.wrappedSender.SendEvent(name, startMillis, endMillis, source, tags, setters...)
+}
+
+func (cs *contextSender) FlushCtx(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return cs.wrappedSender.Flush()
+}
+
+func (cs *contextSender) FlushSignalCtx(ctx context.Context, signal SignalType) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return cs.wrappedSender.FlushSignal(signal)
+}
+
+func (cs *contextSender) CloseCtx(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return cs.wrappedSender.CloseWithError()
+}
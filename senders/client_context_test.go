@@ -0,0 +1,71 @@
+package senders
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContextSenderBufferFull(t *testing.T) {
+	sender, err := NewSender("http://DUMMY_TOKEN@localhost:1", FlushIntervalSeconds(3600), MaxBufferSize(1),
+		BufferFull(BlockWhenBufferFull))
+	require.NoError(t, err)
+	defer sender.Close()
+	cs := NewContextSender(sender)
+	assert.Equal(t, sender, cs, "the direct sender takes the context itself")
+
+	require.NoError(t, cs.SendMetric("foo.metric", 1.2, 0, "test_source", nil))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = cs.SendMetricCtx(ctx, "foo.metric", 1.2, 0, "test_source", nil)
+	assert.Equal(t, context.DeadlineExceeded, err, "the wait for room in the buffer stops with the context")
+
+	err = cs.SendRawLinesCtx(ctx, []string{"\"foo.metric\" 1.2 source=\"test_source\"\n"})
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "%v", err)
+	assert.Equal(t, 1, cs.(PendingLinesReporter).PendingLines()["points"])
+}
+
+func TestContextSenderProxy(t *testing.T) {
+	sender, fakes := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", MetricsPort: 2878})
+	defer sender.Close()
+	cs := NewContextSender(sender)
+	assert.Equal(t, sender, cs, "the proxy sender takes the context itself")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	require.NoError(t, cs.SendMetricCtx(ctx, "foo.metric", 1.2, 1533529977, "test_source", nil))
+	require.NoError(t, cs.FlushCtx(ctx))
+	cancel()
+	assert.Equal(t, context.Canceled, cs.SendMetricCtx(ctx, "bar.metric", 1.2, 1533529977, "test_source", nil))
+	assert.Equal(t, context.Canceled, cs.FlushCtx(ctx))
+	assert.Equal(t, "\"foo.metric\" 1.2 1533529977 source=\"test_source\"\n", fakes[0].data())
+	assert.Equal(t, 1, fakes[0].flushes)
+}
+
+func TestContextSenderCancelled(t *testing.T) {
+	inner := &fakeSender{}
+	sender := NewContextSender(inner)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, sender.SendMetricCtx(ctx, "foo.metric", 1.2, 0, "test_source", nil))
+	assert.Empty(t, inner.calls, "nothing is sent once the context is done")
+}
+
+func TestContextSenderPassThrough(t *testing.T) {
+	inner := &fakeSender{}
+	sender := NewContextSender(inner)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	assert.NoError(t, sender.SendMetricCtx(ctx, "foo.metric", 1.2, 0, "test_source", nil))
+	assert.NoError(t, sender.SendDeltaCounterCtx(context.Background(), "foo.count", 1, "test_source", nil))
+	assert.NoError(t, sender.SendEventCtx(ctx, "deploy", 0, 0, "test_source", nil))
+	assert.Len(t, inner.calls, 3)
+
+	inner.err, inner.failures = errors.New("proxy unreachable"), 1
+	assert.EqualError(t, sender.SendMetricCtx(ctx, "foo.metric", 1.2, 0, "test_source", nil), "proxy unreachable")
+}
@@ -1,15 +1,23 @@
 package senders
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/wavefronthq/wavefront-sdk-go/histogram"
 )
 
 // Option Wavefront client configuration options
 type Option func(*configuration)
 
-// Configuration for the direct ingestion sender
+// Configuration of the direct ingestion and proxy senders of NewSender
 type configuration struct {
 	Server string // Wavefront URL of the form https://<INSTANCE>.wavefront.com
 	Token  string // Wavefront API token with direct data ingestion permission
@@ -27,12 +35,52 @@ type configuration struct {
 	// defaults to 500,000. higher values could use more memory.
 	MaxBufferSize int
 
-	// interval (in seconds) at which to flush data to Wavefront. defaults to 1 Second.
-	// together with batch size controls the max theoretical throughput of the sender.
-	FlushIntervalSeconds int
+	// what is done with the data sent while the buffer of its signal is full. defaults to returning an error.
+	// the data is spilled instead with SpoolDir, unless blocking.
+	BufferFull BufferFullPolicy
+
+	// identifier of the application appended to the User-Agent of the requests, after the SDK name and version.
+	UserAgentSuffix string
+
+	// URL of the forward proxy of the requests, e.g. "http://proxy.corp:3128", HTTPS requests being tunneled with
+	// HTTP CONNECT. defaults to nil, the proxy of the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+	HTTPProxy *url.URL
+
+	// client sending the requests, e.g. instrumented for tracing. the TLS settings and HTTPProxy are ignored when set,
+	// configure the transport of the client instead. defaults to a client with a 10 seconds timeout.
+	HTTPClient *http.Client
+
+	// settings shared by the direct and proxy senders, and those of the proxy senders, set by the options.
+	// the host and ports are set by the URL of NewSender, the proxy settings being ignored by the direct senders.
+	ProxyConfiguration
+
+	// first error of the options, e.g. an invalid value, returned by NewSender.
+	err error
+}
+
+// proxyScheme is the scheme of the proxy URLs of NewSender, e.g. "proxy://localhost:2878".
+const proxyScheme = "proxy"
+
+// ErrGRPCNotSupported is returned by NewSender for the gRPC URLs, e.g. "grpc://localhost:4317": the Wavefront data
+// format ports of the proxies are plain TCP, their only gRPC listener taking OpenTelemetry (OTLP) messages.
+var ErrGRPCNotSupported = errors.New("grpc transport not supported, use a 'proxy' URL")
+
+// proxyPortParams are the query parameters of the proxy URLs setting the ports other than the metrics port.
+var proxyPortParams = map[string]func(cfg *ProxyConfiguration) *int{
+	"distributionPort": func(cfg *ProxyConfiguration) *int { return &cfg.DistributionPort },
+	"tracingPort":      func(cfg *ProxyConfiguration) *int { return &cfg.TracingPort },
+	"eventsPort":       func(cfg *ProxyConfiguration) *int { return &cfg.EventsPort },
+	"spanLogsPort":     func(cfg *ProxyConfiguration) *int { return &cfg.SpanLogsPort },
 }
 
-// NewSender creates Wavefront client
+// NewSender creates Wavefront client from the URL of either a Wavefront instance, with the API token of the direct
+// ingestion as user, e.g. "https://TOKEN@INSTANCE.wavefront.com", or a proxy, e.g. "proxy://localhost:2878".
+// The port of a proxy URL is its metrics port, the other ports being set by query parameters, e.g.
+// "proxy://localhost:2878?distributionPort=40000&tracingPort=30000&eventsPort=2878&spanLogsPort=30001".
+// The "tls=true" parameter encrypts the connections to the proxy, configured by the TLS options.
+// The options apply to both, except those specific to direct ingestion (BatchSize, MaxBufferSize, BufferFull,
+// UserAgentSuffix, HTTPProxy and WithHTTPClient), ignored by proxy senders, and those specific to proxies (e.g.
+// WriteTimeout or FlushFailure), ignored by direct senders.
 func NewSender(wfURL string, setters ...Option) (Sender, error) {
 	cfg := &configuration{}
 
@@ -41,8 +89,26 @@ func NewSender(wfURL string, setters ...Option) (Sender, error) {
 		return nil, err
 	}
 
+	if strings.EqualFold(u.Scheme, proxyScheme) {
+		for _, set := range setters {
+			set(cfg)
+		}
+		if cfg.err != nil {
+			return nil, cfg.err
+		}
+		proxyCfg, err := cfg.proxyConfiguration(u)
+		if err != nil {
+			return nil, err
+		}
+		return NewProxySender(proxyCfg)
+	}
+
+	if strings.EqualFold(u.Scheme, "grpc") || strings.EqualFold(u.Scheme, "grpcs") {
+		return nil, ErrGRPCNotSupported
+	}
+
 	if !strings.HasPrefix(strings.ToLower(u.Scheme), "http") {
-		return nil, fmt.Errorf("invalid schema '%s', only 'http' is supported", u.Scheme)
+		return nil, fmt.Errorf("invalid schema '%s', only 'http' and 'proxy' are supported", u.Scheme)
 	}
 
 	if len(u.User.String()) > 0 {
@@ -55,9 +121,59 @@ func NewSender(wfURL string, setters ...Option) (Sender, error) {
 	for _, set := range setters {
 		set(cfg)
 	}
+	if cfg.err != nil {
+		return nil, cfg.err
+	}
 	return newWavefrontClient(cfg)
 }
 
+// proxyConfiguration returns the configuration of the proxy sender of the proxy URL u and the options.
+func (cfg *configuration) proxyConfiguration(u *url.URL) (*ProxyConfiguration, error) {
+	if u.User != nil {
+		return nil, errors.New("invalid proxy URL: proxies take no token")
+	}
+	if u.Path != "" && u.Path != "/" {
+		return nil, fmt.Errorf("invalid proxy URL of %s: unexpected path %s", u.Host, u.Path)
+	}
+	proxyCfg := cfg.ProxyConfiguration
+	proxyCfg.Host = u.Hostname()
+	if port := u.Port(); port != "" {
+		metricsPort, err := strconv.Atoi(port)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL of %s: invalid port %s", u.Host, port)
+		}
+		proxyCfg.MetricsPort = metricsPort
+	}
+	for name, values := range u.Query() {
+		if name == "tls" {
+			enabled, err := strconv.ParseBool(values[len(values)-1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid proxy URL of %s: invalid tls %s", u.Host, values[len(values)-1])
+			}
+			proxyCfg.TLS = enabled
+			continue
+		}
+		param, ok := proxyPortParams[name]
+		if !ok {
+			return nil, fmt.Errorf("invalid proxy URL of %s: unknown parameter %s", u.Host, name)
+		}
+		port, err := strconv.Atoi(values[len(values)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL of %s: invalid %s %s", u.Host, name, values[len(values)-1])
+		}
+		*param(&proxyCfg) = port
+	}
+
+	return &proxyCfg, nil
+}
+
+// setErr records err as the error of the options unless an earlier option already failed.
+func (cfg *configuration) setErr(err error) {
+	if cfg.err == nil {
+		cfg.err = err
+	}
+}
+
 // BatchSize set max batch of data sent per flush interval. defaults to 10,000. recommended not to exceed 40,000.
 func BatchSize(n int) Option {
 	return func(cfg *configuration) {
@@ -72,9 +188,551 @@ func MaxBufferSize(n int) Option {
 	}
 }
 
+// BufferFull set what is done with the data sent while the buffer of its signal is full: dropped with an error
+// (ErrorWhenBufferFull, the default), dropped silently (DropWhenBufferFull) or sent once a flush frees space,
+// blocking the caller (BlockWhenBufferFull).
+func BufferFull(policy BufferFullPolicy) Option {
+	return func(cfg *configuration) {
+		cfg.BufferFull = policy
+	}
+}
+
+// Spool set the directory of the files the data that couldn't be delivered is spilled to instead of being dropped,
+// e.g. while Wavefront is unreachable or when the sender is closed, and the max size (in bytes) of the file of each
+// signal, 0 for the default of 64 MB. The data is replayed once it can be sent again, including by the next process
+// using the directory, which must not be shared between senders. Events aren't spooled.
+func Spool(dir string, maxBytes int64) Option {
+	return func(cfg *configuration) {
+		cfg.SpoolDir = dir
+		cfg.SpoolMaxBytes = maxBytes
+	}
+}
+
 // FlushIntervalSeconds set the interval (in seconds) at which to flush data to Wavefront. defaults to 1 Second.
 func FlushIntervalSeconds(n int) Option {
 	return func(cfg *configuration) {
 		cfg.FlushIntervalSeconds = n
 	}
 }
+
+// WithFlushInterval set the interval at which to flush data to Wavefront, as FlushIntervalSeconds.
+// NewSender rejects the intervals that aren't a positive whole number of seconds. defaults to 1 Second.
+func WithFlushInterval(interval time.Duration) Option {
+	return func(cfg *configuration) {
+		if interval < time.Second || interval%time.Second != 0 {
+			cfg.setErr(fmt.Errorf("invalid flush interval %s: must be a positive whole number of seconds", interval))
+			return
+		}
+		cfg.FlushIntervalSeconds = int(interval / time.Second)
+	}
+}
+
+// FlushJitter set whether the first flush of each handler is delayed by a random duration up to the flush interval,
+// spreading the load of a fleet of instances deployed at the same time. defaults to false.
+func FlushJitter(enabled bool) Option {
+	return func(cfg *configuration) {
+		cfg.FlushJitter = enabled
+	}
+}
+
+// SharedFlushLoop set whether the handlers of the sender are flushed by a single goroutine instead of a goroutine
+// each, the flush of a handler then waiting for those of the handlers before it. defaults to false.
+func SharedFlushLoop(enabled bool) Option {
+	return func(cfg *configuration) {
+		cfg.SharedFlushLoop = enabled
+	}
+}
+
+// WithFlushScheduler set the scheduler flushing the handlers of the sender along with those of the other senders
+// sharing it, at the interval of the scheduler. See FlushScheduler.
+func WithFlushScheduler(scheduler *FlushScheduler) Option {
+	return func(cfg *configuration) {
+		cfg.FlushScheduler = scheduler
+	}
+}
+
+// OnFlush set a function called after each flush of buffered lines, with the signal flushed, the number of lines
+// reported and failed, and the flush error, e.g. to feed flush outcomes into external accounting.
+func OnFlush(f func(signal SignalType, sent, failed int, err error)) Option {
+	return func(cfg *configuration) {
+		cfg.OnFlush = f
+	}
+}
+
+// DisableSpanLogs set whether span logs are dropped while their spans are still sent. defaults to false.
+func DisableSpanLogs(disable bool) Option {
+	return func(cfg *configuration) {
+		cfg.DisableSpanLogs = disable
+	}
+}
+
+// TraceSampleRate set the head sampling rate (0.0 - 1.0) of the traces whose spans are sent.
+// All the spans of a trace share the same sampling decision. defaults to 0, sending every span.
+func TraceSampleRate(rate float64) Option {
+	return func(cfg *configuration) {
+		cfg.TraceSampleRate = rate
+	}
+}
+
+// DropAllTraces set whether every trace is sampled out, its spans and span logs being dropped and counted by the
+// sampled_out internal metrics. defaults to false.
+func DropAllTraces(drop bool) Option {
+	return func(cfg *configuration) {
+		cfg.DropAllTraces = drop
+	}
+}
+
+// SpanLogSampleRate set the sampling rate (0.0 - 1.0) of the span logs of the spans kept by the trace sampling.
+// The span logs of the spans dropped by the trace sampling are always dropped. defaults to sending every span log.
+func SpanLogSampleRate(rate float64) Option {
+	return func(cfg *configuration) {
+		cfg.SpanLogSampleRate = rate
+	}
+}
+
+// Source set the source of the data sent without source. When not set, it's resolved from the first of:
+// SourceFunc, the WAVEFRONT_SOURCE environment variable, the hostname of the machine and "wavefront_direct_sender"
+// (or "wavefront_proxy_sender") to succeed. The resolved source is returned by SourceReporter.Source.
+func Source(source string) Option {
+	return func(cfg *configuration) {
+		cfg.Source = source
+	}
+}
+
+// SourceFunc set a function returning the source of the data sent without source when no Source is set, e.g.
+// from instance metadata. It's called once, when the sender is created. An error or an empty source falls back
+// to the WAVEFRONT_SOURCE environment variable, then to the hostname of the machine.
+func SourceFunc(f func() (string, error)) Option {
+	return func(cfg *configuration) {
+		cfg.SourceFunc = f
+	}
+}
+
+// SpanSourceTag set the key of a span tag (e.g. "host") whose value is the source of the spans sent without
+// source, e.g. to map a resource attribute to the source. An explicit source takes precedence, then the tag,
+// then the default source of the sender. The tag is still sent with the span.
+func SpanSourceTag(key string) Option {
+	return func(cfg *configuration) {
+		cfg.SpanSourceTag = key
+	}
+}
+
+// TrimWhitespace set whether the surrounding whitespace of the metric, distribution, span and event names and
+// of their tag keys and values is trimmed, so that a stray space from a configuration doesn't split a series
+// in two. defaults to false.
+func TrimWhitespace(enabled bool) Option {
+	return func(cfg *configuration) {
+		cfg.TrimWhitespace = enabled
+	}
+}
+
+// ReservedTags set what is done with the metric tags whose key is reserved ("source" or "host"), colliding with
+// the source argument: sent as is (KeepReservedTags, the default), dropped and counted (DropReservedTags)
+// or rejected with an error (RejectReservedTags).
+func ReservedTags(policy ReservedTagPolicy) Option {
+	return func(cfg *configuration) {
+		cfg.ReservedTags = policy
+	}
+}
+
+// MaxSpanDuration set the max duration of the spans, longer ones (usually from a clock bug) being rejected
+// as invalid. Negative durations are always rejected. defaults to 0, no limit.
+func MaxSpanDuration(max time.Duration) Option {
+	return func(cfg *configuration) {
+		cfg.MaxSpanDuration = max
+	}
+}
+
+// SpanTagDedup set how span tags with duplicate keys are handled. defaults to keeping the last tag of each key.
+func SpanTagDedup(policy SpanTagDedupPolicy) Option {
+	return func(cfg *configuration) {
+		cfg.SpanTagDedup = policy
+	}
+}
+
+// AlignDistributionTimestamps set whether distribution timestamps are aligned on the start of their granularity bin
+// (minute, hour or day) before being sent. defaults to false.
+func AlignDistributionTimestamps(align bool) Option {
+	return func(cfg *configuration) {
+		cfg.AlignDistributionTimestamps = align
+	}
+}
+
+// WithClock set the source of the current time used by the sender, e.g. to get deterministic timestamps in tests.
+// When set, internal metrics are timestamped with it. defaults to the system clock.
+func WithClock(clock Clock) Option {
+	return func(cfg *configuration) {
+		cfg.Clock = clock
+	}
+}
+
+// WithSerializer set the encoder of the data sent by the sender. defaults to the Wavefront data formats.
+// AlignDistributionTimestamps only applies to the default serializer.
+func WithSerializer(serializer Serializer) Option {
+	return func(cfg *configuration) {
+		cfg.Serializer = serializer
+	}
+}
+
+// WithHTTPClient set the client sending the requests of the direct sender, e.g. to add tracing, metrics or
+// retries with a custom http.RoundTripper. The TLS and proxy options are ignored, configure the transport of the
+// client instead.
+// defaults to a client with a 10 seconds timeout.
+func WithHTTPClient(client *http.Client) Option {
+	return func(cfg *configuration) {
+		cfg.HTTPClient = client
+	}
+}
+
+// WithRegistry set the registry the sender is added to on construction, to be flushed or closed with the other
+// registered senders, e.g. on the graceful shutdown of the application.
+func WithRegistry(registry *Registry) Option {
+	return func(cfg *configuration) {
+		cfg.Registry = registry
+	}
+}
+
+// TimestampHorizon set how far in the future metric timestamps are accepted. defaults to 24 hours.
+// Later timestamps usually denote a unit mismatch and are rejected.
+func TimestampHorizon(horizon time.Duration) Option {
+	return func(cfg *configuration) {
+		cfg.TimestampHorizon = horizon
+	}
+}
+
+// WithRegistryReportingDisabled disables the internal metrics of the sender, which then reports no ~sdk.go.core.* metrics.
+func WithRegistryReportingDisabled() Option {
+	return func(cfg *configuration) {
+		cfg.DisableInternalMetrics = true
+	}
+}
+
+// AggregateDeltaCounters set whether delta counters sharing the same name, source and tags are summed client side
+// and sent as a single point per flush interval. defaults to false.
+func AggregateDeltaCounters(aggregate bool) Option {
+	return func(cfg *configuration) {
+		cfg.AggregateDeltaCounters = aggregate
+	}
+}
+
+// DedupMetrics set whether metrics sent with SendMetric or SendMetricNow sharing the same name, source and tags
+// keep only their last value, sent as a single point per flush interval. defaults to false.
+func DedupMetrics(dedup bool) Option {
+	return func(cfg *configuration) {
+		cfg.DedupMetrics = dedup
+	}
+}
+
+// MaxClockSkew set the max difference between the timestamps of the metrics and spans sent and the current time,
+// and what is done with the data further off: counted by the "points.clock_skew" and "spans.clock_skew" internal
+// metrics (CountClockSkew), also logged (LogClockSkew) or rejected with an error (RejectClockSkew). Catches the
+// timestamps in the wrong unit or timezone. defaults to 0, no check.
+func MaxClockSkew(max time.Duration, policy ClockSkewPolicy) Option {
+	return func(cfg *configuration) {
+		cfg.MaxClockSkew = max
+		cfg.ClockSkew = policy
+	}
+}
+
+// MaxEventsPerMinute set the max events sent per minute, the excess events being dropped without error and counted
+// by the "events.throttled" internal metric. Metrics and the other signals are not limited. defaults to 0, no limit.
+func MaxEventsPerMinute(max int) Option {
+	return func(cfg *configuration) {
+		cfg.MaxEventsPerMinute = max
+	}
+}
+
+// DeltaCounterSuffixes set name suffixes (e.g. ".count") of the metrics sent with SendMetric or SendMetricNow
+// as delta counters, as if sent with SendDeltaCounter. Their timestamp is dropped, and their value must be the
+// increment since the last report: sending cumulative values would sum them again. defaults to none.
+func DeltaCounterSuffixes(suffixes ...string) Option {
+	return func(cfg *configuration) {
+		cfg.DeltaCounterSuffixes = suffixes
+	}
+}
+
+// DeltaPrefix set the variant of the prefix of the delta counter names, e.g. GreekDeltaPrefix for the
+// systems expecting "Δ". Names already starting with either variant are sent as is. defaults to IncrementDeltaPrefix, "∆".
+func DeltaPrefix(variant DeltaPrefixVariant) Option {
+	return func(cfg *configuration) {
+		cfg.DeltaPrefix = variant
+	}
+}
+
+// DeltaCounterNames set the names of metrics sent with SendMetric or SendMetricNow as delta counters,
+// see DeltaCounterSuffixes. defaults to none.
+func DeltaCounterNames(names ...string) Option {
+	return func(cfg *configuration) {
+		cfg.DeltaCounterNames = names
+	}
+}
+
+// MaxHistogramLineBytes set the max size (in bytes) of a distribution line. Larger distributions are split
+// in several lines, each with part of the centroids. defaults to no limit.
+func MaxHistogramLineBytes(n int) Option {
+	return func(cfg *configuration) {
+		cfg.MaxHistogramLineBytes = n
+	}
+}
+
+// MaxCentroidsPerDistribution set the max centroids of a distribution, e.g. to stay within the limits of the backend.
+// Larger distributions are split in lines of at most n centroids when MaxHistogramLineBytes is set, and rejected
+// as invalid otherwise. defaults to no limit.
+func MaxCentroidsPerDistribution(n int) Option {
+	return func(cfg *configuration) {
+		cfg.MaxCentroidsPerDistribution = n
+	}
+}
+
+// ValuePrecision set the number of significant digits of the metric and centroid values, e.g. 15 to send 0.3
+// instead of 0.30000000000000004. The digits of the integer part are always kept. defaults to full precision.
+func ValuePrecision(digits int) Option {
+	return func(cfg *configuration) {
+		cfg.ValuePrecision = digits
+	}
+}
+
+// MaxMetricLineBytes set the max size (in bytes) of a metric line, a negative value disabling the limit.
+// defaults to 32768, the max line length of the proxy.
+func MaxMetricLineBytes(n int) Option {
+	return func(cfg *configuration) {
+		cfg.MaxMetricLineBytes = n
+	}
+}
+
+// OversizedLines set how the metric lines longer than MaxMetricLineBytes are handled: rejected with an error
+// (RejectOversizedLines, the default) or sent with their longest tag values truncated (TruncateOversizedTags).
+func OversizedLines(policy OversizedLinePolicy) Option {
+	return func(cfg *configuration) {
+		cfg.OversizedLines = policy
+	}
+}
+
+// MaxInternedTags set the max number of tag keys, and of tag values, whose formatted form is interned and reused
+// across metric lines. Interning reduces the allocations when the same tags are sent repeatedly, at the cost of
+// the memory holding them and of a lookup per tag. defaults to 0, no interning.
+func MaxInternedTags(n int) Option {
+	return func(cfg *configuration) {
+		cfg.MaxInternedTags = n
+	}
+}
+
+// TagValidator set a function called with the key and value of each tag of the data sent, e.g. to enforce
+// naming conventions. Data with a tag it rejects is counted invalid, not sent, and the error returned.
+func TagValidator(validator func(key, value string) error) Option {
+	return func(cfg *configuration) {
+		cfg.TagValidator = validator
+	}
+}
+
+// SkipInvalidTags set whether data rejected by the TagValidator is skipped silently instead of
+// returning the validation error. defaults to false.
+func SkipInvalidTags(skip bool) Option {
+	return func(cfg *configuration) {
+		cfg.SkipInvalidTags = skip
+	}
+}
+
+// DefaultHistogramGranularities set the granularities of the distributions sent with a nil or empty
+// granularity set. Explicit granularities take precedence. defaults to none, such distributions being rejected.
+func DefaultHistogramGranularities(hgs ...histogram.Granularity) Option {
+	return func(cfg *configuration) {
+		cfg.DefaultHistogramGranularities = histogram.Granularities(hgs...)
+	}
+}
+
+// UserAgentSuffix set an identifier of the application appended to the User-Agent of the requests,
+// which identifies the SDK and its version, e.g. "my-app/1.2.3".
+func UserAgentSuffix(suffix string) Option {
+	return func(cfg *configuration) {
+		cfg.UserAgentSuffix = suffix
+	}
+}
+
+// MetricNameSanitizer set a function rewriting the names of the metrics sent, e.g. OpenMetricsNameSanitizer.
+// Delta counter prefixes are kept and internal metrics are not rewritten. Only applies to the default serializer.
+func MetricNameSanitizer(sanitize func(name string) string) Option {
+	return func(cfg *configuration) {
+		cfg.MetricNameSanitizer = sanitize
+	}
+}
+
+// TLSClientCertificate add a client certificate presented to servers requiring mutual TLS,
+// e.g. loaded with tls.LoadX509KeyPair.
+func TLSClientCertificate(cert tls.Certificate) Option {
+	return func(cfg *configuration) {
+		cfg.TLSClientCertificates = append(cfg.TLSClientCertificates, cert)
+	}
+}
+
+// TLSRootCAs set the certificate authorities used to verify the server certificate. defaults to the host's root CAs.
+func TLSRootCAs(pool *x509.CertPool) Option {
+	return func(cfg *configuration) {
+		cfg.TLSRootCAs = pool
+	}
+}
+
+// TLSInsecureSkipVerify set whether the server certificate is not verified, e.g. for self-signed development
+// proxies. Never use it in production. defaults to false.
+func TLSInsecureSkipVerify(skip bool) Option {
+	return func(cfg *configuration) {
+		cfg.TLSInsecureSkipVerify = skip
+	}
+}
+
+// WithTLSConfig set the TLS configuration of the requests, e.g. with a min version, a server name or a private CA.
+// The other TLS options are ignored when set.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(cfg *configuration) {
+		cfg.TLSConfig = config
+	}
+}
+
+// HTTPProxy set the forward proxy of the requests of the direct sender, e.g. "http://proxy.corp:3128", HTTPS
+// requests being tunneled with HTTP CONNECT. defaults to the proxy of the HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+// environment variables.
+func HTTPProxy(proxyURL *url.URL) Option {
+	return func(cfg *configuration) {
+		cfg.HTTPProxy = proxyURL
+	}
+}
+
+// RegistryTags set additional tags, e.g. an instance_id, on the internal metrics reported by the sender.
+func RegistryTags(tags map[string]string) Option {
+	return func(cfg *configuration) {
+		cfg.RegistryTags = tags
+	}
+}
+
+// UnixSocket set the path of a Unix domain socket the proxy listens on, e.g. "/var/run/wavefront.sock", the data of
+// all the signals being written to the socket instead of the host and ports of the proxy URL. Only applies to proxy
+// senders, whose URL still needs a port. defaults to "", TCP.
+func UnixSocket(path string) Option {
+	return func(cfg *configuration) {
+		cfg.UnixSocket = path
+	}
+}
+
+// MetricsUDP set whether the metrics are sent to the metrics port of the proxy over UDP, fire-and-forget, in
+// datagrams of at most maxDatagramBytes (0 for the default of 1432), each line in its own datagram unless pack is
+// set. Only applies to proxy senders. defaults to false, TCP.
+func MetricsUDP(enabled bool, maxDatagramBytes int, pack bool) Option {
+	return func(cfg *configuration) {
+		cfg.MetricsUDP = enabled
+		cfg.MaxDatagramBytes = maxDatagramBytes
+		cfg.PackDatagrams = pack
+	}
+}
+
+// MetricsRoutes set the additional metrics ports of the proxy sender, by route key, and the function selecting the
+// route of each metric, those routed to "" or to a missing key being sent to the metrics port of the proxy URL.
+// Only applies to proxy senders. defaults to none.
+func MetricsRoutes(routes map[string]string, route RouteFunc) Option {
+	return func(cfg *configuration) {
+		cfg.MetricsRoutes = routes
+		cfg.RouteFunc = route
+	}
+}
+
+// Greeting set a greeting line identifying the application sent to the proxy on each connection, "" for the
+// default of "#wavefront-sdk-go <version>". Older proxies reject it as an invalid line. Only applies to proxy senders.
+// defaults to sending none.
+func Greeting(line string) Option {
+	return func(cfg *configuration) {
+		cfg.SendGreeting = true
+		cfg.Greeting = line
+	}
+}
+
+// FlushBatchSize set the number of lines buffered for a port of the proxy beyond which they're flushed right away,
+// in addition to the periodic flushes. Only applies to proxy senders. defaults to 0, only flushing periodically.
+func FlushBatchSize(n int) Option {
+	return func(cfg *configuration) {
+		cfg.FlushBatchSize = n
+	}
+}
+
+// KeepAlive set the TCP keep-alive period of the connections to the proxy, a negative period disabling it.
+// Only applies to proxy senders. defaults to the Go default (15 seconds).
+func KeepAlive(period time.Duration) Option {
+	return func(cfg *configuration) {
+		cfg.KeepAlive = period
+	}
+}
+
+// TLSServerName set the name the certificate of the proxy is verified against when its URL has the "tls=true"
+// parameter. Required over a UnixSocket. Only applies to proxy senders. defaults to the host of the proxy URL.
+func TLSServerName(name string) Option {
+	return func(cfg *configuration) {
+		cfg.TLSServerName = name
+	}
+}
+
+// WriteTimeout set the deadline of each write to the proxy, a write blocked longer failing and resetting the
+// connection. A negative timeout disables it. Only applies to proxy senders. defaults to 10 seconds.
+func WriteTimeout(timeout time.Duration) Option {
+	return func(cfg *configuration) {
+		cfg.WriteTimeout = timeout
+	}
+}
+
+// ReadResponses set whether the lines written back by the proxy are read, the error responses being counted by the
+// <signal>.report.errors internal metrics, and logged when logErrors is set. Only applies to proxy senders.
+// defaults to false.
+func ReadResponses(read, logErrors bool) Option {
+	return func(cfg *configuration) {
+		cfg.ReadResponses = read
+		cfg.LogResponseErrors = logErrors
+	}
+}
+
+// Heartbeat set the interval after which a heartbeat line is written to an idle connection to the proxy, and the
+// line, "" for an empty line. Only applies to proxy senders. defaults to 0, no heartbeat.
+func Heartbeat(interval time.Duration, line string) Option {
+	return func(cfg *configuration) {
+		cfg.HeartbeatInterval = interval
+		cfg.Heartbeat = line
+	}
+}
+
+// FlushFailure set what is done with the lines written to the proxy since the last successful flush when a flush
+// fails, and the max lines of each port retained by ReQueueOnFailure, 0 for the default of 50,000.
+// Only applies to proxy senders. defaults to DropOnFailure.
+func FlushFailure(policy FlushFailurePolicy, maxRequeuedLines int) Option {
+	return func(cfg *configuration) {
+		cfg.FlushFailure = policy
+		cfg.MaxRequeuedLines = maxRequeuedLines
+	}
+}
+
+// OnConnect set a function called each time a connection to the proxy is established, with the signal sent on it.
+// Only applies to proxy senders.
+func OnConnect(f func(signal SignalType)) Option {
+	return func(cfg *configuration) {
+		cfg.OnConnect = f
+	}
+}
+
+// OnDisconnect set a function called each time a connection to the proxy is lost after an error, or closed with
+// a nil error when the sender is closed. Only applies to proxy senders.
+func OnDisconnect(f func(signal SignalType, err error)) Option {
+	return func(cfg *configuration) {
+		cfg.OnDisconnect = f
+	}
+}
+
+// OnReconnectFailed set a function called each time an attempt to (re)connect to the proxy fails.
+// Only applies to proxy senders.
+func OnReconnectFailed(f func(signal SignalType, err error)) Option {
+	return func(cfg *configuration) {
+		cfg.OnReconnectFailed = f
+	}
+}
+
+// BatchSpanLogs set whether the span logs are written to the proxy in a single batch per flush interval instead of
+// once per span. Only applies to proxy senders. defaults to false.
+func BatchSpanLogs(enabled bool) Option {
+	return func(cfg *configuration) {
+		cfg.BatchSpanLogs = enabled
+	}
+}
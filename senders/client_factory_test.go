@@ -0,0 +1,90 @@
+package senders
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithFlushInterval(t *testing.T) {
+	cfg := &configuration{}
+	WithFlushInterval(5 * time.Second)(cfg)
+	assert.NoError(t, cfg.err)
+	assert.Equal(t, 5, cfg.FlushIntervalSeconds)
+
+	for _, interval := range []time.Duration{0, 500 * time.Millisecond, 1500 * time.Millisecond} {
+		cfg := &configuration{}
+		WithFlushInterval(interval)(cfg)
+		assert.Error(t, cfg.err, "%s is not a whole number of seconds", interval)
+		assert.Equal(t, 0, cfg.FlushIntervalSeconds)
+	}
+
+	_, err := NewSender("proxy://localhost:2878", WithFlushInterval(500*time.Millisecond))
+	assert.EqualError(t, err, "invalid flush interval 500ms: must be a positive whole number of seconds")
+	_, err = NewSender("http://token@localhost:8080", WithFlushInterval(1500*time.Millisecond))
+	assert.EqualError(t, err, "invalid flush interval 1.5s: must be a positive whole number of seconds")
+}
+
+func TestNewSenderProxyURL(t *testing.T) {
+	sender, err := NewSender("proxy://localhost:2878?tracingPort=30000&distributionPort=40000",
+		Source("my_host"), WithFlushInterval(10*time.Second), WithRegistryReportingDisabled(), BatchSize(20000),
+		WriteTimeout(time.Second))
+	require.NoError(t, err)
+	defer sender.Close()
+	proxy, ok := sender.(*proxySender)
+	require.True(t, ok, "a proxy sender is created")
+	assert.Equal(t, "my_host", proxy.Source())
+	assert.NotNil(t, proxy.handlers[metricHandler])
+	assert.NotNil(t, proxy.handlers[histoHandler])
+	assert.NotNil(t, proxy.handlers[spanHandler])
+	assert.Nil(t, proxy.handlers[eventHandler])
+
+	u, err := url.Parse("PROXY://proxy.corp:2878/?eventsPort=2878&spanLogsPort=30001")
+	require.NoError(t, err)
+	cfg := &configuration{BatchSize: 20000}
+	for _, set := range []Option{WithFlushInterval(10 * time.Second), Source("my_host"), WithRegistryReportingDisabled(),
+		WriteTimeout(time.Second), FlushFailure(ReQueueOnFailure, 100), Heartbeat(time.Minute, "#heartbeat"),
+		ReadResponses(true, true), Greeting(""), BatchSpanLogs(true)} {
+		set(cfg)
+	}
+	proxyCfg, err := cfg.proxyConfiguration(u)
+	require.NoError(t, err)
+	assert.Equal(t, "proxy.corp", proxyCfg.Host)
+	assert.Equal(t, 2878, proxyCfg.MetricsPort)
+	assert.Equal(t, 2878, proxyCfg.EventsPort)
+	assert.Equal(t, 30001, proxyCfg.SpanLogsPort)
+	assert.Equal(t, 10, proxyCfg.FlushIntervalSeconds)
+	assert.Equal(t, "my_host", proxyCfg.Source)
+	assert.True(t, proxyCfg.DisableInternalMetrics)
+	assert.Equal(t, time.Second, proxyCfg.WriteTimeout)
+	assert.Equal(t, ReQueueOnFailure, proxyCfg.FlushFailure)
+	assert.Equal(t, 100, proxyCfg.MaxRequeuedLines)
+	assert.Equal(t, time.Minute, proxyCfg.HeartbeatInterval)
+	assert.Equal(t, "#heartbeat", proxyCfg.Heartbeat)
+	assert.True(t, proxyCfg.ReadResponses)
+	assert.True(t, proxyCfg.LogResponseErrors)
+	assert.True(t, proxyCfg.SendGreeting)
+	assert.True(t, proxyCfg.BatchSpanLogs)
+}
+
+func TestNewSenderInvalidProxyURL(t *testing.T) {
+	tests := map[string]string{
+		"proxy://token@localhost:2878":         "invalid proxy URL: proxies take no token",
+		"proxy://localhost:2878/metrics":       "invalid proxy URL of localhost:2878: unexpected path /metrics",
+		"proxy://localhost:2878?tracing=30000": "invalid proxy URL of localhost:2878: unknown parameter tracing",
+		"proxy://localhost:2878?eventsPort=x":  "invalid proxy URL of localhost:2878: invalid eventsPort x",
+		"proxy://localhost":                    "at least one proxy port should be enabled",
+		"tcp://localhost:2878":                 "invalid schema 'tcp', only 'http' and 'proxy' are supported",
+		"grpc://localhost:4317":                "grpc transport not supported, use a 'proxy' URL",
+	}
+	for rawURL, expected := range tests {
+		_, err := NewSender(rawURL)
+		assert.EqualError(t, err, expected, rawURL)
+	}
+	_, err := NewSender("GRPCS://localhost:4317")
+	assert.True(t, errors.Is(err, ErrGRPCNotSupported))
+}
@@ -1,8 +1,11 @@
 package senders
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/wavefronthq/wavefront-sdk-go/event"
 	"github.com/wavefronthq/wavefront-sdk-go/histogram"
@@ -36,6 +39,16 @@ func (m *multiError) Error() string {
 	}
 }
 
+// Is reports whether any of the errors matches target, letting errors.Is find sentinel errors like ErrPortNotConfigured.
+func (m *multiError) Is(target error) bool {
+	for _, err := range m.errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
 func (m *multiError) add(es ...error) {
 	m.errors = append(m.errors, es...)
 }
@@ -65,6 +78,28 @@ func (ms *multiSender) SendMetric(name string, value float64, ts int64, source s
 	return errors.get()
 }
 
+func (ms *multiSender) SendIntMetric(name string, value int64, ts int64, source string, tags map[string]string) error {
+	var errors multiError
+	for _, sender := range ms.senders {
+		err := sendIntMetric(sender, name, value, ts, source, tags)
+		if err != nil {
+			errors.add(err)
+		}
+	}
+	return errors.get()
+}
+
+func (ms *multiSender) SendMetricNow(name string, value float64, source string, tags map[string]string) error {
+	var errors multiError
+	for _, sender := range ms.senders {
+		err := sendMetricNow(sender, name, value, source, tags)
+		if err != nil {
+			errors.add(err)
+		}
+	}
+	return errors.get()
+}
+
 func (ms *multiSender) SendDeltaCounter(name string, value float64, source string, tags map[string]string) error {
 	var errors multiError
 	for _, sender := range ms.senders {
@@ -76,6 +111,10 @@ func (ms *multiSender) SendDeltaCounter(name string, value float64, source strin
 	return errors.get()
 }
 
+func (ms *multiSender) SendDistributionG(name string, centroids []histogram.Centroid, ts int64, source string, tags map[string]string, granularities ...histogram.Granularity) error {
+	return ms.SendDistribution(name, centroids, histogram.Granularities(granularities...), ts, source, tags)
+}
+
 func (ms *multiSender) SendDistribution(name string, centroids []histogram.Centroid, hgs map[histogram.Granularity]bool, ts int64, source string, tags map[string]string) error {
 	var errors multiError
 	for _, sender := range ms.senders {
@@ -87,6 +126,22 @@ func (ms *multiSender) SendDistribution(name string, centroids []histogram.Centr
 	return errors.get()
 }
 
+func (ms *multiSender) SendDistributions(dists []DistributionPoint) []error {
+	errors := make([]multiError, len(dists))
+	for _, sender := range ms.senders {
+		for i, err := range sendDistributions(sender, dists) {
+			if err != nil {
+				errors[i].add(err)
+			}
+		}
+	}
+	errs := make([]error, len(dists))
+	for i := range errors {
+		errs[i] = errors[i].get()
+	}
+	return errs
+}
+
 func (ms *multiSender) SendSpan(name string, startMillis, durationMillis int64, source, traceId, spanId string, parents, followsFrom []string, tags []SpanTag, spanLogs []SpanLog) error {
 	var errors multiError
 	for _, sender := range ms.senders {
@@ -98,6 +153,22 @@ func (ms *multiSender) SendSpan(name string, startMillis, durationMillis int64,
 	return errors.get()
 }
 
+func (ms *multiSender) SendSpans(spans []Span) []error {
+	errors := make([]multiError, len(spans))
+	for _, sender := range ms.senders {
+		for i, err := range sendSpans(sender, spans) {
+			if err != nil {
+				errors[i].add(err)
+			}
+		}
+	}
+	errs := make([]error, len(spans))
+	for i := range errors {
+		errs[i] = errors[i].get()
+	}
+	return errs
+}
+
 func (ms *multiSender) SendEvent(name string, startMillis, endMillis int64, source string, tags map[string]string, setters ...event.Option) error {
 	var errors multiError
 	for _, sender := range ms.senders {
@@ -109,6 +180,80 @@ func (ms *multiSender) SendEvent(name string, startMillis, endMillis int64, sour
 	return errors.get()
 }
 
+func (ms *multiSender) SendRawLine(line string) error {
+	var errors multiError
+	for _, sender := range ms.senders {
+		err := sendRawLine(sender, line)
+		if err != nil {
+			errors.add(err)
+		}
+	}
+	return errors.get()
+}
+
+func (ms *multiSender) SendRawLines(lines []string) error {
+	var errors multiError
+	for _, sender := range ms.senders {
+		err := sendRawLines(sender, lines)
+		if err != nil {
+			errors.add(err)
+		}
+	}
+	return errors.get()
+}
+
+func (ms *multiSender) SetSignalEnabled(signal SignalType, enabled bool) {
+	for _, sender := range ms.senders {
+		setSignalEnabled(sender, signal, enabled)
+	}
+}
+
+func (ms *multiSender) FlushSignal(signal SignalType) error {
+	var errors multiError
+	for _, sender := range ms.senders {
+		err := flushSignal(sender, signal)
+		if err != nil {
+			errors.add(err)
+		}
+	}
+	return errors.get()
+}
+
+func (ms *multiSender) Drain(ctx context.Context) error {
+	var errors multiError
+	for _, sender := range ms.senders {
+		err := drainSender(ctx, sender)
+		if err != nil {
+			errors.add(err)
+		}
+	}
+	return errors.get()
+}
+
+func (ms *multiSender) ReportInternalMetricsNow() error {
+	var errors multiError
+	for _, sender := range ms.senders {
+		err := reportInternalMetricsNow(sender)
+		if err != nil {
+			errors.add(err)
+		}
+	}
+	return errors.get()
+}
+
+func (ms *multiSender) FlushWithStats() (FlushStats, error) {
+	stats := newFlushStats()
+	var errors multiError
+	for _, sender := range ms.senders {
+		senderStats, err := flushSenderWithStats(sender)
+		if err != nil {
+			errors.add(err)
+		}
+		stats.add(senderStats)
+	}
+	return stats, errors.get()
+}
+
 func (ms *multiSender) Flush() error {
 	var errors multiError
 	for _, sender := range ms.senders {
@@ -128,6 +273,14 @@ func (ms *multiSender) GetFailureCount() int64 {
 	return fc
 }
 
+func (ms *multiSender) FailureCountDelta() int64 {
+	var fc int64
+	for _, sender := range ms.senders {
+		fc += failureCountDelta(sender)
+	}
+	return fc
+}
+
 func (ms *multiSender) Start() {
 	for _, sender := range ms.senders {
 		sender.Start()
@@ -139,3 +292,55 @@ func (ms *multiSender) Close() {
 		sender.Close()
 	}
 }
+
+func (ms *multiSender) CloseWithError() error {
+	var errors multiError
+	for _, sender := range ms.senders {
+		err := closeWithError(sender)
+		if err != nil {
+			errors.add(err)
+		}
+	}
+	return errors.get()
+}
+
+// PendingLines sums the pending lines of each handler over all the senders.
+func (ms *multiSender) PendingLines() map[string]int {
+	pending := make(map[string]int)
+	for _, sender := range ms.senders {
+		for name, n := range pendingLines(sender) {
+			pending[name] += n
+		}
+	}
+	return pending
+}
+
+// Source returns the source of the first sender, "" when there's none.
+func (ms *multiSender) Source() string {
+	if len(ms.senders) == 0 {
+		return ""
+	}
+	return senderSource(ms.senders[0])
+}
+
+// ConnectionStatus reports a handler as connected only when it is connected on all the senders configuring it.
+func (ms *multiSender) ConnectionStatus() map[string]bool {
+	status := make(map[string]bool)
+	for _, sender := range ms.senders {
+		for name, connected := range connectionStatus(sender) {
+			if prev, ok := status[name]; ok {
+				connected = connected && prev
+			}
+			status[name] = connected
+		}
+	}
+	return status
+}
+
+// now returns the time of the first sender.
+func (ms *multiSender) now() time.Time {
+	if len(ms.senders) == 0 {
+		return time.Now()
+	}
+	return senderNow(ms.senders[0])
+}
@@ -0,0 +1,113 @@
+package senders
+
+import (
+	"strings"
+
+	"github.com/wavefronthq/wavefront-sdk-go/event"
+	"github.com/wavefronthq/wavefront-sdk-go/histogram"
+	"github.com/wavefronthq/wavefront-sdk-go/internal"
+)
+
+// PrefixOption configures a Sender created by NewPrefixingSender.
+type PrefixOption func(*prefixingSender)
+
+// PrefixSpans prefixes the span names too.
+func PrefixSpans() PrefixOption {
+	return func(ps *prefixingSender) {
+		ps.spans = true
+	}
+}
+
+// PrefixEvents prefixes the event names too.
+func PrefixEvents() PrefixOption {
+	return func(ps *prefixingSender) {
+		ps.events = true
+	}
+}
+
+type prefixingSender struct {
+	wrappedSender
+	prefix string
+	spans  bool
+	events bool
+}
+
+// NewPrefixingSender wraps the given sender so the given prefix, e.g. "myapp.", is prepended to the names of the
+// metrics, delta counters and distributions sent. Delta counter names keep their delta prefix first, "∆myapp.foo".
+// Span and event names are only prefixed when enabled by the options. Raw lines are sent as is.
+func NewPrefixingSender(inner Sender, prefix string, opts ...PrefixOption) Sender {
+	ps := &prefixingSender{wrappedSender: wrappedSender{inner}, prefix: prefix}
+	for _, opt := range opts {
+		opt(ps)
+	}
+	return ps
+}
+
+// name prepends the prefix to the given name, after its delta prefix if any.
+func (ps *prefixingSender) name(name string) string {
+	for _, deltaPrefix := range []string{internal.DeltaPrefix, internal.AltDeltaPrefix} {
+		if strings.HasPrefix(name, deltaPrefix) {
+			return deltaPrefix + ps.prefix + strings.TrimPrefix(name, deltaPrefix)
+		}
+	}
+	return ps.prefix + name
+}
+
+func (ps *prefixingSender) SendMetric(name string, value float64, ts int64, source string, tags map[string]string) error {
+	return ps.wrappedSender.SendMetric(ps.name(name), value, ts, source, tags)
+}
+
+func (ps *prefixingSender) SendIntMetric(name string, value int64, ts int64, source string, tags map[string]string) error {
+	return ps.wrappedSender.SendIntMetric(ps.name(name), value, ts, source, tags)
+}
+
+func (ps *prefixingSender) SendMetricNow(name string, value float64, source string, tags map[string]string) error {
+	return ps.wrappedSender.SendMetricNow(ps.name(name), value, source, tags)
+}
+
+func (ps *prefixingSender) SendDeltaCounter(name string, value float64, source string, tags map[string]string) error {
+	return ps.wrappedSender.SendDeltaCounter(ps.name(name), value, source, tags)
+}
+
+func (ps *prefixingSender) SendDistribution(name string, centroids []histogram.Centroid, hgs map[histogram.Granularity]bool, ts int64, source string, tags map[string]string) error {
+	return ps.wrappedSender.SendDistribution(ps.name(name), centroids, hgs, ts, source, tags)
+}
+
+func (ps *prefixingSender) SendDistributionG(name string, centroids []histogram.Centroid, ts int64, source string, tags map[string]string, granularities ...histogram.Granularity) error {
+	return ps.SendDistribution(name, centroids, histogram.Granularities(granularities...), ts, source, tags)
+}
+
+func (ps *prefixingSender) SendDistributions(dists []DistributionPoint) []error {
+	prefixed := make([]DistributionPoint, len(dists))
+	for i, dist := range dists {
+		dist.Name = ps.name(dist.Name)
+		prefixed[i] = dist
+	}
+	return ps.wrappedSender.SendDistributions(prefixed)
+}
+
+func (ps *prefixingSender) SendSpan(name string, startMillis, durationMillis int64, source, traceId, spanId string, parents, followsFrom []string, tags []SpanTag, spanLogs []SpanLog) error {
+	if ps.spans {
+		name = ps.prefix + name
+	}
+	return ps.wrappedSender.SendSpan(name, startMillis, durationMillis, source, traceId, spanId, parents, followsFrom, tags, spanLogs)
+}
+
+func (ps *prefixingSender) SendSpans(spans []Span) []error {
+	if ps.spans {
+		prefixed := make([]Span, len(spans))
+		for i, span := range spans {
+			span.Name = ps.prefix + span.Name
+			prefixed[i] = span
+		}
+		spans = prefixed
+	}
+	return ps.wrappedSender.SendSpans(spans)
+}
+
+func (ps *prefixingSender) SendEvent(name string, startMillis, endMillis int64, source string, tags map[string]string, setters ...event.Option) error {
+	if ps.events {
+		name = ps.prefix + name
+	}
+	return ps.wrappedSender.SendEvent(name, startMillis, endMillis, source, tags, setters...)
+}
@@ -0,0 +1,64 @@
+package senders
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wavefronthq/wavefront-sdk-go/histogram"
+)
+
+func TestPrefixingSender(t *testing.T) {
+	inner := &fakeSender{}
+	sender := NewPrefixingSender(inner, "myapp.")
+
+	assert.NoError(t, sender.SendMetric("foo", 1, 0, "", nil))
+	assert.NoError(t, sender.(MetricNowSender).SendMetricNow("foo", 2, "", nil))
+	assert.NoError(t, sender.SendDistribution("latency", []histogram.Centroid{{Value: 30, Count: 20}}, nil, 0, "", nil))
+	assert.NoError(t, sender.(DistributionGSender).SendDistributionG("latency", []histogram.Centroid{{Value: 30, Count: 20}}, 0, "", nil, histogram.MINUTE))
+	assert.NoError(t, sender.SendSpan("getAllUsers", 0, 1, "", testTraceId, testSpanId, nil, nil, nil, nil))
+	assert.Equal(t, []error{nil}, sender.(SpanBatchSender).SendSpans([]Span{{Name: "getUser"}}))
+	assert.NoError(t, sender.SendEvent("deploy", 0, 0, "", nil))
+	assert.Equal(t, []string{
+		"metric myapp.foo 1",
+		"metric myapp.foo 2",
+		"distribution myapp.latency",
+		"distribution myapp.latency",
+		"span getAllUsers",
+		"span getUser",
+		"event deploy",
+	}, inner.calls)
+}
+
+func TestPrefixingSenderDeltaCounters(t *testing.T) {
+	inner := &fakeSender{}
+	sender := NewPrefixingSender(inner, "myapp.")
+
+	assert.NoError(t, sender.SendDeltaCounter("foo", 1, "", nil))
+	assert.NoError(t, sender.SendDeltaCounter("∆foo", 1, "", nil))
+	assert.NoError(t, sender.SendDeltaCounter("Δfoo", 1, "", nil))
+	assert.NoError(t, sender.SendMetric("∆foo", 1, 0, "", nil))
+	assert.Equal(t, []string{
+		"delta myapp.foo 1",
+		"delta ∆myapp.foo 1",
+		"delta Δmyapp.foo 1",
+		"metric ∆myapp.foo 1",
+	}, inner.calls)
+
+	// the delta prefix added by the proxy sender comes before the name prefix
+	proxy, handlers := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", MetricsPort: 50000})
+	defer proxy.Close()
+	assert.NoError(t, NewPrefixingSender(proxy, "myapp.").SendDeltaCounter("foo", 1, "test_source", nil))
+	assert.Equal(t, "\"∆myapp.foo\" 1 source=\"test_source\"\n", handlers[metricHandler].data())
+}
+
+func TestPrefixingSenderSpansAndEvents(t *testing.T) {
+	inner := &fakeSender{}
+	sender := NewPrefixingSender(inner, "myapp.", PrefixSpans(), PrefixEvents())
+
+	assert.NoError(t, sender.SendSpan("getAllUsers", 0, 1, "", testTraceId, testSpanId, nil, nil, nil, nil))
+	spans := []Span{{Name: "getUser"}}
+	assert.Equal(t, []error{nil}, sender.(SpanBatchSender).SendSpans(spans))
+	assert.NoError(t, sender.SendEvent("deploy", 0, 0, "", nil))
+	assert.Equal(t, []string{"span myapp.getAllUsers", "span myapp.getUser", "event myapp.deploy"}, inner.calls)
+	assert.Equal(t, "getUser", spans[0].Name, "the caller's spans are not modified")
+}
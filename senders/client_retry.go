@@ -0,0 +1,250 @@
+package senders
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/wavefronthq/wavefront-sdk-go/event"
+	"github.com/wavefronthq/wavefront-sdk-go/histogram"
+)
+
+const (
+	defaultMaxRetries     = 3
+	defaultInitialBackoff = 100 * time.Millisecond
+	defaultMaxBackoff     = 5 * time.Second
+)
+
+// RetryConfig for the retrying sender
+type RetryConfig struct {
+	// number of times a failed send is retried. defaults to 3.
+	MaxRetries int
+
+	// delay before the first retry, doubled on each following retry. defaults to 100 milliseconds.
+	InitialBackoff time.Duration
+
+	// maximum delay between two retries. defaults to 5 seconds.
+	MaxBackoff time.Duration
+
+	// metrics and distributions are idempotent (resending a point overrides it), so they are retried unless disabled.
+	DisableMetricRetries       bool
+	DisableDistributionRetries bool
+
+	// delta counters, spans and events can be duplicated when resent, so they are only retried when enabled.
+	RetryDeltaCounters bool
+	RetrySpans         bool
+	RetryEvents        bool
+}
+
+// RetryingSender Interface for a Sender retrying the failed sends of idempotent signals
+type RetryingSender interface {
+	Sender
+
+	// GetRetryCount returns the number of send attempts that were retried.
+	GetRetryCount() int64
+}
+
+type retryingSender struct {
+	// keep this as first element of struct to guarantee 64-bit alignment on 32-bit machines.
+	retries int64
+
+	wrappedSender
+	cfg RetryConfig
+}
+
+// NewRetryingSender wraps the given sender so failed sends are retried with an exponential backoff.
+// Only metrics and distributions are retried by default, see RetryConfig to choose the retried signals.
+// Sends of invalid data are not retried. The caller is blocked while waiting between retries.
+func NewRetryingSender(inner Sender, cfg RetryConfig) RetryingSender {
+	if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = defaultMaxRetries
+	}
+	if cfg.InitialBackoff == 0 {
+		cfg.InitialBackoff = defaultInitialBackoff
+	}
+	if cfg.MaxBackoff == 0 {
+		cfg.MaxBackoff = defaultMaxBackoff
+	}
+	return &retryingSender{wrappedSender: wrappedSender{inner}, cfg: cfg}
+}
+
+// retryable returns whether a send failing with err can succeed when retried, unlike the sends of invalid data.
+func retryable(err error) bool {
+	return err != nil && !errors.Is(err, ErrInvalidData)
+}
+
+// retry calls send again until it succeeds, fails with invalid data or the retries are exhausted, err being the error
+// of the first attempt.
+func (rs *retryingSender) retry(err error, send func() error) error {
+	backoff := rs.cfg.InitialBackoff
+	for i := 0; retryable(err) && i < rs.cfg.MaxRetries; i++ {
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > rs.cfg.MaxBackoff {
+			backoff = rs.cfg.MaxBackoff
+		}
+		atomic.AddInt64(&rs.retries, 1)
+		err = send()
+	}
+	return err
+}
+
+func (rs *retryingSender) SendMetric(name string, value float64, ts int64, source string, tags map[string]string) error {
+	send := func() error {
+		return rs.wrappedSender.SendMetric(name, value, ts, source, tags)
+	}
+	err := send()
+	if !retryable(err) || rs.cfg.DisableMetricRetries {
+		return err
+	}
+	return rs.retry(err, send)
+}
+
+func (rs *retryingSender) SendIntMetric(name string, value int64, ts int64, source string, tags map[string]string) error {
+	send := func() error {
+		return rs.wrappedSender.SendIntMetric(name, value, ts, source, tags)
+	}
+	err := send()
+	if !retryable(err) || rs.cfg.DisableMetricRetries {
+		return err
+	}
+	return rs.retry(err, send)
+}
+
+func (rs *retryingSender) SendMetricNow(name string, value float64, source string, tags map[string]string) error {
+	send := func() error {
+		return rs.wrappedSender.SendMetricNow(name, value, source, tags)
+	}
+	err := send()
+	if !retryable(err) || rs.cfg.DisableMetricRetries {
+		return err
+	}
+	return rs.retry(err, send)
+}
+
+func (rs *retryingSender) SendDeltaCounter(name string, value float64, source string, tags map[string]string) error {
+	send := func() error {
+		return rs.wrappedSender.SendDeltaCounter(name, value, source, tags)
+	}
+	err := send()
+	if !retryable(err) || !rs.cfg.RetryDeltaCounters {
+		return err
+	}
+	return rs.retry(err, send)
+}
+
+func (rs *retryingSender) SendDistributionG(name string, centroids []histogram.Centroid, ts int64, source string, tags map[string]string, granularities ...histogram.Granularity) error {
+	return rs.SendDistribution(name, centroids, histogram.Granularities(granularities...), ts, source, tags)
+}
+
+func (rs *retryingSender) SendDistribution(name string, centroids []histogram.Centroid, hgs map[histogram.Granularity]bool, ts int64, source string, tags map[string]string) error {
+	send := func() error {
+		return rs.wrappedSender.SendDistribution(name, centroids, hgs, ts, source, tags)
+	}
+	err := send()
+	if !retryable(err) || rs.cfg.DisableDistributionRetries {
+		return err
+	}
+	return rs.retry(err, send)
+}
+
+func (rs *retryingSender) SendDistributions(dists []DistributionPoint) []error {
+	errs := rs.wrappedSender.SendDistributions(dists)
+	if rs.cfg.DisableDistributionRetries {
+		return errs
+	}
+
+	// indexes of the valid distributions that failed to be sent
+	var failed []int
+	for i, err := range errs {
+		if retryable(err) {
+			failed = append(failed, i)
+		}
+	}
+	if len(failed) == 0 {
+		return errs
+	}
+
+	rs.retry(errs[failed[0]], func() error {
+		retried := make([]DistributionPoint, len(failed))
+		for i, idx := range failed {
+			retried[i] = dists[idx]
+		}
+		var stillFailed []int
+		for i, err := range rs.wrappedSender.SendDistributions(retried) {
+			errs[failed[i]] = err
+			if retryable(err) {
+				stillFailed = append(stillFailed, failed[i])
+			}
+		}
+		failed = stillFailed
+		if len(failed) > 0 {
+			return errs[failed[0]]
+		}
+		return nil
+	})
+	return errs
+}
+
+func (rs *retryingSender) SendSpan(name string, startMillis, durationMillis int64, source, traceId, spanId string, parents, followsFrom []string, tags []SpanTag, spanLogs []SpanLog) error {
+	send := func() error {
+		return rs.wrappedSender.SendSpan(name, startMillis, durationMillis, source, traceId, spanId, parents, followsFrom, tags, spanLogs)
+	}
+	err := send()
+	if !retryable(err) || !rs.cfg.RetrySpans {
+		return err
+	}
+	return rs.retry(err, send)
+}
+
+func (rs *retryingSender) SendSpans(spans []Span) []error {
+	errs := rs.wrappedSender.SendSpans(spans)
+	if !rs.cfg.RetrySpans {
+		return errs
+	}
+
+	// indexes of the valid spans that failed to be sent
+	var failed []int
+	for i, err := range errs {
+		if retryable(err) {
+			failed = append(failed, i)
+		}
+	}
+	if len(failed) == 0 {
+		return errs
+	}
+
+	rs.retry(errs[failed[0]], func() error {
+		retried := make([]Span, len(failed))
+		for i, idx := range failed {
+			retried[i] = spans[idx]
+		}
+		var stillFailed []int
+		for i, err := range rs.wrappedSender.SendSpans(retried) {
+			errs[failed[i]] = err
+			if retryable(err) {
+				stillFailed = append(stillFailed, failed[i])
+			}
+		}
+		failed = stillFailed
+		if len(failed) > 0 {
+			return errs[failed[0]]
+		}
+		return nil
+	})
+	return errs
+}
+
+func (rs *retryingSender) SendEvent(name string, startMillis, endMillis int64, source string, tags map[string]string, setters ...event.Option) error {
+	send := func() error {
+		return rs.wrappedSender.SendEvent(name, startMillis, endMillis, source, tags, setters...)
+	}
+	err := send()
+	if !retryable(err) || !rs.cfg.RetryEvents {
+		return err
+	}
+	return rs.retry(err, send)
+}
+
+func (rs *retryingSender) GetRetryCount() int64 {
+	return atomic.LoadInt64(&rs.retries)
+}
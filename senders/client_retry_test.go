@@ -0,0 +1,100 @@
+package senders
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wavefronthq/wavefront-sdk-go/histogram"
+)
+
+func newTestRetryingSender(inner Sender, cfg RetryConfig) RetryingSender {
+	cfg.InitialBackoff = time.Millisecond
+	return NewRetryingSender(inner, cfg)
+}
+
+func TestRetryingSenderRetriesMetrics(t *testing.T) {
+	inner := &fakeSender{failures: 2, err: errors.New("connection refused")}
+	sender := newTestRetryingSender(inner, RetryConfig{})
+
+	assert.NoError(t, sender.SendMetric("new-york.power.usage", 42422.0, 0, "go_test", nil))
+	assert.Len(t, inner.calls, 3)
+	assert.Equal(t, int64(2), sender.GetRetryCount())
+
+	inner.failures = 1
+	hgs := map[histogram.Granularity]bool{histogram.MINUTE: true}
+	assert.NoError(t, sender.SendDistribution("request.latency", []histogram.Centroid{{Value: 30.0, Count: 20}}, hgs, 0, "appServer1", nil))
+	assert.Equal(t, int64(3), sender.GetRetryCount())
+}
+
+func TestRetryingSenderGivesUp(t *testing.T) {
+	inner := &fakeSender{failures: 10, err: errors.New("connection refused")}
+	sender := newTestRetryingSender(inner, RetryConfig{MaxRetries: 2})
+
+	assert.EqualError(t, sender.SendMetric("new-york.power.usage", 42422.0, 0, "go_test", nil), "connection refused")
+	assert.Len(t, inner.calls, 3)
+}
+
+func TestRetryingSenderSkipsNonIdempotentSignals(t *testing.T) {
+	inner := &fakeSender{failures: 10, err: errors.New("connection refused")}
+	sender := newTestRetryingSender(inner, RetryConfig{})
+
+	assert.Error(t, sender.SendDeltaCounter("lambda.thumbnail.generate", 10.0, "thumbnail_service", nil))
+	assert.Error(t, sender.SendSpan("getAllUsers", 0, 343500, "localhost",
+		"7b3bf470-9456-11e8-9eb6-529269fb1459", "0313bafe-9457-11e8-9eb6-529269fb1459", nil, nil, nil, nil))
+	assert.Error(t, sender.SendEvent("deploy", 0, 0, "localhost", nil))
+	assert.Len(t, inner.calls, 3)
+	assert.Equal(t, int64(0), sender.GetRetryCount())
+}
+
+func TestRetryingSenderRetriesEnabledSignals(t *testing.T) {
+	inner := &fakeSender{failures: 1, err: errors.New("connection refused")}
+	sender := newTestRetryingSender(inner, RetryConfig{RetrySpans: true, DisableMetricRetries: true})
+
+	spans := []Span{
+		{Name: "getAllUsers", TraceId: "7b3bf470-9456-11e8-9eb6-529269fb1459", SpanId: "0313bafe-9457-11e8-9eb6-529269fb1459"},
+		{Name: "getUser", TraceId: "7b3bf470-9456-11e8-9eb6-529269fb1459", SpanId: "2f64e538-9457-11e8-9eb6-529269fb1459"},
+	}
+	assert.Equal(t, []error{nil, nil}, sender.(SpanBatchSender).SendSpans(spans))
+	assert.Equal(t, []string{"span getAllUsers", "span getUser", "span getAllUsers"}, inner.calls)
+
+	inner.failures = 1
+	assert.Error(t, sender.SendMetric("new-york.power.usage", 42422.0, 0, "go_test", nil))
+}
+
+func TestRetryingSenderSkipsInvalidData(t *testing.T) {
+	inner := &fakeSender{failures: 10, err: invalidData(errors.New("empty metric name"))}
+	sender := newTestRetryingSender(inner, RetryConfig{})
+
+	assert.Error(t, sender.SendMetric("", 42422.0, 0, "go_test", nil))
+	assert.Len(t, inner.calls, 1)
+}
+
+func TestRetryingSenderSkipsFutureTimestamps(t *testing.T) {
+	inner := &fakeSender{failures: 10, err: invalidData(&timestampError{ts: 99999999999, horizon: defaultTimestampHorizon})}
+	sender := newTestRetryingSender(inner, RetryConfig{})
+
+	assert.Error(t, sender.SendMetric("new-york.power.usage", 42422.0, 99999999999, "go_test", nil))
+	assert.Len(t, inner.calls, 1)
+}
+
+func TestRetryingSenderSkipsRejectedData(t *testing.T) {
+	inner, handlers := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", MetricsPort: 30000,
+		TagValidator: func(key, value string) error {
+			if key == "password" {
+				return errors.New("secret tag")
+			}
+			return nil
+		}})
+	defer inner.Close()
+	sender := newTestRetryingSender(inner, RetryConfig{})
+
+	// valid for the default formatters, rejected by the policy of the inner sender
+	err := sender.SendMetric("new-york.power.usage", 42422.0, 0, "go_test", map[string]string{"password": "hunter2"})
+	assert.True(t, errors.Is(err, ErrInvalidData))
+	var te *tagError
+	assert.True(t, errors.As(err, &te), "the reason is kept")
+	assert.Equal(t, int64(0), sender.GetRetryCount())
+	assert.Empty(t, handlers[metricHandler].lines)
+}
@@ -0,0 +1,134 @@
+package senders
+
+import (
+	"github.com/wavefronthq/wavefront-sdk-go/event"
+	"github.com/wavefronthq/wavefront-sdk-go/histogram"
+)
+
+// TagOption configures a Sender created by NewTaggingSender.
+type TagOption func(*taggingSender)
+
+// TagSource sets the source of the data sent without source, instead of the default source of the sender.
+func TagSource(source string) TagOption {
+	return func(ts *taggingSender) {
+		ts.source = source
+	}
+}
+
+type taggingSender struct {
+	wrappedSender
+	tags   map[string]string
+	source string
+}
+
+// NewTaggingSender wraps the given sender so the given tags are added to the metrics, delta counters,
+// distributions, spans and events sent. The tags of each call take precedence over the wrapper tags.
+// Raw lines are sent as is.
+// Wrapping is cheap, e.g. to attribute the data sent while handling a request to its tenant with a shared sender:
+//
+//	tenantSender := senders.NewTaggingSender(sender, map[string]string{"tenant": tenant}, senders.TagSource(host))
+//
+// Nested wrappers apply the outer tags first, so request scoped tags override the ones of a sender wide wrapper.
+// Closing the wrapper closes the wrapped sender.
+func NewTaggingSender(inner Sender, tags map[string]string, opts ...TagOption) Sender {
+	ts := &taggingSender{wrappedSender: wrappedSender{inner}, tags: make(map[string]string, len(tags))}
+	for k, v := range tags {
+		ts.tags[k] = v
+	}
+	for _, opt := range opts {
+		opt(ts)
+	}
+	return ts
+}
+
+// merge returns the wrapper tags overridden by the given tags.
+func (ts *taggingSender) merge(tags map[string]string) map[string]string {
+	if len(ts.tags) == 0 {
+		return tags
+	}
+	merged := make(map[string]string, len(ts.tags)+len(tags))
+	for k, v := range ts.tags {
+		merged[k] = v
+	}
+	for k, v := range tags {
+		merged[k] = v
+	}
+	return merged
+}
+
+// mergeSpanTags returns the given span tags followed by the wrapper tags of the keys they don't have.
+func (ts *taggingSender) mergeSpanTags(tags []SpanTag) []SpanTag {
+	if len(ts.tags) == 0 {
+		return tags
+	}
+	keys := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		keys[tag.Key] = true
+	}
+	merged := append(make([]SpanTag, 0, len(tags)+len(ts.tags)), tags...)
+	for _, k := range sortedKeys(ts.tags) {
+		if !keys[k] {
+			merged = append(merged, SpanTag{Key: k, Value: ts.tags[k]})
+		}
+	}
+	return merged
+}
+
+func (ts *taggingSender) sourceOf(source string) string {
+	if source == "" {
+		return ts.source
+	}
+	return source
+}
+
+func (ts *taggingSender) SendMetric(name string, value float64, timestamp int64, source string, tags map[string]string) error {
+	return ts.wrappedSender.SendMetric(name, value, timestamp, ts.sourceOf(source), ts.merge(tags))
+}
+
+func (ts *taggingSender) SendIntMetric(name string, value int64, timestamp int64, source string, tags map[string]string) error {
+	return ts.wrappedSender.SendIntMetric(name, value, timestamp, ts.sourceOf(source), ts.merge(tags))
+}
+
+func (ts *taggingSender) SendMetricNow(name string, value float64, source string, tags map[string]string) error {
+	return ts.wrappedSender.SendMetricNow(name, value, ts.sourceOf(source), ts.merge(tags))
+}
+
+func (ts *taggingSender) SendDeltaCounter(name string, value float64, source string, tags map[string]string) error {
+	return ts.wrappedSender.SendDeltaCounter(name, value, ts.sourceOf(source), ts.merge(tags))
+}
+
+func (ts *taggingSender) SendDistribution(name string, centroids []histogram.Centroid, hgs map[histogram.Granularity]bool, timestamp int64, source string, tags map[string]string) error {
+	return ts.wrappedSender.SendDistribution(name, centroids, hgs, timestamp, ts.sourceOf(source), ts.merge(tags))
+}
+
+func (ts *taggingSender) SendDistributionG(name string, centroids []histogram.Centroid, timestamp int64, source string, tags map[string]string, granularities ...histogram.Granularity) error {
+	return ts.SendDistribution(name, centroids, histogram.Granularities(granularities...), timestamp, source, tags)
+}
+
+func (ts *taggingSender) SendDistributions(dists []DistributionPoint) []error {
+	tagged := make([]DistributionPoint, len(dists))
+	for i, dist := range dists {
+		dist.Source = ts.sourceOf(dist.Source)
+		dist.Tags = ts.merge(dist.Tags)
+		tagged[i] = dist
+	}
+	return ts.wrappedSender.SendDistributions(tagged)
+}
+
+func (ts *taggingSender) SendSpan(name string, startMillis, durationMillis int64, source, traceId, spanId string, parents, followsFrom []string, tags []SpanTag, spanLogs []SpanLog) error {
+	return ts.wrappedSender.SendSpan(name, startMillis, durationMillis, ts.sourceOf(source), traceId, spanId, parents, followsFrom, ts.mergeSpanTags(tags), spanLogs)
+}
+
+func (ts *taggingSender) SendSpans(spans []Span) []error {
+	tagged := make([]Span, len(spans))
+	for i, span := range spans {
+		span.Source = ts.sourceOf(span.Source)
+		span.Tags = ts.mergeSpanTags(span.Tags)
+		tagged[i] = span
+	}
+	return ts.wrappedSender.SendSpans(tagged)
+}
+
+func (ts *taggingSender) SendEvent(name string, startMillis, endMillis int64, source string, tags map[string]string, setters ...event.Option) error {
+	return ts.wrappedSender.SendEvent(name, startMillis, endMillis, ts.sourceOf(source), ts.merge(tags), setters...)
+}
@@ -0,0 +1,28 @@
+package senders
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaggingSenderPrecedence(t *testing.T) {
+	proxy, handlers := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", MetricsPort: 50000, TracingPort: 50001})
+	defer proxy.Close()
+
+	shared := NewTaggingSender(proxy, map[string]string{"env": "prod", "tenant": "none"}, TagSource("gateway"))
+	tenant := NewTaggingSender(shared, map[string]string{"tenant": "acme"}, TagSource("acme-gateway"))
+
+	require.NoError(t, tenant.SendMetric("requests", 1, 1533529977, "", nil))
+	require.NoError(t, tenant.SendMetric("requests", 1, 1533529977, "host-1", map[string]string{"tenant": "override"}))
+	require.NoError(t, shared.SendMetric("requests", 1, 1533529977, "", nil))
+	assert.Equal(t, "\"requests\" 1 1533529977 source=\"acme-gateway\" \"env\"=\"prod\" \"tenant\"=\"acme\"\n"+
+		"\"requests\" 1 1533529977 source=\"host-1\" \"env\"=\"prod\" \"tenant\"=\"override\"\n"+
+		"\"requests\" 1 1533529977 source=\"gateway\" \"env\"=\"prod\" \"tenant\"=\"none\"\n", handlers[metricHandler].data())
+
+	require.NoError(t, tenant.SendSpan("getUser", 1533529977, 1, "", testTraceId, testSpanId, nil, nil,
+		[]SpanTag{{Key: "env", Value: "test"}}, nil))
+	assert.Equal(t, "\"getUser\" source=\"acme-gateway\" traceId="+testTraceId+" spanId="+testSpanId+
+		" \"env\"=\"test\" \"tenant\"=\"acme\" 1533529977 1\n", handlers[spanHandler].data())
+}
@@ -0,0 +1,102 @@
+package senders
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/wavefronthq/wavefront-sdk-go/internal"
+)
+
+// Clock provides the current time to a sender.
+// Override it with WithClock to get deterministic timestamps in tests or to replay historical data.
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is the default Clock, backed by time.Now.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// clocked is implemented by the senders with a Clock, and by the decorators asking the sender they wrap.
+type clocked interface {
+	now() time.Time
+}
+
+// senderNow returns the current time according to the Clock of sender, or time.Now for the senders without one,
+// e.g. to timestamp the data built by the helpers taking a sender.
+func senderNow(sender interface{}) time.Time {
+	if c, ok := sender.(clocked); ok {
+		return c.now()
+	}
+	return time.Now()
+}
+
+// uptime returns a gauge of the seconds elapsed since its creation according to clock.
+func uptime(clock Clock) func() float64 {
+	start := clock.Now()
+	return func() float64 {
+		return clock.Now().Sub(start).Seconds()
+	}
+}
+
+// flushAges tracks the time of the last successful flush of each signal type,
+// reported by the "<signal>.last_flush_age.seconds" gauges to detect silent outages,
+// and the lines sent by the flushes, reported by FlushWithStats.
+type flushAges struct {
+	// unix nanoseconds of the last successful flushes and lines sent, first for the alignment of the atomic operations
+	last  [handlersCount]int64
+	sent  [handlersCount]int64
+	clock Clock
+}
+
+// newFlushAges returns flushAges counting from the current time until the first flushes.
+func newFlushAges(clock Clock) *flushAges {
+	ages := &flushAges{clock: clock}
+	now := clock.Now().UnixNano()
+	for i := range ages.last {
+		ages.last[i] = now
+	}
+	return ages
+}
+
+// flushed records a flush of the given signal type, ignoring the failed and empty ones.
+func (a *flushAges) flushed(signal SignalType, sent, failed int, err error) {
+	if err == nil && sent > 0 {
+		atomic.StoreInt64(&a.last[signal], a.clock.Now().UnixNano())
+	}
+	atomic.AddInt64(&a.sent[signal], int64(sent))
+}
+
+// onFlush returns the flush callback of a handler of the given signal type, recording its flushes before calling onFlush, if any.
+func (a *flushAges) onFlush(signal SignalType, onFlush func(signal SignalType, sent, failed int, err error)) func(sent, failed int, err error) {
+	return func(sent, failed int, err error) {
+		a.flushed(signal, sent, failed, err)
+		if onFlush != nil {
+			onFlush(signal, sent, failed, err)
+		}
+	}
+}
+
+// sentLines returns the lines sent by the flushes of each signal type so far.
+func (a *flushAges) sentLines() [handlersCount]int64 {
+	var sent [handlersCount]int64
+	for i := range sent {
+		sent[i] = atomic.LoadInt64(&a.sent[i])
+	}
+	return sent
+}
+
+// gauge returns a gauge of the seconds elapsed since the last successful flush of the given signal type.
+func (a *flushAges) gauge(signal SignalType) func() float64 {
+	return func() float64 {
+		return a.clock.Now().Sub(time.Unix(0, atomic.LoadInt64(&a.last[signal]))).Seconds()
+	}
+}
+
+// register adds the gauge of the given signal type to registry.
+func (a *flushAges) register(registry *internal.MetricRegistry, signal SignalType) {
+	registry.NewGaugeFloat64(handlerNames[signal]+".last_flush_age.seconds", a.gauge(signal))
+}
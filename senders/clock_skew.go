@@ -0,0 +1,89 @@
+package senders
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/wavefronthq/wavefront-sdk-go/internal"
+)
+
+// ClockSkewPolicy controls what is done with the metrics and spans whose timestamp is further from the current time
+// of the sender's clock than the max clock skew, which usually denotes a unit mismatch (e.g. milliseconds sent as
+// seconds) or a timezone bug, the data landing in the wrong time window.
+type ClockSkewPolicy int
+
+const (
+	// CountClockSkew sends the data, counting it by the "points.clock_skew" or "spans.clock_skew" internal metric.
+	// This is the default.
+	CountClockSkew ClockSkewPolicy = iota
+	// LogClockSkew sends the data, counting it and logging a warning.
+	LogClockSkew
+	// RejectClockSkew rejects the data as invalid, counting it and returning an error.
+	RejectClockSkew
+)
+
+// clockSkewError reports a timestamp further from the current time than the max clock skew.
+type clockSkewError struct {
+	kind string
+	name string
+	skew time.Duration
+	max  time.Duration
+}
+
+func (e *clockSkewError) Error() string {
+	return fmt.Sprintf("invalid timestamp for %s %s: %v off the current time, more than the max clock skew of %v, check its unit",
+		e.kind, e.name, e.skew, e.max)
+}
+
+// clockSkewGuard applies the clock skew policy to the timestamps of the data sent. A nil guard accepts all of them.
+type clockSkewGuard struct {
+	max    time.Duration
+	policy ClockSkewPolicy
+	clock  Clock
+}
+
+// newClockSkewGuard returns a guard flagging the timestamps more than max away from the current time, nil when max <= 0.
+func newClockSkewGuard(max time.Duration, policy ClockSkewPolicy, clock Clock) *clockSkewGuard {
+	if max <= 0 {
+		return nil
+	}
+	return &clockSkewGuard{max: max, policy: policy, clock: clock}
+}
+
+// checkMetric applies the policy to the timestamp of a metric, counting it with skewed when it's off. The timestamps
+// <= 0, replaced by the current time, are never off. An error is only returned by RejectClockSkew.
+func (g *clockSkewGuard) checkMetric(name string, ts int64, skewed *internal.DeltaCounter) error {
+	if ts <= 0 {
+		return nil
+	}
+	return g.check("metric", name, timestampTime(ts), skewed)
+}
+
+// checkSpan applies the policy to the start of a span, counting it with skewed when it's off.
+// An error is only returned by RejectClockSkew.
+func (g *clockSkewGuard) checkSpan(name string, startMillis int64, skewed *internal.DeltaCounter) error {
+	return g.check("span", name, time.Unix(0, startMillis*int64(time.Millisecond)), skewed)
+}
+
+func (g *clockSkewGuard) check(kind, name string, t time.Time, skewed *internal.DeltaCounter) error {
+	if g == nil {
+		return nil
+	}
+	skew := t.Sub(g.clock.Now())
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew <= g.max {
+		return nil
+	}
+	skewed.Inc()
+	err := &clockSkewError{kind: kind, name: name, skew: skew.Round(time.Second), max: g.max}
+	switch g.policy {
+	case LogClockSkew:
+		log.Println(err)
+	case RejectClockSkew:
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package senders
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxClockSkew(t *testing.T) {
+	now := time.Date(2019, 3, 10, 1, 59, 30, 0, time.UTC)
+	yearAgo := now.AddDate(-1, 0, 0)
+
+	for _, policy := range []ClockSkewPolicy{CountClockSkew, LogClockSkew, RejectClockSkew} {
+		sender, handlers := newTestProxySender(t, &ProxyConfiguration{
+			Host:         "localhost",
+			MetricsPort:  30000,
+			TracingPort:  40000,
+			MaxClockSkew: time.Hour,
+			ClockSkew:    policy,
+			Clock:        fixedClock{now: now},
+		})
+
+		// within the max skew, in seconds and milliseconds
+		require.NoError(t, sender.SendMetric("foo.metric", 1.2, now.Add(-time.Minute).Unix(), "localhost", nil))
+		require.NoError(t, sender.SendMetric("foo.metric", 1.2, now.UnixNano()/int64(time.Millisecond), "localhost", nil))
+		require.NoError(t, sender.SendMetric("foo.metric", 1.2, 0, "localhost", nil))
+		require.NoError(t, sender.SendSpan("getAllUsers", now.UnixNano()/int64(time.Millisecond), 343, "localhost",
+			testTraceId, testSpanId, nil, nil, nil, nil))
+		assert.Equal(t, int64(0), sender.pointsClockSkew.Count())
+		assert.Equal(t, int64(0), sender.spansClockSkew.Count())
+
+		// a year off
+		metricErr := sender.SendMetric("foo.metric", 1.2, yearAgo.Unix(), "localhost", nil)
+		intErr := sender.SendIntMetric("foo.metric", 1, yearAgo.Unix(), "localhost", nil)
+		spanErr := sender.SendSpan("getAllUsers", yearAgo.UnixNano()/int64(time.Millisecond), 343, "localhost",
+			testTraceId, testSpanId, nil, nil, nil, nil)
+		spansErrs := sender.SendSpans([]Span{{Name: "getAllUsers", StartMillis: yearAgo.UnixNano() / int64(time.Millisecond),
+			DurationMillis: 343, Source: "localhost", TraceId: testTraceId, SpanId: testSpanId}})
+		assert.Equal(t, int64(2), sender.pointsClockSkew.Count())
+		assert.Equal(t, int64(2), sender.spansClockSkew.Count())
+
+		if policy == RejectClockSkew {
+			require.Error(t, metricErr)
+			assert.Contains(t, metricErr.Error(), "8760h0m0s off the current time, more than the max clock skew of 1h0m0s")
+			assert.Error(t, intErr)
+			assert.Error(t, spanErr)
+			assert.Error(t, spansErrs[0])
+			assert.Len(t, handlers[metricHandler].lines, 3)
+			assert.Len(t, handlers[spanHandler].lines, 1)
+			assert.Equal(t, int64(2), sender.pointsInvalid.Count())
+		} else {
+			assert.NoError(t, metricErr)
+			assert.NoError(t, intErr)
+			assert.NoError(t, spanErr)
+			assert.NoError(t, spansErrs[0])
+			assert.Len(t, handlers[metricHandler].lines, 5)
+			assert.Len(t, handlers[spanHandler].lines, 3)
+		}
+		sender.Close()
+	}
+}
+
+func TestNoClockSkewGuard(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{
+		Host:        "localhost",
+		MetricsPort: 30000,
+		Clock:       fixedClock{now: time.Date(2019, 3, 10, 1, 59, 30, 0, time.UTC)},
+	})
+	defer sender.Close()
+
+	require.NoError(t, sender.SendMetric("foo.metric", 1.2, 1, "localhost", nil))
+	assert.Len(t, handlers[metricHandler].lines, 1)
+	assert.Equal(t, int64(0), sender.pointsClockSkew.Count())
+}
+
+func TestRetryingSenderClockSkew(t *testing.T) {
+	inner := &fakeSender{failures: 10, err: invalidData(&clockSkewError{kind: "metric", name: "foo", skew: time.Hour, max: time.Minute})}
+	sender := NewRetryingSender(inner, RetryConfig{InitialBackoff: time.Millisecond})
+
+	assert.Error(t, sender.SendMetric("foo", 1, 1533529977, "", nil))
+	assert.Equal(t, int64(0), sender.GetRetryCount(), "clock skew errors are not retried")
+}
@@ -0,0 +1,107 @@
+package senders
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrPortNotConfigured is matched, using errors.Is, by the errors returned when sending data
+// to a proxy sender without a port configured for the signal of that data.
+var ErrPortNotConfigured = errors.New("proxy port not configured")
+
+// portError reports data sent to a proxy sender without a port configured for its signal.
+type portError struct {
+	msg string
+}
+
+func (e *portError) Error() string {
+	return e.msg
+}
+
+func (e *portError) Is(target error) bool {
+	return target == ErrPortNotConfigured
+}
+
+// PartialSendError is returned by the combined sends skipping the unconfigured signals,
+// when the data of some signals was skipped because the sender has no port configured for them.
+type PartialSendError struct {
+	Sent    []SignalType
+	Skipped []SignalType
+}
+
+func (e *PartialSendError) Error() string {
+	skipped := make([]string, len(e.Skipped))
+	for i, signal := range e.Skipped {
+		skipped[i] = signal.String()
+	}
+	return fmt.Sprintf("partially sent: no proxy port configured for %s", strings.Join(skipped, ","))
+}
+
+// CombinedOption configures the combined sends, sending data of several signals at once.
+type CombinedOption func(*combinedSend)
+
+// SkipUnconfiguredSignals makes the combined sends skip the signals without a proxy port configured
+// and return a *PartialSendError instead of failing, letting a single sender be used across
+// environments configuring different ports. The send still fails when no signal is configured.
+func SkipUnconfiguredSignals() CombinedOption {
+	return func(cs *combinedSend) {
+		cs.skipUnconfigured = true
+	}
+}
+
+type combinedSend struct {
+	skipUnconfigured bool
+	signals          []SignalType
+	sends            []func() error
+}
+
+func (cs *combinedSend) add(signal SignalType, send func() error) {
+	cs.signals = append(cs.signals, signal)
+	cs.sends = append(cs.sends, send)
+}
+
+// send sends the data of every signal, even when some fail.
+func (cs *combinedSend) send() error {
+	var errs multiError
+	partial := &PartialSendError{}
+	for i, send := range cs.sends {
+		err := send()
+		switch {
+		case err == nil:
+			partial.Sent = append(partial.Sent, cs.signals[i])
+		case cs.skipUnconfigured && errors.Is(err, ErrPortNotConfigured):
+			partial.Skipped = append(partial.Skipped, cs.signals[i])
+		default:
+			errs.add(err)
+		}
+	}
+	if err := errs.get(); err != nil {
+		return err
+	}
+	if len(partial.Skipped) == 0 {
+		return nil
+	}
+	if len(partial.Sent) == 0 {
+		return fmt.Errorf("nothing sent: %w", ErrPortNotConfigured)
+	}
+	return partial
+}
+
+// SendMetricAndEvent sends both a metric, timestamped by Wavefront, and an instant event of the same name,
+// source and tags, e.g. an application heartbeat visible both on charts and as an event.
+// The event is sent even when the metric fails.
+func SendMetricAndEvent(sender Sender, name string, value float64, source string, tags map[string]string, opts ...CombinedOption) error {
+	cs := &combinedSend{}
+	for _, opt := range opts {
+		opt(cs)
+	}
+	cs.add(MetricSignal, func() error {
+		return sendMetricNow(sender, name, value, source, tags)
+	})
+	cs.add(EventSignal, func() error {
+		return sender.SendEvent(name, senderNow(sender).UnixNano()/int64(time.Millisecond), 0, source, tags)
+	})
+	return cs.send()
+}
@@ -0,0 +1,58 @@
+package senders
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendMetricAndEvent(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", MetricsPort: 50000, EventsPort: 50001})
+	defer sender.Close()
+
+	require.NoError(t, SendMetricAndEvent(sender, "app.heartbeat", 1, "test_source", map[string]string{"env": "test"}))
+	assert.Equal(t, "\"app.heartbeat\" 1 source=\"test_source\" \"env\"=\"test\"\n", handlers[metricHandler].data())
+	assert.Contains(t, handlers[eventHandler].data(), "\"app.heartbeat\" host=\"test_source\"")
+}
+
+func TestSendMetricAndEventUnconfiguredPort(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", MetricsPort: 50000})
+	defer sender.Close()
+
+	err := SendMetricAndEvent(sender, "app.heartbeat", 1, "test_source", nil)
+	assert.True(t, errors.Is(err, ErrPortNotConfigured))
+	assert.Equal(t, "\"app.heartbeat\" 1 source=\"test_source\"\n", handlers[metricHandler].data())
+
+	err = SendMetricAndEvent(sender, "app.heartbeat", 2, "test_source", nil, SkipUnconfiguredSignals())
+	var partial *PartialSendError
+	require.True(t, errors.As(err, &partial), "%v", err)
+	assert.Equal(t, []SignalType{MetricSignal}, partial.Sent)
+	assert.Equal(t, []SignalType{EventSignal}, partial.Skipped)
+	assert.EqualError(t, err, "partially sent: no proxy port configured for events")
+	assert.Equal(t, "\"app.heartbeat\" 1 source=\"test_source\"\n\"app.heartbeat\" 2 source=\"test_source\"\n", handlers[metricHandler].data())
+}
+
+func TestSendMetricAndEventNoConfiguredPort(t *testing.T) {
+	sender, _ := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", TracingPort: 50000})
+	defer sender.Close()
+
+	err := SendMetricAndEvent(sender, "app.heartbeat", 1, "test_source", nil, SkipUnconfiguredSignals())
+	assert.True(t, errors.Is(err, ErrPortNotConfigured))
+	var partial *PartialSendError
+	assert.False(t, errors.As(err, &partial))
+}
+
+func TestSendMetricAndEventClock(t *testing.T) {
+	now := time.Date(2019, 3, 10, 1, 59, 30, 0, time.UTC)
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", MetricsPort: 50000, EventsPort: 50001,
+		Clock: fixedClock{now}})
+	defer sender.Close()
+
+	// the clock of the sender is found through its decorators
+	tagging := NewTaggingSender(sender, map[string]string{"env": "test"})
+	require.NoError(t, SendMetricAndEvent(tagging, "app.heartbeat", 1, "test_source", nil))
+	assert.Contains(t, handlers[eventHandler].data(), "@Event 1552183170000 1552183170001 \"app.heartbeat\"")
+}
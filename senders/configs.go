@@ -1,14 +1,27 @@
 package senders
 
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/wavefronthq/wavefront-sdk-go/histogram"
+)
+
 const (
 	defaultBatchSize          = 10000
 	defaultBufferSize         = 50000
 	defaultFlushInterval      = 1
 	defaultProxyFlushInterval = 5
+	defaultTimestampHorizon   = 24 * time.Hour
+	// fits in an Ethernet frame (1500 bytes MTU) with the IP and UDP headers, as statsd clients do
+	defaultMaxDatagramBytes = 1432
 )
 
 // Configuration for the direct ingestion sender
-// Deprecated: Use 'senders.NewSender(url)'
+// Deprecated: Use 'senders.NewSender(url)' with options. Legacy, kept for the compatibility of existing callers.
 type DirectConfiguration struct {
 	Server string // Wavefront URL of the form https://<INSTANCE>.wavefront.com
 	Token  string // Wavefront API token with direct data ingestion permission
@@ -32,7 +45,8 @@ type DirectConfiguration struct {
 }
 
 // Configuration for the proxy sender
-// Deprecated: Use 'senders.NewSender(url)'
+// Deprecated: Use 'senders.NewSender("proxy://host:port")' with options, each field having its own option.
+// Legacy, kept for the compatibility of existing callers.
 type ProxyConfiguration struct {
 	Host string // the hostname of the Wavefront proxy
 
@@ -43,5 +57,334 @@ type ProxyConfiguration struct {
 	TracingPort      int // tracing port on which the proxy is listening on.
 	EventsPort       int // events port on which the proxy is listening on.
 
+	// port on which the proxy is listening on for span logs, when they're sent to a different port than the spans.
+	// defaults to 0, the span logs being sent to TracingPort.
+	SpanLogsPort int
+
+	// when set, the metrics are sent to MetricsPort over UDP, fire-and-forget, e.g. for workloads preferring a bounded
+	// latency and accepting loss, statsd-style: the lines of the datagrams lost on the way are not detected. the
+	// metrics routes and the other signals still use TCP. ignored with UnixSocket. defaults to false.
+	MetricsUDP bool
+
+	// max size (in bytes) of the UDP datagrams with MetricsUDP. longer lines are dropped with an error, set
+	// MaxMetricLineBytes to at most that size to handle them per OversizedLines instead. defaults to 1432.
+	MaxDatagramBytes int
+
+	// when set with MetricsUDP, the metric lines are packed in datagrams of up to MaxDatagramBytes, sent once full
+	// or every FlushIntervalSeconds. defaults to false, each line being sent right away in its own datagram.
+	PackDatagrams bool
+
+	// path of a Unix domain socket the proxy listens on, e.g. "/var/run/wavefront.sock" or
+	// "unix:///var/run/wavefront.sock", avoiding TCP on single-host deployments. when set, Host and the ports are
+	// ignored, the data of all the signals being written to the socket, on a connection each. defaults to "", TCP.
+	UnixSocket string
+
+	// additional metrics ports, by route key, each the "host:port" address of a proxy metrics port, e.g. of a proxy
+	// dedicated to critical metrics, or the "unix://" URL of a socket. only used with RouteFunc.
+	MetricsRoutes map[string]string
+
+	// selects the key of the metrics route of each metric sent with SendMetric, SendMetricNow or SendDeltaCounter.
+	// metrics routed to "" or to a key missing from MetricsRoutes fall back to the default metrics port, MetricsPort.
+	// defaults to nil, all the metrics being sent to MetricsPort.
+	RouteFunc RouteFunc
+
 	FlushIntervalSeconds int // defaults to 1 second
+
+	// when set, the first flush of each port is delayed by a random duration up to the flush interval,
+	// so that a fleet of instances started at the same time don't flush in sync.
+	FlushJitter bool
+
+	// when set, the ports are flushed by a single goroutine instead of a goroutine each, see FlushScheduler.
+	// the heartbeats still need a goroutine per port.
+	SharedFlushLoop bool
+
+	// scheduler flushing the ports along with those of the other senders sharing it, instead of the flush interval
+	// of the sender. defaults to nil, none.
+	FlushScheduler *FlushScheduler
+
+	DisableSpanLogs bool // when set, spans are still sent but their span logs are dropped.
+
+	// head sampling rate (0.0 - 1.0) of the traces whose spans are sent, decided per traceId.
+	// defaults to 0, which disables sampling and sends every span.
+	TraceSampleRate float64
+
+	// when set, every trace is sampled out, its spans being dropped as by a TraceSampleRate keeping none.
+	// defaults to false.
+	DropAllTraces bool
+
+	// sampling rate (0.0 - 1.0) of the span logs of the spans kept, decided per spanId.
+	// defaults to 0, which disables sampling and sends the span logs of every span kept. see DisableSpanLogs to
+	// drop them all.
+	SpanLogSampleRate float64
+
+	SpanTagDedup SpanTagDedupPolicy // how span tags with duplicate keys are handled. defaults to last-wins.
+
+	// source of the data sent without source. defaults to the first non-empty of: the result of SourceFunc,
+	// the WAVEFRONT_SOURCE environment variable, the hostname of the machine and a fixed fallback
+	// ("wavefront_proxy_sender" or "wavefront_direct_sender").
+	Source string
+
+	// returns the source of the data sent without source when Source is not set, e.g. from instance metadata.
+	// an error or an empty source falls back to the next step. called once, when the sender is created.
+	SourceFunc func() (string, error)
+
+	// key of a span tag (e.g. "host") whose value is the source of the spans sent without source.
+	// an explicit source takes precedence, then the tag, then the default source. the tag is still sent.
+	// defaults to "", none.
+	SpanSourceTag string
+
+	// when set, the surrounding whitespace of the metric, distribution, span and event names and of their tag keys
+	// and values is trimmed, e.g. so that "cpu.load " and "cpu.load" are the same series. defaults to false.
+	TrimWhitespace bool
+
+	// what is done with the metric tags whose key is reserved ("source" or "host"), colliding with the source
+	// of the points: sent as is (KeepReservedTags), dropped (DropReservedTags) or rejected (RejectReservedTags).
+	// defaults to KeepReservedTags.
+	ReservedTags ReservedTagPolicy
+
+	// max duration of the spans, longer ones (usually from a clock bug) being rejected as invalid.
+	// negative durations are always rejected. defaults to 0, no limit.
+	MaxSpanDuration time.Duration
+
+	// when set, the timestamp of each distribution line is aligned on the start of its granularity bin.
+	AlignDistributionTimestamps bool
+
+	// source of the current time used by the sender, e.g. to get deterministic timestamps in tests.
+	// when set, internal metrics are timestamped with it. defaults to the system clock.
+	Clock Clock
+
+	// encoder of the data sent. defaults to the Wavefront data formats.
+	// AlignDistributionTimestamps only applies to the default serializer.
+	Serializer Serializer
+
+	// how far in the future metric timestamps are accepted. later timestamps usually denote a unit mismatch
+	// and are rejected. defaults to 24 hours.
+	TimestampHorizon time.Duration
+
+	DisableInternalMetrics bool // when set, the sender doesn't create nor report its internal metrics.
+
+	RegistryTags map[string]string // additional tags of the internal metrics, e.g. an instance_id.
+
+	// when set, delta counters sharing the same name, source and tags are summed client side
+	// and sent as a single point per flush interval.
+	AggregateDeltaCounters bool
+
+	// when set, metrics sent with SendMetric, SendMetricNow or SendIntMetric sharing the same name, source and tags keep only
+	// their last value, sent as a single point per flush interval.
+	DedupMetrics bool
+
+	// max events sent per minute, the excess events being dropped and counted by the "events.throttled" internal
+	// metric, e.g. so that a loop emitting events doesn't flood the events pipeline. defaults to 0, no limit.
+	MaxEventsPerMinute int
+
+	// max difference between the timestamps of the metrics and spans sent and the current time of the sender's clock,
+	// the data further off being counted by the "points.clock_skew" and "spans.clock_skew" internal metrics, and
+	// logged or rejected per ClockSkew. defaults to 0, no check.
+	MaxClockSkew time.Duration
+
+	// what is done with the metrics and spans further off than MaxClockSkew: counted (CountClockSkew),
+	// also logged (LogClockSkew) or rejected (RejectClockSkew). defaults to CountClockSkew.
+	ClockSkew ClockSkewPolicy
+
+	// metrics sent with SendMetric or SendMetricNow as delta counters, by name suffix (e.g. ".count") or full name.
+	// their timestamp is dropped and their value must be the increment since the last report: cumulative values
+	// would be summed again by the Wavefront service. defaults to none.
+	DeltaCounterSuffixes []string
+	DeltaCounterNames    []string
+
+	// variant of the prefix of the delta counter names. defaults to IncrementDeltaPrefix, "∆".
+	DeltaPrefix DeltaPrefixVariant
+
+	// registry the sender is added to on construction, to be flushed or closed with the other registered senders.
+	// defaults to nil, none.
+	Registry *Registry
+
+	// when set, a greeting line identifying the SDK is sent to the proxy on each connection, letting proxies
+	// that log client versions attribute the traffic. older proxies reject it as an invalid line. defaults to false.
+	SendGreeting bool
+
+	// greeting line sent when SendGreeting is set. defaults to "#wavefront-sdk-go <version>".
+	Greeting string
+
+	// max size (in bytes) of a distribution line. larger distributions are split in several lines,
+	// each with part of the centroids. defaults to 0, no limit.
+	MaxHistogramLineBytes int
+
+	// max centroids of a distribution. when MaxHistogramLineBytes is set, larger distributions are split in lines
+	// of at most that many centroids, otherwise they're rejected as invalid. defaults to 0, no limit.
+	MaxCentroidsPerDistribution int
+
+	// significant digits of the metric and centroid values, e.g. 15 to send 0.3 instead of 0.30000000000000004.
+	// the digits of the integer part are always kept, integers being sent as is. defaults to 0, full precision.
+	ValuePrecision int
+
+	// max size (in bytes) of a metric line. defaults to 32768, the max line length of the proxy.
+	// a negative value disables the limit.
+	MaxMetricLineBytes int
+
+	// how the metric lines longer than MaxMetricLineBytes are handled. defaults to rejecting them with an error.
+	OversizedLines OversizedLinePolicy
+
+	// max number of tag keys, and of tag values, whose formatted form is interned and reused across metric lines,
+	// trading memory for fewer allocations when the same tags are sent repeatedly. defaults to 0, no interning.
+	MaxInternedTags int
+
+	// when set, the data buffered for a port is flushed to the proxy as soon as it holds that many lines,
+	// in addition to the periodic flushes. defaults to 0, flushing only every FlushIntervalSeconds.
+	FlushBatchSize int
+
+	// TCP keep-alive period of the connections to the proxy, letting the OS detect connections silently dropped
+	// by intermediaries (NAT, firewalls). defaults to 0, the Go default (15 seconds). a negative value disables it.
+	KeepAlive time.Duration
+
+	// when set, the connections to the proxy ports are encrypted with TLS, e.g. to a proxy behind a TLS-terminating
+	// load balancer. the certificate of the proxy is verified against Host. defaults to false, plaintext.
+	TLS bool
+
+	// name the certificate of the proxy is verified against when TLS is set. defaults to Host. required with
+	// UnixSocket, whose address has no host, unless TLSInsecureSkipVerify is set.
+	TLSServerName string
+
+	// certificate authorities used to verify the certificate of the proxy when TLS is set. defaults to the host's root CAs.
+	TLSRootCAs *x509.CertPool
+
+	// when set, the certificate of the proxy is not verified, e.g. for self-signed development proxies.
+	// never use it in production. defaults to false.
+	TLSInsecureSkipVerify bool
+
+	// client certificates presented to proxies requiring mutual TLS when TLS is set, e.g. loaded with
+	// tls.LoadX509KeyPair. defaults to none.
+	TLSClientCertificates []tls.Certificate
+
+	// TLS configuration of the connections to the proxy, e.g. with a min version, a server name or a private CA.
+	// when set, the connections are encrypted even if TLS isn't set, and the TLS settings above are ignored.
+	// defaults to nil.
+	TLSConfig *tls.Config
+
+	// deadline of each write to the proxy. a write blocked longer, e.g. on a half-open connection, fails and
+	// resets the connection, the lines it held being counted as write errors and lost.
+	// defaults to 0, 10 seconds. a negative value disables it.
+	WriteTimeout time.Duration
+
+	// when set, the lines written back by the proxy are read, the error responses (e.g. "ERROR: ...") being
+	// counted by the <signal>.report.errors internal metrics, and logged when LogResponseErrors is set.
+	// defaults to false, as proxies usually don't respond.
+	ReadResponses     bool
+	LogResponseErrors bool
+
+	// when set, a heartbeat line is written to the proxy on each connection idle for that long, keeping it open
+	// and detecting broken connections before the next data is sent. defaults to 0, no heartbeat.
+	HeartbeatInterval time.Duration
+
+	// heartbeat line written when HeartbeatInterval is set. defaults to an empty line.
+	Heartbeat string
+
+	// what is done with the lines written to the proxy since the last successful flush when a flush fails:
+	// discarded (DropOnFailure, the default) or retained and written again once reconnected (ReQueueOnFailure).
+	FlushFailure FlushFailurePolicy
+
+	// max lines of each port retained by ReQueueOnFailure, the oldest ones being dropped. defaults to 50,000.
+	MaxRequeuedLines int
+
+	// directory of the files the lines that couldn't be delivered are spilled to instead of being dropped: those of
+	// the failed flushes (or retained by ReQueueOnFailure beyond MaxRequeuedLines), those sent while the proxy is
+	// unreachable and those retained when the sender is closed. they're written again after the successful
+	// flushes, including by the next process using the directory, some of them possibly being duplicated. a file per
+	// port, e.g. "points.spool". the directory must not be shared between senders. defaults to "", none.
+	SpoolDir string
+
+	// max size (in bytes) of the spool file of each port, the lines spilled once it's full being dropped.
+	// defaults to 64 MB.
+	SpoolMaxBytes int64
+
+	// called each time a connection to the proxy is established, with the signal type sent on that connection.
+	// callbacks are invoked without holding any sender lock and may send data.
+	OnConnect func(signal SignalType)
+
+	// called each time a connection to the proxy is lost after an error, or closed with a nil error
+	// when the sender is closed.
+	OnDisconnect func(signal SignalType, err error)
+
+	// called each time an attempt to (re)connect to the proxy fails.
+	OnReconnectFailed func(signal SignalType, err error)
+
+	// called after each flush of buffered lines to the proxy, with the number of lines written and failed
+	// (lost with the connection) and the flush error. invoked without holding any sender lock.
+	OnFlush func(signal SignalType, sent, failed int, err error)
+
+	// when set, the span logs of the spans sent are buffered and written to the proxy in a single batch
+	// per flush interval, instead of once per span. defaults to false.
+	BatchSpanLogs bool
+
+	// called with the key and value of each tag of the points, distributions, spans and events sent.
+	// data with a tag it rejects is counted invalid and not sent. defaults to nil, no validation.
+	TagValidator func(key, value string) error
+
+	// when set, data rejected by TagValidator is skipped silently instead of returning the validation error.
+	// defaults to false.
+	SkipInvalidTags bool
+
+	// granularities of the distributions sent without any. defaults to none, such distributions being rejected.
+	DefaultHistogramGranularities map[histogram.Granularity]bool
+
+	// rewrites the names of the metrics sent, e.g. OpenMetricsNameSanitizer. delta counter prefixes are kept
+	// and internal metrics are not rewritten. only applies to the default serializer. defaults to nil, names sent as is.
+	MetricNameSanitizer func(name string) string
+}
+
+// Validate checks the configuration, returning an error describing the first problem found:
+// a missing host, no port enabled, a port out of range or a negative flush interval.
+func (cfg *ProxyConfiguration) Validate() error {
+	ports := []struct {
+		name string
+		port int
+	}{
+		{"metrics", cfg.MetricsPort},
+		{"distribution", cfg.DistributionPort},
+		{"tracing", cfg.TracingPort},
+		{"events", cfg.EventsPort},
+	}
+	if cfg.UnixSocket == "" {
+		if cfg.SpanLogsPort != 0 && (cfg.SpanLogsPort < 1 || cfg.SpanLogsPort > 65535) {
+			return fmt.Errorf("invalid proxy span logs port %d: must be between 1 and 65535", cfg.SpanLogsPort)
+		}
+
+		enabled := false
+		for _, p := range ports {
+			if p.port == 0 {
+				continue
+			}
+			if p.port < 1 || p.port > 65535 {
+				return fmt.Errorf("invalid proxy %s port %d: must be between 1 and 65535", p.name, p.port)
+			}
+			enabled = true
+		}
+		if !enabled {
+			return errors.New("at least one proxy port should be enabled")
+		}
+		if cfg.Host == "" {
+			return errors.New("proxy host is required")
+		}
+	}
+	if cfg.FlushIntervalSeconds < 0 {
+		return fmt.Errorf("invalid flush interval %d: must be positive, or 0 for the default", cfg.FlushIntervalSeconds)
+	}
+	if cfg.FlushBatchSize < 0 {
+		return fmt.Errorf("invalid flush batch size %d: must be positive, or 0 to disable", cfg.FlushBatchSize)
+	}
+	if cfg.MaxDatagramBytes < 0 {
+		return fmt.Errorf("invalid max datagram size %d: must be positive, or 0 for the default", cfg.MaxDatagramBytes)
+	}
+	if cfg.UnixSocket != "" && proxyTLSConfig(cfg) != nil {
+		tlsCfg := proxyTLSConfig(cfg)
+		if tlsCfg.ServerName == "" && !tlsCfg.InsecureSkipVerify {
+			return errors.New("TLS over a Unix socket requires TLSServerName, or a ServerName in TLSConfig")
+		}
+	}
+	for key, address := range cfg.MetricsRoutes {
+		if key == "" || address == "" {
+			return fmt.Errorf("invalid metrics route %q: both its key and address are required", key)
+		}
+	}
+	return nil
 }
@@ -0,0 +1,95 @@
+package senders
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// lastValueAggregator keeps the last value of the metrics sharing the same name, source and tags,
+// sending a single point per series when flushed.
+type lastValueAggregator struct {
+	send        func(name string, value metricValue, ts int64, source string, tags map[string]string) error
+	flushTicker *time.Ticker
+	done        chan struct{}
+
+	mtx    sync.Mutex
+	series map[string]*lastValue
+}
+
+type lastValue struct {
+	name   string
+	source string
+	tags   map[string]string
+	value  metricValue
+	ts     int64
+}
+
+func newLastValueAggregator(flushInterval time.Duration, send func(name string, value metricValue, ts int64, source string, tags map[string]string) error) *lastValueAggregator {
+	return &lastValueAggregator{
+		send:        send,
+		flushTicker: time.NewTicker(flushInterval),
+		series:      make(map[string]*lastValue),
+	}
+}
+
+func (a *lastValueAggregator) start() {
+	a.done = make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-a.flushTicker.C:
+				if err := a.flush(); err != nil {
+					log.Println(err)
+				}
+			case <-a.done:
+				return
+			}
+		}
+	}()
+}
+
+// stop stops the periodic flushes and flushes the pending values.
+func (a *lastValueAggregator) stop() error {
+	a.flushTicker.Stop()
+	if a.done != nil {
+		close(a.done)
+	}
+	return a.flush()
+}
+
+// add records the value of a series, replacing the one recorded since the last flush.
+func (a *lastValueAggregator) add(name string, value metricValue, ts int64, source string, tags map[string]string) {
+	key := deltaKey(name, source, tags)
+
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	if last, ok := a.series[key]; ok {
+		last.value, last.ts = value, ts
+		return
+	}
+	// copy the tags as the caller could modify them before the flush
+	tagsCopy := make(map[string]string, len(tags))
+	for k, v := range tags {
+		tagsCopy[k] = v
+	}
+	a.series[key] = &lastValue{name: name, source: source, tags: tagsCopy, value: value, ts: ts}
+}
+
+// flush sends the last values and resets them.
+func (a *lastValueAggregator) flush() error {
+	a.mtx.Lock()
+	series := a.series
+	a.series = make(map[string]*lastValue, len(series))
+	a.mtx.Unlock()
+
+	var errors multiError
+	for _, last := range series {
+		if err := a.send(last.name, last.value, last.ts, last.source, last.tags); err != nil {
+			errors.add(err)
+		}
+	}
+	return errors.get()
+}
@@ -0,0 +1,28 @@
+package senders
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDedupMetrics(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", MetricsPort: 50000, DedupMetrics: true})
+
+	require.NoError(t, sender.SendMetric("new-york.power.usage", 42422, 1533529977, "go_test", nil))
+	require.NoError(t, sender.SendMetric("new-york.power.usage", 42423, 1533529987, "go_test", nil))
+	require.NoError(t, sender.SendMetric("new-york.power.usage", 42424, 1533529997, "go_test", nil))
+	// delta counters are still sent as is
+	require.NoError(t, sender.SendDeltaCounter("lambda.thumbnail.generate", 10, "go_test", nil))
+	assert.Equal(t, "\"∆lambda.thumbnail.generate\" 10 source=\"go_test\"\n", handlers[metricHandler].data())
+
+	require.NoError(t, sender.Flush())
+	assert.Equal(t, "\"∆lambda.thumbnail.generate\" 10 source=\"go_test\"\n"+
+		"\"new-york.power.usage\" 42424 1533529997 source=\"go_test\"\n", handlers[metricHandler].data())
+
+	require.NoError(t, sender.SendMetricNow("new-york.power.usage", 1, "go_test", map[string]string{"env": "test"}))
+	require.NoError(t, sender.SendMetricNow("new-york.power.usage", 2, "go_test", map[string]string{"env": "test"}))
+	require.NoError(t, sender.CloseWithError())
+	assert.Equal(t, "\"new-york.power.usage\" 2 source=\"go_test\" \"env\"=\"test\"\n", handlers[metricHandler].lines[2])
+}
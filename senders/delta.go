@@ -0,0 +1,130 @@
+package senders
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wavefronthq/wavefront-sdk-go/internal"
+)
+
+// DeltaPrefixVariant is the character prefixing the names of the delta counters. Wavefront accepts both variants,
+// names already starting with either of them are sent as is.
+type DeltaPrefixVariant int
+
+const (
+	// IncrementDeltaPrefix is "∆" (U+2206 INCREMENT). This is the default.
+	IncrementDeltaPrefix DeltaPrefixVariant = iota
+	// GreekDeltaPrefix is "Δ" (U+0394 GREEK CAPITAL LETTER DELTA), for the systems expecting it.
+	GreekDeltaPrefix
+)
+
+// deltaCounterName returns the name of a delta counter, prefixed by the given variant unless it already has a delta prefix.
+func deltaCounterName(name string, variant DeltaPrefixVariant) string {
+	if variant == GreekDeltaPrefix {
+		return internal.PrefixedDeltaCounterName(name, internal.AltDeltaPrefix)
+	}
+	return internal.DeltaCounterName(name)
+}
+
+// deltaAggregator sums the delta counters sharing the same name, source and tags,
+// sending a single point per counter when flushed.
+type deltaAggregator struct {
+	send        func(name string, value float64, source string, tags map[string]string) error
+	flushTicker *time.Ticker
+	done        chan struct{}
+
+	mtx      sync.Mutex
+	counters map[string]*aggregatedDelta
+}
+
+type aggregatedDelta struct {
+	name   string
+	source string
+	tags   map[string]string
+	value  float64
+}
+
+func newDeltaAggregator(flushInterval time.Duration, send func(name string, value float64, source string, tags map[string]string) error) *deltaAggregator {
+	return &deltaAggregator{
+		send:        send,
+		flushTicker: time.NewTicker(flushInterval),
+		counters:    make(map[string]*aggregatedDelta),
+	}
+}
+
+func (a *deltaAggregator) start() {
+	a.done = make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-a.flushTicker.C:
+				if err := a.flush(); err != nil {
+					log.Println(err)
+				}
+			case <-a.done:
+				return
+			}
+		}
+	}()
+}
+
+// stop stops the periodic flushes and flushes the pending counters.
+func (a *deltaAggregator) stop() error {
+	a.flushTicker.Stop()
+	if a.done != nil {
+		close(a.done)
+	}
+	return a.flush()
+}
+
+func (a *deltaAggregator) add(name string, value float64, source string, tags map[string]string) {
+	key := deltaKey(name, source, tags)
+
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	if counter, ok := a.counters[key]; ok {
+		counter.value += value
+		return
+	}
+	// copy the tags as the caller could modify them before the flush
+	tagsCopy := make(map[string]string, len(tags))
+	for k, v := range tags {
+		tagsCopy[k] = v
+	}
+	a.counters[key] = &aggregatedDelta{name: name, source: source, tags: tagsCopy, value: value}
+}
+
+// flush sends the aggregated counters and resets them.
+func (a *deltaAggregator) flush() error {
+	a.mtx.Lock()
+	counters := a.counters
+	a.counters = make(map[string]*aggregatedDelta, len(counters))
+	a.mtx.Unlock()
+
+	var errors multiError
+	for _, counter := range counters {
+		if err := a.send(counter.name, counter.value, counter.source, counter.tags); err != nil {
+			errors.add(err)
+		}
+	}
+	return errors.get()
+}
+
+// deltaKey identifies a delta counter by its name, source and tags.
+func deltaKey(name, source string, tags map[string]string) string {
+	var sb strings.Builder
+	sb.WriteString(name)
+	sb.WriteByte(0)
+	sb.WriteString(source)
+	for _, k := range sortedKeys(tags) {
+		sb.WriteByte(0)
+		sb.WriteString(k)
+		sb.WriteByte(0)
+		sb.WriteString(tags[k])
+	}
+	return sb.String()
+}
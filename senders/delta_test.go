@@ -0,0 +1,78 @@
+package senders
+
+import (
+	"math"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeltaAggregator(t *testing.T) {
+	var sent []aggregatedDelta
+	aggregator := newDeltaAggregator(time.Hour, func(name string, value float64, source string, tags map[string]string) error {
+		sent = append(sent, aggregatedDelta{name: name, source: source, tags: tags, value: value})
+		return nil
+	})
+
+	tags := map[string]string{"format": "jpeg", "size": "small"}
+	aggregator.add("∆thumbnail.generate", 1, "svc", tags)
+	aggregator.add("∆thumbnail.generate", 2, "svc", map[string]string{"size": "small", "format": "jpeg"})
+	aggregator.add("∆thumbnail.generate", 4, "svc", map[string]string{"format": "png"})
+	aggregator.add("∆thumbnail.generate", 8, "other", tags)
+	aggregator.add("∆thumbnail.generate", 16, "svc", nil)
+	aggregator.add("∆thumbnail.generate", 32, "svc", map[string]string{})
+	tags["format"] = "gif"
+
+	require.NoError(t, aggregator.flush())
+	sort.Slice(sent, func(i, j int) bool { return sent[i].value < sent[j].value })
+	assert.Equal(t, []aggregatedDelta{
+		{name: "∆thumbnail.generate", source: "svc", tags: map[string]string{"format": "jpeg", "size": "small"}, value: 3},
+		{name: "∆thumbnail.generate", source: "svc", tags: map[string]string{"format": "png"}, value: 4},
+		{name: "∆thumbnail.generate", source: "other", tags: map[string]string{"format": "jpeg", "size": "small"}, value: 8},
+		{name: "∆thumbnail.generate", source: "svc", tags: map[string]string{}, value: 48},
+	}, sent)
+
+	// the counters are reset on flush
+	sent = nil
+	require.NoError(t, aggregator.flush())
+	assert.Empty(t, sent)
+}
+
+func TestAggregateDeltaCounters(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", MetricsPort: 50000, AggregateDeltaCounters: true})
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, sender.SendDeltaCounter("lambda.thumbnail.generate", 1, "thumbnail_service", nil))
+	}
+	assert.Error(t, sender.SendDeltaCounter("lambda.thumbnail.generate", math.Inf(1), "thumbnail_service", nil))
+	assert.Empty(t, handlers[metricHandler].lines)
+
+	require.NoError(t, sender.Flush())
+	assert.Equal(t, "\"∆lambda.thumbnail.generate\" 10 source=\"thumbnail_service\"\n", handlers[metricHandler].data())
+
+	require.NoError(t, sender.SendDeltaCounter("lambda.thumbnail.generate", 5, "thumbnail_service", nil))
+	require.NoError(t, sender.CloseWithError())
+	assert.Equal(t, "\"∆lambda.thumbnail.generate\" 10 source=\"thumbnail_service\"\n"+
+		"\"∆lambda.thumbnail.generate\" 5 source=\"thumbnail_service\"\n", handlers[metricHandler].data())
+}
+
+func TestDeltaPrefix(t *testing.T) {
+	for variant, prefix := range map[DeltaPrefixVariant]string{IncrementDeltaPrefix: "∆", GreekDeltaPrefix: "Δ"} {
+		sender, handlers := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", MetricsPort: 50000, DeltaPrefix: variant})
+
+		require.NoError(t, sender.SendDeltaCounter("lambda.thumbnail.generate", 10, "localhost", nil))
+		// names with either prefix are sent as is
+		require.NoError(t, sender.SendDeltaCounter("∆lambda.thumbnail.resize", 10, "localhost", nil))
+		require.NoError(t, sender.SendDeltaCounter("Δlambda.thumbnail.crop", 10, "localhost", nil))
+		assert.Equal(t, []string{
+			"\"" + prefix + "lambda.thumbnail.generate\" 10 source=\"localhost\"\n",
+			"\"∆lambda.thumbnail.resize\" 10 source=\"localhost\"\n",
+			"\"Δlambda.thumbnail.crop\" 10 source=\"localhost\"\n",
+		}, handlers[metricHandler].lines, prefix)
+		assert.Equal(t, prefix+"errors", deltaCounterName(deltaCounterName("errors", variant), variant))
+		sender.Close()
+	}
+}
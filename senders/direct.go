@@ -12,6 +12,15 @@ import (
 	"github.com/wavefronthq/wavefront-sdk-go/version"
 )
 
+// ReportError is returned by the flushes of the direct sender when a batch of data could not be reported,
+// and passed to the OnFlush callback. Use errors.As to get it, and its Retryable method to tell the network errors,
+// 5xx and throttling (406, 429) statuses, after which the data is buffered again and counted by the
+// <signal>.report.errors internal metric, from the other 4xx statuses rejecting the data, which is dropped and
+// counted by the <signal>.report.invalid internal metric.
+// A 429 response is counted by the <signal>.report.throttled internal metric instead, the flushes of the signal
+// being paused for the delay of its Retry-After header, if any.
+type ReportError = internal.ReportError
+
 type directSender struct {
 	reporter         internal.Reporter
 	defaultSource    string
@@ -207,6 +216,62 @@ func (sender *directSender) SendSpan(name string, startMillis, durationMillis in
 	return nil
 }
 
+// SendSpans formats the lines of all the spans, then buffers them, and their span logs, as one batch of each.
+func (sender *directSender) SendSpans(spans []Span) []error {
+	errs := make([]error, len(spans))
+	// the lines to buffer, and the indexes of the spans they belong to
+	var spanLines, logLines []string
+	var written []int
+	for i, span := range spans {
+		line, err := SpanLine(span.Name, span.StartMillis, span.DurationMillis, span.Source, span.TraceId, span.SpanId,
+			span.Parents, span.FollowsFrom, span.Tags, span.SpanLogs, sender.defaultSource)
+		if err != nil {
+			sender.spansInvalid.Inc()
+			errs[i] = err
+			continue
+		}
+		// the span logs are formatted first, a span whose span logs are invalid being rejected with them
+		if len(span.SpanLogs) > 0 {
+			logs, err := SpanLogJSON(span.TraceId, span.SpanId, span.SpanLogs)
+			if err != nil {
+				sender.spansInvalid.Inc()
+				sender.spanLogsInvalid.Inc()
+				errs[i] = err
+				continue
+			}
+			sender.spanLogsValid.Inc()
+			logLines = append(logLines, logs)
+		}
+		sender.spansValid.Inc()
+		spanLines = append(spanLines, line)
+		written = append(written, i)
+	}
+	if len(spanLines) == 0 {
+		return errs
+	}
+
+	if err := sender.spanHandler.HandleLines(spanLines); err != nil {
+		// the span logs are dropped along with their spans
+		sender.spansDropped.Add(int64(len(spanLines)))
+		sender.spanLogsDropped.Add(int64(len(logLines)))
+		for _, i := range written {
+			errs[i] = err
+		}
+		return errs
+	}
+	if len(logLines) > 0 {
+		if err := sender.spanLogHandler.HandleLines(logLines); err != nil {
+			sender.spanLogsDropped.Add(int64(len(logLines)))
+			for _, i := range written {
+				if len(spans[i].SpanLogs) > 0 {
+					errs[i] = err
+				}
+			}
+		}
+	}
+	return errs
+}
+
 func (sender *directSender) SendEvent(name string, startMillis, endMillis int64, source string, tags map[string]string, setters ...event.Option) error {
 	line, err := EventLineJSON(name, startMillis, endMillis, source, tags, setters...)
 	if err != nil {
@@ -1,8 +1,16 @@
 package senders_test
 
 import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/wavefronthq/wavefront-sdk-go/histogram"
 	"github.com/wavefronthq/wavefront-sdk-go/senders"
 )
@@ -63,3 +71,148 @@ func TestDirectSends(t *testing.T) {
 	}
 
 }
+
+// countingTransport counts the requests sent through it.
+type countingTransport struct {
+	requests int64
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt64(&t.requests, 1)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestDirectSenderStatuses(t *testing.T) {
+	tests := []struct {
+		status    int
+		retryable bool
+		pending   int
+	}{
+		{http.StatusBadRequest, false, 0},
+		{http.StatusTooManyRequests, true, 1},
+		{http.StatusServiceUnavailable, true, 1},
+	}
+
+	for _, test := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(test.status)
+		}))
+		transport := &countingTransport{}
+		sender, err := senders.NewSender(strings.Replace(server.URL, "http://", "http://DUMMY_TOKEN@", 1),
+			senders.FlushIntervalSeconds(3600), senders.WithHTTPClient(&http.Client{Transport: transport}))
+		require.NoError(t, err)
+
+		require.NoError(t, sender.SendMetric("foo.metric", 1.2, 1533529977, "test_source", nil))
+		err = sender.(senders.SignalFlusher).FlushSignal(senders.MetricSignal)
+		var reportErr *senders.ReportError
+		require.True(t, errors.As(err, &reportErr), test.status)
+		assert.Equal(t, test.status, reportErr.StatusCode)
+		assert.Equal(t, test.retryable, reportErr.Retryable(), test.status)
+		assert.Equal(t, test.pending, sender.(senders.PendingLinesReporter).PendingLines()["points"], "retryable data is buffered again")
+		assert.Equal(t, int64(1), atomic.LoadInt64(&transport.requests), "the requests are sent by the given client")
+
+		sender.Close()
+		server.Close()
+	}
+}
+
+func TestDirectSenderNetworkError(t *testing.T) {
+	sender, err := senders.NewSender("http://DUMMY_TOKEN@localhost:1", senders.FlushIntervalSeconds(3600))
+	require.NoError(t, err)
+	defer sender.Close()
+
+	require.NoError(t, sender.SendMetric("foo.metric", 1.2, 1533529977, "test_source", nil))
+	var reportErr *senders.ReportError
+	require.True(t, errors.As(sender.(senders.SignalFlusher).FlushSignal(senders.MetricSignal), &reportErr))
+	assert.Equal(t, 0, reportErr.StatusCode)
+	assert.True(t, reportErr.Retryable())
+	assert.Error(t, errors.Unwrap(reportErr))
+}
+
+func TestDirectSenderRetryAfter(t *testing.T) {
+	var requests int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&requests, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	sender, err := senders.NewSender(strings.Replace(server.URL, "http://", "http://DUMMY_TOKEN@", 1), senders.FlushIntervalSeconds(3600))
+	require.NoError(t, err)
+	defer sender.Close()
+
+	require.NoError(t, sender.SendMetric("foo.metric", 1.2, 1533529977, "test_source", nil))
+	start := time.Now()
+	var reportErr *senders.ReportError
+	require.True(t, errors.As(sender.(senders.SignalFlusher).FlushSignal(senders.MetricSignal), &reportErr))
+	assert.Equal(t, http.StatusTooManyRequests, reportErr.StatusCode)
+	assert.Equal(t, time.Second, reportErr.RetryAfter)
+
+	// the flushes are paused until the delay elapses
+	require.NoError(t, sender.(senders.SignalFlusher).FlushSignal(senders.MetricSignal))
+	assert.Equal(t, int64(1), atomic.LoadInt64(&requests))
+	assert.Equal(t, 1, sender.(senders.PendingLinesReporter).PendingLines()["points"])
+
+	assert.Eventually(t, func() bool {
+		return sender.(senders.SignalFlusher).FlushSignal(senders.MetricSignal) == nil && sender.(senders.PendingLinesReporter).PendingLines()["points"] == 0
+	}, 5*time.Second, 50*time.Millisecond)
+	assert.True(t, time.Since(start) >= time.Second)
+	assert.Equal(t, int64(2), atomic.LoadInt64(&requests))
+}
+
+func TestDirectSendSpans(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	sender, err := senders.NewSender(strings.Replace(server.URL, "http://", "http://DUMMY_TOKEN@", 1),
+		senders.FlushIntervalSeconds(3600), senders.MaxBufferSize(2))
+	require.NoError(t, err)
+	defer sender.Close()
+
+	span := senders.Span{Name: "getAllUsers", DurationMillis: 343500, Source: "localhost",
+		TraceId: "7b3bf470-9456-11e8-9eb6-529269fb1459", SpanId: "0313bafe-9457-11e8-9eb6-529269fb1459"}
+	errs := sender.(senders.SpanBatchSender).SendSpans([]senders.Span{span, span})
+	assert.Equal(t, []error{nil, nil}, errs)
+	assert.Equal(t, 2, sender.(senders.PendingLinesReporter).PendingLines()["spans"])
+
+	// a batch not fitting in the buffer is not split
+	require.NoError(t, sender.Flush())
+	errs = sender.(senders.SpanBatchSender).SendSpans([]senders.Span{span, span, span})
+	if assert.Len(t, errs, 3) {
+		for _, err := range errs {
+			assert.EqualError(t, err, "buffer full, dropping 3 lines")
+		}
+	}
+	assert.Equal(t, 0, sender.(senders.PendingLinesReporter).PendingLines()["spans"])
+}
+
+func TestNewDirectSenderSendSpans(t *testing.T) {
+	var requests int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	sender, err := senders.NewDirectSender(&senders.DirectConfiguration{Server: server.URL, Token: "DUMMY_TOKEN",
+		FlushIntervalSeconds: 3600})
+	require.NoError(t, err)
+	defer sender.Close()
+
+	span := senders.Span{Name: "getAllUsers", DurationMillis: 343500, Source: "localhost",
+		TraceId: "7b3bf470-9456-11e8-9eb6-529269fb1459", SpanId: "0313bafe-9457-11e8-9eb6-529269fb1459",
+		SpanLogs: []senders.SpanLog{{Timestamp: 1554363517965, Fields: map[string]string{"event": "error"}}}}
+	errs := sender.(senders.SpanBatchSender).SendSpans([]senders.Span{span, {Name: ""}, span})
+	assert.NoError(t, errs[0])
+	assert.Error(t, errs[1])
+	assert.NoError(t, errs[2])
+
+	require.NoError(t, sender.Flush())
+	assert.Equal(t, int64(2), atomic.LoadInt64(&requests), "one request for the spans, one for the span logs")
+}
@@ -0,0 +1,44 @@
+package senders
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// drainError reports a Drain interrupted by its context before all the buffered data was sent.
+type drainError struct {
+	err  error // the context error
+	last error // the error of the last flush attempt
+}
+
+func (e *drainError) Error() string {
+	return fmt.Sprintf("drain interrupted: %v, last flush error: %v", e.err, e.last)
+}
+
+func (e *drainError) Unwrap() error {
+	return e.err
+}
+
+// drain calls flushAll until it succeeds, waiting between attempts with an exponential backoff.
+// It gives up when ctx is done, returning an error wrapping the context error.
+func drain(ctx context.Context, flushAll func() error) error {
+	backoff := defaultInitialBackoff
+	for {
+		err := flushAll()
+		if err == nil {
+			return nil
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return &drainError{err: ctx.Err(), last: err}
+		case <-timer.C:
+		}
+		if backoff *= 2; backoff > defaultMaxBackoff {
+			backoff = defaultMaxBackoff
+		}
+	}
+}
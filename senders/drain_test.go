@@ -0,0 +1,105 @@
+package senders
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDrainRetries(t *testing.T) {
+	attempts := 0
+	err := drain(context.Background(), func() error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("proxy unreachable")
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+}
+
+func TestDrainContextDone(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := drain(ctx, func() error {
+		return errors.New("proxy unreachable")
+	})
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Contains(t, err.Error(), "proxy unreachable")
+}
+
+func TestDirectSenderDrain(t *testing.T) {
+	var mtx sync.Mutex
+	requests := 0
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		zr, err := gzip.NewReader(r.Body)
+		require.NoError(t, err)
+		body, _ := ioutil.ReadAll(zr)
+		received = append(received, string(body))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	sender, err := NewSender(strings.Replace(server.URL, "http://", "http://"+"DUMMY_TOKEN@", 1), FlushIntervalSeconds(3600))
+	require.NoError(t, err)
+	defer sender.Close()
+
+	require.NoError(t, sender.SendMetric("foo.metric", 1.2, 1533529977, "test_source", nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, sender.(Drainer).Drain(ctx))
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	assert.Equal(t, 2, requests, "the first attempt fails and is retried")
+	assert.Equal(t, []string{"\"foo.metric\" 1.2 1533529977 source=\"test_source\"\n"}, received)
+}
+
+func TestDirectSenderPendingLines(t *testing.T) {
+	sender, err := NewSender("http://DUMMY_TOKEN@localhost:1", FlushIntervalSeconds(3600))
+	require.NoError(t, err)
+	defer sender.Close()
+
+	require.NoError(t, sender.SendMetric("foo.metric", 1.2, 0, "test_source", nil))
+	require.NoError(t, sender.SendMetric("bar.metric", 1.2, 0, "test_source", nil))
+	assert.Equal(t, map[string]int{"points": 2, "histograms": 0, "spans": 0, "span_logs": 0, "events": 0}, sender.(PendingLinesReporter).PendingLines())
+}
+
+func TestFailureCountDelta(t *testing.T) {
+	sender, err := NewSender("http://DUMMY_TOKEN@localhost:1", FlushIntervalSeconds(3600), MaxBufferSize(2))
+	require.NoError(t, err)
+	defer sender.Close()
+
+	for i := 0; i < 5; i++ {
+		sender.SendMetric("foo.metric", 1.2, 0, "test_source", nil)
+	}
+	assert.Equal(t, int64(3), sender.GetFailureCount())
+	assert.Equal(t, int64(3), sender.(FailureCountResetter).FailureCountDelta())
+	assert.Equal(t, int64(0), sender.(FailureCountResetter).FailureCountDelta())
+	assert.Equal(t, int64(0), sender.GetFailureCount())
+
+	sender.SendMetric("foo.metric", 1.2, 0, "test_source", nil)
+	assert.Equal(t, int64(1), sender.(FailureCountResetter).FailureCountDelta())
+}
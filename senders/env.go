@@ -0,0 +1,123 @@
+package senders
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Environment variables read by NewSenderFromEnv.
+const (
+	// EnvSenderType selects the sender to create: "proxy" (the default) or "direct".
+	EnvSenderType = "WAVEFRONT_SENDER_TYPE"
+
+	// EnvProxyHost is the hostname of the Wavefront proxy. Required by the proxy sender.
+	EnvProxyHost = "WAVEFRONT_PROXY_HOST"
+	// EnvProxyMetricsPort is the metrics port of the proxy, typically 2878.
+	EnvProxyMetricsPort = "WAVEFRONT_PROXY_METRICS_PORT"
+	// EnvProxyDistributionPort is the distribution port of the proxy, typically 40000.
+	EnvProxyDistributionPort = "WAVEFRONT_PROXY_DISTRIBUTION_PORT"
+	// EnvProxyTracingPort is the tracing port of the proxy.
+	EnvProxyTracingPort = "WAVEFRONT_PROXY_TRACING_PORT"
+	// EnvProxyEventsPort is the events port of the proxy.
+	EnvProxyEventsPort = "WAVEFRONT_PROXY_EVENTS_PORT"
+
+	// EnvServer is the Wavefront URL of the form https://<INSTANCE>.wavefront.com. Required by the direct sender.
+	EnvServer = "WAVEFRONT_SERVER"
+	// EnvToken is the Wavefront API token with direct data ingestion permission. Required by the direct sender.
+	EnvToken = "WAVEFRONT_TOKEN"
+	// EnvBatchSize is the max batch of data sent per flush interval by the direct sender.
+	EnvBatchSize = "WAVEFRONT_BATCH_SIZE"
+	// EnvMaxBufferSize is the size of the internal buffers of the direct sender.
+	EnvMaxBufferSize = "WAVEFRONT_MAX_BUFFER_SIZE"
+
+	// EnvFlushIntervalSeconds is the interval (in seconds) at which data is flushed, for both senders.
+	EnvFlushIntervalSeconds = "WAVEFRONT_FLUSH_INTERVAL_SECONDS"
+)
+
+// NewSenderFromEnv creates a Wavefront sender configured from the environment variables documented above.
+// WAVEFRONT_SENDER_TYPE selects a proxy sender (default) or a direct sender.
+// An error is returned if a required variable is missing or if a value is invalid.
+func NewSenderFromEnv() (Sender, error) {
+	switch senderType := strings.ToLower(os.Getenv(EnvSenderType)); senderType {
+	case "", "proxy":
+		cfg, err := proxyConfigurationFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		return NewProxySender(cfg)
+	case "direct":
+		cfg, err := configurationFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		return newWavefrontClient(cfg)
+	default:
+		return nil, fmt.Errorf("invalid %s '%s', must be 'proxy' or 'direct'", EnvSenderType, senderType)
+	}
+}
+
+func proxyConfigurationFromEnv() (*ProxyConfiguration, error) {
+	cfg := &ProxyConfiguration{Host: os.Getenv(EnvProxyHost)}
+	if cfg.Host == "" {
+		return nil, fmt.Errorf("%s is required", EnvProxyHost)
+	}
+
+	var err error
+	if cfg.MetricsPort, err = envInt(EnvProxyMetricsPort); err != nil {
+		return nil, err
+	}
+	if cfg.DistributionPort, err = envInt(EnvProxyDistributionPort); err != nil {
+		return nil, err
+	}
+	if cfg.TracingPort, err = envInt(EnvProxyTracingPort); err != nil {
+		return nil, err
+	}
+	if cfg.EventsPort, err = envInt(EnvProxyEventsPort); err != nil {
+		return nil, err
+	}
+	if cfg.MetricsPort == 0 && cfg.DistributionPort == 0 && cfg.TracingPort == 0 && cfg.EventsPort == 0 {
+		return nil, fmt.Errorf("at least one of %s, %s, %s or %s is required",
+			EnvProxyMetricsPort, EnvProxyDistributionPort, EnvProxyTracingPort, EnvProxyEventsPort)
+	}
+	if cfg.FlushIntervalSeconds, err = envInt(EnvFlushIntervalSeconds); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func configurationFromEnv() (*configuration, error) {
+	cfg := &configuration{
+		Server: os.Getenv(EnvServer),
+		Token:  os.Getenv(EnvToken),
+	}
+	if cfg.Server == "" || cfg.Token == "" {
+		return nil, fmt.Errorf("%s and %s are required", EnvServer, EnvToken)
+	}
+
+	var err error
+	if cfg.BatchSize, err = envInt(EnvBatchSize); err != nil {
+		return nil, err
+	}
+	if cfg.MaxBufferSize, err = envInt(EnvMaxBufferSize); err != nil {
+		return nil, err
+	}
+	if cfg.FlushIntervalSeconds, err = envInt(EnvFlushIntervalSeconds); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// envInt reads a non-negative integer from the given environment variable, returning 0 when it isn't set.
+func envInt(name string) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s '%s', must be a non-negative integer", name, value)
+	}
+	return n, nil
+}
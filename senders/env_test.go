@@ -0,0 +1,95 @@
+package senders
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setEnv sets the given environment variables, unsetting all the other sender variables,
+// and returns a function restoring the previous environment.
+func setEnv(vars map[string]string) func() {
+	names := []string{EnvSenderType, EnvProxyHost, EnvProxyMetricsPort, EnvProxyDistributionPort, EnvProxyTracingPort,
+		EnvProxyEventsPort, EnvServer, EnvToken, EnvBatchSize, EnvMaxBufferSize, EnvFlushIntervalSeconds}
+	previous := make(map[string]string)
+	for _, name := range names {
+		if value, ok := os.LookupEnv(name); ok {
+			previous[name] = value
+		}
+		os.Unsetenv(name)
+	}
+	for name, value := range vars {
+		os.Setenv(name, value)
+	}
+	return func() {
+		for _, name := range names {
+			os.Unsetenv(name)
+		}
+		for name, value := range previous {
+			os.Setenv(name, value)
+		}
+	}
+}
+
+func TestProxyConfigurationFromEnv(t *testing.T) {
+	defer setEnv(map[string]string{
+		EnvProxyHost:            "proxy.local",
+		EnvProxyMetricsPort:     "2878",
+		EnvProxyTracingPort:     "30000",
+		EnvFlushIntervalSeconds: "10",
+	})()
+
+	cfg, err := proxyConfigurationFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, &ProxyConfiguration{
+		Host:                 "proxy.local",
+		MetricsPort:          2878,
+		TracingPort:          30000,
+		FlushIntervalSeconds: 10,
+	}, cfg)
+
+	sender, err := NewSenderFromEnv()
+	require.NoError(t, err)
+	assert.IsType(t, &proxySender{}, sender)
+	sender.Close()
+}
+
+func TestDirectConfigurationFromEnv(t *testing.T) {
+	defer setEnv(map[string]string{
+		EnvSenderType: "direct",
+		EnvServer:     "http://localhost:8080",
+		EnvToken:      "DUMMY_TOKEN",
+		EnvBatchSize:  "20000",
+	})()
+
+	cfg, err := configurationFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, &configuration{Server: "http://localhost:8080", Token: "DUMMY_TOKEN", BatchSize: 20000}, cfg)
+
+	sender, err := NewSenderFromEnv()
+	require.NoError(t, err)
+	assert.IsType(t, &wavefrontSender{}, sender)
+	sender.Close()
+}
+
+func TestNewSenderFromEnvErrors(t *testing.T) {
+	tests := map[string]map[string]string{
+		"missing proxy host":  {EnvProxyMetricsPort: "2878"},
+		"missing proxy ports": {EnvProxyHost: "proxy.local"},
+		"invalid port":        {EnvProxyHost: "proxy.local", EnvProxyMetricsPort: "metrics"},
+		"negative interval":   {EnvProxyHost: "proxy.local", EnvProxyMetricsPort: "2878", EnvFlushIntervalSeconds: "-1"},
+		"missing token":       {EnvSenderType: "direct", EnvServer: "http://localhost:8080"},
+		"invalid batch size":  {EnvSenderType: "direct", EnvServer: "http://localhost:8080", EnvToken: "DUMMY_TOKEN", EnvBatchSize: "1e4"},
+		"invalid sender type": {EnvSenderType: "udp"},
+	}
+
+	for name, vars := range tests {
+		restore := setEnv(vars)
+		sender, err := NewSenderFromEnv()
+		restore()
+		assert.Error(t, err, name)
+		assert.Nil(t, sender, name)
+	}
+}
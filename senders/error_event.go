@@ -0,0 +1,23 @@
+package senders
+
+import (
+	"errors"
+	"time"
+
+	"github.com/wavefronthq/wavefront-sdk-go/event"
+)
+
+// ErrorEventSeverity is the severity of the events sent by SendErrorEvent.
+const ErrorEventSeverity = "severe"
+
+// SendErrorEvent sends an instant event reporting err, e.g. when a background job fails: the event
+// starts and ends now, has the "severe" severity and the error message as details. The setters
+// are applied after these annotations, and can add or override them.
+func SendErrorEvent(sender EventSender, name string, err error, source string, tags map[string]string, setters ...event.Option) error {
+	if err == nil {
+		return invalidData(errors.New("no error to report in event " + name))
+	}
+	now := senderNow(sender).UnixNano() / int64(time.Millisecond)
+	options := append([]event.Option{event.Severity(ErrorEventSeverity), event.Details(err.Error())}, setters...)
+	return sender.SendEvent(name, now, now, source, tags, options...)
+}
@@ -0,0 +1,39 @@
+package senders
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wavefronthq/wavefront-sdk-go/event"
+)
+
+func TestSendErrorEvent(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", EventsPort: 50000})
+	defer sender.Close()
+
+	jobErr := errors.New("job failed: connection refused")
+	require.NoError(t, SendErrorEvent(sender, "backup", jobErr, "db-1", map[string]string{"job": "backup"}))
+	line := handlers[eventHandler].data()
+	assert.Contains(t, line, " \"backup\" ")
+	assert.Contains(t, line, "severity=\"severe\"")
+	assert.Contains(t, line, "details=\"job failed: connection refused\"")
+	assert.Contains(t, line, "host=\"db-1\"")
+
+	require.NoError(t, SendErrorEvent(sender, "backup", jobErr, "db-1", nil, event.Severity("warn")))
+	assert.Contains(t, handlers[eventHandler].lines[1], "severity=\"warn\"", "the setters override the defaults")
+
+	assert.Error(t, SendErrorEvent(sender, "backup", nil, "db-1", nil))
+	assert.Len(t, handlers[eventHandler].lines, 2)
+}
+
+func TestSendErrorEventClock(t *testing.T) {
+	now := time.Date(2019, 3, 10, 1, 59, 30, 0, time.UTC)
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", EventsPort: 50000, Clock: fixedClock{now}})
+	defer sender.Close()
+
+	require.NoError(t, SendErrorEvent(sender, "backup", errors.New("job failed"), "db-1", nil))
+	assert.Contains(t, handlers[eventHandler].data(), "@Event 1552183170000 1552183170000 \"backup\"")
+}
@@ -0,0 +1,46 @@
+package senders
+
+import (
+	"sync"
+	"time"
+)
+
+// eventLimiter limits the events sent per minute, counting them in fixed windows of a minute.
+// A nil limiter allows all the events.
+type eventLimiter struct {
+	max   int
+	clock Clock
+
+	mtx         sync.Mutex
+	windowStart time.Time
+	count       int
+}
+
+// newEventLimiter returns a limiter allowing max events per minute, nil when max <= 0.
+func newEventLimiter(max int, clock Clock) *eventLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return &eventLimiter{max: max, clock: clock}
+}
+
+// allow returns whether an event can be sent, counting it if so.
+func (l *eventLimiter) allow() bool {
+	if l == nil {
+		return true
+	}
+	now := l.clock.Now()
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if now.Sub(l.windowStart) >= time.Minute {
+		l.windowStart = now
+		l.count = 0
+	}
+	if l.count >= l.max {
+		return false
+	}
+	l.count++
+	return true
+}
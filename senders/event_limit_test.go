@@ -0,0 +1,42 @@
+package senders
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxEventsPerMinute(t *testing.T) {
+	clock := &fixedClock{now: time.Date(2019, 3, 10, 1, 59, 30, 0, time.UTC)}
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{
+		Host:               "localhost",
+		MetricsPort:        50000,
+		EventsPort:         50001,
+		MaxEventsPerMinute: 3,
+		Clock:              clock,
+	})
+	defer sender.Close()
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, sender.SendEvent("deploy", 1533531013, 0, "localhost", nil))
+	}
+	assert.Len(t, handlers[eventHandler].lines, 3)
+	assert.Equal(t, int64(7), sender.eventsThrottled.Count())
+	assert.Equal(t, int64(3), sender.eventsValid.Count())
+
+	// metrics are not limited
+	for i := 0; i < 10; i++ {
+		require.NoError(t, sender.SendMetric("deploys", 1, 0, "localhost", nil))
+	}
+	assert.Len(t, handlers[metricHandler].lines, 10)
+
+	clock.now = clock.now.Add(59 * time.Second)
+	require.NoError(t, sender.SendEvent("deploy", 1533531013, 0, "localhost", nil))
+	assert.Len(t, handlers[eventHandler].lines, 3, "still in the same window")
+
+	clock.now = clock.now.Add(time.Second)
+	require.NoError(t, sender.SendEvent("deploy", 1533531013, 0, "localhost", nil))
+	assert.Len(t, handlers[eventHandler].lines, 4, "next window")
+}
@@ -0,0 +1,22 @@
+package senders
+
+import "errors"
+
+// ExemplarTraceIdTag is the key of the point tag carrying the trace id of the metrics sent by SendMetricWithExemplar,
+// matching the traceId field of the spans.
+const ExemplarTraceIdTag = "traceId"
+
+// SendMetricWithExemplar sends a metric linked to a trace, e.g. a latency measured while serving a traced request,
+// to correlate the metric with the trace in the Wavefront UI. The trace id must be a UUID, it's added to a copy of
+// the tags under the ExemplarTraceIdTag key, overriding any tag with that key.
+func SendMetricWithExemplar(sender MetricSender, name string, value float64, ts int64, source string, tags map[string]string, traceId string) error {
+	if !isUUIDFormat(traceId) {
+		return invalidData(errors.New("invalid exemplar of metric " + name + ": traceId is not in UUID format"))
+	}
+	exemplarTags := make(map[string]string, len(tags)+1)
+	for k, v := range tags {
+		exemplarTags[k] = v
+	}
+	exemplarTags[ExemplarTraceIdTag] = traceId
+	return sender.SendMetric(name, value, ts, source, exemplarTags)
+}
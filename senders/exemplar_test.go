@@ -0,0 +1,23 @@
+package senders
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendMetricWithExemplar(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", MetricsPort: 50000})
+	defer sender.Close()
+
+	tags := map[string]string{"env": "test"}
+	require.NoError(t, SendMetricWithExemplar(sender, "request.latency", 42, 1533529977, "appServer1", tags, testTraceId))
+	assert.Equal(t, "\"request.latency\" 42 1533529977 source=\"appServer1\" \"env\"=\"test\" \"traceId\"=\""+testTraceId+"\"\n",
+		handlers[metricHandler].data())
+	assert.Len(t, tags, 1, "the tags of the caller are not modified")
+
+	assert.EqualError(t, SendMetricWithExemplar(sender, "request.latency", 42, 1533529977, "appServer1", nil, "not-a-trace-id"),
+		"invalid exemplar of metric request.latency: traceId is not in UUID format")
+	assert.Len(t, handlers[metricHandler].lines, 1)
+}
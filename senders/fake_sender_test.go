@@ -0,0 +1,79 @@
+package senders
+
+import (
+	"fmt"
+
+	"github.com/wavefronthq/wavefront-sdk-go/event"
+	"github.com/wavefronthq/wavefront-sdk-go/histogram"
+)
+
+// fakeSender records the calls made to it. The Sender methods it doesn't override panic.
+type fakeSender struct {
+	Sender
+
+	// number of calls failing before the following ones succeed
+	failures int
+	err      error
+	calls    []string
+}
+
+func (f *fakeSender) call(format string, args ...interface{}) error {
+	f.calls = append(f.calls, fmt.Sprintf(format, args...))
+	if f.failures > 0 {
+		f.failures--
+		return f.err
+	}
+	return nil
+}
+
+func (f *fakeSender) SendMetric(name string, value float64, ts int64, source string, tags map[string]string) error {
+	return f.call("metric %s %v", name, value)
+}
+
+func (f *fakeSender) SendMetricNow(name string, value float64, source string, tags map[string]string) error {
+	return f.call("metric %s %v", name, value)
+}
+
+func (f *fakeSender) SendDeltaCounter(name string, value float64, source string, tags map[string]string) error {
+	return f.call("delta %s %v", name, value)
+}
+
+func (f *fakeSender) SendDistribution(name string, centroids []histogram.Centroid, hgs map[histogram.Granularity]bool, ts int64, source string, tags map[string]string) error {
+	return f.call("distribution %s", name)
+}
+
+func (f *fakeSender) SendDistributions(dists []DistributionPoint) []error {
+	errs := make([]error, len(dists))
+	for i, dist := range dists {
+		errs[i] = f.call("distribution %s", dist.Name)
+	}
+	return errs
+}
+
+func (f *fakeSender) SendSpan(name string, startMillis, durationMillis int64, source, traceId, spanId string, parents, followsFrom []string, tags []SpanTag, spanLogs []SpanLog) error {
+	return f.call("span %s", name)
+}
+
+func (f *fakeSender) SendSpans(spans []Span) []error {
+	errs := make([]error, len(spans))
+	for i, span := range spans {
+		errs[i] = f.call("span %s", span.Name)
+	}
+	return errs
+}
+
+func (f *fakeSender) SendEvent(name string, startMillis, endMillis int64, source string, tags map[string]string, setters ...event.Option) error {
+	return f.call("event %s", name)
+}
+
+func (f *fakeSender) Flush() error {
+	return f.call("flush")
+}
+
+func (f *fakeSender) Close() {
+	f.call("close")
+}
+
+func (f *fakeSender) CloseWithError() error {
+	return f.call("close")
+}
@@ -0,0 +1,185 @@
+package senders
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/wavefronthq/wavefront-sdk-go/internal"
+)
+
+const (
+	defaultFileMaxBytes = 64 * 1024 * 1024
+	// longest line read when replaying a file, span logs lines being the longest
+	maxReplayLineBytes = 16 * 1024 * 1024
+)
+
+// FileOption configures a file sender.
+type FileOption func(*fileConfiguration)
+
+type fileConfiguration struct {
+	maxBytes             int64
+	maxAge               time.Duration
+	flushIntervalSeconds int
+}
+
+// FileMaxBytes set the size (in bytes) from which the file is rotated. defaults to 64 MB, a negative value disabling
+// the size based rotation.
+func FileMaxBytes(n int64) FileOption {
+	return func(cfg *fileConfiguration) {
+		cfg.maxBytes = n
+	}
+}
+
+// FileMaxAge set the age from which the file is rotated, counted from its opening. defaults to 0, no time based rotation.
+func FileMaxAge(age time.Duration) FileOption {
+	return func(cfg *fileConfiguration) {
+		cfg.maxAge = age
+	}
+}
+
+// FileFlushIntervalSeconds set how often the buffered lines are written to the file. defaults to 5 seconds.
+func FileFlushIntervalSeconds(seconds int) FileOption {
+	return func(cfg *fileConfiguration) {
+		cfg.flushIntervalSeconds = seconds
+	}
+}
+
+// NewFileSender returns a sender appending the data of every signal to the file at path, formatted as sent to a
+// Wavefront proxy, e.g. to persist it while the proxy is unreachable and send it later with ReplayFile.
+// The file is rotated by size (see FileMaxBytes) and optionally by age (see FileMaxAge): the full file is renamed
+// with the rotation time as suffix, e.g. "metrics.log.20060102T150405.000000000", and a new file started.
+// The file is closed when the sender is closed.
+func NewFileSender(path string, opts ...FileOption) (Sender, error) {
+	cfg := &fileConfiguration{maxBytes: defaultFileMaxBytes, flushIntervalSeconds: defaultProxyFlushInterval}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.maxBytes < 0 {
+		cfg.maxBytes = 0
+	}
+	if cfg.flushIntervalSeconds <= 0 {
+		return nil, fmt.Errorf("invalid flush interval %d: must be positive", cfg.flushIntervalSeconds)
+	}
+
+	file := internal.NewRotatingFile(path, cfg.maxBytes, cfg.maxAge)
+	if err := file.Open(); err != nil {
+		return nil, err
+	}
+	flushInterval := time.Second * time.Duration(cfg.flushIntervalSeconds)
+	proxyCfg := &ProxyConfiguration{FlushIntervalSeconds: cfg.flushIntervalSeconds}
+	return newProxySender(proxyCfg, func(signal SignalType, _ string, registry *internal.MetricRegistry, _ []internal.ProxyConnectionHandlerOption) internal.ConnectionHandler {
+		return internal.NewFileHandler(file, flushInterval, handlerNames[signal], registry)
+	}), nil
+}
+
+// lineReplayer is implemented by the senders able to send the lines written by a file sender.
+type lineReplayer interface {
+	// replayLine sends a newline terminated line of the given signal, either a span or span logs line for spans.
+	replayLine(signal SignalType, spanLogs bool, line string) error
+}
+
+// ReplayFile sends the lines of a file written by a file sender through sender, e.g. a proxy sender once the proxy
+// is reachable, then flushes the sender. The signal of each line is recognized from its format. The lines are sent
+// as is, without validation, sampling nor internal metrics. Events can only be replayed through proxy senders, as
+// direct ingestion requires them in JSON. The errors of the lines that couldn't be sent are returned, the other
+// lines being sent anyway.
+// The rotated files can be replayed in the order of their suffixes, followed by the current file.
+func ReplayFile(path string, sender Sender) error {
+	replayer, ok := sender.(lineReplayer)
+	if !ok {
+		return fmt.Errorf("sender %T cannot replay lines", sender)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var errors multiError
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxReplayLineBytes)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		signal, spanLogs := replaySignal(line)
+		if err := replayer.replayLine(signal, spanLogs, line+"\n"); err != nil {
+			errors.add(err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		errors.add(fmt.Errorf("unable to read file %s: %w", path, err))
+	}
+	if err := sender.Flush(); err != nil {
+		errors.add(err)
+	}
+	return errors.get()
+}
+
+// replaySignal returns the signal of a line in a Wavefront data format, and whether it's a span logs line.
+func replaySignal(line string) (signal SignalType, spanLogs bool) {
+	switch {
+	case strings.HasPrefix(line, "@Event "):
+		return EventSignal, false
+	case strings.HasPrefix(line, "!M ") || strings.HasPrefix(line, "!H ") || strings.HasPrefix(line, "!D "):
+		return HistogramSignal, false
+	case strings.HasPrefix(line, "{\"traceId\""):
+		return SpanSignal, true
+	case strings.Contains(line, " traceId="):
+		// the tags of metrics are quoted, only the spans have unquoted traceId fields
+		return SpanSignal, false
+	default:
+		return MetricSignal, false
+	}
+}
+
+func (sender *proxySender) replayLine(signal SignalType, spanLogs bool, line string) error {
+	handler := sender.handlers[signal]
+	if spanLogs && handler != nil {
+		handler = sender.spanLogHandler()
+	}
+	if handler == nil {
+		return &portError{msg: fmt.Sprintf("proxy %s port not provided, cannot replay %s", handlerNames[signal], handlerNames[signal])}
+	}
+	if !handler.Connected() {
+		if err := handler.Connect(); err != nil {
+			return err
+		}
+	}
+	return handler.SendData(line)
+}
+
+func (sender *wavefrontSender) replayLine(signal SignalType, spanLogs bool, line string) error {
+	switch {
+	case signal == MetricSignal:
+		return sender.pointHandler.HandleLine(line)
+	case signal == HistogramSignal:
+		return sender.histoHandler.HandleLine(line)
+	case signal == SpanSignal && spanLogs:
+		return sender.spanLogHandler.HandleLine(line)
+	case signal == SpanSignal:
+		return sender.spanHandler.HandleLine(line)
+	default:
+		return errors.New("events cannot be replayed through a direct sender")
+	}
+}
+
+func (ms *multiSender) replayLine(signal SignalType, spanLogs bool, line string) error {
+	var errors multiError
+	for _, sender := range ms.senders {
+		replayer, ok := sender.(lineReplayer)
+		if !ok {
+			errors.add(fmt.Errorf("sender %T cannot replay lines", sender))
+			continue
+		}
+		if err := replayer.replayLine(signal, spanLogs, line); err != nil {
+			errors.add(err)
+		}
+	}
+	return errors.get()
+}
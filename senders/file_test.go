@@ -0,0 +1,73 @@
+package senders
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wavefronthq/wavefront-sdk-go/histogram"
+)
+
+func TestFileSender(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wavefront-file-sender")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "telemetry.log")
+
+	sender, err := NewFileSender(path, FileMaxBytes(300))
+	require.NoError(t, err)
+	spanLogs := []SpanLog{{Timestamp: 1554363517965, Fields: map[string]string{"event": "error"}}}
+	require.NoError(t, sender.SendMetric("new-york.power.usage", 42422, 1533529977, "test", map[string]string{"traceId": "1"}))
+	require.NoError(t, sender.SendDeltaCounter("lambda.thumbnail.generate", 10, "test", nil))
+	require.NoError(t, sender.(DistributionGSender).SendDistributionG("request.latency", []histogram.Centroid{{Value: 30, Count: 20}}, 1533529977, "test", nil, histogram.MINUTE))
+	require.NoError(t, sender.SendSpan("getAllUsers", 1533529977, 343, "test", testTraceId, testSpanId, nil, nil, nil, spanLogs))
+	require.NoError(t, sender.SendEvent("deploy", 1533529977, 0, "test", nil))
+	require.NoError(t, sender.(ErrorCloser).CloseWithError())
+
+	rotated, err := filepath.Glob(path + ".*")
+	require.NoError(t, err)
+	assert.NotEmpty(t, rotated, "the file was rotated")
+	sort.Strings(rotated)
+
+	proxy, handlers := newTestProxySender(t, &ProxyConfiguration{
+		Host:             "localhost",
+		MetricsPort:      50000,
+		DistributionPort: 50001,
+		TracingPort:      50002,
+		EventsPort:       50003,
+	})
+	defer proxy.Close()
+	for _, file := range append(rotated, path) {
+		require.NoError(t, ReplayFile(file, proxy))
+	}
+
+	assert.Equal(t, "\"new-york.power.usage\" 42422 1533529977 source=\"test\" \"traceId\"=\"1\"\n"+
+		"\"∆lambda.thumbnail.generate\" 10 source=\"test\"\n", handlers[metricHandler].data())
+	assert.Equal(t, "!M 1533529977 #20 30 \"request.latency\" source=\"test\"\n", handlers[histoHandler].data())
+	spans := handlers[spanHandler].data()
+	assert.True(t, strings.HasPrefix(spans, "\"getAllUsers\" source=\"test\" traceId="+testTraceId), spans)
+	assert.Contains(t, spans, "\n{\"traceId\":\""+testTraceId+"\"")
+	assert.Contains(t, handlers[eventHandler].data(), "@Event 1533529977000 1533529977001 \"deploy\"")
+}
+
+func TestReplaySignal(t *testing.T) {
+	for line, expected := range map[string]SignalType{
+		"\"cpu\" 1 source=\"test\"":                        MetricSignal,
+		"\"cpu\" 1 source=\"test\" \"traceId\"=\"1\"":      MetricSignal,
+		"!H 1533529977 #20 30 \"latency\" source=\"test\"": HistogramSignal,
+		"\"span\" source=\"test\" traceId=1 spanId=2 1 1":  SpanSignal,
+		"{\"traceId\":\"1\",\"spanId\":\"2\",\"logs\":[]}": SpanSignal,
+		"@Event 1533529977000 1533529977001 \"deploy\"":    EventSignal,
+	} {
+		signal, _ := replaySignal(line)
+		assert.Equal(t, expected, signal, line)
+	}
+
+	sender := NewPrefixingSender(&fakeSender{}, "app")
+	assert.Error(t, ReplayFile("missing.log", sender), "the sender can't replay lines")
+}
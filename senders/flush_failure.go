@@ -0,0 +1,25 @@
+package senders
+
+import "github.com/wavefronthq/wavefront-sdk-go/internal"
+
+// FlushFailurePolicy controls what a proxy sender does with the lines written to a proxy since the last successful
+// flush when a flush fails, e.g. when the proxy restarts.
+type FlushFailurePolicy int
+
+const (
+	// DropOnFailure discards the lines, counted by the "flush.dropped" internal metric of the signal. Lines are
+	// sent at most once. This is the default.
+	DropOnFailure FlushFailurePolicy = iota
+	// ReQueueOnFailure retains the lines and writes them first once reconnected to the proxy, counted by the
+	// "flush.requeued" internal metric of the signal. Lines are sent at least once: those that reached the proxy
+	// before the failure are sent again. Up to MaxRequeuedLines lines are retained, the oldest ones being dropped.
+	ReQueueOnFailure
+)
+
+// handlerPolicy returns the connection handler policy implementing the policy.
+func (policy FlushFailurePolicy) handlerPolicy() internal.FlushFailurePolicy {
+	if policy == ReQueueOnFailure {
+		return internal.FlushFailureRequeue
+	}
+	return internal.FlushFailureDrop
+}
@@ -0,0 +1,57 @@
+package senders
+
+import "strings"
+
+// FlushStats reports the volume of data flushed by FlushWithStats, in lines per signal.
+type FlushStats struct {
+	// lines sent during the flush, including those sent concurrently by the periodic flushes
+	Sent map[SignalType]int
+	// lines still buffered after the flush, e.g. when it failed
+	Remaining map[SignalType]int
+}
+
+// add adds the stats of another sender to the stats.
+func (s *FlushStats) add(other FlushStats) {
+	for signal, sent := range other.Sent {
+		s.Sent[signal] += sent
+	}
+	for signal, remaining := range other.Remaining {
+		s.Remaining[signal] += remaining
+	}
+}
+
+func newFlushStats() FlushStats {
+	return FlushStats{Sent: make(map[SignalType]int), Remaining: make(map[SignalType]int)}
+}
+
+// flushWithStats flushes with flush, counting the lines sent from the flushes recorded by ages
+// and the remaining lines from the pending lines of each handler.
+func flushWithStats(ages *flushAges, flush func() error, pendingLines func() map[string]int) (FlushStats, error) {
+	before := ages.sentLines()
+	err := flush()
+	after := ages.sentLines()
+
+	stats := newFlushStats()
+	for i := range after {
+		stats.Sent[SignalType(i)] = int(after[i] - before[i])
+	}
+	for name, lines := range pendingLines() {
+		stats.Remaining[handlerSignal(name)] += lines
+	}
+	return stats, err
+}
+
+// handlerSignal returns the signal of a handler given its name, as returned by PendingLines.
+func handlerSignal(name string) SignalType {
+	if name == spanLogsRoute {
+		return SpanSignal
+	}
+	// the handlers of the metrics routes are named after the metrics handler
+	name = strings.SplitN(name, ".", 2)[0]
+	for i, handlerName := range handlerNames {
+		if name == handlerName {
+			return SignalType(i)
+		}
+	}
+	return MetricSignal
+}
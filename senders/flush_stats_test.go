@@ -0,0 +1,58 @@
+package senders
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlushWithStats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	sender, err := NewSender(strings.Replace(server.URL, "http://", "http://"+"DUMMY_TOKEN@", 1), FlushIntervalSeconds(3600))
+	require.NoError(t, err)
+	defer sender.Close()
+
+	require.NoError(t, sender.SendMetric("foo.metric", 1.2, 0, "test_source", nil))
+	require.NoError(t, sender.SendMetric("bar.metric", 1.2, 0, "test_source", nil))
+
+	stats, err := sender.(StatsFlusher).FlushWithStats()
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Sent[MetricSignal])
+	assert.Equal(t, 0, stats.Sent[SpanSignal])
+	assert.Equal(t, 0, stats.Remaining[MetricSignal])
+
+	stats, err = sender.(StatsFlusher).FlushWithStats()
+	require.NoError(t, err)
+	assert.Equal(t, 0, stats.Sent[MetricSignal], "nothing left to send")
+}
+
+func TestFlushWithStatsFailure(t *testing.T) {
+	sender, err := NewSender("http://DUMMY_TOKEN@localhost:1", FlushIntervalSeconds(3600))
+	require.NoError(t, err)
+	defer sender.Close()
+
+	require.NoError(t, sender.SendMetric("foo.metric", 1.2, 0, "test_source", nil))
+	require.NoError(t, sender.SendMetric("bar.metric", 1.2, 0, "test_source", nil))
+
+	stats, err := sender.(StatsFlusher).FlushWithStats()
+	assert.Error(t, err)
+	assert.Equal(t, 0, stats.Sent[MetricSignal])
+	assert.Equal(t, 2, stats.Remaining[MetricSignal], "the lines are kept for the next flush")
+}
+
+func TestHandlerSignal(t *testing.T) {
+	assert.Equal(t, MetricSignal, handlerSignal("points"))
+	assert.Equal(t, MetricSignal, handlerSignal("points.billing"))
+	assert.Equal(t, HistogramSignal, handlerSignal("histograms"))
+	assert.Equal(t, SpanSignal, handlerSignal("spans"))
+	assert.Equal(t, SpanSignal, handlerSignal("span_logs"))
+	assert.Equal(t, EventSignal, handlerSignal("events"))
+}
@@ -0,0 +1,65 @@
+package senders
+
+import (
+	"errors"
+
+	"github.com/wavefronthq/wavefront-sdk-go/event"
+	"github.com/wavefronthq/wavefront-sdk-go/histogram"
+	"github.com/wavefronthq/wavefront-sdk-go/internal"
+)
+
+// The Format* functions are the canonical way to produce Wavefront lines without a sender, e.g. in tests or
+// in pipelines buffering the data externally. They produce the same lines as the senders, using the default
+// serializer, but never fall back to a default source: an empty source is omitted from the line.
+
+// FormatMetric returns the line of a metric in the Wavefront metrics data format, e.g.
+// "new-york.power.usage" 42422 1533531013 source="localhost" "datacenter"="dc1".
+// A ts of 0 omits the timestamp, letting Wavefront assign it.
+func FormatMetric(name string, value float64, ts int64, source string, tags map[string]string) (string, error) {
+	return MetricLine(name, value, ts, formatSource(source), tags, "")
+}
+
+// FormatDeltaCounter returns the line of a delta counter, its name being prefixed with the delta prefix
+// when it doesn't have one, e.g. "∆lambda.thumbnail.generate" 10 source="thumbnail_service".
+func FormatDeltaCounter(name string, value float64, source string, tags map[string]string) (string, error) {
+	if err := checkName("metric", name); err != nil {
+		return "", err
+	}
+	if !internal.HasDeltaPrefix(name) {
+		name = internal.DeltaCounterName(name)
+	}
+	return MetricLine(name, value, 0, formatSource(source), tags, "")
+}
+
+// FormatDistribution returns the lines of a distribution in the Wavefront histogram data format,
+// one per granularity, e.g. !M 1533531013 #20 30 #10 5.1 "request.latency" source="appServer1".
+func FormatDistribution(name string, centroids []histogram.Centroid, hgs map[histogram.Granularity]bool, ts int64, source string, tags map[string]string) (string, error) {
+	return HistoLine(name, centroids, hgs, ts, formatSource(source), tags, "")
+}
+
+// FormatSpan returns the line of a span in the Wavefront span data format. Spans require a source.
+func FormatSpan(name string, startMillis, durationMillis int64, source, traceId, spanId string, parents, followsFrom []string, tags []SpanTag, spanLogs []SpanLog) (string, error) {
+	if source == "" || source == NoSource {
+		return "", errors.New("empty span source")
+	}
+	return SpanLine(name, startMillis, durationMillis, source, traceId, spanId, parents, followsFrom, tags, spanLogs, "")
+}
+
+// FormatSpanLogs returns the JSON line of the span logs of a span, sent along with its span line.
+func FormatSpanLogs(traceId, spanId string, spanLogs []SpanLog) (string, error) {
+	return SpanLogJSON(traceId, spanId, spanLogs)
+}
+
+// FormatEvent returns the line of an event in the Wavefront proxy events format.
+// An endMillis of 0 makes an instantaneous event.
+func FormatEvent(name string, startMillis, endMillis int64, source string, tags map[string]string, setters ...event.Option) (string, error) {
+	return EventLine(name, startMillis, endMillis, source, tags, setters...)
+}
+
+// formatSource maps an empty source to NoSource, the line formatters otherwise using their default source.
+func formatSource(source string) string {
+	if source == "" {
+		return NoSource
+	}
+	return source
+}
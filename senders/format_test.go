@@ -0,0 +1,76 @@
+package senders
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wavefronthq/wavefront-sdk-go/event"
+	"github.com/wavefronthq/wavefront-sdk-go/histogram"
+)
+
+func TestFormatMetric(t *testing.T) {
+	line, err := FormatMetric("new-york.power.usage", 42422, 1533529977, "test_source", map[string]string{"env": "test"})
+	require.NoError(t, err)
+	assert.Equal(t, "\"new-york.power.usage\" 42422 1533529977 source=\"test_source\" \"env\"=\"test\"\n", line)
+
+	line, err = FormatMetric("new-york.power.usage", 42422, 0, "", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "\"new-york.power.usage\" 42422\n", line)
+
+	_, err = FormatMetric("", 42422, 0, "", nil)
+	assert.Error(t, err)
+}
+
+func TestFormatDeltaCounter(t *testing.T) {
+	line, err := FormatDeltaCounter("lambda.thumbnail.generate", 10, "thumbnail_service", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "\"∆lambda.thumbnail.generate\" 10 source=\"thumbnail_service\"\n", line)
+
+	line, err = FormatDeltaCounter("Δlambda.thumbnail.generate", 10, "", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "\"Δlambda.thumbnail.generate\" 10\n", line)
+
+	_, err = FormatDeltaCounter("", 10, "", nil)
+	assert.Error(t, err)
+}
+
+func TestFormatDistribution(t *testing.T) {
+	centroids := []histogram.Centroid{{Value: 30, Count: 20}, {Value: 5.1, Count: 10}}
+	line, err := FormatDistribution("request.latency", centroids, histogram.Granularities(histogram.MINUTE, histogram.HOUR),
+		1533529977, "appServer1", map[string]string{"region": "us-west"})
+	require.NoError(t, err)
+	assert.Equal(t, "!M 1533529977 #20 30 #10 5.1 \"request.latency\" source=\"appServer1\" \"region\"=\"us-west\"\n"+
+		"!H 1533529977 #20 30 #10 5.1 \"request.latency\" source=\"appServer1\" \"region\"=\"us-west\"\n", line)
+
+	line, err = FormatDistribution("request.latency", centroids, histogram.Granularities(histogram.MINUTE), 0, "", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "!M #20 30 #10 5.1 \"request.latency\"\n", line)
+}
+
+func TestFormatSpan(t *testing.T) {
+	spanLogs := []SpanLog{{Timestamp: 1554363517965, Fields: map[string]string{"event": "error"}}}
+	line, err := FormatSpan("getAllUsers", 1533529977, 343, "localhost", testTraceId, testSpanId, nil, nil,
+		[]SpanTag{{Key: "application", Value: "Wavefront"}}, spanLogs)
+	require.NoError(t, err)
+	assert.Equal(t, "\"getAllUsers\" source=\"localhost\" traceId="+testTraceId+" spanId="+testSpanId+
+		" \"_spanLogs\"=\"true\" \"application\"=\"Wavefront\" 1533529977 343\n", line)
+
+	_, err = FormatSpan("getAllUsers", 1533529977, 343, "", testTraceId, testSpanId, nil, nil, nil, nil)
+	assert.Error(t, err)
+
+	line, err = FormatSpanLogs(testTraceId, testSpanId, spanLogs)
+	require.NoError(t, err)
+	assert.Equal(t, "{\"traceId\":\""+testTraceId+"\",\"spanId\":\""+testSpanId+
+		"\",\"logs\":[{\"timestamp\":1554363517965,\"fields\":{\"event\":\"error\"}}]}\n", line)
+}
+
+func TestFormatEvent(t *testing.T) {
+	line, err := FormatEvent("deploy", 1533531013, 0, "localhost", map[string]string{"env": "test"}, event.Severity("info"))
+	require.NoError(t, err)
+	assert.Equal(t, "@Event 1533531013000 1533531013001 \"deploy\" severity=\"info\" host=\"localhost\" tag=\"env: test\"\n", line)
+
+	line, err = FormatEvent("deploy", 1533531013, 0, "", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "@Event 1533531013000 1533531013001 \"deploy\"\n", line)
+}
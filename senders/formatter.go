@@ -5,24 +5,57 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"regexp"
+	"math"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/wavefronthq/wavefront-sdk-go/event"
 	"github.com/wavefronthq/wavefront-sdk-go/histogram"
 	"github.com/wavefronthq/wavefront-sdk-go/internal"
 )
 
-var /* const */ quotation = regexp.MustCompile("\"")
-var /* const */ lineBreak = regexp.MustCompile("\\n")
+// NoSource can be passed as the source of a metric or distribution to send it without a source tag,
+// letting the Wavefront proxy assign the source (the address of the sending client) instead of
+// falling back to the sender's default source (the hostname).
+// The line is written as "<metricName> <metricValue> [<timestamp>] [pointTags]" with no "source=" segment.
+const NoSource = "\x00"
+
 
 // Gets a metric line in the Wavefront metrics data format:
 // <metricName> <metricValue> [<timestamp>] source=<source> [pointTags]
 // Example: "new-york.power.usage 42422.0 1533531013 source=localhost datacenter=dc1"
+// A nil tags map is handled like an empty one, as in all the line formatters.
+// Tags are written sorted by key, so that a given point always produces the same line.
 func MetricLine(name string, value float64, ts int64, source string, tags map[string]string, defaultSource string) (string, error) {
+	return metricLine(name, value, ts, source, tags, defaultSource, nil)
+}
+
+// checkName returns an error when the name of a metric, distribution, span or event is empty or only made of whitespace,
+// the proxy rejecting such lines.
+func checkName(kind, name string) error {
 	if name == "" {
-		return "", errors.New("empty metric name")
+		return fmt.Errorf("empty %s name", kind)
+	}
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("blank %s name %q", kind, name)
+	}
+	return nil
+}
+
+// metricLine formats a metric line, using the formatted tags interned in cache, nil to format each tag.
+func metricLine(name string, value float64, ts int64, source string, tags map[string]string, defaultSource string, cache *tagCache) (string, error) {
+	return formatMetricLine(name, floatValue(value), ts, source, tags, defaultSource, cache)
+}
+
+func formatMetricLine(name string, value metricValue, ts int64, source string, tags map[string]string, defaultSource string, cache *tagCache) (string, error) {
+	if err := checkName("metric", name); err != nil {
+		return "", err
+	}
+
+	if !value.isInt && (math.IsNaN(value.float) || math.IsInf(value.float, 0)) {
+		return "", fmt.Errorf("invalid value %v for metric %s: value must be finite", value.float, name)
 	}
 
 	if source == "" {
@@ -32,26 +65,29 @@ func MetricLine(name string, value float64, ts int64, source string, tags map[st
 	sb := internal.GetBuffer()
 	defer internal.PutBuffer(sb)
 
-	sb.WriteString(strconv.Quote(sanitizeInternal(name)))
+	writeQuotedInternal(sb, name)
 	sb.WriteString(" ")
-	sb.WriteString(strconv.FormatFloat(value, 'f', -1, 64))
+	value.write(sb)
 
 	if ts != 0 {
 		sb.WriteString(" ")
-		sb.WriteString(strconv.FormatInt(ts, 10))
+		writeInt(sb, ts)
 	}
 
-	sb.WriteString(" source=")
-	sb.WriteString(sanitizeValue(source))
+	if source != NoSource {
+		sb.WriteString(" source=")
+		writeValue(sb, source)
+	}
 
-	for k, v := range tags {
+	for _, k := range sortedKeys(tags) {
+		v := tags[k]
 		if v == "" {
 			return "", errors.New("metric point tag value cannot be blank")
 		}
 		sb.WriteString(" ")
-		sb.WriteString(strconv.Quote(sanitizeInternal(k)))
+		cache.writeKey(sb, k)
 		sb.WriteString("=")
-		sb.WriteString(sanitizeValue(v))
+		cache.writeValue(sb, v)
 	}
 	sb.WriteString("\n")
 	return sb.String(), nil
@@ -61,8 +97,26 @@ func MetricLine(name string, value float64, ts int64, source string, tags map[st
 // {!M | !H | !D} [<timestamp>] #<count> <mean> [centroids] <histogramName> source=<source> [pointTags]
 // Example: "!M 1533531013 #20 30.0 #10 5.1 request.latency source=appServer1 region=us-west"
 func HistoLine(name string, centroids histogram.Centroids, hgs map[histogram.Granularity]bool, ts int64, source string, tags map[string]string, defaultSource string) (string, error) {
-	if name == "" {
-		return "", errors.New("empty distribution name")
+	return histoLine(name, centroids, hgs, ts, source, tags, defaultSource, histoLineOptions{})
+}
+
+// histoLineOptions controls how histoLine formats a distribution.
+type histoLineOptions struct {
+	// align the timestamp of each granularity line on the start of its bin
+	alignTimestamps bool
+	// split the distribution in lines of at most maxLineBytes bytes, each with part of the centroids. 0 for no limit.
+	maxLineBytes int
+	// significant digits of the centroid values, see roundToPrecision. 0 for full precision.
+	precision int
+	// max centroids of a distribution, 0 for no limit. larger distributions are rejected,
+	// or split in lines of at most maxCentroids centroids when split by maxLineBytes.
+	maxCentroids int
+}
+
+// histoLine gets the histogram lines of a distribution, one per granularity unless split by opts.maxLineBytes.
+func histoLine(name string, centroids histogram.Centroids, hgs map[histogram.Granularity]bool, ts int64, source string, tags map[string]string, defaultSource string, opts histoLineOptions) (string, error) {
+	if err := checkName("distribution", name); err != nil {
+		return "", err
 	}
 
 	if len(centroids) == 0 {
@@ -73,6 +127,12 @@ func HistoLine(name string, centroids histogram.Centroids, hgs map[histogram.Gra
 		return "", errors.New("histogram granularities cannot be empty")
 	}
 
+	for _, centroid := range centroids {
+		if err := centroid.Validate(); err != nil {
+			return "", fmt.Errorf("distribution %s: %w", name, err)
+		}
+	}
+
 	if source == "" {
 		source = defaultSource
 	}
@@ -80,52 +140,113 @@ func HistoLine(name string, centroids histogram.Centroids, hgs map[histogram.Gra
 	sb := internal.GetBuffer()
 	defer internal.PutBuffer(sb)
 
-	if ts != 0 {
-		sb.WriteString(" ")
-		sb.WriteString(strconv.FormatInt(ts, 10))
-	}
-	// Preprocess line. We know len(hgs) > 0 here.
-	for _, centroid := range centroids.Compact() {
-		sb.WriteString(" #")
-		sb.WriteString(strconv.Itoa(centroid.Count))
-		sb.WriteString(" ")
-		sb.WriteString(strconv.FormatFloat(centroid.Value, 'f', -1, 64))
-	}
+	// Preprocess the end of the line.
 	sb.WriteString(" ")
-	sb.WriteString(strconv.Quote(sanitizeInternal(name)))
-	sb.WriteString(" source=")
-	sb.WriteString(sanitizeValue(source))
+	writeQuotedInternal(sb, name)
+	if source != NoSource {
+		sb.WriteString(" source=")
+		writeValue(sb, source)
+	}
 
-	for k, v := range tags {
+	for _, k := range sortedKeys(tags) {
+		v := tags[k]
 		if v == "" {
 			return "", errors.New("histogram tag value cannot be blank")
 		}
 		sb.WriteString(" ")
-		sb.WriteString(strconv.Quote(sanitizeInternal(k)))
+		writeQuotedInternal(sb, k)
 		sb.WriteString("=")
-		sb.WriteString(sanitizeValue(v))
+		writeValue(sb, v)
 	}
 	sbBytes := sb.Bytes()
 
-	sbg := bytes.Buffer{}
+	// size of a line without its centroids, an aligned timestamp being no longer than ts.
+	overhead := len("!M") + len(sbBytes) + len("\n")
+	if ts != 0 {
+		overhead += len(" ") + len(strconv.FormatInt(ts, 10))
+	}
+
+	compacted := centroids.Compact()
+	if opts.maxCentroids > 0 && len(compacted) > opts.maxCentroids && opts.maxLineBytes <= 0 {
+		return "", fmt.Errorf("distribution %s has %d centroids, more than the max of %d", name, len(compacted), opts.maxCentroids)
+	}
+
+	// Preprocess the centroids, split in chunks fitting in opts.maxLineBytes and opts.maxCentroids. We know len(hgs) > 0 here.
+	cb := internal.GetBuffer()
+	defer internal.PutBuffer(cb)
+	var chunkEnds []int
+	chunkStart, chunkCentroids := 0, 0
+	for _, centroid := range compacted {
+		centroidStart := cb.Len()
+		cb.WriteString(" #")
+		writeInt(cb, int64(centroid.Count))
+		cb.WriteString(" ")
+		writeFloat(cb, roundToPrecision(centroid.Value, opts.precision))
+		chunkCentroids++
+		if opts.maxLineBytes > 0 && (overhead+cb.Len()-chunkStart > opts.maxLineBytes ||
+			opts.maxCentroids > 0 && chunkCentroids > opts.maxCentroids) {
+			// start a new chunk with this centroid
+			if centroidStart > chunkStart {
+				chunkEnds = append(chunkEnds, centroidStart)
+				chunkStart = centroidStart
+				chunkCentroids = 1
+			}
+			if overhead+cb.Len()-chunkStart > opts.maxLineBytes {
+				return "", fmt.Errorf("distribution %s cannot be split in lines of at most %d bytes", name, opts.maxLineBytes)
+			}
+		}
+	}
+	chunkEnds = append(chunkEnds, cb.Len())
+	cbBytes := cb.Bytes()
+
+	// write the granularities in a stable order, MINUTE first
+	var granularities []histogram.Granularity
 	for hg, on := range hgs {
 		if on {
+			granularities = append(granularities, hg)
+		}
+	}
+	sort.Slice(granularities, func(i, j int) bool { return granularities[i] < granularities[j] })
+
+	sbg := bytes.Buffer{}
+	for _, hg := range granularities {
+		start := 0
+		for _, end := range chunkEnds {
 			sbg.WriteString(hg.String())
+			if ts != 0 {
+				sbg.WriteString(" ")
+				if opts.alignTimestamps {
+					writeInt(&sbg, alignHistoTimestamp(ts, hg))
+				} else {
+					writeInt(&sbg, ts)
+				}
+			}
+			sbg.Write(cbBytes[start:end])
 			sbg.Write(sbBytes)
 			sbg.WriteString("\n")
+			start = end
 		}
 	}
 	return sbg.String(), nil
 }
 
+// alignHistoTimestamp aligns the timestamp on the start of its bin of the given granularity,
+// keeping its unit (seconds or milliseconds).
+func alignHistoTimestamp(ts int64, hg histogram.Granularity) int64 {
+	if ts > 999999999999 {
+		return histogram.AlignTimestamp(hg, time.Unix(0, ts*int64(time.Millisecond))) * 1000
+	}
+	return histogram.AlignTimestamp(hg, time.Unix(ts, 0))
+}
+
 // Gets a span line in the Wavefront span data format:
 // <tracingSpanName> source=<source> [pointTags] <start_millis> <duration_milli_seconds>
 // Example:
 // "getAllUsers source=localhost traceId=7b3bf470-9456-11e8-9eb6-529269fb1459 spanId=0313bafe-9457-11e8-9eb6-529269fb1459
 //    parent=2f64e538-9457-11e8-9eb6-529269fb1459 application=Wavefront http.method=GET 1533531013 343500"
 func SpanLine(name string, startMillis, durationMillis int64, source, traceId, spanId string, parents, followsFrom []string, tags []SpanTag, spanLogs []SpanLog, defaultSource string) (string, error) {
-	if name == "" {
-		return "", errors.New("empty span name")
+	if err := checkName("span", name); err != nil {
+		return "", err
 	}
 
 	if source == "" {
@@ -138,13 +259,16 @@ func SpanLine(name string, startMillis, durationMillis int64, source, traceId, s
 	if !isUUIDFormat(spanId) {
 		return "", errors.New("spanId is not in UUID format")
 	}
+	if durationMillis < 0 {
+		return "", fmt.Errorf("invalid duration %d for span %s: duration must not be negative", durationMillis, name)
+	}
 
 	sb := internal.GetBuffer()
 	defer internal.PutBuffer(sb)
 
-	sb.WriteString(sanitizeValue(name))
+	writeValue(sb, name)
 	sb.WriteString(" source=")
-	sb.WriteString(sanitizeValue(source))
+	writeValue(sb, source)
 	sb.WriteString(" traceId=")
 	sb.WriteString(traceId)
 	sb.WriteString(" spanId=")
@@ -162,9 +286,9 @@ func SpanLine(name string, startMillis, durationMillis int64, source, traceId, s
 
 	if len(spanLogs) > 0 {
 		sb.WriteString(" ")
-		sb.WriteString(strconv.Quote(sanitizeInternal("_spanLogs")))
+		writeQuotedInternal(sb, "_spanLogs")
 		sb.WriteString("=")
-		sb.WriteString(strconv.Quote(sanitizeInternal("true")))
+		writeQuotedInternal(sb, "true")
 	}
 
 	for _, tag := range tags {
@@ -172,14 +296,14 @@ func SpanLine(name string, startMillis, durationMillis int64, source, traceId, s
 			return "", errors.New("span tag key/value cannot be blank")
 		}
 		sb.WriteString(" ")
-		sb.WriteString(strconv.Quote(sanitizeInternal(tag.Key)))
+		writeQuotedInternal(sb, tag.Key)
 		sb.WriteString("=")
-		sb.WriteString(sanitizeValue(tag.Value))
+		writeValue(sb, tag.Value)
 	}
 	sb.WriteString(" ")
-	sb.WriteString(strconv.FormatInt(startMillis, 10))
+	writeInt(sb, startMillis)
 	sb.WriteString(" ")
-	sb.WriteString(strconv.FormatInt(durationMillis, 10))
+	writeInt(sb, durationMillis)
 	sb.WriteString("\n")
 
 	return sb.String(), nil
@@ -200,7 +324,12 @@ func SpanLogJSON(traceId, spanId string, spanLogs []SpanLog) (string, error) {
 
 // EventLine encode the event to a wf proxy format
 // set endMillis to 0 for a 'Instantaneous' event
+// nil options are ignored
 func EventLine(name string, startMillis, endMillis int64, source string, tags map[string]string, setters ...event.Option) (string, error) {
+	if err := checkName("event", name); err != nil {
+		return "", err
+	}
+
 	sb := internal.GetBuffer()
 	defer internal.PutBuffer(sb)
 
@@ -209,7 +338,9 @@ func EventLine(name string, startMillis, endMillis int64, source string, tags ma
 		"annotations": annotations,
 	}
 	for _, set := range setters {
-		set(l)
+		if set != nil {
+			set(l)
+		}
 	}
 
 	sb.WriteString("@Event")
@@ -217,18 +348,18 @@ func EventLine(name string, startMillis, endMillis int64, source string, tags ma
 	startMillis, endMillis = adjustStartEndTime(startMillis, endMillis)
 
 	sb.WriteString(" ")
-	sb.WriteString(strconv.FormatInt(startMillis, 10))
+	writeInt(sb, startMillis)
 	sb.WriteString(" ")
-	sb.WriteString(strconv.FormatInt(endMillis, 10))
+	writeInt(sb, endMillis)
 
 	sb.WriteString(" ")
 	sb.WriteString(strconv.Quote(name))
 
-	for k, v := range annotations {
+	for _, k := range sortedKeys(annotations) {
 		sb.WriteString(" ")
 		sb.WriteString(k)
 		sb.WriteString("=")
-		sb.WriteString(strconv.Quote(v))
+		sb.WriteString(strconv.Quote(annotations[k]))
 	}
 
 	if len(source) > 0 {
@@ -236,9 +367,9 @@ func EventLine(name string, startMillis, endMillis int64, source string, tags ma
 		sb.WriteString(strconv.Quote(source))
 	}
 
-	for k, v := range tags {
+	for _, k := range sortedKeys(tags) {
 		sb.WriteString(" tag=")
-		sb.WriteString(strconv.Quote(fmt.Sprintf("%v: %v", k, v)))
+		sb.WriteString(strconv.Quote(fmt.Sprintf("%v: %v", k, tags[k])))
 	}
 
 	sb.WriteString("\n")
@@ -247,7 +378,12 @@ func EventLine(name string, startMillis, endMillis int64, source string, tags ma
 
 // EventLine encode the event to a wf API format
 // set endMillis to 0 for a 'Instantaneous' event
+// nil options are ignored
 func EventLineJSON(name string, startMillis, endMillis int64, source string, tags map[string]string, setters ...event.Option) (string, error) {
+	if err := checkName("event", name); err != nil {
+		return "", err
+	}
+
 	annotations := map[string]string{}
 	l := map[string]interface{}{
 		"name":        name,
@@ -255,7 +391,9 @@ func EventLineJSON(name string, startMillis, endMillis int64, source string, tag
 	}
 
 	for _, set := range setters {
-		set(l)
+		if set != nil {
+			set(l)
+		}
 	}
 
 	startMillis, endMillis = adjustStartEndTime(startMillis, endMillis)
@@ -265,8 +403,8 @@ func EventLineJSON(name string, startMillis, endMillis int64, source string, tag
 
 	if len(tags) > 0 {
 		var tagList []string
-		for k, v := range tags {
-			tagList = append(tagList, fmt.Sprintf("%v: %v", k, v))
+		for _, k := range sortedKeys(tags) {
+			tagList = append(tagList, fmt.Sprintf("%v: %v", k, tags[k]))
 		}
 		l["tags"] = tagList
 	}
@@ -283,6 +421,16 @@ func EventLineJSON(name string, startMillis, endMillis int64, source string, tag
 	return string(jsonData), nil
 }
 
+// sortedKeys returns the keys of m in increasing order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func adjustStartEndTime(startMillis, endMillis int64) (int64, int64) {
 	// secs to millis
 	if startMillis < 999999999999 {
@@ -322,6 +470,19 @@ func sanitizeInternal(str string) string {
 	sb := internal.GetBuffer()
 	defer internal.PutBuffer(sb)
 
+	writeSanitizedInternal(sb, str)
+	return sb.String()
+}
+
+// writeQuotedInternal writes the sanitized str, quoted. The sanitized characters never need escaping.
+func writeQuotedInternal(sb *bytes.Buffer, str string) {
+	sb.WriteByte('"')
+	writeSanitizedInternal(sb, str)
+	sb.WriteByte('"')
+}
+
+// writeSanitizedInternal writes str sanitized according to the rule of Wavefront proxy, see sanitizeInternal.
+func writeSanitizedInternal(sb *bytes.Buffer, str string) {
 	// first character can be \u2206 (∆ - INCREMENT) or \u0394 (Δ - GREEK CAPITAL LETTER DELTA)
 	// or ~ tilda character for internal metrics
 	skipHead := 0
@@ -337,11 +498,11 @@ func sanitizeInternal(str string) string {
 	// is \u2206 (∆ - INCREMENT) or \u0394 (Δ - GREEK CAPITAL LETTER)
 	if (strings.HasPrefix(str, internal.DeltaPrefix) || strings.HasPrefix(str, internal.AltDeltaPrefix)) &&
 		str[skipHead] == 126 {
-		sb.WriteString(string(str[skipHead]))
+		sb.WriteByte(str[skipHead])
 		skipHead += 1
 	}
 	if str[0] == 126 {
-		sb.WriteString(string(str[0]))
+		sb.WriteByte(str[0])
 		skipHead = 1
 	}
 
@@ -351,26 +512,48 @@ func sanitizeInternal(str string) string {
 			skipHead = 0
 		}
 		cur := str[i]
-		strCur := string(cur)
-		isLegal := true
-
-		if !(44 <= cur && cur <= 57) && !(65 <= cur && cur <= 90) && !(97 <= cur && cur <= 122) && cur != 95 {
-			isLegal = false
-		}
-		if isLegal {
-			sb.WriteString(strCur)
+		if (44 <= cur && cur <= 57) || (65 <= cur && cur <= 90) || (97 <= cur && cur <= 122) || cur == 95 {
+			sb.WriteByte(cur)
 		} else {
-			sb.WriteString("-")
+			sb.WriteByte('-')
 		}
 	}
-	return sb.String()
 }
 
 //Sanitize string of tags value, etc.
 func sanitizeValue(str string) string {
-	res := strings.TrimSpace(str)
-	if strings.Contains(str, "\"") || strings.Contains(str, "'") {
-		res = quotation.ReplaceAllString(res, "\\\"")
+	sb := internal.GetBuffer()
+	defer internal.PutBuffer(sb)
+
+	writeValue(sb, str)
+	return sb.String()
+}
+
+// writeValue writes str trimmed and quoted, escaping its quotes and line breaks, see sanitizeValue.
+func writeValue(sb *bytes.Buffer, str string) {
+	sb.WriteByte('"')
+	str = strings.TrimSpace(str)
+	for i := 0; i < len(str); i++ {
+		switch cur := str[i]; cur {
+		case '"':
+			sb.WriteString("\\\"")
+		case '\n':
+			sb.WriteString("\\n")
+		default:
+			sb.WriteByte(cur)
+		}
 	}
-	return "\"" + lineBreak.ReplaceAllString(res, "\\n") + "\""
+	sb.WriteByte('"')
+}
+
+// writeInt writes the decimal representation of i without allocating.
+func writeInt(sb *bytes.Buffer, i int64) {
+	var b [20]byte
+	sb.Write(strconv.AppendInt(b[:0], i, 10))
+}
+
+// writeFloat writes the shortest decimal representation of f, without exponent.
+func writeFloat(sb *bytes.Buffer, f float64) {
+	var b [32]byte
+	sb.Write(strconv.AppendFloat(b[:0], f, 'f', -1, 64))
 }
@@ -1,10 +1,15 @@
 package senders
 
 import (
+	"bytes"
+	"math"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wavefronthq/wavefront-sdk-go/event"
 	"github.com/wavefronthq/wavefront-sdk-go/histogram"
 )
 
@@ -37,6 +42,24 @@ func TestSanitizeValue(t *testing.T) {
 	assert.Equal(t, "\"hello\\nworld\"", sanitizeValue("hello\nworld"))
 }
 
+func TestWriteQuotedInternal(t *testing.T) {
+	for _, str := range []string{"hello", "hello world", "hello\"world\"", "~component.heartbeat", "∆~component.heartbeat"} {
+		sb := &bytes.Buffer{}
+		writeQuotedInternal(sb, str)
+		assert.Equal(t, strconv.Quote(sanitizeInternal(str)), sb.String(), str)
+	}
+	assert.Equal(t, "\"hello world\"", sanitizeValue("  hello world\n"))
+}
+
+func TestMetricLineAllocs(t *testing.T) {
+	tags := map[string]string{"env": "test"}
+	allocs := testing.AllocsPerRun(100, func() {
+		line, _ = MetricLine("foo.metric", 1.2, 1533529977, "test_source", tags, "")
+	})
+	// the line itself, the formatting going through a pooled buffer
+	assert.Equal(t, 1.0, allocs)
+}
+
 func BenchmarkMetricLine(b *testing.B) {
 	name := "foo.metric"
 	value := 1.2
@@ -71,6 +94,53 @@ func TestMetricLine(t *testing.T) {
 	assert.Equal(t, expected, line)
 }
 
+func TestMetricLineNoSource(t *testing.T) {
+	line, err := MetricLine("foo.metric", 1.2, 1533529977, NoSource,
+		map[string]string{"env": "test"}, "default")
+	expected := "\"foo.metric\" 1.2 1533529977 \"env\"=\"test\"\n"
+	assert.Nil(t, err)
+	assert.Equal(t, expected, line)
+	assert.NotContains(t, line, "source=")
+
+	line, err = HistoLine("request.latency", makeCentroids(), map[histogram.Granularity]bool{histogram.MINUTE: true},
+		1533529977, NoSource, map[string]string{"env": "test"}, "default")
+	expected = "!M 1533529977 #20 30 \"request.latency\" \"env\"=\"test\"\n"
+	assert.Nil(t, err)
+	assert.Equal(t, expected, line)
+}
+
+func TestNonFiniteValues(t *testing.T) {
+	for _, value := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		line, err := MetricLine("foo.metric", value, 1533529977, "test_source", nil, "")
+		assert.NotNil(t, err, "metric value %v", value)
+		assert.Empty(t, line)
+
+		centroids := []histogram.Centroid{{Value: 30.0, Count: 20}, {Value: value, Count: 1}}
+		line, err = HistoLine("request.latency", centroids, map[histogram.Granularity]bool{histogram.MINUTE: true},
+			1533529977, "test_source", nil, "")
+		assert.NotNil(t, err, "centroid value %v", value)
+		assert.Empty(t, line)
+	}
+}
+
+func TestInvalidCentroidCounts(t *testing.T) {
+	for _, count := range []int{0, -1} {
+		centroids := []histogram.Centroid{{Value: 30.0, Count: 20}, {Value: 5.1, Count: count}}
+		line, err := HistoLine("request.latency", centroids, map[histogram.Granularity]bool{histogram.MINUTE: true},
+			1533529977, "test_source", nil, "")
+		assert.Error(t, err, "centroid count %d", count)
+		assert.Empty(t, line)
+	}
+
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", DistributionPort: 40000})
+	defer sender.Close()
+	err := sender.SendDistribution("request.latency", []histogram.Centroid{{Value: 30.0, Count: 0}},
+		map[histogram.Granularity]bool{histogram.MINUTE: true}, 1533529977, "test_source", nil)
+	assert.EqualError(t, err, "distribution request.latency: invalid centroid count 0: count must be at least 1")
+	assert.Equal(t, int64(1), sender.histogramsInvalid.Count())
+	assert.Empty(t, handlers[histoHandler].data())
+}
+
 func BenchmarkHistoLine(b *testing.B) {
 	name := "request.latency"
 	centroids := makeCentroids()
@@ -146,9 +216,169 @@ func TestHistoLine(t *testing.T) {
 		1533529977, "test_source", map[string]string{"env": "test"}, "")
 	expected = "!M 1533529977 #20 30 \"request.latency\" source=\"test_source\" \"env\"=\"test\"\n" +
 		"!H 1533529977 #20 30 \"request.latency\" source=\"test_source\" \"env\"=\"test\"\n"
-	if len(line) != len(expected) {
-		t.Errorf("lines don't match. expected: %s, actual: %s", expected, line)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, line)
+}
+
+func TestDeterministicTagOrder(t *testing.T) {
+	tags := map[string]string{"env": "test", "az": "us-west-2a", "cluster": "c1", "zone": "z", "service": "api"}
+	hgs := map[histogram.Granularity]bool{histogram.DAY: true, histogram.HOUR: true, histogram.MINUTE: true}
+	tagsSuffix := " \"az\"=\"us-west-2a\" \"cluster\"=\"c1\" \"env\"=\"test\" \"service\"=\"api\" \"zone\"=\"z\"\n"
+
+	for i := 0; i < 20; i++ {
+		line, err := MetricLine("foo.metric", 1.2, 1533529977, "test_source", tags, "")
+		require.NoError(t, err)
+		assert.Equal(t, "\"foo.metric\" 1.2 1533529977 source=\"test_source\""+tagsSuffix, line)
+
+		line, err = HistoLine("request.latency", makeCentroids(), hgs, 1533529977, "test_source", tags, "")
+		require.NoError(t, err)
+		assert.Equal(t, "!M 1533529977 #20 30 \"request.latency\" source=\"test_source\""+tagsSuffix+
+			"!H 1533529977 #20 30 \"request.latency\" source=\"test_source\""+tagsSuffix+
+			"!D 1533529977 #20 30 \"request.latency\" source=\"test_source\""+tagsSuffix, line)
+
+		line, err = EventLine("event", 1533531013, 1533531014, "localhost", tags,
+			event.Severity("info"), event.Type("backup"), event.Annotate("details", "done"))
+		require.NoError(t, err)
+		assert.Equal(t, "@Event 1533531013000 1533531014000 \"event\" details=\"done\" severity=\"info\" type=\"backup\" host=\"localhost\""+
+			" tag=\"az: us-west-2a\" tag=\"cluster: c1\" tag=\"env: test\" tag=\"service: api\" tag=\"zone: z\"\n", line)
+
+		line, err = EventLineJSON("event", 1533531013, 1533531014, "localhost", tags)
+		require.NoError(t, err)
+		assert.Contains(t, line, "\"tags\":[\"az: us-west-2a\",\"cluster: c1\",\"env: test\",\"service: api\",\"zone: z\"]")
+	}
+}
+
+func TestHistoLineAlignedTimestamps(t *testing.T) {
+	centroids := makeCentroids()
+	tags := map[string]string{"env": "test"}
+
+	line, err := histoLine("request.latency", centroids, map[histogram.Granularity]bool{histogram.MINUTE: true},
+		1533529977, "test_source", tags, "", histoLineOptions{alignTimestamps: true})
+	assert.Nil(t, err)
+	assert.Equal(t, "!M 1533529920 #20 30 \"request.latency\" source=\"test_source\" \"env\"=\"test\"\n", line)
+
+	line, err = histoLine("request.latency", centroids, map[histogram.Granularity]bool{histogram.HOUR: true},
+		1533529977, "test_source", tags, "", histoLineOptions{alignTimestamps: true})
+	assert.Nil(t, err)
+	assert.Equal(t, "!H 1533528000 #20 30 \"request.latency\" source=\"test_source\" \"env\"=\"test\"\n", line)
+
+	line, err = histoLine("request.latency", centroids, map[histogram.Granularity]bool{histogram.DAY: true},
+		1533529977, "test_source", tags, "", histoLineOptions{alignTimestamps: true})
+	assert.Nil(t, err)
+	assert.Equal(t, "!D 1533513600 #20 30 \"request.latency\" source=\"test_source\" \"env\"=\"test\"\n", line)
+
+	// milliseconds stay milliseconds
+	line, err = histoLine("request.latency", centroids, map[histogram.Granularity]bool{histogram.MINUTE: true},
+		1533529977123, "test_source", tags, "", histoLineOptions{alignTimestamps: true})
+	assert.Nil(t, err)
+	assert.Equal(t, "!M 1533529920000 #20 30 \"request.latency\" source=\"test_source\" \"env\"=\"test\"\n", line)
+
+	// each granularity line gets its own bin
+	line, err = histoLine("request.latency", centroids, map[histogram.Granularity]bool{histogram.MINUTE: true, histogram.DAY: true},
+		1533529977, "test_source", tags, "", histoLineOptions{alignTimestamps: true})
+	assert.Nil(t, err)
+	assert.Contains(t, line, "!M 1533529920 #20 30")
+	assert.Contains(t, line, "!D 1533513600 #20 30")
+
+	// no timestamp, nothing to align
+	line, err = histoLine("request.latency", centroids, map[histogram.Granularity]bool{histogram.MINUTE: true},
+		0, "test_source", tags, "", histoLineOptions{alignTimestamps: true})
+	assert.Nil(t, err)
+	assert.Equal(t, "!M #20 30 \"request.latency\" source=\"test_source\" \"env\"=\"test\"\n", line)
+}
+
+func TestNilAndEmptyTags(t *testing.T) {
+	centroids := makeCentroids()
+	hgs := map[histogram.Granularity]bool{histogram.MINUTE: true}
+
+	nilLine, err := MetricLine("new-york.power.usage", 42422, 1533529977, "test_source", nil, "")
+	assert.Nil(t, err)
+	emptyLine, err := MetricLine("new-york.power.usage", 42422, 1533529977, "test_source", map[string]string{}, "")
+	assert.Nil(t, err)
+	assert.Equal(t, "\"new-york.power.usage\" 42422 1533529977 source=\"test_source\"\n", nilLine)
+	assert.Equal(t, nilLine, emptyLine)
+
+	nilLine, err = HistoLine("request.latency", centroids, hgs, 1533529977, "test_source", nil, "")
+	assert.Nil(t, err)
+	emptyLine, err = HistoLine("request.latency", centroids, hgs, 1533529977, "test_source", map[string]string{}, "")
+	assert.Nil(t, err)
+	assert.Equal(t, "!M 1533529977 #20 30 \"request.latency\" source=\"test_source\"\n", nilLine)
+	assert.Equal(t, nilLine, emptyLine)
+
+	traceId := "7b3bf470-9456-11e8-9eb6-529269fb1459"
+	nilLine, err = SpanLine("getAllUsers", 1533531013, 343500, "localhost", traceId, traceId, nil, nil, nil, nil, "")
+	assert.Nil(t, err)
+	emptyLine, err = SpanLine("getAllUsers", 1533531013, 343500, "localhost", traceId, traceId, []string{}, []string{}, []SpanTag{}, []SpanLog{}, "")
+	assert.Nil(t, err)
+	assert.Equal(t, nilLine, emptyLine)
+
+	nilLine, err = EventLine("event", 1533531013, 0, "localhost", nil)
+	assert.Nil(t, err)
+	emptyLine, err = EventLine("event", 1533531013, 0, "localhost", map[string]string{})
+	assert.Nil(t, err)
+	assert.Equal(t, "@Event 1533531013000 1533531013001 \"event\" host=\"localhost\"\n", nilLine)
+	assert.Equal(t, nilLine, emptyLine)
+
+	nilLine, err = EventLineJSON("event", 1533531013, 0, "localhost", nil)
+	assert.Nil(t, err)
+	emptyLine, err = EventLineJSON("event", 1533531013, 0, "localhost", map[string]string{})
+	assert.Nil(t, err)
+	assert.NotContains(t, nilLine, "tags")
+	assert.Equal(t, nilLine, emptyLine)
+}
+
+func TestEventLineNilOption(t *testing.T) {
+	line, err := EventLine("event", 1533531013, 0, "localhost", nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "@Event 1533531013000 1533531013001 \"event\" host=\"localhost\"\n", line)
+
+	line, err = EventLineJSON("event", 1533531013, 0, "localhost", nil, nil)
+	assert.Nil(t, err)
+	assert.Contains(t, line, "\"name\":\"event\"")
+}
+
+func TestHistoLineSplit(t *testing.T) {
+	centroids := make(histogram.Centroids, 5000)
+	total := 0
+	for i := range centroids {
+		centroids[i] = histogram.Centroid{Value: float64(i) + 0.5, Count: i%7 + 1}
+		total += centroids[i].Count
+	}
+	hgs := map[histogram.Granularity]bool{histogram.MINUTE: true, histogram.HOUR: true}
+	tags := map[string]string{"env": "test"}
+	maxLineBytes := 1024
+
+	line, err := histoLine("request.latency", centroids, hgs, 1533529977, "test_source", tags, "", histoLineOptions{maxLineBytes: maxLineBytes})
+	require.NoError(t, err)
+
+	lines := strings.SplitAfter(line, "\n")
+	lines = lines[:len(lines)-1]
+	assert.True(t, len(lines) > 2, "distribution not split")
+
+	counts := map[string]int{}
+	for _, l := range lines {
+		assert.True(t, len(l) <= maxLineBytes, "line of %d bytes", len(l))
+		assert.True(t, strings.HasSuffix(l, " \"request.latency\" source=\"test_source\" \"env\"=\"test\"\n"), l)
+
+		fields := strings.Fields(l)
+		assert.Equal(t, "1533529977", fields[1])
+		for i := 2; strings.HasPrefix(fields[i], "#"); i += 2 {
+			count, err := strconv.Atoi(fields[i][1:])
+			require.NoError(t, err)
+			counts[fields[0]] += count
+		}
 	}
+	// each granularity gets all the centroids
+	assert.Equal(t, map[string]int{"!M": total, "!H": total}, counts)
+
+	// no limit, a single line per granularity
+	line, err = histoLine("request.latency", centroids, hgs, 1533529977, "test_source", tags, "", histoLineOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(line, "\n"))
+
+	// a limit too low for a single centroid
+	_, err = histoLine("request.latency", centroids, hgs, 1533529977, "test_source", tags, "", histoLineOptions{maxLineBytes: 40})
+	assert.Error(t, err)
 }
 
 func BenchmarkSpanLine(b *testing.B) {
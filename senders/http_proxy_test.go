@@ -0,0 +1,88 @@
+package senders
+
+import (
+	"context"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newForwardProxy starts a forward proxy tunneling the CONNECT requests to their target and answering the other
+// requests itself, sending the method and host of each request on the returned channel.
+func newForwardProxy() (*httptest.Server, chan string) {
+	requests := make(chan string, 10)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r.Method + " " + r.Host
+		if r.Method != http.MethodConnect {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		upstream, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			upstream.Close()
+			return
+		}
+		conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		go func() {
+			io.Copy(upstream, conn)
+			upstream.Close()
+		}()
+		io.Copy(conn, upstream)
+		conn.Close()
+	}))
+	return proxy, requests
+}
+
+func sendThroughProxy(t *testing.T, wfURL string, opts ...Option) error {
+	opts = append(opts, FlushIntervalSeconds(3600))
+	sender, err := NewSender(wfURL, opts...)
+	require.NoError(t, err)
+	defer sender.Close()
+
+	require.NoError(t, sender.SendMetric("foo.metric", 1.2, 0, "test_source", nil))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	return sender.(Drainer).Drain(ctx)
+}
+
+func TestHTTPProxy(t *testing.T) {
+	proxy, requests := newForwardProxy()
+	defer proxy.Close()
+	proxyURL, err := url.Parse(proxy.URL)
+	require.NoError(t, err)
+
+	// the proxy answers the HTTP requests, the host doesn't need to resolve
+	require.NoError(t, sendThroughProxy(t, "http://DUMMY_TOKEN@wavefront.test", HTTPProxy(proxyURL)))
+	assert.Equal(t, "POST wavefront.test", <-requests)
+}
+
+func TestHTTPProxyConnect(t *testing.T) {
+	proxy, requests := newForwardProxy()
+	defer proxy.Close()
+	proxyURL, err := url.Parse(proxy.URL)
+	require.NoError(t, err)
+	server, clientCerts := newTLSTestServer()
+	defer server.Close()
+
+	// the HTTPS requests are tunneled, keeping the TLS settings
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	wfURL := strings.Replace(server.URL, "https://", "https://DUMMY_TOKEN@", 1)
+	require.NoError(t, sendThroughProxy(t, wfURL, HTTPProxy(proxyURL), TLSRootCAs(pool)))
+	assert.Equal(t, "CONNECT "+strings.TrimPrefix(server.URL, "https://"), <-requests)
+	assert.Equal(t, 0, <-clientCerts)
+}
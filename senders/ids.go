@@ -0,0 +1,60 @@
+package senders
+
+import (
+	cryptorand "crypto/rand"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+)
+
+// defaultIDs generates the ids of NewTraceID and NewSpanID from a cryptographically secure source.
+var defaultIDs = &IDGenerator{rand: cryptorand.Reader}
+
+// NewTraceID returns a random trace id, a version 4 UUID as expected by SendSpan.
+func NewTraceID() string {
+	return defaultIDs.TraceID()
+}
+
+// NewSpanID returns a random span id, a version 4 UUID as expected by SendSpan.
+func NewSpanID() string {
+	return defaultIDs.SpanID()
+}
+
+// IDGenerator generates trace and span ids. Created with a seed, it generates the same sequence of ids
+// for the same seed, e.g. to get reproducible ids in tests or when replaying data. It's safe for concurrent use,
+// although the sequence is then only reproducible when the ids are generated in the same order.
+type IDGenerator struct {
+	mtx  sync.Mutex
+	rand io.Reader
+}
+
+// NewIDGenerator returns an IDGenerator generating a deterministic sequence of ids from the given seed.
+// Use NewTraceID and NewSpanID for random ids.
+func NewIDGenerator(seed int64) *IDGenerator {
+	return &IDGenerator{rand: rand.New(rand.NewSource(seed))}
+}
+
+// TraceID returns the next trace id, a version 4 UUID.
+func (g *IDGenerator) TraceID() string {
+	return g.uuid()
+}
+
+// SpanID returns the next span id, a version 4 UUID.
+func (g *IDGenerator) SpanID() string {
+	return g.uuid()
+}
+
+func (g *IDGenerator) uuid() string {
+	var b [16]byte
+	g.mtx.Lock()
+	_, err := io.ReadFull(g.rand, b[:])
+	g.mtx.Unlock()
+	if err != nil {
+		// only the system's secure source can fail, and then no ids can be generated
+		panic(fmt.Sprintf("unable to generate a random id: %v", err))
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,42 @@
+package senders
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTraceAndSpanIDs(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		for _, id := range []string{NewTraceID(), NewSpanID()} {
+			assert.True(t, isUUIDFormat(id), id)
+			assert.Equal(t, byte('4'), id[14], "version 4 UUID: %s", id)
+			assert.False(t, seen[id], "duplicate id %s", id)
+			seen[id] = true
+		}
+	}
+}
+
+func TestIDGeneratorSeed(t *testing.T) {
+	g1, g2 := NewIDGenerator(42), NewIDGenerator(42)
+	for i := 0; i < 10; i++ {
+		traceId, spanId := g1.TraceID(), g1.SpanID()
+		assert.True(t, isUUIDFormat(traceId), traceId)
+		assert.True(t, isUUIDFormat(spanId), spanId)
+		assert.NotEqual(t, traceId, spanId)
+		assert.Equal(t, traceId, g2.TraceID(), "same seed, same ids")
+		assert.Equal(t, spanId, g2.SpanID(), "same seed, same ids")
+	}
+	assert.NotEqual(t, NewIDGenerator(1).TraceID(), NewIDGenerator(2).TraceID())
+}
+
+func TestGeneratedIDsPassSpanLine(t *testing.T) {
+	g := NewIDGenerator(7)
+	traceId := g.TraceID()
+	_, err := SpanLine("getAllUsers", 1552949776000, 343, "localhost", traceId, g.SpanID(), []string{g.SpanID()}, nil, nil, nil, "default")
+	require.NoError(t, err)
+	_, err = SpanLine("getAllUsers", 1552949776000, 343, "localhost", NewTraceID(), NewSpanID(), nil, nil, nil, nil, "default")
+	require.NoError(t, err)
+}
@@ -0,0 +1,50 @@
+package senders
+
+import "bytes"
+
+// IntMetricSerializer is implemented by the serializers encoding the integer values of the metrics sent with
+// SendIntMetric exactly. The values sent to the other serializers are converted to float64, losing the precision
+// of the values beyond 2^53.
+type IntMetricSerializer interface {
+	IntMetricLine(name string, value int64, ts int64, source string, tags map[string]string, defaultSource string) (string, error)
+}
+
+// IntMetricLine gets a metric line like MetricLine, the integer value being written exactly, e.g. 9007199254740993
+// which isn't representable as a float64.
+func IntMetricLine(name string, value int64, ts int64, source string, tags map[string]string, defaultSource string) (string, error) {
+	return formatMetricLine(name, intValue(value), ts, source, tags, defaultSource, nil)
+}
+
+// metricValue is the value of a metric point, a float or an integer written exactly.
+type metricValue struct {
+	float float64
+	int   int64
+	isInt bool
+}
+
+func floatValue(f float64) metricValue {
+	return metricValue{float: f}
+}
+
+func intValue(i int64) metricValue {
+	return metricValue{int: i, isInt: true}
+}
+
+func (v metricValue) write(sb *bytes.Buffer) {
+	if v.isInt {
+		writeInt(sb, v.int)
+	} else {
+		writeFloat(sb, v.float)
+	}
+}
+
+// serializeMetric serializes a metric point, the integer values with IntMetricLine when the serializer implements it.
+func serializeMetric(s Serializer, name string, value metricValue, ts int64, source string, tags map[string]string, defaultSource string) (string, error) {
+	if !value.isInt {
+		return s.MetricLine(name, value.float, ts, source, tags, defaultSource)
+	}
+	if is, ok := s.(IntMetricSerializer); ok {
+		return is.IntMetricLine(name, value.int, ts, source, tags, defaultSource)
+	}
+	return s.MetricLine(name, float64(value.int), ts, source, tags, defaultSource)
+}
@@ -0,0 +1,53 @@
+package senders
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntMetricLine(t *testing.T) {
+	// 2^53+1 isn't representable as a float64
+	line, err := IntMetricLine("bytes.sent", 9007199254740993, 1533529977, "test_source", map[string]string{"env": "test"}, "")
+	require.NoError(t, err)
+	assert.Equal(t, "\"bytes.sent\" 9007199254740993 1533529977 source=\"test_source\" \"env\"=\"test\"\n", line)
+
+	line, err = MetricLine("bytes.sent", float64(9007199254740993), 1533529977, "test_source", nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, "\"bytes.sent\" 9007199254740992 1533529977 source=\"test_source\"\n", line, "the float loses the last digit")
+
+	line, err = IntMetricLine("offset", -9223372036854775808, 0, "test_source", nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, "\"offset\" -9223372036854775808 source=\"test_source\"\n", line)
+}
+
+func TestSendIntMetric(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", MetricsPort: 30000, ValuePrecision: 3})
+	defer sender.Close()
+
+	require.NoError(t, sender.SendIntMetric("bytes.sent", 9007199254740993, 1533529977, "test_source", nil))
+	assert.Equal(t, "\"bytes.sent\" 9007199254740993 1533529977 source=\"test_source\"\n", handlers[metricHandler].data(),
+		"the precision only rounds the float values")
+}
+
+func TestSendIntMetricDedup(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", MetricsPort: 30000, DedupMetrics: true})
+	defer sender.Close()
+
+	require.NoError(t, sender.SendIntMetric("bytes.sent", 1, 1533529977, "test_source", nil))
+	require.NoError(t, sender.SendIntMetric("bytes.sent", 9007199254740993, 1533529978, "test_source", nil))
+	require.NoError(t, sender.Flush())
+	assert.Equal(t, "\"bytes.sent\" 9007199254740993 1533529978 source=\"test_source\"\n", handlers[metricHandler].data())
+}
+
+// floatSerializer is a Serializer without IntMetricLine.
+type floatSerializer struct {
+	Serializer
+}
+
+func TestSerializeIntMetricFallback(t *testing.T) {
+	line, err := serializeMetric(floatSerializer{&lineSerializer{}}, "count", intValue(42), 0, "test_source", nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, "\"count\" 42 source=\"test_source\"\n", line)
+}
@@ -0,0 +1,70 @@
+package senders
+
+import (
+	"bytes"
+	"sync"
+)
+
+// tagCache interns the formatted (sanitized and quoted) keys and values of the tags of metric lines,
+// so that the tags repeated across points are formatted once and share the same strings.
+// It holds at most max keys and max values, each map being reset when full.
+// A nil cache formats every tag.
+type tagCache struct {
+	max int
+
+	mtx    sync.RWMutex
+	keys   map[string]string
+	values map[string]string
+}
+
+// newTagCache returns a cache holding at most max keys and max values, nil when max <= 0.
+func newTagCache(max int) *tagCache {
+	if max <= 0 {
+		return nil
+	}
+	return &tagCache{
+		max:    max,
+		keys:   make(map[string]string),
+		values: make(map[string]string),
+	}
+}
+
+// writeKey writes the formatted tag key k.
+func (c *tagCache) writeKey(sb *bytes.Buffer, k string) {
+	if c == nil {
+		writeQuotedInternal(sb, k)
+		return
+	}
+	sb.WriteString(c.intern(&c.keys, k, formatTagKey))
+}
+
+// writeValue writes the formatted tag value v.
+func (c *tagCache) writeValue(sb *bytes.Buffer, v string) {
+	if c == nil {
+		writeValue(sb, v)
+		return
+	}
+	sb.WriteString(c.intern(&c.values, v, sanitizeValue))
+}
+
+func (c *tagCache) intern(m *map[string]string, s string, format func(string) string) string {
+	c.mtx.RLock()
+	formatted, ok := (*m)[s]
+	c.mtx.RUnlock()
+	if ok {
+		return formatted
+	}
+
+	formatted = format(s)
+	c.mtx.Lock()
+	if len(*m) >= c.max {
+		*m = make(map[string]string, c.max)
+	}
+	(*m)[s] = formatted
+	c.mtx.Unlock()
+	return formatted
+}
+
+func formatTagKey(k string) string {
+	return "\"" + sanitizeInternal(k) + "\""
+}
@@ -0,0 +1,76 @@
+package senders
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagCache(t *testing.T) {
+	tags := map[string]string{"env": "test", "bad key": "quoted \"value\""}
+	expected, err := MetricLine("foo.metric", 1.2, 1533529977, "test_source", tags, "")
+	require.NoError(t, err)
+
+	cache := newTagCache(2)
+	for i := 0; i < 3; i++ {
+		line, err := metricLine("foo.metric", 1.2, 1533529977, "test_source", tags, "", cache)
+		require.NoError(t, err)
+		assert.Equal(t, expected, line)
+	}
+	assert.Len(t, cache.keys, 2)
+	assert.Len(t, cache.values, 2)
+
+	// the cache is reset when full
+	cache.writeValue(&bytes.Buffer{}, "other")
+	assert.Equal(t, map[string]string{"other": "\"other\""}, cache.values)
+
+	assert.Nil(t, newTagCache(0))
+	var sb bytes.Buffer
+	(*tagCache)(nil).writeKey(&sb, "bad key")
+	assert.Equal(t, "\"bad-key\"", sb.String())
+}
+
+func TestMaxInternedTags(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{
+		Host:            "localhost",
+		MetricsPort:     50000,
+		MaxInternedTags: 100,
+	})
+	defer sender.Close()
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, sender.SendMetric("foo.metric", 1, 1533529977, "test", map[string]string{"env": "test"}))
+	}
+	line := "\"foo.metric\" 1 1533529977 source=\"test\" \"env\"=\"test\"\n"
+	assert.Equal(t, line+line, handlers[metricHandler].data())
+}
+
+// BenchmarkMetricLineTags formats the points of a fleet of services, each point having tags drawn
+// from a few thousands of distinct keys and values, with and without interning them.
+func BenchmarkMetricLineTags(b *testing.B) {
+	const series = 10000
+	tags := make([]map[string]string, series)
+	for i := range tags {
+		tags[i] = map[string]string{
+			"env":     []string{"dev", "staging", "prod"}[i%3],
+			"region":  fmt.Sprintf("region-%d", i%8),
+			"service": fmt.Sprintf("service-%d", i%50),
+			"host":    fmt.Sprintf("host-%d.example.com", i%500),
+			"pod":     fmt.Sprintf("pod-%d", i%2000),
+		}
+	}
+
+	for name, cache := range map[string]*tagCache{"formatted": nil, "interned": newTagCache(4096)} {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			var r string
+			for n := 0; n < b.N; n++ {
+				r, _ = metricLine("request.latency", 1.2, 1533529977, "test_source", tags[n%series], "", cache)
+			}
+			line = r
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package senders
+
+// Middleware decorates a Sender with a cross-cutting behavior, e.g. prefixing the names of the data sent.
+// The returned Sender forwards the data, possibly modified, to the given one. Compose them with Chain.
+type Middleware func(Sender) Sender
+
+// Chain wraps base with the given middlewares, the first one being the outermost: it gets the data first and
+// forwards it to the next one, the last one forwarding it to base. For instance
+//
+//	sender := senders.Chain(base, senders.Tagging(tags), senders.Prefixing("myapp."))
+//
+// tags the data, then prefixes it, then sends it with base. Closing the chain closes base.
+func Chain(base Sender, mws ...Middleware) Sender {
+	sender := base
+	for i := len(mws) - 1; i >= 0; i-- {
+		sender = mws[i](sender)
+	}
+	return sender
+}
+
+// Prefixing returns a Middleware prepending the given prefix to the names of the data sent, see NewPrefixingSender.
+func Prefixing(prefix string, opts ...PrefixOption) Middleware {
+	return func(inner Sender) Sender {
+		return NewPrefixingSender(inner, prefix, opts...)
+	}
+}
+
+// Tagging returns a Middleware adding the given tags to the data sent, see NewTaggingSender.
+func Tagging(tags map[string]string, opts ...TagOption) Middleware {
+	return func(inner Sender) Sender {
+		return NewTaggingSender(inner, tags, opts...)
+	}
+}
+
+// Retrying returns a Middleware retrying the failed sends, see NewRetryingSender.
+func Retrying(cfg RetryConfig) Middleware {
+	return func(inner Sender) Sender {
+		return NewRetryingSender(inner, cfg)
+	}
+}
@@ -0,0 +1,30 @@
+package senders
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChain(t *testing.T) {
+	inner := &fakeSender{}
+	assert.Equal(t, Sender(inner), Chain(inner), "no middleware")
+
+	var order []string
+	recording := func(name string) Middleware {
+		return func(next Sender) Sender {
+			order = append(order, name)
+			return next
+		}
+	}
+	Chain(inner, recording("outer"), recording("inner"))
+	assert.Equal(t, []string{"inner", "outer"}, order, "the middlewares are applied from the innermost")
+
+	sender := Chain(inner, Prefixing("myapp."), Prefixing("team."))
+	assert.NoError(t, sender.SendMetric("requests", 1, 0, "", nil))
+	assert.NoError(t, sender.SendDeltaCounter("errors", 1, "", nil))
+	assert.Equal(t, []string{
+		"metric team.myapp.requests 1",
+		"delta team.myapp.errors 1",
+	}, inner.calls, "the first middleware gets the data first")
+}
@@ -0,0 +1,44 @@
+package senders
+
+import (
+	"strings"
+
+	"github.com/wavefronthq/wavefront-sdk-go/internal"
+)
+
+// OpenMetricsNameSanitizer replaces the characters not allowed in OpenMetrics metric names (other than ASCII
+// letters, digits, underscores and colons) with underscores, prefixing with an underscore the names starting
+// with a digit. Use it with the MetricNameSanitizer option.
+func OpenMetricsNameSanitizer(name string) string {
+	var sb strings.Builder
+	sb.Grow(len(name))
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', c == '_', c == ':':
+			sb.WriteByte(c)
+		case '0' <= c && c <= '9':
+			if i == 0 {
+				sb.WriteByte('_')
+			}
+			sb.WriteByte(c)
+		default:
+			sb.WriteByte('_')
+		}
+	}
+	return sb.String()
+}
+
+// sanitizeMetricName applies sanitize to a metric name, keeping its delta counter prefix if any.
+// Internal metrics, prefixed with ~, are left untouched.
+func sanitizeMetricName(name string, sanitize func(string) string) string {
+	if sanitize == nil || strings.HasPrefix(name, "~") {
+		return name
+	}
+	for _, prefix := range []string{internal.DeltaPrefix, internal.AltDeltaPrefix} {
+		if strings.HasPrefix(name, prefix) {
+			return prefix + sanitize(strings.TrimPrefix(name, prefix))
+		}
+	}
+	return sanitize(name)
+}
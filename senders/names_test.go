@@ -0,0 +1,50 @@
+package senders
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenMetricsNameSanitizer(t *testing.T) {
+	assert.Equal(t, "http_server_requests_total", OpenMetricsNameSanitizer("http/server requests.total"))
+	assert.Equal(t, "api:latency_p99", OpenMetricsNameSanitizer("api:latency-p99"))
+	assert.Equal(t, "_5xx_errors", OpenMetricsNameSanitizer("5xx errors"))
+	assert.Equal(t, "valid_name", OpenMetricsNameSanitizer("valid_name"))
+}
+
+func TestMetricNameSanitizer(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{
+		Host:                "localhost",
+		MetricsPort:         50000,
+		MetricNameSanitizer: OpenMetricsNameSanitizer,
+	})
+	defer sender.Close()
+
+	require.NoError(t, sender.SendMetric("http/server requests", 1, 1533529977, "test_source", nil))
+	require.NoError(t, sender.SendDeltaCounter("jobs/processed count", 1, "test_source", nil))
+	require.NoError(t, sender.SendMetric("~sdk.go.core.sender.proxy.points.valid", 1, 1533529977, "test_source", nil))
+	assert.Equal(t, "\"http_server_requests\" 1 1533529977 source=\"test_source\"\n"+
+		"\"∆jobs_processed_count\" 1 source=\"test_source\"\n"+
+		"\"~sdk.go.core.sender.proxy.points.valid\" 1 1533529977 source=\"test_source\"\n", handlers[metricHandler].data())
+
+	// the names are sent as is by default
+	sender, handlers = newTestProxySender(t, &ProxyConfiguration{Host: "localhost", MetricsPort: 50000})
+	defer sender.Close()
+	require.NoError(t, sender.SendMetric("http/server requests", 1, 1533529977, "test_source", nil))
+	assert.Equal(t, "\"http/server-requests\" 1 1533529977 source=\"test_source\"\n", handlers[metricHandler].data())
+}
+
+func TestCustomMetricNameSanitizer(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{
+		Host:                "localhost",
+		MetricsPort:         50000,
+		MetricNameSanitizer: strings.ToLower,
+	})
+	defer sender.Close()
+
+	require.NoError(t, sender.SendMetric("Request.Count", 1, 1533529977, "test_source", nil))
+	assert.Equal(t, "\"request.count\" 1 1533529977 source=\"test_source\"\n", handlers[metricHandler].data())
+}
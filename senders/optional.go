@@ -0,0 +1,353 @@
+package senders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/wavefronthq/wavefront-sdk-go/histogram"
+)
+
+// The interfaces below are implemented by the senders of this package on top of Sender. Type-assert a Sender to
+// use them, e.g.
+//
+//	if flusher, ok := sender.(senders.SignalFlusher); ok {
+//		err = flusher.FlushSignal(senders.SpanSignal)
+//	}
+//
+// The wrappers of this package (NewMultiSender, NewPrefixingSender, NewTaggingSender, NewRetryingSender and
+// NewContextSender) implement all of them, forwarding to the wrapped senders and falling back to the methods
+// of Sender for the senders not implementing them.
+
+// MetricNowSender is a sender of metrics without timestamp.
+type MetricNowSender interface {
+	// Sends a single metric to Wavefront without timestamp, the proxy or Wavefront service assigning its
+	// arrival time. The line has no timestamp field, e.g. "cpu.usage" 42 source="host" instead of
+	// "cpu.usage" 42 1533531013 source="host" as sent by SendMetric with ts <= 0, which uses the sender's clock.
+	SendMetricNow(name string, value float64, source string, tags map[string]string) error
+}
+
+// IntMetricSender is a sender of metrics with an integer value.
+type IntMetricSender interface {
+	// Sends a single metric like SendMetric, the integer value being sent exactly instead of being converted to
+	// a float64, which loses the precision beyond 2^53, e.g. for byte counters or ids sent as values.
+	SendIntMetric(name string, value int64, ts int64, source string, tags map[string]string) error
+}
+
+// DistributionGSender is a sender of distributions taking their granularities as variadic arguments.
+type DistributionGSender interface {
+	// Sends a distribution like SendDistribution, the granularities being listed instead of set in a map,
+	// e.g. SendDistributionG("request.latency", centroids, 0, "", nil, histogram.MINUTE, histogram.HOUR).
+	SendDistributionG(name string, centroids []histogram.Centroid, ts int64, source string, tags map[string]string, granularities ...histogram.Granularity) error
+}
+
+// DistributionBatchSender is a sender of batches of distributions.
+type DistributionBatchSender interface {
+	// Sends a batch of distributions, each with its own source and tags, to Wavefront in a single write.
+	// The returned slice has one entry per distribution, nil for the distributions that were sent successfully.
+	SendDistributions(dists []DistributionPoint) []error
+}
+
+// SpanBatchSender is a sender of batches of spans.
+type SpanBatchSender interface {
+	// Sends a batch of tracing spans (and their span logs) to Wavefront in a single write.
+	// The returned slice has one entry per span, nil for the spans that were sent successfully.
+	SendSpans(spans []Span) []error
+}
+
+// ErrorCloser is a sender whose Close errors can be returned.
+type ErrorCloser interface {
+	// CloseWithError closes the sender like Close, returning the errors from the final flush.
+	CloseWithError() error
+}
+
+// SignalFlusher is a sender flushing the data of each signal separately.
+type SignalFlusher interface {
+	// FlushSignal flushes the buffered data of the given signal only. Flushing spans also flushes their span logs.
+	FlushSignal(signal SignalType) error
+}
+
+// SignalEnabler is a sender whose signals can be disabled at runtime.
+type SignalEnabler interface {
+	// SetSignalEnabled enables or disables the given signal at runtime, e.g. to stop sending spans during an
+	// incident. The data of a disabled signal is dropped without error and counted by its "suppressed"
+	// internal metric; the data already buffered is still sent. Disabling metrics also suppresses the
+	// internal metrics of the sender. All the signals are enabled by default.
+	SetSignalEnabled(signal SignalType, enabled bool)
+}
+
+// Drainer is a sender retrying its flushes until all the buffered data is sent.
+type Drainer interface {
+	// Drain blocks until all the buffered data is sent, flushing each handler and retrying (reconnecting
+	// to the proxy as needed) until it succeeds or ctx is done. Unlike Flush, which makes a single attempt,
+	// it lets short-lived jobs make sure their final data is not lost. The returned error wraps ctx.Err()
+	// when ctx is done first.
+	Drain(ctx context.Context) error
+}
+
+// ConnectionStatusReporter is a sender reporting the connected state of its handlers.
+type ConnectionStatusReporter interface {
+	// ConnectionStatus returns the connected state of each configured handler, keyed by signal
+	// ("points", "histograms", "spans", "span_logs" or "events").
+	ConnectionStatus() map[string]bool
+}
+
+// FailureCountResetter is a sender whose failure count can be reset.
+type FailureCountResetter interface {
+	// FailureCountDelta returns the failures of all the handlers since the previous call (or since the sender
+	// was created) and resets their count, e.g. to compute a failure rate per interval. Each handler count is
+	// reset atomically, so failures occurring concurrently are reported by the next call. GetFailureCount
+	// then only counts the failures since the last reset.
+	FailureCountDelta() int64
+}
+
+// InternalMetricsReporter is a sender whose internal metrics can be reported on demand.
+type InternalMetricsReporter interface {
+	// ReportInternalMetricsNow sends the current values of the internal metrics of the sender without waiting for
+	// their next scheduled report, and flushes the metrics, e.g. so that the last values are not lost on a quick exit.
+	// It does nothing when the internal metrics are disabled.
+	ReportInternalMetricsNow() error
+}
+
+// PendingLinesReporter is a sender reporting the lines not yet sent by its handlers.
+type PendingLinesReporter interface {
+	// PendingLines returns the number of lines buffered and not yet sent by each configured handler, keyed
+	// like ConnectionStatus. Together with Drain or Close, it lets callers wait until nothing is pending.
+	PendingLines() map[string]int
+}
+
+// StatsFlusher is a sender reporting the volume of data of its flushes.
+type StatsFlusher interface {
+	// FlushWithStats flushes like Flush, also returning the lines sent and still buffered per signal,
+	// e.g. to log the effectiveness of the flushes while draining on shutdown.
+	FlushWithStats() (FlushStats, error)
+}
+
+// SourceReporter is a sender reporting its default source.
+type SourceReporter interface {
+	// Source returns the source of the data sent without source, as resolved when the sender was created
+	// (see the Source option), e.g. to log it.
+	Source() string
+}
+
+// errRawLinesNotSupported is returned for the raw lines sent to a sender not implementing RawLineSender.
+var errRawLinesNotSupported = errors.New("raw lines not supported by the sender")
+
+// The functions below call the optional methods of a sender, falling back to the methods of Sender.
+
+func sendMetricNow(sender Sender, name string, value float64, source string, tags map[string]string) error {
+	if s, ok := sender.(MetricNowSender); ok {
+		return s.SendMetricNow(name, value, source, tags)
+	}
+	return sender.SendMetric(name, value, 0, source, tags)
+}
+
+func sendIntMetric(sender Sender, name string, value int64, ts int64, source string, tags map[string]string) error {
+	if s, ok := sender.(IntMetricSender); ok {
+		return s.SendIntMetric(name, value, ts, source, tags)
+	}
+	return sender.SendMetric(name, float64(value), ts, source, tags)
+}
+
+func sendDistributionG(sender Sender, name string, centroids []histogram.Centroid, ts int64, source string, tags map[string]string, granularities ...histogram.Granularity) error {
+	if s, ok := sender.(DistributionGSender); ok {
+		return s.SendDistributionG(name, centroids, ts, source, tags, granularities...)
+	}
+	return sender.SendDistribution(name, centroids, histogram.Granularities(granularities...), ts, source, tags)
+}
+
+func sendDistributions(sender Sender, dists []DistributionPoint) []error {
+	if s, ok := sender.(DistributionBatchSender); ok {
+		return s.SendDistributions(dists)
+	}
+	errs := make([]error, len(dists))
+	for i, d := range dists {
+		errs[i] = sender.SendDistribution(d.Name, d.Centroids, d.Granularities, d.Timestamp, d.Source, d.Tags)
+	}
+	return errs
+}
+
+func sendSpans(sender Sender, spans []Span) []error {
+	if s, ok := sender.(SpanBatchSender); ok {
+		return s.SendSpans(spans)
+	}
+	errs := make([]error, len(spans))
+	for i, span := range spans {
+		errs[i] = sender.SendSpan(span.Name, span.StartMillis, span.DurationMillis, span.Source, span.TraceId, span.SpanId,
+			span.Parents, span.FollowsFrom, span.Tags, span.SpanLogs)
+	}
+	return errs
+}
+
+func sendRawLine(sender Sender, line string) error {
+	if s, ok := sender.(RawLineSender); ok {
+		return s.SendRawLine(line)
+	}
+	return fmt.Errorf("%w: %T", errRawLinesNotSupported, sender)
+}
+
+func sendRawLines(sender Sender, lines []string) error {
+	if s, ok := sender.(RawLineSender); ok {
+		return s.SendRawLines(lines)
+	}
+	return fmt.Errorf("%w: %T", errRawLinesNotSupported, sender)
+}
+
+// closeWithError closes sender, returning nil for the senders not returning their Close errors.
+func closeWithError(sender Sender) error {
+	if s, ok := sender.(ErrorCloser); ok {
+		return s.CloseWithError()
+	}
+	sender.Close()
+	return nil
+}
+
+// flushSignal flushes the given signal of sender, or all its signals when it can't flush them separately.
+func flushSignal(sender Sender, signal SignalType) error {
+	if s, ok := sender.(SignalFlusher); ok {
+		return s.FlushSignal(signal)
+	}
+	return sender.Flush()
+}
+
+// setSignalEnabled enables or disables the given signal of sender, doing nothing when it can't.
+func setSignalEnabled(sender Sender, signal SignalType, enabled bool) {
+	if s, ok := sender.(SignalEnabler); ok {
+		s.SetSignalEnabled(signal, enabled)
+	}
+}
+
+// drainSender drains sender, retrying its flushes when it doesn't drain itself.
+func drainSender(ctx context.Context, sender Sender) error {
+	if s, ok := sender.(Drainer); ok {
+		return s.Drain(ctx)
+	}
+	return drain(ctx, sender.Flush)
+}
+
+// connectionStatus returns the connected state of the handlers of sender, nil when it doesn't report it.
+func connectionStatus(sender Sender) map[string]bool {
+	if s, ok := sender.(ConnectionStatusReporter); ok {
+		return s.ConnectionStatus()
+	}
+	return nil
+}
+
+// failureCountDelta returns and resets the failure count of sender, 0 when it can't be reset.
+func failureCountDelta(sender Sender) int64 {
+	if s, ok := sender.(FailureCountResetter); ok {
+		return s.FailureCountDelta()
+	}
+	return 0
+}
+
+// reportInternalMetricsNow reports the internal metrics of sender, doing nothing when it can't.
+func reportInternalMetricsNow(sender Sender) error {
+	if s, ok := sender.(InternalMetricsReporter); ok {
+		return s.ReportInternalMetricsNow()
+	}
+	return nil
+}
+
+// pendingLines returns the lines not yet sent by the handlers of sender, nil when it doesn't report them.
+func pendingLines(sender Sender) map[string]int {
+	if s, ok := sender.(PendingLinesReporter); ok {
+		return s.PendingLines()
+	}
+	return nil
+}
+
+// flushSenderWithStats flushes sender, with empty stats when it doesn't report them.
+func flushSenderWithStats(sender Sender) (FlushStats, error) {
+	if s, ok := sender.(StatsFlusher); ok {
+		return s.FlushWithStats()
+	}
+	return newFlushStats(), sender.Flush()
+}
+
+// senderSource returns the default source of sender, "" when it doesn't report it.
+func senderSource(sender Sender) string {
+	if s, ok := sender.(SourceReporter); ok {
+		return s.Source()
+	}
+	return ""
+}
+
+// wrappedSender is embedded by the wrappers of a sender, forwarding the methods they don't override to it,
+// including the optional ones.
+type wrappedSender struct {
+	Sender
+}
+
+func (ws wrappedSender) SendMetricNow(name string, value float64, source string, tags map[string]string) error {
+	return sendMetricNow(ws.Sender, name, value, source, tags)
+}
+
+func (ws wrappedSender) SendIntMetric(name string, value int64, ts int64, source string, tags map[string]string) error {
+	return sendIntMetric(ws.Sender, name, value, ts, source, tags)
+}
+
+func (ws wrappedSender) SendDistributionG(name string, centroids []histogram.Centroid, ts int64, source string, tags map[string]string, granularities ...histogram.Granularity) error {
+	return sendDistributionG(ws.Sender, name, centroids, ts, source, tags, granularities...)
+}
+
+func (ws wrappedSender) SendDistributions(dists []DistributionPoint) []error {
+	return sendDistributions(ws.Sender, dists)
+}
+
+func (ws wrappedSender) SendSpans(spans []Span) []error {
+	return sendSpans(ws.Sender, spans)
+}
+
+func (ws wrappedSender) SendRawLine(line string) error {
+	return sendRawLine(ws.Sender, line)
+}
+
+func (ws wrappedSender) SendRawLines(lines []string) error {
+	return sendRawLines(ws.Sender, lines)
+}
+
+func (ws wrappedSender) CloseWithError() error {
+	return closeWithError(ws.Sender)
+}
+
+func (ws wrappedSender) FlushSignal(signal SignalType) error {
+	return flushSignal(ws.Sender, signal)
+}
+
+func (ws wrappedSender) SetSignalEnabled(signal SignalType, enabled bool) {
+	setSignalEnabled(ws.Sender, signal, enabled)
+}
+
+func (ws wrappedSender) Drain(ctx context.Context) error {
+	return drainSender(ctx, ws.Sender)
+}
+
+func (ws wrappedSender) ConnectionStatus() map[string]bool {
+	return connectionStatus(ws.Sender)
+}
+
+func (ws wrappedSender) FailureCountDelta() int64 {
+	return failureCountDelta(ws.Sender)
+}
+
+func (ws wrappedSender) ReportInternalMetricsNow() error {
+	return reportInternalMetricsNow(ws.Sender)
+}
+
+func (ws wrappedSender) PendingLines() map[string]int {
+	return pendingLines(ws.Sender)
+}
+
+func (ws wrappedSender) FlushWithStats() (FlushStats, error) {
+	return flushSenderWithStats(ws.Sender)
+}
+
+func (ws wrappedSender) Source() string {
+	return senderSource(ws.Sender)
+}
+
+func (ws wrappedSender) now() time.Time {
+	return senderNow(ws.Sender)
+}
@@ -0,0 +1,102 @@
+package senders
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wavefronthq/wavefront-sdk-go/histogram"
+)
+
+// optionalSender lists the optional interfaces implemented by the senders of the package.
+type optionalSender interface {
+	Sender
+	MetricNowSender
+	IntMetricSender
+	DistributionGSender
+	DistributionBatchSender
+	SpanBatchSender
+	RawLineSender
+	ErrorCloser
+	SignalFlusher
+	SignalEnabler
+	Drainer
+	ConnectionStatusReporter
+	FailureCountResetter
+	InternalMetricsReporter
+	PendingLinesReporter
+	StatsFlusher
+	SourceReporter
+}
+
+var (
+	_ optionalSender = (*wavefrontSender)(nil)
+	_ optionalSender = (*proxySender)(nil)
+	_ optionalSender = (*multiSender)(nil)
+	_ optionalSender = (*prefixingSender)(nil)
+	_ optionalSender = (*taggingSender)(nil)
+	_ optionalSender = (*retryingSender)(nil)
+	_ optionalSender = (*contextSender)(nil)
+)
+
+// baseSender only implements the methods of Sender, recording the calls.
+type baseSender struct {
+	fakeSender
+}
+
+func (b *baseSender) GetFailureCount() int64 {
+	return 0
+}
+
+func (b *baseSender) Start() {}
+
+func TestOptionalFallbacks(t *testing.T) {
+	base := &baseSender{}
+	var sender Sender = base
+	_, ok := sender.(SignalFlusher)
+	require.False(t, ok)
+
+	wrapped := NewTaggingSender(sender, map[string]string{"env": "test"}).(optionalSender)
+	require.NoError(t, wrapped.SendMetricNow("now", 1, "", nil))
+	require.NoError(t, wrapped.SendIntMetric("int", 2, 0, "", nil))
+	require.NoError(t, wrapped.SendDistributionG("dist", nil, 0, "", nil, histogram.MINUTE))
+	assert.Equal(t, []error{nil}, wrapped.SendDistributions([]DistributionPoint{{Name: "dists"}}))
+	assert.Equal(t, []error{nil, nil}, wrapped.SendSpans([]Span{{Name: "first"}, {Name: "second"}}))
+	assert.True(t, errors.Is(wrapped.SendRawLine("raw 1\n"), errRawLinesNotSupported))
+	assert.True(t, errors.Is(wrapped.SendRawLines([]string{"raw 1\n"}), errRawLinesNotSupported))
+	assert.Equal(t, []string{"metric now 1", "metric int 2", "distribution dist", "distribution dists", "span first",
+		"span second"}, base.calls)
+
+	base.calls = nil
+	require.NoError(t, wrapped.FlushSignal(SpanSignal))
+	require.NoError(t, wrapped.Drain(context.Background()))
+	stats, err := wrapped.FlushWithStats()
+	require.NoError(t, err)
+	assert.Empty(t, stats.Sent)
+	wrapped.SetSignalEnabled(SpanSignal, false)
+	assert.Nil(t, wrapped.ConnectionStatus())
+	assert.Nil(t, wrapped.PendingLines())
+	assert.Equal(t, int64(0), wrapped.FailureCountDelta())
+	assert.NoError(t, wrapped.ReportInternalMetricsNow())
+	assert.Equal(t, "", wrapped.Source())
+	assert.NoError(t, wrapped.CloseWithError())
+	assert.Equal(t, []string{"flush", "flush", "flush", "close"}, base.calls)
+}
+
+func TestOptionalForwarded(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", MetricsPort: 50000, Source: "host"})
+	defer sender.Close()
+
+	chained := Chain(sender, Prefixing("app."), Retrying(RetryConfig{})).(optionalSender)
+	require.NoError(t, chained.SendRawLine("raw 1\n"))
+	assert.Equal(t, "raw 1\n", handlers[metricHandler].data(), "raw lines are sent as is")
+	assert.Equal(t, map[string]int{"points": 0}, chained.PendingLines())
+	assert.Equal(t, "host", chained.Source())
+	assert.Equal(t, map[string]bool{"points": true}, chained.ConnectionStatus())
+
+	chained.SetSignalEnabled(MetricSignal, false)
+	require.NoError(t, chained.SendMetric("cpu", 1, 0, "", nil))
+	assert.Equal(t, int64(1), sender.pointsSuppressed.Count())
+}
@@ -0,0 +1,83 @@
+package senders
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// OversizedLinePolicy controls how the metric lines longer than the max line length are handled.
+type OversizedLinePolicy int
+
+const (
+	// RejectOversizedLines rejects the point with an error. This is the default.
+	RejectOversizedLines OversizedLinePolicy = iota
+	// TruncateOversizedTags truncates the longest tag values, ending them with an ellipsis,
+	// until the line fits. The point is rejected when truncating its tags isn't enough.
+	TruncateOversizedTags
+)
+
+const (
+	// max length of a line received by the proxy (pushListenerMaxReceivedLength)
+	defaultMaxMetricLineBytes = 32768
+
+	truncationMarker = "..."
+)
+
+// maxMetricLineBytes returns the max size of a metric line, defaulting to the max line length of the proxy.
+func maxMetricLineBytes(n int) int {
+	if n == 0 {
+		return defaultMaxMetricLineBytes
+	}
+	return n
+}
+
+// fitMetricLine returns the line of a metric fitting in maxBytes according to the policy, given its oversized line.
+// truncated is called when the tags of the point were truncated.
+func fitMetricLine(line string, maxBytes int, policy OversizedLinePolicy, truncated func(),
+	name string, value metricValue, ts int64, source string, tags map[string]string, defaultSource string) (string, error) {
+	if policy != TruncateOversizedTags {
+		return "", fmt.Errorf("metric %s line of %d bytes exceeds the max of %d bytes", name, len(line), maxBytes)
+	}
+
+	// copy the tags as the caller's map must not be modified
+	fitted := make(map[string]string, len(tags))
+	for k, v := range tags {
+		fitted[k] = v
+	}
+	for len(line) > maxBytes {
+		key := longestTagValue(fitted)
+		if key == "" {
+			return "", fmt.Errorf("metric %s line of %d bytes cannot be truncated to fit in %d bytes", name, len(line), maxBytes)
+		}
+		v := fitted[key]
+		keep := len(v) - (len(line) - maxBytes) - len(truncationMarker)
+		if keep < 0 {
+			keep = 0
+		}
+		// don't split a multi-byte character
+		for keep > 0 && !utf8.RuneStart(v[keep]) {
+			keep--
+		}
+		fitted[key] = v[:keep] + truncationMarker
+
+		var err error
+		if line, err = formatMetricLine(name, value, ts, source, fitted, defaultSource, nil); err != nil {
+			return "", err
+		}
+	}
+	if truncated != nil {
+		truncated()
+	}
+	return line, nil
+}
+
+// longestTagValue returns the key of the longest tag value that can still be truncated, "" if none.
+func longestTagValue(tags map[string]string) string {
+	longest := ""
+	for _, k := range sortedKeys(tags) {
+		if v := tags[k]; len(v) > len(truncationMarker) && (longest == "" || len(v) > len(tags[longest])) {
+			longest = k
+		}
+	}
+	return longest
+}
@@ -0,0 +1,56 @@
+package senders
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOversizedLinesRejected(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", MetricsPort: 30000, MaxMetricLineBytes: 100})
+	defer sender.Close()
+
+	tags := map[string]string{"env": "test", "query": strings.Repeat("x", 100)}
+	err := sender.SendMetric("new-york.power.usage", 42422, 1533529977, "test_source", tags)
+	assert.EqualError(t, err, "metric new-york.power.usage line of 185 bytes exceeds the max of 100 bytes")
+	assert.Empty(t, handlers[metricHandler].data())
+	assert.Equal(t, int64(1), sender.pointsInvalid.Count())
+
+	require.NoError(t, sender.SendMetric("new-york.power.usage", 42422, 1533529977, "test_source", map[string]string{"env": "test"}))
+	assert.Equal(t, int64(0), sender.pointsTruncated.Count())
+}
+
+func TestOversizedLinesTruncated(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", MetricsPort: 30000,
+		MaxMetricLineBytes: 100, OversizedLines: TruncateOversizedTags})
+	defer sender.Close()
+
+	tags := map[string]string{"env": "test", "query": strings.Repeat("x", 100)}
+	require.NoError(t, sender.SendMetric("new-york.power.usage", 42422, 1533529977, "test_source", tags))
+	line := handlers[metricHandler].data()
+	assert.Equal(t, "\"new-york.power.usage\" 42422 1533529977 source=\"test_source\" \"env\"=\"test\" \"query\"=\""+
+		strings.Repeat("x", 12)+"...\"\n", line)
+	assert.Len(t, line, 100)
+	assert.Len(t, tags["query"], 100, "the caller's tags are not modified")
+	assert.Equal(t, int64(1), sender.pointsTruncated.Count())
+
+	// lines that can't fit even with truncated tags are rejected
+	err := sender.SendMetric(strings.Repeat("m", 100), 42422, 1533529977, "test_source", tags)
+	assert.Error(t, err)
+	assert.Equal(t, int64(1), sender.pointsTruncated.Count())
+}
+
+func TestFitMetricLineMultiByte(t *testing.T) {
+	tags := map[string]string{"city": strings.Repeat("é", 50), "note": strings.Repeat("\"", 10)}
+	line, err := MetricLine("foo", 1, 0, NoSource, tags, "")
+	require.NoError(t, err)
+
+	fitted, err := fitMetricLine(line, 60, TruncateOversizedTags, nil, "foo", floatValue(1), 0, NoSource, tags, "")
+	require.NoError(t, err)
+	assert.True(t, len(fitted) <= 60, fitted)
+	assert.True(t, utf8.ValidString(fitted), fitted)
+	assert.Contains(t, fitted, "...")
+}
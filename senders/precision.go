@@ -0,0 +1,25 @@
+package senders
+
+import (
+	"math"
+	"strconv"
+)
+
+// roundToPrecision rounds v to the given number of significant digits, never fewer than the digits of
+// its integer part, so that only the fractional digits are dropped. Integers, and any value when
+// digits <= 0, are returned as is.
+func roundToPrecision(v float64, digits int) float64 {
+	if digits <= 0 || v == math.Trunc(v) || math.IsNaN(v) || math.IsInf(v, 0) {
+		return v
+	}
+	if abs := math.Abs(v); abs >= 1 {
+		if intDigits := int(math.Log10(abs)) + 1; intDigits > digits {
+			digits = intDigits
+		}
+	}
+	rounded, err := strconv.ParseFloat(strconv.FormatFloat(v, 'g', digits, 64), 64)
+	if err != nil {
+		return v
+	}
+	return rounded
+}
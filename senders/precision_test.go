@@ -0,0 +1,42 @@
+package senders
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wavefronthq/wavefront-sdk-go/histogram"
+)
+
+// tenth is a variable, the constant 0.1 + 0.2 being exactly 0.3.
+var tenth = 0.1
+
+func TestRoundToPrecision(t *testing.T) {
+	assert.Equal(t, 0.30000000000000004, roundToPrecision(tenth+0.2, 0))
+	assert.Equal(t, 0.3, roundToPrecision(tenth+0.2, 15))
+	assert.Equal(t, 3.142, roundToPrecision(3.14159, 4))
+	assert.Equal(t, -0.00123, roundToPrecision(-0.0012345, 3))
+	// integers, and the integer part of the values, are kept
+	assert.Equal(t, float64(42422), roundToPrecision(42422, 2))
+	assert.Equal(t, float64(123457), roundToPrecision(123456.789, 3))
+	assert.Equal(t, 1e20, roundToPrecision(1e20, 3))
+}
+
+func TestValuePrecision(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{
+		Host:             "localhost",
+		MetricsPort:      50000,
+		DistributionPort: 50001,
+		ValuePrecision:   3,
+	})
+	defer sender.Close()
+
+	require.NoError(t, sender.SendMetric("cpu.usage", tenth+0.2, 1533529977, "test", nil))
+	require.NoError(t, sender.SendMetric("power.usage", 42422, 1533529977, "test", nil))
+	assert.Equal(t, "\"cpu.usage\" 0.3 1533529977 source=\"test\"\n"+
+		"\"power.usage\" 42422 1533529977 source=\"test\"\n", handlers[metricHandler].data())
+
+	require.NoError(t, sender.SendDistributionG("request.latency", []histogram.Centroid{{Value: 1.23456, Count: 2}, {Value: 30, Count: 1}},
+		1533529977, "test", nil, histogram.MINUTE))
+	assert.Equal(t, "!M 1533529977 #2 1.23 #1 30 \"request.latency\" source=\"test\"\n", handlers[histoHandler].data())
+}
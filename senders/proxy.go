@@ -1,7 +1,9 @@
 package senders
 
 import (
+	"context"
 	"errors"
+	"log"
 	"os"
 	"strconv"
 	"strings"
@@ -13,140 +15,489 @@ import (
 	"github.com/wavefronthq/wavefront-sdk-go/version"
 )
 
+// the handlers of a proxy sender, indexed by SignalType
 const (
-	metricHandler int = iota
-	histoHandler
-	spanHandler
-	eventHandler
-	handlersCount
+	metricHandler = int(MetricSignal)
+	histoHandler  = int(HistogramSignal)
+	spanHandler   = int(SpanSignal)
+	eventHandler  = int(EventSignal)
+	handlersCount = eventHandler + 1
 )
 
+// handlerNames are the signal names of the handlers, also used as prefix of their internal metrics
+var handlerNames = [handlersCount]string{"points", "histograms", "spans", "events"}
+
+// spanLogsRoute is the route of the span logs handler of the span signal, writing the span logs to SpanLogsPort.
+const spanLogsRoute = "span_logs"
+
 type proxySender struct {
 	handlers         []internal.ConnectionHandler
 	defaultSource    string
 	internalRegistry *internal.MetricRegistry
 
-	pointsValid     *internal.DeltaCounter
-	pointsInvalid   *internal.DeltaCounter
-	pointsDropped   *internal.DeltaCounter
-	pointsDiscarded *internal.DeltaCounter
+	pointsValid               *internal.DeltaCounter
+	pointsInvalid             *internal.DeltaCounter
+	pointsDropped             *internal.DeltaCounter
+	pointsDiscarded           *internal.DeltaCounter
+	pointsSuppressed          *internal.DeltaCounter
+	pointsTruncated           *internal.DeltaCounter
+	pointsReservedTagsDropped *internal.DeltaCounter
+	pointsClockSkew           *internal.DeltaCounter
+
+	histogramsValid      *internal.DeltaCounter
+	histogramsInvalid    *internal.DeltaCounter
+	histogramsDropped    *internal.DeltaCounter
+	histogramsDiscarded  *internal.DeltaCounter
+	histogramsSuppressed *internal.DeltaCounter
+
+	spansValid      *internal.DeltaCounter
+	spansInvalid    *internal.DeltaCounter
+	spansClockSkew  *internal.DeltaCounter
+	spansDropped    *internal.DeltaCounter
+	spansSampled    *internal.DeltaCounter
+	spansDiscarded  *internal.DeltaCounter
+	spansSuppressed *internal.DeltaCounter
+
+	spanLogsValid      *internal.DeltaCounter
+	spanLogsInvalid    *internal.DeltaCounter
+	spanLogsDropped    *internal.DeltaCounter
+	spanLogsDiscarded  *internal.DeltaCounter
+	spanLogsSuppressed *internal.DeltaCounter
+	spanLogsSampled    *internal.DeltaCounter
+	spanLogBatches     *internal.DeltaCounter
+	spanLogsBatched    *internal.DeltaCounter
+
+	eventsValid      *internal.DeltaCounter
+	eventsInvalid    *internal.DeltaCounter
+	eventsDropped    *internal.DeltaCounter
+	eventsDiscarded  *internal.DeltaCounter
+	eventsSuppressed *internal.DeltaCounter
+	eventsThrottled  *internal.DeltaCounter
 
-	histogramsValid     *internal.DeltaCounter
-	histogramsInvalid   *internal.DeltaCounter
-	histogramsDropped   *internal.DeltaCounter
-	histogramsDiscarded *internal.DeltaCounter
+	disableSpanLogs bool
+	traceSampleRate float64
+	spanLogRate     float64
+	signals         signalSwitch
+	spanTagDedup    SpanTagDedupPolicy
+	deltaPrefix     DeltaPrefixVariant
+	autoDelta       *deltaCounterMatcher
+	clock           Clock
+	serializer      Serializer
+	skipInvalidTags bool
+	defaultHgs      map[histogram.Granularity]bool
 
-	spansValid     *internal.DeltaCounter
-	spansInvalid   *internal.DeltaCounter
-	spansDropped   *internal.DeltaCounter
-	spansDiscarded *internal.DeltaCounter
+	timestampHorizon time.Duration
+	deltaAggregator  *deltaAggregator
+	lastValues       *lastValueAggregator
+	flushAges        *flushAges
+	eventLimiter     *eventLimiter
+	clockSkew        *clockSkewGuard
+	spanLogBatcher   *spanLogBatcher
 
-	spanLogsValid     *internal.DeltaCounter
-	spanLogsInvalid   *internal.DeltaCounter
-	spanLogsDropped   *internal.DeltaCounter
-	spanLogsDiscarded *internal.DeltaCounter
+	routes map[string]internal.ConnectionHandler
+	route  RouteFunc
 
-	eventsValid     *internal.DeltaCounter
-	eventsInvalid   *internal.DeltaCounter
-	eventsDropped   *internal.DeltaCounter
-	eventsDiscarded *internal.DeltaCounter
+	// writes the span logs when set, instead of the spans handler
+	spanLogsHandler internal.ConnectionHandler
 }
 
 // Creates and returns a Wavefront Proxy Sender instance
 // Deprecated: Use 'senders.NewSender(url)'
 func NewProxySender(cfg *ProxyConfiguration) (Sender, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if err := createSpoolDir(cfg.SpoolDir); err != nil {
+		return nil, err
+	}
+
+	ports := [handlersCount]int{cfg.MetricsPort, cfg.DistributionPort, cfg.TracingPort, cfg.EventsPort}
+	return newProxySender(cfg, func(signal SignalType, route string, registry *internal.MetricRegistry, opts []internal.ProxyConnectionHandlerOption) internal.ConnectionHandler {
+		flushInterval := time.Second * time.Duration(cfg.FlushIntervalSeconds)
+		spanLogs := signal == SpanSignal && route == spanLogsRoute
+		name := handlerNames[signal]
+		if spanLogs {
+			name = spanLogsRoute
+		} else if route != "" {
+			name = routeName(route)
+		}
+		if spool := newSpool(cfg.SpoolDir, cfg.SpoolMaxBytes, name); spool != nil {
+			opts = append(opts[:len(opts):len(opts)], internal.SetSpool(spool))
+		}
+
+		if route != "" && !spanLogs {
+			return internal.NewProxyConnectionHandler(cfg.MetricsRoutes[route], flushInterval, name, registry, opts...)
+		}
+		if cfg.UnixSocket != "" {
+			if spanLogs {
+				// the span logs are written with the spans
+				return nil
+			}
+			return internal.NewProxyConnectionHandler(unixSocketAddress(cfg.UnixSocket), flushInterval, name, registry, opts...)
+		}
+		if spanLogs {
+			return makeConnHandler(cfg.Host, cfg.SpanLogsPort, cfg.FlushIntervalSeconds, name, registry, opts...)
+		}
+		if ports[signal] == 0 {
+			return nil
+		}
+		if signal == MetricSignal && cfg.MetricsUDP {
+			maxDatagramBytes := cfg.MaxDatagramBytes
+			if maxDatagramBytes == 0 {
+				maxDatagramBytes = defaultMaxDatagramBytes
+			}
+			address := cfg.Host + ":" + strconv.Itoa(cfg.MetricsPort)
+			return internal.NewUDPHandler(address, maxDatagramBytes, cfg.PackDatagrams, flushInterval, handlerNames[signal], registry)
+		}
+		return makeConnHandler(cfg.Host, ports[signal], cfg.FlushIntervalSeconds, handlerNames[signal], registry, opts...)
+	}), nil
+}
+
+// newProxySender returns a started proxy sender writing the lines of each signal with the handler returned by
+// newHandler, given the internal metrics registry and the handler options of cfg. newHandler returns nil
+// for the signals not sent. It's also called with the metric signal and the key of each metrics route of cfg,
+// and with the span signal and spanLogsRoute when cfg has a SpanLogsPort.
+func newProxySender(cfg *ProxyConfiguration, newHandler func(signal SignalType, route string, registry *internal.MetricRegistry, opts []internal.ProxyConnectionHandlerOption) internal.ConnectionHandler) *proxySender {
 	sender := &proxySender{
-		defaultSource: internal.GetHostname("wavefront_proxy_sender"),
-		handlers:      make([]internal.ConnectionHandler, handlersCount),
+		defaultSource:   resolveSource(cfg.Source, cfg.SourceFunc, "wavefront_proxy_sender"),
+		handlers:        make([]internal.ConnectionHandler, handlersCount),
+		disableSpanLogs: cfg.DisableSpanLogs,
+		traceSampleRate: traceSampleRate(cfg.TraceSampleRate, cfg.DropAllTraces),
+		spanLogRate:     sampleRate(cfg.SpanLogSampleRate),
+		spanTagDedup:    cfg.SpanTagDedup,
+		deltaPrefix:     cfg.DeltaPrefix,
+		autoDelta:       newDeltaCounterMatcher(cfg.DeltaCounterSuffixes, cfg.DeltaCounterNames),
+		clock:           cfg.Clock,
+		serializer:      cfg.Serializer,
+		skipInvalidTags: cfg.SkipInvalidTags,
+		defaultHgs:      cfg.DefaultHistogramGranularities,
+	}
+	if sender.clock == nil {
+		sender.clock = systemClock{}
+	}
+	sender.timestampHorizon = cfg.TimestampHorizon
+	if sender.timestampHorizon == 0 {
+		sender.timestampHorizon = defaultTimestampHorizon
+	}
+	if sender.serializer == nil {
+		sender.serializer = &lineSerializer{
+			histo: histoLineOptions{
+				alignTimestamps: cfg.AlignDistributionTimestamps,
+				maxLineBytes:    cfg.MaxHistogramLineBytes,
+				precision:       cfg.ValuePrecision,
+				maxCentroids:    cfg.MaxCentroidsPerDistribution,
+			},
+			metricName:         cfg.MetricNameSanitizer,
+			maxMetricLineBytes: maxMetricLineBytes(cfg.MaxMetricLineBytes),
+			oversizedLines:     cfg.OversizedLines,
+			tags:               newTagCache(cfg.MaxInternedTags),
+			precision:          cfg.ValuePrecision,
+			spanSourceTag:      cfg.SpanSourceTag,
+			trimWhitespace:     cfg.TrimWhitespace,
+			maxSpanDuration:    cfg.MaxSpanDuration,
+			reservedTags:       cfg.ReservedTags,
+			truncated: func() {
+				sender.pointsTruncated.Inc()
+			},
+			reservedTagsDropped: func() {
+				sender.pointsReservedTagsDropped.Inc()
+			},
+		}
+	}
+	if cfg.TagValidator != nil {
+		sender.serializer = &tagValidatingSerializer{Serializer: sender.serializer, validate: cfg.TagValidator}
 	}
 
-	sender.internalRegistry = internal.NewMetricRegistry(
-		sender,
+	registryOptions := []internal.RegistryOption{
 		internal.SetPrefix("~sdk.go.core.sender.proxy"),
 		internal.SetTag("pid", strconv.Itoa(os.Getpid())),
-	)
+	}
+	for key, value := range cfg.RegistryTags {
+		registryOptions = append(registryOptions, internal.SetTag(key, value))
+	}
+	if cfg.Clock != nil {
+		registryOptions = append(registryOptions, internal.SetTimeSupplier(cfg.Clock.Now))
+	}
+	if !cfg.DisableInternalMetrics {
+		sender.internalRegistry = internal.NewMetricRegistry(sender, registryOptions...)
+	}
 
 	if sdkVersion, e := internal.GetSemVer(version.Version); e == nil {
 		sender.internalRegistry.NewGaugeFloat64("version", func() float64 {
 			return sdkVersion
 		})
 	}
+	sender.internalRegistry.NewGaugeFloat64("uptime.seconds", uptime(sender.clock))
 
 	if cfg.FlushIntervalSeconds == 0 {
 		cfg.FlushIntervalSeconds = defaultProxyFlushInterval
 	}
 
-	if cfg.MetricsPort != 0 {
-		sender.handlers[metricHandler] = makeConnHandler(cfg.Host, cfg.MetricsPort, cfg.FlushIntervalSeconds, "points", sender.internalRegistry)
+	var handlerOptions []internal.ProxyConnectionHandlerOption
+	if cfg.SendGreeting {
+		handlerOptions = append(handlerOptions, internal.SetGreeting(greetingLine(cfg.Greeting)))
 	}
-
-	if cfg.DistributionPort != 0 {
-		sender.handlers[histoHandler] = makeConnHandler(cfg.Host, cfg.DistributionPort, cfg.FlushIntervalSeconds, "histograms", sender.internalRegistry)
+	if cfg.FlushJitter {
+		handlerOptions = append(handlerOptions, internal.SetFlushJitter(time.Second*time.Duration(cfg.FlushIntervalSeconds)))
 	}
-
-	if cfg.TracingPort != 0 {
-		sender.handlers[spanHandler] = makeConnHandler(cfg.Host, cfg.TracingPort, cfg.FlushIntervalSeconds, "spans", sender.internalRegistry)
+	if scheduler := flushScheduler(cfg.FlushScheduler, cfg.SharedFlushLoop, time.Second*time.Duration(cfg.FlushIntervalSeconds)); scheduler != nil {
+		handlerOptions = append(handlerOptions, internal.SetFlushScheduler(scheduler.scheduler))
+	}
+	if cfg.FlushBatchSize > 0 {
+		handlerOptions = append(handlerOptions, internal.SetFlushBatchSize(cfg.FlushBatchSize))
+	}
+	if cfg.KeepAlive != 0 {
+		handlerOptions = append(handlerOptions, internal.SetKeepAlive(cfg.KeepAlive))
+	}
+	if cfg.WriteTimeout != 0 {
+		handlerOptions = append(handlerOptions, internal.SetWriteTimeout(cfg.WriteTimeout))
+	}
+	if tlsCfg := proxyTLSConfig(cfg); tlsCfg != nil {
+		handlerOptions = append(handlerOptions, internal.SetConnectionTLSConfig(tlsCfg))
+	}
+	if cfg.ReadResponses {
+		handlerOptions = append(handlerOptions, internal.SetReadResponses(cfg.LogResponseErrors))
+	}
+	if cfg.HeartbeatInterval > 0 {
+		handlerOptions = append(handlerOptions, internal.SetHeartbeat(heartbeatLine(cfg.Heartbeat), cfg.HeartbeatInterval))
+	}
+	if cfg.FlushFailure != DropOnFailure {
+		maxLines := cfg.MaxRequeuedLines
+		if maxLines <= 0 {
+			maxLines = defaultBufferSize
+		}
+		handlerOptions = append(handlerOptions, internal.SetFlushFailurePolicy(cfg.FlushFailure.handlerPolicy(), maxLines))
 	}
 
-	if cfg.EventsPort != 0 {
-		sender.handlers[eventHandler] = makeConnHandler(cfg.Host, cfg.EventsPort, cfg.FlushIntervalSeconds, "events", sender.internalRegistry)
+	ages := newFlushAges(sender.clock)
+	sender.flushAges = ages
+	for i := range sender.handlers {
+		signal := SignalType(i)
+		sender.handlers[i] = newHandler(signal, "", sender.internalRegistry, connectionCallbacks(cfg, ages, signal, handlerOptions))
+		if sender.handlers[i] != nil {
+			ages.register(sender.internalRegistry, signal)
+		}
+	}
+	if cfg.SpanLogsPort != 0 {
+		sender.spanLogsHandler = newHandler(SpanSignal, spanLogsRoute, sender.internalRegistry, connectionCallbacks(cfg, ages, SpanSignal, handlerOptions))
+	}
+	if cfg.RouteFunc != nil {
+		sender.route = cfg.RouteFunc
+		sender.routes = make(map[string]internal.ConnectionHandler, len(cfg.MetricsRoutes))
+		for route := range cfg.MetricsRoutes {
+			sender.routes[route] = newHandler(MetricSignal, route, sender.internalRegistry, connectionCallbacks(cfg, ages, MetricSignal, handlerOptions))
+		}
 	}
 
 	sender.pointsValid = sender.internalRegistry.NewDeltaCounter("points.valid")
 	sender.pointsInvalid = sender.internalRegistry.NewDeltaCounter("points.invalid")
 	sender.pointsDropped = sender.internalRegistry.NewDeltaCounter("points.dropped")
+	sender.pointsTruncated = sender.internalRegistry.NewDeltaCounter("points.truncated")
+	sender.pointsReservedTagsDropped = sender.internalRegistry.NewDeltaCounter("points.reserved_tags.dropped")
+	sender.pointsClockSkew = sender.internalRegistry.NewDeltaCounter("points.clock_skew")
 	sender.pointsDiscarded = sender.internalRegistry.NewDeltaCounter("points.discarded")
+	sender.pointsSuppressed = sender.internalRegistry.NewDeltaCounter("points.suppressed")
 
 	sender.histogramsValid = sender.internalRegistry.NewDeltaCounter("histograms.valid")
 	sender.histogramsInvalid = sender.internalRegistry.NewDeltaCounter("histograms.invalid")
 	sender.histogramsDropped = sender.internalRegistry.NewDeltaCounter("histograms.dropped")
 	sender.histogramsDiscarded = sender.internalRegistry.NewDeltaCounter("histograms.discarded")
+	sender.histogramsSuppressed = sender.internalRegistry.NewDeltaCounter("histograms.suppressed")
 
 	sender.spansValid = sender.internalRegistry.NewDeltaCounter("spans.valid")
 	sender.spansInvalid = sender.internalRegistry.NewDeltaCounter("spans.invalid")
+	sender.spansClockSkew = sender.internalRegistry.NewDeltaCounter("spans.clock_skew")
 	sender.spansDropped = sender.internalRegistry.NewDeltaCounter("spans.dropped")
+	sender.spansSampled = sender.internalRegistry.NewDeltaCounter("spans.sampled_out")
 	sender.spansDiscarded = sender.internalRegistry.NewDeltaCounter("spans.discarded")
+	sender.spansSuppressed = sender.internalRegistry.NewDeltaCounter("spans.suppressed")
 
 	sender.spanLogsValid = sender.internalRegistry.NewDeltaCounter("span_logs.valid")
 	sender.spanLogsInvalid = sender.internalRegistry.NewDeltaCounter("span_logs.invalid")
 	sender.spanLogsDropped = sender.internalRegistry.NewDeltaCounter("span_logs.dropped")
 	sender.spanLogsDiscarded = sender.internalRegistry.NewDeltaCounter("span_logs.discarded")
+	sender.spanLogsSuppressed = sender.internalRegistry.NewDeltaCounter("span_logs.suppressed")
+	sender.spanLogsSampled = sender.internalRegistry.NewDeltaCounter("span_logs.sampled_out")
+	sender.spanLogBatches = sender.internalRegistry.NewDeltaCounter("span_logs.batches")
+	sender.spanLogsBatched = sender.internalRegistry.NewDeltaCounter("span_logs.batched")
 
 	sender.eventsValid = sender.internalRegistry.NewDeltaCounter("events.valid")
 	sender.eventsInvalid = sender.internalRegistry.NewDeltaCounter("events.invalid")
 	sender.eventsDropped = sender.internalRegistry.NewDeltaCounter("events.dropped")
 	sender.eventsDiscarded = sender.internalRegistry.NewDeltaCounter("events.discarded")
+	sender.eventsSuppressed = sender.internalRegistry.NewDeltaCounter("events.suppressed")
+	sender.eventsThrottled = sender.internalRegistry.NewDeltaCounter("events.throttled")
 
-	for _, h := range sender.handlers {
-		if h != nil {
-			sender.Start()
-			return sender, nil
-		}
+	if cfg.AggregateDeltaCounters {
+		sender.deltaAggregator = newDeltaAggregator(time.Second*time.Duration(cfg.FlushIntervalSeconds), sender.sendDelta)
 	}
+	sender.eventLimiter = newEventLimiter(cfg.MaxEventsPerMinute, sender.clock)
+	sender.clockSkew = newClockSkewGuard(cfg.MaxClockSkew, cfg.ClockSkew, sender.clock)
+	if cfg.DedupMetrics {
+		sender.lastValues = newLastValueAggregator(time.Second*time.Duration(cfg.FlushIntervalSeconds), sender.sendLastValue)
+	}
+	if cfg.BatchSpanLogs && sender.handlers[spanHandler] != nil {
+		sender.spanLogBatcher = newSpanLogBatcher(time.Second*time.Duration(cfg.FlushIntervalSeconds), sender.sendSpanLogBatch)
+	}
+
+	sender.Start()
+	if cfg.Registry != nil {
+		cfg.Registry.Register(sender)
+	}
+	return sender
+}
 
-	return nil, errors.New("at least one proxy port should be enabled")
+// connectionCallbacks returns opts with the connection lifecycle callbacks of cfg, bound to the given signal type,
+// and the recording of its flushes in ages.
+func connectionCallbacks(cfg *ProxyConfiguration, ages *flushAges, signal SignalType, opts []internal.ProxyConnectionHandlerOption) []internal.ProxyConnectionHandlerOption {
+	// copy opts as they're shared by all the handlers
+	opts = append([]internal.ProxyConnectionHandlerOption(nil), opts...)
+	if onConnect := cfg.OnConnect; onConnect != nil {
+		opts = append(opts, internal.SetOnConnect(func() {
+			onConnect(signal)
+		}))
+	}
+	if onDisconnect := cfg.OnDisconnect; onDisconnect != nil {
+		opts = append(opts, internal.SetOnDisconnect(func(err error) {
+			onDisconnect(signal, err)
+		}))
+	}
+	if onReconnectFailed := cfg.OnReconnectFailed; onReconnectFailed != nil {
+		opts = append(opts, internal.SetOnConnectFailed(func(err error) {
+			onReconnectFailed(signal, err)
+		}))
+	}
+	return append(opts, internal.SetOnFlush(ages.onFlush(signal, cfg.OnFlush)))
 }
 
-func makeConnHandler(host string, port, flushIntervalSeconds int, prefix string, internalRegistry *internal.MetricRegistry) internal.ConnectionHandler {
+func makeConnHandler(host string, port, flushIntervalSeconds int, prefix string, internalRegistry *internal.MetricRegistry,
+	opts ...internal.ProxyConnectionHandlerOption) internal.ConnectionHandler {
 	addr := host + ":" + strconv.FormatInt(int64(port), 10)
 	flushInterval := time.Second * time.Duration(flushIntervalSeconds)
-	return internal.NewProxyConnectionHandler(addr, flushInterval, prefix, internalRegistry)
+	return internal.NewProxyConnectionHandler(addr, flushInterval, prefix, internalRegistry, opts...)
+}
+
+// unixSocketAddress returns the "unix://" URL of a Unix domain socket given its path or URL.
+func unixSocketAddress(socket string) string {
+	if strings.HasPrefix(socket, "unix://") {
+		return socket
+	}
+	return "unix://" + socket
+}
+
+// greetingLine returns the newline terminated greeting sent to the proxy, defaulting to the SDK name and version.
+func greetingLine(greeting string) string {
+	if greeting == "" {
+		greeting = "#wavefront-sdk-go " + version.Version
+	}
+	if !strings.HasSuffix(greeting, "\n") {
+		greeting += "\n"
+	}
+	return greeting
+}
+
+// heartbeatLine returns the newline terminated heartbeat sent to the proxy, defaulting to an empty line.
+func heartbeatLine(heartbeat string) string {
+	if !strings.HasSuffix(heartbeat, "\n") {
+		heartbeat += "\n"
+	}
+	return heartbeat
 }
 
 func (sender *proxySender) Start() {
-	for _, h := range sender.handlers {
+	for _, h := range sender.allHandlers() {
 		if h != nil {
 			h.Start()
 		}
 	}
 	sender.internalRegistry.Start()
+	if sender.deltaAggregator != nil {
+		sender.deltaAggregator.start()
+	}
+	if sender.lastValues != nil {
+		sender.lastValues.start()
+	}
+	if sender.spanLogBatcher != nil {
+		sender.spanLogBatcher.start()
+	}
 }
 
 func (sender *proxySender) SendMetric(name string, value float64, ts int64, source string, tags map[string]string) error {
-	handler := sender.handlers[metricHandler]
+	return sender.SendMetricCtx(context.Background(), name, value, ts, source, tags)
+}
+
+func (sender *proxySender) SendMetricCtx(ctx context.Context, name string, value float64, ts int64, source string, tags map[string]string) error {
+	if sender.autoDelta.match(name) {
+		return sender.SendDeltaCounterCtx(ctx, name, value, source, tags)
+	}
+	if err := sender.clockSkew.checkMetric(name, ts, sender.pointsClockSkew); err != nil {
+		sender.pointsInvalid.Inc()
+		return invalidData(err)
+	}
+	ts, err := metricTimestamp(ts, sender.clock.Now(), sender.timestampHorizon)
+	if err != nil {
+		sender.pointsInvalid.Inc()
+		return invalidData(err)
+	}
+	return sender.dedupMetric(ctx, name, floatValue(value), ts, source, tags)
+}
+
+func (sender *proxySender) SendIntMetric(name string, value int64, ts int64, source string, tags map[string]string) error {
+	return sender.SendIntMetricCtx(context.Background(), name, value, ts, source, tags)
+}
+
+func (sender *proxySender) SendIntMetricCtx(ctx context.Context, name string, value int64, ts int64, source string, tags map[string]string) error {
+	if sender.autoDelta.match(name) {
+		return sender.SendDeltaCounterCtx(ctx, name, float64(value), source, tags)
+	}
+	if err := sender.clockSkew.checkMetric(name, ts, sender.pointsClockSkew); err != nil {
+		sender.pointsInvalid.Inc()
+		return invalidData(err)
+	}
+	ts, err := metricTimestamp(ts, sender.clock.Now(), sender.timestampHorizon)
+	if err != nil {
+		sender.pointsInvalid.Inc()
+		return invalidData(err)
+	}
+	return sender.dedupMetric(ctx, name, intValue(value), ts, source, tags)
+}
+
+func (sender *proxySender) SendMetricNow(name string, value float64, source string, tags map[string]string) error {
+	return sender.SendMetricNowCtx(context.Background(), name, value, source, tags)
+}
+
+func (sender *proxySender) SendMetricNowCtx(ctx context.Context, name string, value float64, source string, tags map[string]string) error {
+	if sender.autoDelta.match(name) {
+		return sender.SendDeltaCounterCtx(ctx, name, value, source, tags)
+	}
+	return sender.dedupMetric(ctx, name, floatValue(value), 0, source, tags)
+}
+
+// dedupMetric keeps the metric in the last value aggregator until the next flush when enabled, sends it otherwise.
+func (sender *proxySender) dedupMetric(ctx context.Context, name string, value metricValue, ts int64, source string, tags map[string]string) error {
+	if sender.lastValues == nil {
+		return sender.sendMetric(ctx, name, value, ts, source, tags)
+	}
+	if sender.suppressed(MetricSignal, 1) {
+		return nil
+	}
+	if _, err := serializeMetric(sender.serializer, name, value, ts, source, tags, sender.defaultSource); err != nil {
+		sender.pointsInvalid.Inc()
+		return invalidResult(err, sender.skipInvalidTags)
+	}
+	sender.lastValues.add(name, value, ts, source, tags)
+	return nil
+}
+
+// sendMetric sends a metric with the given timestamp, 0 letting the proxy assign it.
+func (sender *proxySender) sendMetric(ctx context.Context, name string, value metricValue, ts int64, source string, tags map[string]string) error {
+	if sender.suppressed(MetricSignal, 1) {
+		return nil
+	}
+	handler := sender.routedHandler(name, tags)
 	if handler == nil {
 		sender.pointsDiscarded.Inc()
-		return errors.New("proxy metrics port not provided, cannot send metric data")
+		return &portError{msg: "proxy metrics port not provided, cannot send metric data"}
 	}
 
 	if !handler.Connected() {
@@ -156,14 +507,14 @@ func (sender *proxySender) SendMetric(name string, value float64, ts int64, sour
 		}
 	}
 
-	line, err := MetricLine(name, value, ts, source, tags, sender.defaultSource)
+	line, err := serializeMetric(sender.serializer, name, value, ts, source, tags, sender.defaultSource)
 	if err != nil {
 		sender.pointsInvalid.Inc()
-		return err
+		return invalidResult(err, sender.skipInvalidTags)
 	} else {
 		sender.pointsValid.Inc()
 	}
-	err = handler.SendData(line)
+	err = handler.SendDataCtx(ctx, line)
 	if err != nil {
 		sender.pointsDropped.Inc()
 	}
@@ -171,24 +522,107 @@ func (sender *proxySender) SendMetric(name string, value float64, ts int64, sour
 }
 
 func (sender *proxySender) SendDeltaCounter(name string, value float64, source string, tags map[string]string) error {
-	if name == "" {
+	return sender.SendDeltaCounterCtx(context.Background(), name, value, source, tags)
+}
+
+func (sender *proxySender) SendDeltaCounterCtx(ctx context.Context, name string, value float64, source string, tags map[string]string) error {
+	if sender.suppressed(MetricSignal, 1) {
+		return nil
+	}
+	if err := checkName("metric", name); err != nil {
 		sender.pointsInvalid.Inc()
-		return errors.New("empty metric name")
+		return invalidData(err)
 	}
-	if !internal.HasDeltaPrefix(name) {
-		name = internal.DeltaCounterName(name)
+	name = deltaCounterName(name, sender.deltaPrefix)
+	if value <= 0 {
+		return nil
 	}
-	if value > 0 {
-		return sender.SendMetric(name, value, 0, source, tags)
+	if sender.deltaAggregator != nil {
+		if _, err := sender.serializer.MetricLine(name, value, 0, source, tags, sender.defaultSource); err != nil {
+			sender.pointsInvalid.Inc()
+			return invalidResult(err, sender.skipInvalidTags)
+		}
+		sender.deltaAggregator.add(name, value, source, tags)
+		return nil
 	}
-	return nil
+	return sender.sendMetric(ctx, name, floatValue(value), 0, source, tags)
+}
+
+// sendDelta sends a delta counter summed by the delta aggregator.
+func (sender *proxySender) sendDelta(name string, value float64, source string, tags map[string]string) error {
+	return sender.sendMetric(context.Background(), name, floatValue(value), 0, source, tags)
+}
+
+// sendLastValue sends a metric kept by the last value aggregator.
+func (sender *proxySender) sendLastValue(name string, value metricValue, ts int64, source string, tags map[string]string) error {
+	return sender.sendMetric(context.Background(), name, value, ts, source, tags)
+}
+
+func (sender *proxySender) SendRawLine(line string) error {
+	return sender.SendRawLineCtx(context.Background(), line)
+}
+
+func (sender *proxySender) SendRawLineCtx(ctx context.Context, line string) error {
+	return sender.SendRawLinesCtx(ctx, []string{line})
+}
+
+func (sender *proxySender) SendRawLines(lines []string) error {
+	return sender.SendRawLinesCtx(context.Background(), lines)
+}
+
+func (sender *proxySender) SendRawLinesCtx(ctx context.Context, lines []string) error {
+	if sender.suppressed(MetricSignal, len(lines)) {
+		return nil
+	}
+	handler := sender.handlers[metricHandler]
+	if handler == nil {
+		sender.pointsDiscarded.Add(int64(len(lines)))
+		return &portError{msg: "proxy metrics port not provided, cannot send metric data"}
+	}
+
+	if !handler.Connected() {
+		if err := handler.Connect(); err != nil {
+			sender.pointsDiscarded.Add(int64(len(lines)))
+			return err
+		}
+	}
+
+	data, err := rawLines(lines)
+	if err != nil {
+		sender.pointsInvalid.Add(int64(len(lines)))
+		return invalidData(err)
+	}
+	sender.pointsValid.Add(int64(len(lines)))
+	if len(data) == 0 {
+		return nil
+	}
+	err = handler.SendDataCtx(ctx, data)
+	if err != nil {
+		sender.pointsDropped.Add(int64(len(lines)))
+	}
+	return err
+}
+
+func (sender *proxySender) SendDistributionG(name string, centroids []histogram.Centroid, ts int64, source string, tags map[string]string, granularities ...histogram.Granularity) error {
+	return sender.SendDistributionGCtx(context.Background(), name, centroids, ts, source, tags, granularities...)
+}
+
+func (sender *proxySender) SendDistributionGCtx(ctx context.Context, name string, centroids []histogram.Centroid, ts int64, source string, tags map[string]string, granularities ...histogram.Granularity) error {
+	return sender.SendDistributionCtx(ctx, name, centroids, histogram.Granularities(granularities...), ts, source, tags)
 }
 
 func (sender *proxySender) SendDistribution(name string, centroids []histogram.Centroid, hgs map[histogram.Granularity]bool, ts int64, source string, tags map[string]string) error {
+	return sender.SendDistributionCtx(context.Background(), name, centroids, hgs, ts, source, tags)
+}
+
+func (sender *proxySender) SendDistributionCtx(ctx context.Context, name string, centroids []histogram.Centroid, hgs map[histogram.Granularity]bool, ts int64, source string, tags map[string]string) error {
+	if sender.suppressed(HistogramSignal, 1) {
+		return nil
+	}
 	handler := sender.handlers[histoHandler]
 	if handler == nil {
 		sender.histogramsDiscarded.Inc()
-		return errors.New("proxy distribution port not provided, cannot send distribution data")
+		return &portError{msg: "proxy distribution port not provided, cannot send distribution data"}
 	}
 
 	if !handler.Connected() {
@@ -198,28 +632,108 @@ func (sender *proxySender) SendDistribution(name string, centroids []histogram.C
 		}
 	}
 
-	line, err := HistoLine(name, centroids, hgs, ts, source, tags, sender.defaultSource)
+	if len(hgs) == 0 {
+		hgs = sender.defaultHgs
+	}
+	line, err := sender.serializer.HistoLine(name, centroids, hgs, ts, source, tags, sender.defaultSource)
 	if err != nil {
 		sender.histogramsInvalid.Inc()
-		return err
+		return invalidResult(err, sender.skipInvalidTags)
 	} else {
 		sender.histogramsValid.Inc()
 	}
-	err = handler.SendData(line)
+	err = handler.SendDataCtx(ctx, line)
 	if err != nil {
 		sender.histogramsDropped.Inc()
 	}
 	return err
 }
 
+func (sender *proxySender) SendDistributions(dists []DistributionPoint) []error {
+	return sender.SendDistributionsCtx(context.Background(), dists)
+}
+
+func (sender *proxySender) SendDistributionsCtx(ctx context.Context, dists []DistributionPoint) []error {
+	errs := make([]error, len(dists))
+	if len(dists) == 0 || sender.suppressed(HistogramSignal, len(dists)) {
+		return errs
+	}
+
+	discard := func(err error) []error {
+		for i := range dists {
+			sender.histogramsDiscarded.Inc()
+			errs[i] = err
+		}
+		return errs
+	}
+
+	handler := sender.handlers[histoHandler]
+	if handler == nil {
+		return discard(&portError{msg: "proxy distribution port not provided, cannot send distribution data"})
+	}
+
+	if !handler.Connected() {
+		if err := handler.Connect(); err != nil {
+			return discard(err)
+		}
+	}
+
+	sb := internal.GetBuffer()
+	defer internal.PutBuffer(sb)
+
+	// indexes of the distributions written to the buffer
+	var written []int
+	for i, dist := range dists {
+		hgs := dist.Granularities
+		if len(hgs) == 0 {
+			hgs = sender.defaultHgs
+		}
+		line, err := sender.serializer.HistoLine(dist.Name, dist.Centroids, hgs, dist.Timestamp, dist.Source, dist.Tags, sender.defaultSource)
+		if err != nil {
+			sender.histogramsInvalid.Inc()
+			errs[i] = invalidResult(err, sender.skipInvalidTags)
+			continue
+		}
+		sender.histogramsValid.Inc()
+		sb.WriteString(line)
+		written = append(written, i)
+	}
+
+	if len(written) == 0 {
+		return errs
+	}
+
+	if err := handler.SendDataCtx(ctx, sb.String()); err != nil {
+		for _, i := range written {
+			sender.histogramsDropped.Inc()
+			errs[i] = err
+		}
+	}
+	return errs
+}
+
 func (sender *proxySender) SendSpan(name string, startMillis, durationMillis int64, source, traceId, spanId string, parents, followsFrom []string, tags []SpanTag, spanLogs []SpanLog) error {
+	return sender.SendSpanCtx(context.Background(), name, startMillis, durationMillis, source, traceId, spanId, parents, followsFrom, tags, spanLogs)
+}
+
+func (sender *proxySender) SendSpanCtx(ctx context.Context, name string, startMillis, durationMillis int64, source, traceId, spanId string, parents, followsFrom []string, tags []SpanTag, spanLogs []SpanLog) error {
+	if sender.suppressed(SpanSignal, 1) {
+		if len(spanLogs) > 0 {
+			sender.spanLogsSuppressed.Inc()
+		}
+		return nil
+	}
+	if !sender.sampleSpan(traceId, spanLogs) {
+		return nil
+	}
+	spanLogs = sender.spanLogsToSend(spanId, spanLogs)
 	handler := sender.handlers[spanHandler]
 	if handler == nil {
 		sender.spansDiscarded.Inc()
 		if spanLogs != nil {
 			sender.spanLogsDiscarded.Inc()
 		}
-		return errors.New("proxy tracing port not provided, cannot send span data")
+		return &portError{msg: "proxy tracing port not provided, cannot send span data"}
 	}
 
 	if !handler.Connected() {
@@ -232,42 +746,248 @@ func (sender *proxySender) SendSpan(name string, startMillis, durationMillis int
 		}
 	}
 
-	line, err := SpanLine(name, startMillis, durationMillis, source, traceId, spanId, parents, followsFrom, tags, spanLogs, sender.defaultSource)
+	tags = dedupSpanTags(tags, sender.spanTagDedup)
+	line, err := sender.serializer.SpanLine(name, startMillis, durationMillis, source, traceId, spanId, parents, followsFrom, tags, spanLogs, sender.defaultSource)
+	if err == nil {
+		err = sender.clockSkew.checkSpan(name, startMillis, sender.spansClockSkew)
+	}
 	if err != nil {
 		sender.spansInvalid.Inc()
-
-		return err
+		return invalidResult(err, sender.skipInvalidTags)
 	} else {
 		sender.spansValid.Inc()
 	}
-	err = handler.SendData(line)
+	err = handler.SendDataCtx(ctx, line)
 	if err != nil {
 		sender.spansDropped.Inc()
 		return err
 	}
 
 	if len(spanLogs) > 0 {
-		logs, err := SpanLogJSON(traceId, spanId, spanLogs)
+		logs, err := sender.serializer.SpanLogs(traceId, spanId, spanLogs)
 		if err != nil {
 			sender.spanLogsInvalid.Inc()
-			return err
+			return invalidData(err)
 		} else {
 			sender.spanLogsValid.Inc()
 		}
-		err = handler.SendData(logs)
-		if err != nil {
-			sender.spanLogsDropped.Inc()
+		if sender.spanLogBatcher != nil {
+			return sender.spanLogBatcher.add(logs)
+		}
+		return sender.sendSpanLogs(ctx, logs, 1)
+	}
+	return nil
+}
+
+// spanLogHandler returns the handler writing the span logs: the span logs handler when configured,
+// the spans handler otherwise.
+func (sender *proxySender) spanLogHandler() internal.ConnectionHandler {
+	if sender.spanLogsHandler != nil {
+		return sender.spanLogsHandler
+	}
+	return sender.handlers[spanHandler]
+}
+
+// sendSpanLogs writes the encoded span logs of count spans with the span logs handler.
+func (sender *proxySender) sendSpanLogs(ctx context.Context, logs string, count int) error {
+	handler := sender.spanLogHandler()
+	if !handler.Connected() {
+		if err := handler.Connect(); err != nil {
+			sender.spanLogsDiscarded.Add(int64(count))
+			return err
 		}
+	}
+	if err := handler.SendDataCtx(ctx, logs); err != nil {
+		sender.spanLogsDropped.Add(int64(count))
+		return err
+	}
+	return nil
+}
+
+// sendSpanLogBatch writes a batch of count span logs buffered by the span log batcher.
+func (sender *proxySender) sendSpanLogBatch(batch string, count int) error {
+	if err := sender.sendSpanLogs(context.Background(), batch, count); err != nil {
 		return err
 	}
+	sender.spanLogBatches.Inc()
+	sender.spanLogsBatched.Add(int64(count))
 	return nil
 }
 
+// sampleSpan applies head sampling to a span, counting it as dropped when its trace is not sampled.
+// The span logs of a dropped span are dropped along with it.
+func (sender *proxySender) sampleSpan(traceId string, spanLogs []SpanLog) bool {
+	if traceSampled(traceId, sender.traceSampleRate) {
+		return true
+	}
+	sender.spansDropped.Inc()
+	sender.spansSampled.Inc()
+	if len(spanLogs) > 0 {
+		sender.spanLogsSampled.Inc()
+	}
+	return false
+}
+
+// spanLogsToSend drops the given span logs of a kept span when span logs are disabled or not sampled.
+func (sender *proxySender) spanLogsToSend(spanId string, spanLogs []SpanLog) []SpanLog {
+	if len(spanLogs) == 0 {
+		return spanLogs
+	}
+	if sender.disableSpanLogs {
+		sender.spanLogsSuppressed.Inc()
+		return nil
+	}
+	if !spanLogsSampled(spanId, sender.spanLogRate) {
+		sender.spanLogsSampled.Inc()
+		return nil
+	}
+	return spanLogs
+}
+
+func (sender *proxySender) SendSpans(spans []Span) []error {
+	return sender.SendSpansCtx(context.Background(), spans)
+}
+
+func (sender *proxySender) SendSpansCtx(ctx context.Context, spans []Span) []error {
+	errs := make([]error, len(spans))
+	if sender.suppressed(SpanSignal, len(spans)) {
+		for _, span := range spans {
+			if len(span.SpanLogs) > 0 {
+				sender.spanLogsSuppressed.Inc()
+			}
+		}
+		return errs
+	}
+
+	// indexes of the spans kept by the trace sampling
+	var kept []int
+	for i, span := range spans {
+		if sender.sampleSpan(span.TraceId, span.SpanLogs) {
+			kept = append(kept, i)
+		}
+	}
+	if len(kept) == 0 {
+		return errs
+	}
+
+	discard := func(err error) []error {
+		for _, i := range kept {
+			sender.spansDiscarded.Inc()
+			if sender.spanLogsToSend(spans[i].SpanId, spans[i].SpanLogs) != nil {
+				sender.spanLogsDiscarded.Inc()
+			}
+			errs[i] = err
+		}
+		return errs
+	}
+
+	handler := sender.handlers[spanHandler]
+	if handler == nil {
+		return discard(&portError{msg: "proxy tracing port not provided, cannot send span data"})
+	}
+
+	if !handler.Connected() {
+		if err := handler.Connect(); err != nil {
+			return discard(err)
+		}
+	}
+
+	sb := internal.GetBuffer()
+	defer internal.PutBuffer(sb)
+
+	// span logs written to the span logs handler when configured, and the indexes of their spans
+	lb := internal.GetBuffer()
+	defer internal.PutBuffer(lb)
+	var separateLogs []int
+
+	// indexes of the spans written to the buffer, and whether their span logs were written too
+	var written []int
+	withLogs := make(map[int]bool)
+	for _, i := range kept {
+		span := spans[i]
+		spanLogs := sender.spanLogsToSend(span.SpanId, span.SpanLogs)
+		line, err := sender.serializer.SpanLine(span.Name, span.StartMillis, span.DurationMillis, span.Source, span.TraceId, span.SpanId,
+			span.Parents, span.FollowsFrom, dedupSpanTags(span.Tags, sender.spanTagDedup), spanLogs, sender.defaultSource)
+		if err == nil {
+			err = sender.clockSkew.checkSpan(span.Name, span.StartMillis, sender.spansClockSkew)
+		}
+		if err != nil {
+			sender.spansInvalid.Inc()
+			errs[i] = invalidResult(err, sender.skipInvalidTags)
+			continue
+		}
+		// the span logs are formatted first, a span whose span logs are invalid being rejected with them
+		var logs string
+		if len(spanLogs) > 0 {
+			if logs, err = sender.serializer.SpanLogs(span.TraceId, span.SpanId, spanLogs); err != nil {
+				sender.spansInvalid.Inc()
+				sender.spanLogsInvalid.Inc()
+				errs[i] = invalidData(err)
+				continue
+			}
+		}
+		sender.spansValid.Inc()
+		sb.WriteString(line)
+		written = append(written, i)
+
+		if len(spanLogs) > 0 {
+			sender.spanLogsValid.Inc()
+			if sender.spanLogBatcher != nil {
+				if err := sender.spanLogBatcher.add(logs); err != nil {
+					errs[i] = err
+				}
+				continue
+			}
+			if sender.spanLogsHandler != nil {
+				lb.WriteString(logs)
+				separateLogs = append(separateLogs, i)
+				continue
+			}
+			sb.WriteString(logs)
+			withLogs[i] = true
+		}
+	}
+
+	if len(separateLogs) > 0 {
+		if err := sender.sendSpanLogs(ctx, lb.String(), len(separateLogs)); err != nil {
+			for _, i := range separateLogs {
+				if errs[i] == nil {
+					errs[i] = err
+				}
+			}
+		}
+	}
+
+	if len(written) == 0 {
+		return errs
+	}
+
+	if err := handler.SendDataCtx(ctx, sb.String()); err != nil {
+		for _, i := range written {
+			sender.spansDropped.Inc()
+			if withLogs[i] {
+				sender.spanLogsDropped.Inc()
+			}
+			if errs[i] == nil {
+				errs[i] = err
+			}
+		}
+	}
+	return errs
+}
+
 func (sender *proxySender) SendEvent(name string, startMillis, endMillis int64, source string, tags map[string]string, setters ...event.Option) error {
+	return sender.SendEventCtx(context.Background(), name, startMillis, endMillis, source, tags, setters...)
+}
+
+func (sender *proxySender) SendEventCtx(ctx context.Context, name string, startMillis, endMillis int64, source string, tags map[string]string, setters ...event.Option) error {
+	if sender.suppressed(EventSignal, 1) {
+		return nil
+	}
 	handler := sender.handlers[eventHandler]
 	if handler == nil {
 		sender.eventsDiscarded.Inc()
-		return errors.New("proxy events port not provided, cannot send events data")
+		return &portError{msg: "proxy events port not provided, cannot send events data"}
 	}
 
 	if !handler.Connected() {
@@ -277,14 +997,17 @@ func (sender *proxySender) SendEvent(name string, startMillis, endMillis int64,
 		}
 	}
 
-	line, err := EventLine(name, startMillis, endMillis, source, tags, setters...)
+	line, err := sender.serializer.EventLine(name, startMillis, endMillis, source, tags, setters...)
 	if err != nil {
 		sender.eventsInvalid.Inc()
-		return err
-	} else {
-		sender.eventsValid.Inc()
+		return invalidResult(err, sender.skipInvalidTags)
+	}
+	if !sender.eventLimiter.allow() {
+		sender.eventsThrottled.Inc()
+		return nil
 	}
-	err = handler.SendData(line)
+	sender.eventsValid.Inc()
+	err = handler.SendDataCtx(ctx, line)
 	if err != nil {
 		sender.eventsDropped.Inc()
 	}
@@ -292,19 +1015,181 @@ func (sender *proxySender) SendEvent(name string, startMillis, endMillis int64,
 }
 
 func (sender *proxySender) Close() {
-	for _, h := range sender.handlers {
+	if err := sender.CloseWithError(); err != nil {
+		log.Println(err)
+	}
+}
+
+func (sender *proxySender) CloseWithError() error {
+	sender.internalRegistry.Stop()
+
+	var errors multiError
+	if sender.deltaAggregator != nil {
+		if err := sender.deltaAggregator.stop(); err != nil {
+			errors.add(err)
+		}
+	}
+	if sender.lastValues != nil {
+		if err := sender.lastValues.stop(); err != nil {
+			errors.add(err)
+		}
+	}
+	if sender.spanLogBatcher != nil {
+		if err := sender.spanLogBatcher.stop(); err != nil {
+			errors.add(err)
+		}
+	}
+	for _, h := range sender.allHandlers() {
 		if h != nil {
-			h.Close()
+			if err := h.Close(); err != nil {
+				errors.add(err)
+			}
 		}
 	}
-	sender.internalRegistry.Stop()
+	return errors.get()
+}
+
+// CloseCtx flushes the pending data within ctx before closing the sender.
+func (sender *proxySender) CloseCtx(ctx context.Context) error {
+	var errors multiError
+	if err := sender.FlushCtx(ctx); err != nil {
+		errors.add(err)
+	}
+	if err := sender.CloseWithError(); err != nil {
+		errors.add(err)
+	}
+	return errors.get()
+}
+
+func (sender *proxySender) SetSignalEnabled(signal SignalType, enabled bool) {
+	sender.signals.set(signal, enabled)
+}
+
+// suppressed returns whether the given signal is disabled, counting its count data items as suppressed if so.
+func (sender *proxySender) suppressed(signal SignalType, count int) bool {
+	if sender.signals.enabled(signal) {
+		return false
+	}
+	switch signal {
+	case MetricSignal:
+		sender.pointsSuppressed.Add(int64(count))
+	case HistogramSignal:
+		sender.histogramsSuppressed.Add(int64(count))
+	case SpanSignal:
+		sender.spansSuppressed.Add(int64(count))
+	case EventSignal:
+		sender.eventsSuppressed.Add(int64(count))
+	}
+	return true
+}
+
+func (sender *proxySender) FlushSignal(signal SignalType) error {
+	return sender.FlushSignalCtx(context.Background(), signal)
+}
+
+func (sender *proxySender) FlushSignalCtx(ctx context.Context, signal SignalType) error {
+	if signal < 0 || int(signal) >= handlersCount {
+		return errors.New("unknown signal type " + signal.String())
+	}
+	if signal == MetricSignal && sender.deltaAggregator != nil {
+		if err := sender.deltaAggregator.flush(); err != nil {
+			return err
+		}
+	}
+	if signal == MetricSignal && sender.lastValues != nil {
+		if err := sender.lastValues.flush(); err != nil {
+			return err
+		}
+	}
+	if signal == SpanSignal && sender.spanLogBatcher != nil {
+		if err := sender.spanLogBatcher.flush(); err != nil {
+			return err
+		}
+	}
+	if signal == SpanSignal && sender.spanLogsHandler != nil {
+		if err := sender.spanLogsHandler.FlushCtx(ctx); err != nil {
+			return err
+		}
+	}
+	if signal == MetricSignal {
+		for _, key := range sortedRoutes(sender.routes) {
+			if err := sender.routes[key].FlushCtx(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	if h := sender.handlers[signal]; h != nil {
+		return h.FlushCtx(ctx)
+	}
+	return nil
+}
+
+func (sender *proxySender) Drain(ctx context.Context) error {
+	return drain(ctx, func() error {
+		var errors multiError
+		if sender.deltaAggregator != nil {
+			if err := sender.deltaAggregator.flush(); err != nil {
+				errors.add(err)
+			}
+		}
+		if sender.lastValues != nil {
+			if err := sender.lastValues.flush(); err != nil {
+				errors.add(err)
+			}
+		}
+		if sender.spanLogBatcher != nil {
+			if err := sender.spanLogBatcher.flush(); err != nil {
+				errors.add(err)
+			}
+		}
+		for _, h := range sender.allHandlers() {
+			if h == nil {
+				continue
+			}
+			if err := h.Connect(); err != nil {
+				errors.add(err)
+			} else if err := h.Flush(); err != nil {
+				errors.add(err)
+			}
+		}
+		return errors.get()
+	})
+}
+
+func (sender *proxySender) ReportInternalMetricsNow() error {
+	if sender.internalRegistry == nil {
+		return nil
+	}
+	if err := sender.internalRegistry.Report(); err != nil {
+		return err
+	}
+	return sender.FlushSignal(MetricSignal)
 }
 
 func (sender *proxySender) Flush() error {
+	return sender.FlushCtx(context.Background())
+}
+
+func (sender *proxySender) FlushCtx(ctx context.Context) error {
 	errStr := ""
-	for _, h := range sender.handlers {
+	if sender.deltaAggregator != nil {
+		if err := sender.deltaAggregator.flush(); err != nil {
+			errStr = errStr + err.Error() + "\n"
+		}
+	}
+	if sender.lastValues != nil {
+		if err := sender.lastValues.flush(); err != nil {
+			errStr = errStr + err.Error() + "\n"
+		}
+	}
+	if sender.spanLogBatcher != nil {
+		if err := sender.spanLogBatcher.flush(); err != nil {
+			errStr = errStr + err.Error() + "\n"
+		}
+	}
+	for _, h := range sender.allHandlers() {
 		if h != nil {
-			err := h.Flush()
+			err := h.FlushCtx(ctx)
 			if err != nil {
 				errStr = errStr + err.Error() + "\n"
 			}
@@ -316,12 +1201,69 @@ func (sender *proxySender) Flush() error {
 	return nil
 }
 
+func (sender *proxySender) FlushWithStats() (FlushStats, error) {
+	return flushWithStats(sender.flushAges, sender.Flush, sender.PendingLines)
+}
+
 func (sender *proxySender) GetFailureCount() int64 {
 	var failures int64
-	for _, h := range sender.handlers {
+	for _, h := range sender.allHandlers() {
 		if h != nil {
 			failures += h.GetFailureCount()
 		}
 	}
 	return failures
 }
+
+func (sender *proxySender) FailureCountDelta() int64 {
+	var failures int64
+	for _, h := range sender.allHandlers() {
+		if h != nil {
+			failures += h.ResetFailureCount()
+		}
+	}
+	return failures
+}
+
+func (sender *proxySender) Source() string {
+	return sender.defaultSource
+}
+
+func (sender *proxySender) PendingLines() map[string]int {
+	pending := make(map[string]int)
+	for i, h := range sender.handlers {
+		if h != nil {
+			pending[handlerNames[i]] = h.PendingLines()
+		}
+	}
+	for key, h := range sender.routes {
+		pending[routeName(key)] = h.PendingLines()
+	}
+	if sender.spanLogsHandler != nil {
+		pending[spanLogsRoute] = sender.spanLogsHandler.PendingLines()
+	}
+	if sender.spanLogBatcher != nil {
+		pending[handlerNames[spanHandler]] += sender.spanLogBatcher.pending()
+	}
+	return pending
+}
+
+func (sender *proxySender) ConnectionStatus() map[string]bool {
+	status := make(map[string]bool)
+	for i, h := range sender.handlers {
+		if h != nil {
+			status[handlerNames[i]] = h.Connected()
+		}
+	}
+	for key, h := range sender.routes {
+		status[routeName(key)] = h.Connected()
+	}
+	if sender.spanLogsHandler != nil {
+		status[spanLogsRoute] = sender.spanLogsHandler.Connected()
+	}
+	return status
+}
+
+func (sender *proxySender) now() time.Time {
+	return sender.clock.Now()
+}
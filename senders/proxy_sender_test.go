@@ -0,0 +1,511 @@
+package senders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wavefronthq/wavefront-sdk-go/histogram"
+	"github.com/wavefronthq/wavefront-sdk-go/version"
+)
+
+// fakeConnectionHandler records the data sent through it instead of writing to a proxy.
+type fakeConnectionHandler struct {
+	connected bool
+	lines     []string
+	flushErr  error
+	flushes   int
+}
+
+func (h *fakeConnectionHandler) Connect() error {
+	h.connected = true
+	return nil
+}
+
+func (h *fakeConnectionHandler) Connected() bool {
+	return h.connected
+}
+
+func (h *fakeConnectionHandler) Close() error {
+	h.connected = false
+	return h.flushErr
+}
+
+func (h *fakeConnectionHandler) SendData(lines string) error {
+	return h.SendDataCtx(context.Background(), lines)
+}
+
+func (h *fakeConnectionHandler) SendDataCtx(ctx context.Context, lines string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	h.lines = append(h.lines, lines)
+	return nil
+}
+
+func (h *fakeConnectionHandler) Flush() error {
+	return h.FlushCtx(context.Background())
+}
+
+func (h *fakeConnectionHandler) FlushCtx(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	h.flushes++
+	return h.flushErr
+}
+
+func (h *fakeConnectionHandler) PendingLines() int {
+	return 0
+}
+
+func (h *fakeConnectionHandler) GetFailureCount() int64 {
+	return 0
+}
+
+func (h *fakeConnectionHandler) ResetFailureCount() int64 {
+	return 0
+}
+
+func (h *fakeConnectionHandler) Start() {}
+
+func (h *fakeConnectionHandler) data() string {
+	return strings.Join(h.lines, "")
+}
+
+// newTestProxySender creates a proxy sender whose configured handlers are replaced by fakes.
+func newTestProxySender(t *testing.T, cfg *ProxyConfiguration) (*proxySender, []*fakeConnectionHandler) {
+	s, err := NewProxySender(cfg)
+	require.NoError(t, err)
+	sender := s.(*proxySender)
+
+	fakes := make([]*fakeConnectionHandler, len(sender.handlers))
+	for i, h := range sender.handlers {
+		if h != nil {
+			h.Close()
+			fakes[i] = &fakeConnectionHandler{}
+			sender.handlers[i] = fakes[i]
+		}
+	}
+	return sender, fakes
+}
+
+const (
+	testTraceId = "7b3bf470-9456-11e8-9eb6-529269fb1459"
+	testSpanId  = "0313bafe-9457-11e8-9eb6-529269fb1459"
+)
+
+func TestDisableSpanLogs(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", TracingPort: 50000, DisableSpanLogs: true})
+	defer sender.Close()
+
+	spanLogs := []SpanLog{{Timestamp: 1554363517965, Fields: map[string]string{"event": "error"}}}
+	err := sender.SendSpan("getAllUsers", 0, 343500, "localhost", testTraceId, testSpanId, nil, nil, nil, spanLogs)
+	require.NoError(t, err)
+
+	data := handlers[spanHandler].data()
+	assert.Contains(t, data, "getAllUsers")
+	assert.NotContains(t, data, "_spanLogs")
+	assert.NotContains(t, data, "\"logs\"")
+	assert.Equal(t, int64(1), sender.spanLogsSuppressed.Count())
+}
+
+func TestConnectionStatus(t *testing.T) {
+	sender, _ := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", MetricsPort: 30000, TracingPort: 50000})
+	defer sender.Close()
+
+	assert.Equal(t, map[string]bool{"points": false, "spans": false}, sender.ConnectionStatus())
+
+	require.NoError(t, sender.SendMetric("new-york.power.usage", 42422.0, 0, "go_test", nil))
+	assert.Equal(t, map[string]bool{"points": true, "spans": false}, sender.ConnectionStatus())
+}
+
+func TestSendMetricNonFinite(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", MetricsPort: 30000})
+	defer sender.Close()
+
+	assert.Error(t, sender.SendMetric("new-york.power.usage", math.Inf(1), 0, "go_test", nil))
+	assert.Empty(t, handlers[metricHandler].data())
+	assert.Equal(t, int64(1), sender.pointsInvalid.Count())
+}
+
+func TestCloseWithError(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", MetricsPort: 30000, TracingPort: 50000})
+	handlers[spanHandler].flushErr = errors.New("flush failed")
+
+	err := sender.CloseWithError()
+	assert.EqualError(t, err, "flush failed")
+}
+
+func TestSendSpanDedupsTags(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", TracingPort: 50000})
+	defer sender.Close()
+
+	tags := []SpanTag{{Key: "env", Value: "test"}, {Key: "env", Value: "dev"}}
+	require.NoError(t, sender.SendSpan("getAllUsers", 0, 343500, "localhost", testTraceId, testSpanId, nil, nil, tags, nil))
+
+	data := handlers[spanHandler].data()
+	assert.Contains(t, data, "\"env\"=\"dev\"")
+	assert.NotContains(t, data, "\"env\"=\"test\"")
+}
+
+func TestDisableInternalMetrics(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{
+		Host:                   "localhost",
+		MetricsPort:            50000,
+		TracingPort:            50001,
+		DisableInternalMetrics: true,
+	})
+	sender.Start()
+	defer sender.Close()
+	assert.Nil(t, sender.internalRegistry)
+
+	require.NoError(t, sender.SendMetric("new-york.power.usage", 42422, 1533529977, "localhost", nil))
+	assert.Error(t, sender.SendMetric("", 42422, 1533529977, "localhost", nil))
+	require.NoError(t, sender.SendSpan("getAllUsers", 0, 343500, "localhost", testTraceId, testSpanId, nil, nil, nil, nil))
+	assert.Len(t, handlers[metricHandler].lines, 1)
+	assert.Len(t, handlers[spanHandler].lines, 1)
+}
+
+func TestReportInternalMetricsNow(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", MetricsPort: 50000})
+	defer sender.Close()
+
+	assert.Error(t, sender.SendMetric("", 42422, 1533529977, "localhost", nil))
+	require.NoError(t, sender.ReportInternalMetricsNow())
+	assert.Contains(t, handlers[metricHandler].data(), "\"∆~sdk.go.core.sender.proxy.points.invalid\" 1 ")
+	assert.Equal(t, 1, handlers[metricHandler].flushes)
+
+	sender, handlers = newTestProxySender(t, &ProxyConfiguration{Host: "localhost", MetricsPort: 50000, DisableInternalMetrics: true})
+	defer sender.Close()
+	require.NoError(t, sender.ReportInternalMetricsNow())
+	assert.Empty(t, handlers[metricHandler].lines)
+}
+
+func TestFlushSignal(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", MetricsPort: 50000, TracingPort: 50001})
+	defer sender.Close()
+
+	require.NoError(t, sender.FlushSignal(SpanSignal))
+	assert.Equal(t, 0, handlers[metricHandler].flushes)
+	assert.Equal(t, 1, handlers[spanHandler].flushes)
+
+	// no events port, nothing to flush
+	require.NoError(t, sender.FlushSignal(EventSignal))
+
+	handlers[metricHandler].flushErr = errors.New("flush failed")
+	assert.EqualError(t, sender.FlushSignal(MetricSignal), "flush failed")
+	assert.Equal(t, 1, handlers[metricHandler].flushes)
+	assert.Equal(t, 1, handlers[spanHandler].flushes)
+	handlers[metricHandler].flushErr = nil
+
+	assert.EqualError(t, sender.FlushSignal(SignalType(7)), "unknown signal type SignalType(7)")
+}
+
+func TestSignalTypeString(t *testing.T) {
+	assert.Equal(t, "points", MetricSignal.String())
+	assert.Equal(t, "histograms", HistogramSignal.String())
+	assert.Equal(t, "spans", SpanSignal.String())
+	assert.Equal(t, "events", EventSignal.String())
+	assert.Equal(t, "SignalType(-1)", SignalType(-1).String())
+}
+
+func TestGreetingLine(t *testing.T) {
+	assert.Equal(t, "#wavefront-sdk-go "+version.Version+"\n", greetingLine(""))
+	assert.Equal(t, "#my-app 1.2.3\n", greetingLine("#my-app 1.2.3"))
+	assert.Equal(t, "#my-app 1.2.3\n", greetingLine("#my-app 1.2.3\n"))
+}
+
+func TestSendDistributionSplit(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", DistributionPort: 50000, MaxHistogramLineBytes: 70})
+	defer sender.Close()
+
+	centroids := []histogram.Centroid{{Value: 30, Count: 20}, {Value: 5.1, Count: 10}, {Value: 1234.5678, Count: 3}, {Value: 0.25, Count: 7}}
+	hgs := map[histogram.Granularity]bool{histogram.MINUTE: true}
+	require.NoError(t, sender.SendDistribution("request.latency", centroids, hgs, 1533529977, "test_source", nil))
+
+	lines := strings.SplitAfter(handlers[histoHandler].data(), "\n")
+	lines = lines[:len(lines)-1]
+	assert.True(t, len(lines) > 1, "distribution not split")
+	for _, line := range lines {
+		assert.True(t, len(line) <= 70, line)
+		assert.True(t, strings.HasPrefix(line, "!M 1533529977 #"), line)
+	}
+}
+
+func TestMaxCentroidsPerDistribution(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", DistributionPort: 50000, MaxCentroidsPerDistribution: 3})
+	defer sender.Close()
+
+	hgs := map[histogram.Granularity]bool{histogram.MINUTE: true}
+	under := []histogram.Centroid{{Value: 30, Count: 20}, {Value: 5.1, Count: 10}, {Value: 0.25, Count: 7}}
+	require.NoError(t, sender.SendDistribution("request.latency", under, hgs, 1533529977, "test_source", nil))
+	assert.Equal(t, "!M 1533529977 #20 30 #10 5.1 #7 0.25 \"request.latency\" source=\"test_source\"\n", handlers[histoHandler].data())
+
+	over := append(under, histogram.Centroid{Value: 1234.5678, Count: 3})
+	assert.EqualError(t, sender.SendDistribution("request.latency", over, hgs, 1533529977, "test_source", nil),
+		"distribution request.latency has 4 centroids, more than the max of 3")
+	assert.Equal(t, int64(1), sender.histogramsInvalid.Count())
+	assert.Len(t, handlers[histoHandler].lines, 1)
+
+	// split when splitting is enabled
+	sender, handlers = newTestProxySender(t, &ProxyConfiguration{Host: "localhost", DistributionPort: 50000,
+		MaxCentroidsPerDistribution: 3, MaxHistogramLineBytes: 1000})
+	defer sender.Close()
+	require.NoError(t, sender.SendDistribution("request.latency", over, hgs, 1533529977, "test_source", nil))
+	assert.Equal(t, "!M 1533529977 #20 30 #10 5.1 #7 0.25 \"request.latency\" source=\"test_source\"\n"+
+		"!M 1533529977 #3 1234.5678 \"request.latency\" source=\"test_source\"\n", handlers[histoHandler].data())
+}
+
+func TestConnectionCallbacks(t *testing.T) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	port := lis.Addr().(*net.TCPAddr).Port
+	lis.Close()
+
+	var failed []SignalType
+	sender, err := NewProxySender(&ProxyConfiguration{
+		Host:             "localhost",
+		DistributionPort: port,
+		OnReconnectFailed: func(signal SignalType, err error) {
+			assert.Error(t, err)
+			failed = append(failed, signal)
+		},
+	})
+	require.NoError(t, err)
+	defer sender.Close()
+
+	centroids := []histogram.Centroid{{Value: 30, Count: 20}}
+	hgs := map[histogram.Granularity]bool{histogram.MINUTE: true}
+	assert.Error(t, sender.SendDistribution("request.latency", centroids, hgs, 0, "test_source", nil))
+	assert.Equal(t, []SignalType{HistogramSignal}, failed)
+}
+
+func TestDefaultHistogramGranularities(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{
+		Host:                          "localhost",
+		DistributionPort:              50000,
+		DefaultHistogramGranularities: map[histogram.Granularity]bool{histogram.MINUTE: true},
+	})
+	defer sender.Close()
+	histos := handlers[histoHandler]
+
+	centroids := []histogram.Centroid{{Value: 30, Count: 20}}
+	require.NoError(t, sender.SendDistribution("request.latency", centroids, nil, 1533529977, "test_source", nil))
+	require.NoError(t, sender.SendDistribution("request.latency", centroids, map[histogram.Granularity]bool{}, 1533529977, "test_source", nil))
+	require.NoError(t, sender.SendDistribution("request.latency", centroids, map[histogram.Granularity]bool{histogram.HOUR: true}, 1533529977, "test_source", nil))
+	assert.Equal(t, []string{
+		"!M 1533529977 #20 30 \"request.latency\" source=\"test_source\"\n",
+		"!M 1533529977 #20 30 \"request.latency\" source=\"test_source\"\n",
+		"!H 1533529977 #20 30 \"request.latency\" source=\"test_source\"\n",
+	}, histos.lines)
+
+	// without a default, distributions without granularities are rejected
+	sender, _ = newTestProxySender(t, &ProxyConfiguration{Host: "localhost", DistributionPort: 50000})
+	defer sender.Close()
+	assert.Error(t, sender.SendDistribution("request.latency", centroids, nil, 1533529977, "test_source", nil))
+
+	cfg := &configuration{}
+	DefaultHistogramGranularities(histogram.MINUTE, histogram.DAY)(cfg)
+	assert.Equal(t, map[histogram.Granularity]bool{histogram.MINUTE: true, histogram.DAY: true}, cfg.DefaultHistogramGranularities)
+}
+
+func TestHeartbeatLine(t *testing.T) {
+	assert.Equal(t, "\n", heartbeatLine(""))
+	assert.Equal(t, "#heartbeat\n", heartbeatLine("#heartbeat"))
+	assert.Equal(t, "#heartbeat\n", heartbeatLine("#heartbeat\n"))
+}
+
+func TestProxyConfigurationValidate(t *testing.T) {
+	assert.NoError(t, (&ProxyConfiguration{Host: "localhost", MetricsPort: 2878}).Validate())
+	assert.NoError(t, (&ProxyConfiguration{Host: "localhost", TracingPort: 30000, FlushIntervalSeconds: 10}).Validate())
+	assert.NoError(t, (&ProxyConfiguration{UnixSocket: "/var/run/wavefront.sock"}).Validate())
+
+	tests := map[string]struct {
+		cfg ProxyConfiguration
+		err string
+	}{
+		"no port":           {ProxyConfiguration{Host: "localhost"}, "at least one proxy port should be enabled"},
+		"no host":           {ProxyConfiguration{MetricsPort: 2878}, "proxy host is required"},
+		"negative port":     {ProxyConfiguration{Host: "localhost", MetricsPort: -1}, "invalid proxy metrics port -1: must be between 1 and 65535"},
+		"port too large":    {ProxyConfiguration{Host: "localhost", MetricsPort: 2878, EventsPort: 65536}, "invalid proxy events port 65536: must be between 1 and 65535"},
+		"negative interval": {ProxyConfiguration{Host: "localhost", MetricsPort: 2878, FlushIntervalSeconds: -5}, "invalid flush interval -5: must be positive, or 0 for the default"},
+		"negative batch":    {ProxyConfiguration{Host: "localhost", MetricsPort: 2878, FlushBatchSize: -1}, "invalid flush batch size -1: must be positive, or 0 to disable"},
+		"negative datagram": {ProxyConfiguration{Host: "localhost", MetricsPort: 2878, MaxDatagramBytes: -1}, "invalid max datagram size -1: must be positive, or 0 for the default"},
+	}
+	for name, test := range tests {
+		assert.EqualError(t, test.cfg.Validate(), test.err, name)
+	}
+
+	sender, err := NewProxySender(&ProxyConfiguration{MetricsPort: 2878})
+	assert.EqualError(t, err, "proxy host is required")
+	assert.Nil(t, sender)
+}
+
+func TestMetricsUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	sender, err := NewProxySender(&ProxyConfiguration{
+		Host:                   "127.0.0.1",
+		MetricsPort:            conn.LocalAddr().(*net.UDPAddr).Port,
+		MetricsUDP:             true,
+		PackDatagrams:          true,
+		DisableInternalMetrics: true,
+	})
+	require.NoError(t, err)
+	defer sender.Close()
+
+	require.NoError(t, sender.SendMetric("foo.metric", 1.2, 1533529977, "test_source", nil))
+	require.NoError(t, sender.SendMetric("bar.metric", 3, 1533529977, "test_source", nil))
+	require.NoError(t, sender.Flush())
+
+	buf := make([]byte, defaultMaxDatagramBytes)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "\"foo.metric\" 1.2 1533529977 source=\"test_source\"\n\"bar.metric\" 3 1533529977 source=\"test_source\"\n", string(buf[:n]))
+}
+
+func TestUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wavefront")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "wavefront.sock")
+	lis, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	defer lis.Close()
+
+	received := make(chan string)
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				data, _ := ioutil.ReadAll(conn)
+				received <- string(data)
+			}()
+		}
+	}()
+
+	// the ports are ignored, each signal being written on its own connection to the socket
+	for _, path := range []string{socket, "unix://" + socket} {
+		sender, err := NewProxySender(&ProxyConfiguration{UnixSocket: path, SpanLogsPort: 30001, DisableInternalMetrics: true})
+		require.NoError(t, err)
+		require.NoError(t, sender.SendMetric("foo.metric", 1.2, 1533529977, "test_source", nil))
+		require.NoError(t, sender.SendEvent("deploy", 1533529977, 0, "test_source", nil))
+		sender.Close()
+
+		data := []string{<-received, <-received}
+		sort.Strings(data)
+		assert.Equal(t, "\"foo.metric\" 1.2 1533529977 source=\"test_source\"\n", data[0])
+		assert.True(t, strings.HasPrefix(data[1], "@Event 1533529977000"), data[1])
+	}
+}
+
+func TestSendDistributionG(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", DistributionPort: 50000})
+	defer sender.Close()
+	histos := handlers[histoHandler]
+
+	centroids := []histogram.Centroid{{Value: 30, Count: 20}, {Value: 5.1, Count: 10}}
+	tags := map[string]string{"region": "us-west"}
+	hgs := map[histogram.Granularity]bool{histogram.MINUTE: true, histogram.HOUR: true, histogram.DAY: false}
+	require.NoError(t, sender.SendDistribution("request.latency", centroids, hgs, 1533529977, "appServer1", tags))
+	require.NoError(t, sender.SendDistributionG("request.latency", centroids, 1533529977, "appServer1", tags, histogram.MINUTE, histogram.HOUR))
+	require.Len(t, histos.lines, 2)
+	assert.Equal(t, histos.lines[0], histos.lines[1])
+
+	assert.Error(t, sender.SendDistributionG("request.latency", centroids, 1533529977, "appServer1", tags))
+}
+
+func TestSendDistributions(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", DistributionPort: 50000})
+	defer sender.Close()
+	histos := handlers[histoHandler]
+
+	minute := map[histogram.Granularity]bool{histogram.MINUTE: true}
+	errs := sender.SendDistributions([]DistributionPoint{
+		{Name: "request.latency", Centroids: []histogram.Centroid{{Value: 30, Count: 20}}, Granularities: minute, Timestamp: 1533529977, Source: "appServer1"},
+		{Name: "", Centroids: []histogram.Centroid{{Value: 5, Count: 1}}, Granularities: minute, Timestamp: 1533529977, Source: "appServer1"},
+		{Name: "request.latency", Centroids: []histogram.Centroid{{Value: 5.1, Count: 10}}, Granularities: minute, Timestamp: 1533529977, Source: "appServer2",
+			Tags: map[string]string{"region": "us-west"}},
+	})
+	require.Len(t, errs, 3)
+	assert.NoError(t, errs[0])
+	assert.Error(t, errs[1])
+	assert.NoError(t, errs[2])
+
+	// the valid distributions are written at once
+	assert.Equal(t, []string{"!M 1533529977 #20 30 \"request.latency\" source=\"appServer1\"\n" +
+		"!M 1533529977 #10 5.1 \"request.latency\" source=\"appServer2\" \"region\"=\"us-west\"\n"}, histos.lines)
+	assert.Equal(t, int64(2), sender.histogramsValid.Count())
+	assert.Equal(t, int64(1), sender.histogramsInvalid.Count())
+
+	sender, _ = newTestProxySender(t, &ProxyConfiguration{Host: "localhost", MetricsPort: 50000})
+	defer sender.Close()
+	for _, err := range sender.SendDistributions([]DistributionPoint{{Name: "request.latency"}, {Name: "request.size"}}) {
+		assert.Error(t, err)
+	}
+	assert.Equal(t, int64(2), sender.histogramsDiscarded.Count())
+}
+
+func TestSendBlankNames(t *testing.T) {
+	sender, _ := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", MetricsPort: 50000, DistributionPort: 50001,
+		TracingPort: 50002, EventsPort: 50003})
+	defer sender.Close()
+
+	centroids := []histogram.Centroid{{Value: 30, Count: 20}}
+	signals := map[string]func(name string) error{
+		"metric": func(name string) error {
+			return sender.SendMetric(name, 42, 1533529977, "localhost", nil)
+		},
+		"distribution": func(name string) error {
+			return sender.SendDistributionG(name, centroids, 1533529977, "localhost", nil, histogram.MINUTE)
+		},
+		"span": func(name string) error {
+			return sender.SendSpan(name, 1533529977000, 343, "localhost", testTraceId, testSpanId, nil, nil, nil, nil)
+		},
+		"event": func(name string) error {
+			return sender.SendEvent(name, 1533529977000, 0, "localhost", nil)
+		},
+	}
+	tests := []struct {
+		name string
+		err  string
+	}{
+		{"", "empty %s name"},
+		{" \t", "blank %s name \" \\t\""},
+		{"request.latency", ""},
+	}
+
+	for signal, send := range signals {
+		for _, test := range tests {
+			err := send(test.name)
+			if test.err == "" {
+				assert.NoError(t, err, signal)
+			} else {
+				assert.EqualError(t, err, fmt.Sprintf(test.err, signal), signal)
+			}
+		}
+	}
+	assert.EqualError(t, sender.SendDeltaCounter(" ", 10, "localhost", nil), "blank metric name \" \"")
+	assert.Equal(t, int64(3), sender.pointsInvalid.Count())
+	assert.Equal(t, int64(2), sender.histogramsInvalid.Count())
+	assert.Equal(t, int64(2), sender.spansInvalid.Count())
+	assert.Equal(t, int64(2), sender.eventsInvalid.Count())
+}
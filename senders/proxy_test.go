@@ -6,6 +6,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/wavefronthq/wavefront-sdk-go/histogram"
 	"github.com/wavefronthq/wavefront-sdk-go/senders"
 )
@@ -75,6 +76,22 @@ func TestProxySends(t *testing.T) {
 		t.Error("Failed SendSpan", err)
 	}
 
+	errs := proxy.(senders.SpanBatchSender).SendSpans([]senders.Span{
+		{
+			Name: "getAllUsers", DurationMillis: 343500, Source: "localhost",
+			TraceId: "7b3bf470-9456-11e8-9eb6-529269fb1459", SpanId: "0313bafe-9457-11e8-9eb6-529269fb1459",
+			Tags: []senders.SpanTag{{Key: "application", Value: "Wavefront"}},
+		},
+		{
+			Name: "getAllUsers", DurationMillis: 343500, Source: "localhost",
+			TraceId: "invalid", SpanId: "0313bafe-9457-11e8-9eb6-529269fb1459",
+		},
+	})
+	if assert.Len(t, errs, 2) {
+		assert.Nil(t, errs[0], "Failed SendSpans")
+		assert.NotNil(t, errs[1], "SendSpans accepted an invalid traceId")
+	}
+
 	proxy.Flush()
 	proxy.Close()
 	if proxy.GetFailureCount() > 0 {
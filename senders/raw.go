@@ -0,0 +1,36 @@
+package senders
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/wavefronthq/wavefront-sdk-go/internal"
+)
+
+// rawLine validates a pre-formatted line, returning it newline terminated.
+func rawLine(line string) (string, error) {
+	line = strings.TrimSuffix(line, "\n")
+	if strings.TrimSpace(line) == "" {
+		return "", errors.New("empty line")
+	}
+	if strings.ContainsAny(line, "\r\n") {
+		return "", fmt.Errorf("line break in line: %q", line)
+	}
+	return line + "\n", nil
+}
+
+// rawLines validates pre-formatted lines, returning them newline terminated and concatenated.
+func rawLines(lines []string) (string, error) {
+	sb := internal.GetBuffer()
+	defer internal.PutBuffer(sb)
+
+	for _, line := range lines {
+		l, err := rawLine(line)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(l)
+	}
+	return sb.String(), nil
+}
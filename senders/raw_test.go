@@ -0,0 +1,39 @@
+package senders
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRawLine(t *testing.T) {
+	line, err := rawLine("new-york.power.usage 42422 source=localhost")
+	require.NoError(t, err)
+	assert.Equal(t, "new-york.power.usage 42422 source=localhost\n", line)
+
+	line, err = rawLine("new-york.power.usage 42422 source=localhost\n")
+	require.NoError(t, err)
+	assert.Equal(t, "new-york.power.usage 42422 source=localhost\n", line)
+
+	for _, invalid := range []string{"", "\n", "  ", "a 1\nb 2", "a 1\r\n", "a 1\n\n"} {
+		_, err = rawLine(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestSendRawLines(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", MetricsPort: 50000})
+	defer sender.Close()
+
+	require.NoError(t, sender.SendRawLine("new-york.power.usage 42422 source=localhost"))
+	require.NoError(t, sender.SendRawLines([]string{"a 1 source=localhost\n", "b 2 source=localhost"}))
+	assert.Error(t, sender.SendRawLines([]string{"c 3 source=localhost", ""}))
+
+	assert.Equal(t, []string{
+		"new-york.power.usage 42422 source=localhost\n",
+		"a 1 source=localhost\nb 2 source=localhost\n",
+	}, handlers[metricHandler].lines)
+	assert.Equal(t, int64(3), sender.pointsValid.Count())
+	assert.Equal(t, int64(2), sender.pointsInvalid.Count())
+}
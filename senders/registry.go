@@ -0,0 +1,94 @@
+package senders
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Registry tracks senders to flush or close them together, e.g. on the graceful shutdown of an application
+// sending to several backends or tenants. Senders are added with Register, or on construction with the
+// WithRegistry option or the Registry field of the ProxyConfiguration.
+type Registry struct {
+	mtx     sync.Mutex
+	senders []Sender
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds a sender to the registry.
+func (r *Registry) Register(sender Sender) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.senders = append(r.senders, sender)
+}
+
+// Unregister removes a sender from the registry, e.g. before closing it separately: a sender must not be closed twice.
+func (r *Registry) Unregister(sender Sender) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	for i, s := range r.senders {
+		if s == sender {
+			r.senders = append(r.senders[:i:i], r.senders[i+1:]...)
+			return
+		}
+	}
+}
+
+// Senders returns the registered senders, in their registration order.
+func (r *Registry) Senders() []Sender {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return append([]Sender(nil), r.senders...)
+}
+
+// FlushAll flushes all the registered senders concurrently, returning their errors. When ctx is done first,
+// the senders still flushing are reported with the context error and their flush goes on in the background.
+func (r *Registry) FlushAll(ctx context.Context) error {
+	return apply(ctx, r.Senders(), Sender.Flush)
+}
+
+// CloseAll closes all the registered senders concurrently, flushing their buffered data, and empties the registry.
+// It returns their errors like FlushAll, the senders not closed before ctx is done going on closing in the background.
+func (r *Registry) CloseAll(ctx context.Context) error {
+	r.mtx.Lock()
+	senders := r.senders
+	r.senders = nil
+	r.mtx.Unlock()
+	return apply(ctx, senders, closeWithError)
+}
+
+// apply calls f on each sender concurrently, and returns their errors once they all returned or ctx is done.
+func apply(ctx context.Context, senders []Sender, f func(Sender) error) error {
+	errs := make([]error, len(senders))
+	done := make([]bool, len(senders))
+	results := make(chan int, len(senders))
+	for i, sender := range senders {
+		go func(i int, sender Sender) {
+			errs[i] = f(sender)
+			results <- i
+		}(i, sender)
+	}
+
+	var errors multiError
+	for remaining := len(senders); remaining > 0; remaining-- {
+		select {
+		case i := <-results:
+			done[i] = true
+			if errs[i] != nil {
+				errors.add(errs[i])
+			}
+		case <-ctx.Done():
+			for i := range senders {
+				if !done[i] {
+					errors.add(fmt.Errorf("sender %d: %w", i, ctx.Err()))
+				}
+			}
+			return errors.get()
+		}
+	}
+	return errors.get()
+}
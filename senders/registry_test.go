@@ -0,0 +1,84 @@
+package senders
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lifecycleSender records its flushes and closes, blocking them until release is closed when set.
+type lifecycleSender struct {
+	fakeSender
+	release chan struct{}
+	flushes int
+	closes  int
+}
+
+func (s *lifecycleSender) wait() {
+	if s.release != nil {
+		<-s.release
+	}
+}
+
+func (s *lifecycleSender) Flush() error {
+	s.wait()
+	s.flushes++
+	return s.err
+}
+
+func (s *lifecycleSender) CloseWithError() error {
+	s.wait()
+	s.closes++
+	return s.err
+}
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	senders := []*lifecycleSender{{}, {}, {fakeSender: fakeSender{err: errors.New("proxy unreachable")}}}
+	for _, sender := range senders {
+		registry.Register(sender)
+	}
+
+	assert.EqualError(t, registry.FlushAll(context.Background()), "proxy unreachable")
+	for _, sender := range senders {
+		assert.Equal(t, 1, sender.flushes)
+	}
+
+	registry.Unregister(senders[2])
+	require.NoError(t, registry.CloseAll(context.Background()))
+	assert.Equal(t, 1, senders[0].closes)
+	assert.Equal(t, 1, senders[1].closes)
+	assert.Equal(t, 0, senders[2].closes)
+	assert.Empty(t, registry.Senders())
+}
+
+func TestRegistryTimeout(t *testing.T) {
+	registry := NewRegistry()
+	blocked := &lifecycleSender{release: make(chan struct{})}
+	defer close(blocked.release)
+	registry.Register(&lifecycleSender{})
+	registry.Register(blocked)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := registry.CloseAll(ctx)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.EqualError(t, err, "sender 1: context deadline exceeded")
+}
+
+func TestRegisterOnConstruction(t *testing.T) {
+	registry := NewRegistry()
+	sender, err := NewSender("http://localhost:2878", WithRegistry(registry))
+	require.NoError(t, err)
+	assert.Equal(t, []Sender{sender}, registry.Senders())
+
+	proxy, err := NewProxySender(&ProxyConfiguration{Host: "localhost", MetricsPort: 2878, Registry: registry})
+	require.NoError(t, err)
+	assert.Equal(t, []Sender{sender, proxy}, registry.Senders())
+
+	assert.NoError(t, registry.CloseAll(context.Background()))
+}
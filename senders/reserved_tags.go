@@ -0,0 +1,65 @@
+package senders
+
+import (
+	"fmt"
+	"strings"
+)
+
+// reservedTagKeys are the point tag keys colliding with the source of the metrics: the Wavefront proxy
+// takes a "host" tag as the source of the points without source.
+var reservedTagKeys = []string{"source", "host"}
+
+// ReservedTagPolicy controls what is done with the metric tags whose key is reserved, e.g. "source",
+// colliding with the source argument and making the source of the point ambiguous.
+type ReservedTagPolicy int
+
+const (
+	// KeepReservedTags sends the reserved tags as is. This is the default.
+	KeepReservedTags ReservedTagPolicy = iota
+	// DropReservedTags removes the reserved tags from the metrics, counted by the "points.reserved_tags.dropped"
+	// internal metric. The points are sent with their source argument.
+	DropReservedTags
+	// RejectReservedTags rejects the metrics with a reserved tag as invalid, returning an error.
+	RejectReservedTags
+)
+
+// isReservedTagKey returns whether a tag key is reserved, ignoring its case.
+func isReservedTagKey(key string) bool {
+	for _, reserved := range reservedTagKeys {
+		if strings.EqualFold(key, reserved) {
+			return true
+		}
+	}
+	return false
+}
+
+// reservedTags applies the policy to the tags of a metric, returning the tags to send. dropped is called for
+// each dropped tag. The tags are copied rather than modified.
+func (policy ReservedTagPolicy) reservedTags(name string, tags map[string]string, dropped func()) (map[string]string, error) {
+	if policy == KeepReservedTags {
+		return tags, nil
+	}
+	var kept map[string]string
+	for _, k := range sortedKeys(tags) {
+		if !isReservedTagKey(k) {
+			continue
+		}
+		if policy == RejectReservedTags {
+			return nil, fmt.Errorf("invalid tag %q for metric %s: reserved tag key, use the source argument instead", k, name)
+		}
+		if kept == nil {
+			kept = make(map[string]string, len(tags))
+			for key, value := range tags {
+				kept[key] = value
+			}
+		}
+		delete(kept, k)
+		if dropped != nil {
+			dropped()
+		}
+	}
+	if kept == nil {
+		return tags, nil
+	}
+	return kept, nil
+}
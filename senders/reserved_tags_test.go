@@ -0,0 +1,34 @@
+package senders
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReservedTags(t *testing.T) {
+	tags := map[string]string{"source": "db-2", "Host": "db-3", "env": "prod"}
+
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", MetricsPort: 50000})
+	require.NoError(t, sender.SendMetric("db.connections", 10, 1533529977, "db-1", tags))
+	assert.Equal(t, "\"db.connections\" 10 1533529977 source=\"db-1\" \"Host\"=\"db-3\" \"env\"=\"prod\" \"source\"=\"db-2\"\n",
+		handlers[metricHandler].data(), "kept by default")
+	sender.Close()
+
+	sender, handlers = newTestProxySender(t, &ProxyConfiguration{Host: "localhost", MetricsPort: 50000, ReservedTags: DropReservedTags})
+	require.NoError(t, sender.SendMetric("db.connections", 10, 1533529977, "db-1", tags))
+	assert.Equal(t, "\"db.connections\" 10 1533529977 source=\"db-1\" \"env\"=\"prod\"\n", handlers[metricHandler].data())
+	assert.Equal(t, int64(2), sender.pointsReservedTagsDropped.Count())
+	assert.Len(t, tags, 3, "the tags of the caller are not modified")
+	sender.Close()
+
+	sender, handlers = newTestProxySender(t, &ProxyConfiguration{Host: "localhost", MetricsPort: 50000, ReservedTags: RejectReservedTags})
+	err := sender.SendMetric("db.connections", 10, 1533529977, "db-1", map[string]string{"source": "db-2"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "reserved tag key")
+	assert.Empty(t, handlers[metricHandler].lines)
+	assert.Equal(t, int64(1), sender.pointsInvalid.Count())
+	require.NoError(t, sender.SendMetric("db.connections", 10, 1533529977, "db-1", map[string]string{"env": "prod"}))
+	sender.Close()
+}
@@ -0,0 +1,54 @@
+package senders
+
+import (
+	"sort"
+
+	"github.com/wavefronthq/wavefront-sdk-go/internal"
+)
+
+// RouteFunc selects the metrics route of a metric given its name and tags, returning a key of
+// ProxyConfiguration.MetricsRoutes. Metrics routed to "" or to an unknown key are sent to the default
+// metrics port. It's called for each metric sent and must be safe for concurrent use.
+type RouteFunc func(name string, tags map[string]string) (handlerKey string)
+
+// routeName returns the name of the handler of a metrics route, used to prefix its internal metrics.
+func routeName(key string) string {
+	return handlerNames[metricHandler] + "." + key
+}
+
+// routedHandler returns the handler of the route of a metric, the default metrics handler when it's not routed.
+func (sender *proxySender) routedHandler(name string, tags map[string]string) internal.ConnectionHandler {
+	if sender.route != nil {
+		if h, ok := sender.routes[sender.route(name, tags)]; ok {
+			return h
+		}
+	}
+	return sender.handlers[metricHandler]
+}
+
+// allHandlers returns the handlers of the signals, the span logs handler and the handlers of the metrics routes,
+// the latter sorted by key.
+func (sender *proxySender) allHandlers() []internal.ConnectionHandler {
+	all := make([]internal.ConnectionHandler, 0, len(sender.handlers)+len(sender.routes))
+	for _, h := range sender.handlers {
+		if h != nil {
+			all = append(all, h)
+		}
+	}
+	if sender.spanLogsHandler != nil {
+		all = append(all, sender.spanLogsHandler)
+	}
+	for _, key := range sortedRoutes(sender.routes) {
+		all = append(all, sender.routes[key])
+	}
+	return all
+}
+
+func sortedRoutes(routes map[string]internal.ConnectionHandler) []string {
+	keys := make([]string, 0, len(routes))
+	for key := range routes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,43 @@
+package senders
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouteFunc(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{
+		Host:          "localhost",
+		MetricsPort:   50000,
+		MetricsRoutes: map[string]string{"critical": "localhost:50001"},
+		RouteFunc: func(name string, tags map[string]string) string {
+			return tags["tier"]
+		},
+	})
+	sender.routes["critical"].Close()
+	critical := &fakeConnectionHandler{}
+	sender.routes["critical"] = critical
+	defer sender.Close()
+
+	require.NoError(t, sender.SendMetric("request.latency", 10, 1533529977, "localhost", map[string]string{"tier": "critical"}))
+	require.NoError(t, sender.SendDeltaCounter("request.count", 1, "localhost", map[string]string{"tier": "critical"}))
+	// unknown and empty keys fall back to the default metrics port
+	require.NoError(t, sender.SendMetric("request.latency", 20, 1533529977, "localhost", map[string]string{"tier": "batch"}))
+	require.NoError(t, sender.SendMetric("request.latency", 30, 1533529977, "localhost", nil))
+
+	assert.Equal(t, "\"request.latency\" 10 1533529977 source=\"localhost\" \"tier\"=\"critical\"\n"+
+		"\"∆request.count\" 1 source=\"localhost\" \"tier\"=\"critical\"\n", critical.data())
+	assert.Equal(t, "\"request.latency\" 20 1533529977 source=\"localhost\" \"tier\"=\"batch\"\n"+
+		"\"request.latency\" 30 1533529977 source=\"localhost\"\n", handlers[metricHandler].data())
+
+	require.NoError(t, sender.Flush())
+	assert.Equal(t, 1, critical.flushes)
+	assert.Contains(t, sender.ConnectionStatus(), "points.critical")
+}
+
+func TestMetricsRoutesValidation(t *testing.T) {
+	cfg := &ProxyConfiguration{Host: "localhost", MetricsPort: 50000, MetricsRoutes: map[string]string{"critical": ""}}
+	assert.Error(t, cfg.Validate())
+}
@@ -0,0 +1,18 @@
+package senders
+
+import (
+	"errors"
+
+	"github.com/wavefronthq/wavefront-sdk-go/histogram"
+)
+
+// SendHistogram sends the distribution of raw samples, e.g. latency measurements, without computing its centroids:
+// they're approximated by histogram.SampleCentroids, which is exact for small sets of samples, and sent with
+// SendDistribution. NaN and infinite samples are ignored.
+func SendHistogram(sender DistributionSender, name string, samples []float64, hgs map[histogram.Granularity]bool, ts int64, source string, tags map[string]string) error {
+	centroids := histogram.SampleCentroids(samples)
+	if len(centroids) == 0 {
+		return invalidData(errors.New("no samples to send in distribution " + name))
+	}
+	return sender.SendDistribution(name, centroids, hgs, ts, source, tags)
+}
@@ -0,0 +1,21 @@
+package senders
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wavefronthq/wavefront-sdk-go/histogram"
+)
+
+func TestSendHistogram(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", DistributionPort: 50000})
+	defer sender.Close()
+
+	samples := []float64{20, 35.5, 20, 12}
+	require.NoError(t, SendHistogram(sender, "request.latency", samples, histogram.Granularities(histogram.MINUTE), 1533529977, "appServer1", nil))
+	assert.Equal(t, "!M 1533529977 #1 12 #2 20 #1 35.5 \"request.latency\" source=\"appServer1\"\n", handlers[histoHandler].data())
+
+	assert.EqualError(t, SendHistogram(sender, "request.latency", nil, histogram.Granularities(histogram.MINUTE), 1533529977, "appServer1", nil),
+		"no samples to send in distribution request.latency")
+}
@@ -0,0 +1,46 @@
+package senders
+
+import (
+	"hash/fnv"
+	"math"
+	"strings"
+)
+
+// traceSampled makes a head sampling decision for the trace with the given id.
+// The decision is derived from a hash of the traceId so that all the spans of a trace
+// share the same decision and partial traces are not reported.
+// A rate of 1 or more keeps every trace, a rate of 0 or less drops them all.
+func traceSampled(traceId string, rate float64) bool {
+	if rate >= 1 {
+		return true
+	}
+	if rate <= 0 {
+		return false
+	}
+	h := fnv.New64a()
+	h.Write([]byte(strings.ToLower(traceId)))
+	return float64(h.Sum64())/math.MaxUint64 < rate
+}
+
+// spanLogsSampled makes the sampling decision for the span logs of the span with the given id.
+// It is independent of the trace sampling, which drops the span logs along with their span.
+// A rate of 1 or more keeps every span log, a rate of 0 or less drops them all.
+func spanLogsSampled(spanId string, rate float64) bool {
+	return traceSampled(spanId, rate)
+}
+
+// sampleRate returns the sampling rate of a configured rate, a rate of 0 or less (not set) disabling the sampling.
+func sampleRate(rate float64) float64 {
+	if rate <= 0 {
+		return 1
+	}
+	return rate
+}
+
+// traceSampleRate returns the sampling rate of the traces, 0 when they're all dropped.
+func traceSampleRate(rate float64, dropAll bool) float64 {
+	if dropAll {
+		return 0
+	}
+	return sampleRate(rate)
+}
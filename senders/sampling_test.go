@@ -0,0 +1,109 @@
+package senders
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTraceSampled(t *testing.T) {
+	sampled := 0
+	for i := 0; i < 10000; i++ {
+		traceId := fmt.Sprintf("%08x-9456-11e8-9eb6-%012x", i, i*7919)
+		decision := traceSampled(traceId, 0.25)
+		assert.Equal(t, decision, traceSampled(traceId, 0.25), "sampling decision is not deterministic")
+		if decision {
+			sampled++
+		}
+
+		assert.False(t, traceSampled(traceId, 0), "rate 0 should drop every trace")
+		assert.True(t, traceSampled(traceId, 1))
+	}
+	assert.InDelta(t, 2500, sampled, 250)
+}
+
+func TestSendSpanTraceSampling(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", TracingPort: 50000, TraceSampleRate: 0.5})
+	defer sender.Close()
+
+	var kept, dropped string
+	for i := 0; kept == "" || dropped == ""; i++ {
+		traceId := fmt.Sprintf("%08x-9456-11e8-9eb6-529269fb1459", i)
+		if traceSampled(traceId, 0.5) {
+			kept = traceId
+		} else {
+			dropped = traceId
+		}
+	}
+
+	for _, traceId := range []string{kept, dropped} {
+		assert.NoError(t, sender.SendSpan("getAllUsers", 0, 343500, "localhost", traceId, testSpanId, nil, nil, nil, nil))
+		assert.NoError(t, sender.SendSpan("getUser", 0, 1200, "localhost", traceId, testTraceId, nil, nil, nil, nil))
+	}
+
+	data := handlers[spanHandler].data()
+	assert.Contains(t, data, kept)
+	assert.NotContains(t, data, dropped)
+	assert.Equal(t, int64(2), sender.spansDropped.Count())
+}
+
+func TestSpanLogSampling(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", TracingPort: 50000,
+		TraceSampleRate: 0.5, SpanLogSampleRate: 0.5})
+	defer sender.Close()
+
+	var keptTrace, droppedTrace string
+	for i := 0; keptTrace == "" || droppedTrace == ""; i++ {
+		traceId := fmt.Sprintf("%08x-9456-11e8-9eb6-529269fb1459", i)
+		if traceSampled(traceId, 0.5) {
+			keptTrace = traceId
+		} else {
+			droppedTrace = traceId
+		}
+	}
+	var keptLogs, droppedLogs string
+	for i := 0; keptLogs == "" || droppedLogs == ""; i++ {
+		spanId := fmt.Sprintf("%08x-9456-11e8-9eb6-529269fb1459", i+1000)
+		if spanLogsSampled(spanId, 0.5) {
+			keptLogs = spanId
+		} else {
+			droppedLogs = spanId
+		}
+	}
+
+	spanLogs := []SpanLog{{Timestamp: 1554363517965, Fields: map[string]string{"event": "error"}}}
+	for _, spanId := range []string{keptLogs, droppedLogs} {
+		for _, traceId := range []string{keptTrace, droppedTrace} {
+			assert.NoError(t, sender.SendSpan("getAllUsers", 0, 343500, "localhost", traceId, spanId, nil, nil, nil, spanLogs))
+		}
+	}
+
+	data := handlers[spanHandler].data()
+	assert.NotContains(t, data, droppedTrace)
+	assert.Equal(t, 2, strings.Count(data, "\"getAllUsers\""))
+	assert.Equal(t, 1, strings.Count(data, `"_spanLogs"="true"`))
+	assert.Equal(t, 1, strings.Count(data, "\"spanId\":\""+keptLogs+"\""))
+	assert.NotContains(t, data, "\"spanId\":\""+droppedLogs+"\"")
+
+	assert.Equal(t, int64(2), sender.spansSampled.Count())
+	assert.Equal(t, int64(3), sender.spanLogsSampled.Count(), "2 with their dropped spans and 1 sub-sampled")
+}
+
+func TestDropAllTraces(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", TracingPort: 50000, DropAllTraces: true})
+	defer sender.Close()
+
+	for i := 0; i < 100; i++ {
+		traceId := fmt.Sprintf("%08x-9456-11e8-9eb6-529269fb1459", i)
+		assert.NoError(t, sender.SendSpan("getAllUsers", 0, 343500, "localhost", traceId, testSpanId, nil, nil, nil, nil))
+	}
+	assert.Empty(t, handlers[spanHandler].data(), "every trace is sampled out")
+	assert.Equal(t, int64(100), sender.spansSampled.Count())
+
+	sender, handlers = newTestProxySender(t, &ProxyConfiguration{Host: "localhost", TracingPort: 50000})
+	defer sender.Close()
+	assert.NoError(t, sender.SendSpan("getAllUsers", 0, 343500, "localhost", testTraceId, testSpanId, nil, nil, nil, nil))
+	assert.Contains(t, handlers[spanHandler].data(), testTraceId, "not sampled by default")
+}
@@ -0,0 +1,33 @@
+package senders
+
+import (
+	"time"
+
+	"github.com/wavefronthq/wavefront-sdk-go/internal"
+)
+
+// FlushScheduler flushes the handlers of the senders sharing it from a single goroutine and ticker, instead of
+// a flush loop per handler, reducing the goroutines of the applications creating many low-volume senders.
+// Set it with the WithFlushScheduler option or the FlushScheduler field of the ProxyConfiguration.
+//
+// The handlers are flushed one after the other on each tick, so a slow flush, e.g. a report to an unreachable
+// Wavefront service waiting for its timeout, delays the flushes of the next handlers by as much. Use a flush loop
+// per handler (the default) when the data of a sender must not wait for the others.
+type FlushScheduler struct {
+	scheduler *internal.FlushScheduler
+}
+
+// NewFlushScheduler returns a FlushScheduler flushing the handlers of its senders every interval,
+// which replaces the flush interval of the senders.
+func NewFlushScheduler(interval time.Duration) *FlushScheduler {
+	return &FlushScheduler{scheduler: internal.NewFlushScheduler(interval)}
+}
+
+// flushScheduler returns the scheduler flushing the handlers of a sender: the given one, a new one dedicated to the
+// sender with a shared flush loop, or nil for a flush loop per handler.
+func flushScheduler(scheduler *FlushScheduler, sharedLoop bool, flushInterval time.Duration) *FlushScheduler {
+	if scheduler == nil && sharedLoop {
+		return NewFlushScheduler(flushInterval)
+	}
+	return scheduler
+}
@@ -0,0 +1,55 @@
+package senders
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wavefronthq/wavefront-sdk-go/histogram"
+)
+
+func TestSharedFlushLoop(t *testing.T) {
+	var mtx sync.Mutex
+	formats := make(map[string]bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		if f := r.URL.Query().Get("f"); f != "" {
+			formats[f] = true
+		} else {
+			formats[r.URL.Path] = true
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	scheduler := NewFlushScheduler(10 * time.Millisecond)
+	url := strings.Replace(server.URL, "http://", "http://"+"DUMMY_TOKEN@", 1)
+	sender, err := NewSender(url, FlushIntervalSeconds(3600), WithFlushScheduler(scheduler))
+	require.NoError(t, err)
+	defer sender.Close()
+	// a second sender sharing the scheduler
+	other, err := NewSender(url, FlushIntervalSeconds(3600), WithFlushScheduler(scheduler))
+	require.NoError(t, err)
+	defer other.Close()
+
+	require.NoError(t, sender.SendMetric("foo.metric", 1.2, 0, "test_source", nil))
+	require.NoError(t, sender.SendDistribution("request.latency", []histogram.Centroid{{Value: 30, Count: 20}}, map[histogram.Granularity]bool{histogram.MINUTE: true}, 0, "test_source", nil))
+	require.NoError(t, sender.SendSpan("getAllUsers", 0, 343, "localhost", testTraceId, testSpanId, nil, nil, nil,
+		[]SpanLog{{Timestamp: 1552949776000, Fields: map[string]string{"event": "error"}}}))
+	require.NoError(t, other.SendEvent("event", 0, 0, "test_source", nil))
+
+	assert.Eventually(t, func() bool {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return len(formats) == 5
+	}, 5*time.Second, 10*time.Millisecond, "all the handlers are flushed despite the flush interval of an hour")
+	mtx.Lock()
+	defer mtx.Unlock()
+	assert.Equal(t, map[string]bool{"wavefront": true, "histogram": true, "trace": true, "spanLogs": true, "/api/v2/event": true}, formats)
+}
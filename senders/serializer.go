@@ -0,0 +1,121 @@
+package senders
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/wavefronthq/wavefront-sdk-go/event"
+	"github.com/wavefronthq/wavefront-sdk-go/histogram"
+)
+
+// Serializer encodes the data sent by a sender.
+// The default implementation uses the Wavefront data formats, see MetricLine, HistoLine, SpanLine,
+// SpanLogJSON and EventLine. Install a custom one with WithSerializer, e.g. to send an alternate encoding
+// accepted by a modified proxy. Each encoded value is sent as is and must include its own delimiter.
+type Serializer interface {
+	MetricLine(name string, value float64, ts int64, source string, tags map[string]string, defaultSource string) (string, error)
+	HistoLine(name string, centroids histogram.Centroids, hgs map[histogram.Granularity]bool, ts int64, source string, tags map[string]string, defaultSource string) (string, error)
+	SpanLine(name string, startMillis, durationMillis int64, source, traceId, spanId string, parents, followsFrom []string, tags []SpanTag, spanLogs []SpanLog, defaultSource string) (string, error)
+	SpanLogs(traceId, spanId string, spanLogs []SpanLog) (string, error)
+	EventLine(name string, startMillis, endMillis int64, source string, tags map[string]string, setters ...event.Option) (string, error)
+}
+
+// lineSerializer is the default Serializer, using the Wavefront data formats.
+type lineSerializer struct {
+	// formatting of distributions
+	histo histoLineOptions
+	// encode events in the JSON format of the Wavefront API
+	eventsJSON bool
+	// rewrites the metric names, nil to keep them as is
+	metricName func(string) string
+	// max size (in bytes) of a metric line, 0 for no limit, and how longer lines are handled
+	maxMetricLineBytes int
+	oversizedLines     OversizedLinePolicy
+	// called when the tags of a metric line were truncated to fit, may be nil
+	truncated func()
+	// interned tags of the metric lines, nil to format each tag
+	tags *tagCache
+	// significant digits of the metric values, see roundToPrecision. 0 for full precision.
+	precision int
+	// key of the span tag used as source of the spans sent without source, "" for none
+	spanSourceTag string
+	// trim the surrounding whitespace of the names, tag keys and tag values
+	trimWhitespace bool
+	// max duration of the spans, longer ones being rejected. 0 for no limit.
+	maxSpanDuration time.Duration
+	// what is done with the metric tags whose key is reserved, and called for each one dropped, may be nil
+	reservedTags        ReservedTagPolicy
+	reservedTagsDropped func()
+}
+
+func (s *lineSerializer) MetricLine(name string, value float64, ts int64, source string, tags map[string]string, defaultSource string) (string, error) {
+	return s.metricLine(name, floatValue(value), ts, source, tags, defaultSource)
+}
+
+func (s *lineSerializer) IntMetricLine(name string, value int64, ts int64, source string, tags map[string]string, defaultSource string) (string, error) {
+	return s.metricLine(name, intValue(value), ts, source, tags, defaultSource)
+}
+
+func (s *lineSerializer) metricLine(name string, value metricValue, ts int64, source string, tags map[string]string, defaultSource string) (string, error) {
+	if s.trimWhitespace {
+		name, tags = strings.TrimSpace(name), trimTags(tags)
+	}
+	tags, err := s.reservedTags.reservedTags(name, tags, s.reservedTagsDropped)
+	if err != nil {
+		return "", err
+	}
+	name = sanitizeMetricName(name, s.metricName)
+	if !value.isInt {
+		value.float = roundToPrecision(value.float, s.precision)
+	}
+	line, err := formatMetricLine(name, value, ts, source, tags, defaultSource, s.tags)
+	if err != nil || s.maxMetricLineBytes <= 0 || len(line) <= s.maxMetricLineBytes {
+		return line, err
+	}
+	return fitMetricLine(line, s.maxMetricLineBytes, s.oversizedLines, s.truncated, name, value, ts, source, tags, defaultSource)
+}
+
+func (s *lineSerializer) HistoLine(name string, centroids histogram.Centroids, hgs map[histogram.Granularity]bool, ts int64, source string, tags map[string]string, defaultSource string) (string, error) {
+	if s.trimWhitespace {
+		name, tags = strings.TrimSpace(name), trimTags(tags)
+	}
+	return histoLine(name, centroids, hgs, ts, source, tags, defaultSource, s.histo)
+}
+
+func (s *lineSerializer) SpanLine(name string, startMillis, durationMillis int64, source, traceId, spanId string, parents, followsFrom []string, tags []SpanTag, spanLogs []SpanLog, defaultSource string) (string, error) {
+	if s.trimWhitespace {
+		name, tags = strings.TrimSpace(name), trimSpanTags(tags)
+	}
+	if s.maxSpanDuration > 0 && durationMillis > s.maxSpanDuration.Milliseconds() {
+		return "", fmt.Errorf("invalid duration %d for span %s: duration exceeds the max of %v", durationMillis, name, s.maxSpanDuration)
+	}
+	if source == "" && s.spanSourceTag != "" {
+		source = spanTagValue(tags, s.spanSourceTag)
+	}
+	return SpanLine(name, startMillis, durationMillis, source, traceId, spanId, parents, followsFrom, tags, spanLogs, defaultSource)
+}
+
+func (s *lineSerializer) SpanLogs(traceId, spanId string, spanLogs []SpanLog) (string, error) {
+	return SpanLogJSON(traceId, spanId, spanLogs)
+}
+
+func (s *lineSerializer) EventLine(name string, startMillis, endMillis int64, source string, tags map[string]string, setters ...event.Option) (string, error) {
+	if s.trimWhitespace {
+		name, tags = strings.TrimSpace(name), trimTags(tags)
+	}
+	if s.eventsJSON {
+		return EventLineJSON(name, startMillis, endMillis, source, tags, setters...)
+	}
+	return EventLine(name, startMillis, endMillis, source, tags, setters...)
+}
+
+// spanTagValue returns the value of the first span tag with the given key, "" when there's none.
+func spanTagValue(tags []SpanTag, key string) string {
+	for _, tag := range tags {
+		if tag.Key == key {
+			return tag.Value
+		}
+	}
+	return ""
+}
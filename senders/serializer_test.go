@@ -0,0 +1,96 @@
+package senders
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pipeSerializer encodes metrics as pipe separated values and everything else with the default serializer.
+type pipeSerializer struct {
+	lineSerializer
+}
+
+func (s *pipeSerializer) MetricLine(name string, value float64, ts int64, source string, tags map[string]string, defaultSource string) (string, error) {
+	return name + "|" + strconv.FormatFloat(value, 'f', -1, 64) + "|" + strconv.FormatInt(ts, 10) + "\n", nil
+}
+
+func TestCustomSerializer(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{
+		Host:        "localhost",
+		MetricsPort: 50000,
+		EventsPort:  50001,
+		Serializer:  &pipeSerializer{},
+	})
+	defer sender.Close()
+
+	require.NoError(t, sender.SendMetric("new-york.power.usage", 42422, 1533529977, "", nil))
+	require.NoError(t, sender.SendDeltaCounter("lambda.thumbnail.generate", 10, "", nil))
+	assert.Equal(t, "new-york.power.usage|42422|1533529977\n∆lambda.thumbnail.generate|10|0\n", handlers[metricHandler].data())
+
+	require.NoError(t, sender.SendEvent("event", 1533531013, 0, "localhost", nil))
+	assert.Equal(t, "@Event 1533531013000 1533531013001 \"event\" host=\"localhost\"\n", handlers[eventHandler].data())
+}
+
+// failingSpanLogsSerializer rejects all the span logs and encodes everything else with the default serializer.
+type failingSpanLogsSerializer struct {
+	lineSerializer
+}
+
+func (s *failingSpanLogsSerializer) SpanLogs(traceId, spanId string, spanLogs []SpanLog) (string, error) {
+	return "", errors.New("invalid span logs")
+}
+
+func TestSendSpansInvalidSpanLogs(t *testing.T) {
+	spanLogs := []SpanLog{{Timestamp: 1554363517965, Fields: map[string]string{"event": "error"}}}
+	spans := []Span{
+		{Name: "getAllUsers", DurationMillis: 343500, Source: "localhost", TraceId: testTraceId, SpanId: testSpanId},
+		{Name: "getUser", DurationMillis: 1000, Source: "localhost", TraceId: testTraceId, SpanId: testSpanId, SpanLogs: spanLogs},
+	}
+
+	proxy, handlers := newTestProxySender(t, &ProxyConfiguration{
+		Host:        "localhost",
+		TracingPort: 50000,
+		Serializer:  &failingSpanLogsSerializer{},
+	})
+	defer proxy.Close()
+	errs := proxy.SendSpans(spans)
+	assert.NoError(t, errs[0])
+	assert.EqualError(t, errs[1], "invalid span logs")
+	assert.True(t, errors.Is(errs[1], ErrInvalidData))
+	assert.Equal(t, 1, strings.Count(handlers[spanHandler].data(), "\n"), "the span with invalid span logs is not sent")
+	assert.Equal(t, int64(1), proxy.spansInvalid.Count())
+	assert.Equal(t, int64(1), proxy.spanLogsInvalid.Count())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	s, err := NewSender(strings.Replace(server.URL, "http://", "http://DUMMY_TOKEN@", 1),
+		FlushIntervalSeconds(3600), WithSerializer(&failingSpanLogsSerializer{}))
+	require.NoError(t, err)
+	defer s.Close()
+	direct := s.(*wavefrontSender)
+	errs = direct.SendSpans(spans)
+	assert.NoError(t, errs[0])
+	assert.EqualError(t, errs[1], "invalid span logs")
+	assert.Equal(t, 1, direct.spanHandler.PendingLines(), "the span with invalid span logs is not buffered")
+	assert.Equal(t, int64(1), direct.spansInvalid.Count())
+	assert.Equal(t, int64(1), direct.spanLogsInvalid.Count())
+}
+
+func TestLineSerializerEvents(t *testing.T) {
+	line, err := (&lineSerializer{}).EventLine("event", 1533531013, 0, "localhost", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "@Event 1533531013000 1533531013001 \"event\" host=\"localhost\"\n", line)
+
+	line, err = (&lineSerializer{eventsJSON: true}).EventLine("event", 1533531013, 0, "localhost", nil)
+	require.NoError(t, err)
+	assert.Contains(t, line, "\"name\":\"event\"")
+}
@@ -0,0 +1,64 @@
+package senders
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// notifySignals, stopSignals and osExit install the signal handlers and exit the process, overridden in tests.
+var (
+	notifySignals = signal.Notify
+	stopSignals   = signal.Stop
+	osExit        = os.Exit
+)
+
+// FlushOnSignal closes the sender when the process receives one of the given signals, SIGINT and SIGTERM by default,
+// flushing its buffered data before exiting the process with the conventional 128+signal status. It spares the
+// programs without their own shutdown hook from losing their final data on Ctrl-C.
+// The returned function removes the handler, the signals then getting their previous behavior back; it can be
+// called several times. Programs handling the signals themselves should close the sender there instead.
+func FlushOnSignal(sender Sender, signals ...os.Signal) (cancel func()) {
+	if len(signals) == 0 {
+		signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	notifySignals(ch, signals...)
+
+	go func() {
+		select {
+		case sig := <-ch:
+			select {
+			case <-done:
+				// cancelled while the signal was pending
+				return
+			default:
+			}
+			stopSignals(ch)
+			if err := closeWithError(sender); err != nil {
+				log.Println(err)
+			}
+			osExit(signalExitCode(sig))
+		case <-done:
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			stopSignals(ch)
+			close(done)
+		})
+	}
+}
+
+// signalExitCode returns the exit status of a process terminated by a signal, 128+signal as set by the shells.
+func signalExitCode(sig os.Signal) int {
+	if s, ok := sig.(syscall.Signal); ok {
+		return 128 + int(s)
+	}
+	return 1
+}
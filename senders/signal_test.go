@@ -0,0 +1,78 @@
+package senders
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// closingSender records when it's closed.
+type closingSender struct {
+	Sender
+	closed chan struct{}
+}
+
+func (s *closingSender) CloseWithError() error {
+	close(s.closed)
+	return nil
+}
+
+// stubSignals replaces the signal handling with the returned channels, receiving the channel of the installed
+// handler and the exit code of the process.
+func stubSignals(t *testing.T) (notified chan chan<- os.Signal, exited chan int, restore func()) {
+	notified, exited = make(chan chan<- os.Signal, 1), make(chan int, 1)
+	notify, stop, exit := notifySignals, stopSignals, osExit
+	notifySignals = func(c chan<- os.Signal, sig ...os.Signal) {
+		assert.Equal(t, []os.Signal{os.Interrupt, syscall.SIGTERM}, sig)
+		notified <- c
+	}
+	stopSignals = func(c chan<- os.Signal) {}
+	osExit = func(code int) { exited <- code }
+	return notified, exited, func() {
+		notifySignals, stopSignals, osExit = notify, stop, exit
+	}
+}
+
+func TestFlushOnSignal(t *testing.T) {
+	notified, exited, restore := stubSignals(t)
+	defer restore()
+
+	sender := &closingSender{closed: make(chan struct{})}
+	cancel := FlushOnSignal(sender)
+	defer cancel()
+
+	(<-notified) <- syscall.SIGTERM
+	select {
+	case code := <-exited:
+		assert.Equal(t, 128+int(syscall.SIGTERM), code)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "the process didn't exit on SIGTERM")
+	}
+	select {
+	case <-sender.closed:
+	default:
+		assert.Fail(t, "the sender wasn't closed before exiting")
+	}
+}
+
+func TestFlushOnSignalCancel(t *testing.T) {
+	notified, exited, restore := stubSignals(t)
+	defer restore()
+
+	sender := &closingSender{closed: make(chan struct{})}
+	cancel := FlushOnSignal(sender)
+	c := <-notified
+	cancel()
+	cancel()
+
+	c <- os.Interrupt
+	select {
+	case <-exited:
+		assert.Fail(t, "the process exited after the handler was cancelled")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,44 @@
+package senders
+
+import (
+	"strconv"
+	"sync/atomic"
+)
+
+// SignalType identifies a type of data sent to Wavefront.
+type SignalType int
+
+const (
+	MetricSignal SignalType = iota
+	HistogramSignal
+	SpanSignal
+	EventSignal
+)
+
+// String returns the name of the signal, also used as prefix of its internal metrics.
+func (s SignalType) String() string {
+	if s < 0 || int(s) >= handlersCount {
+		return "SignalType(" + strconv.Itoa(int(s)) + ")"
+	}
+	return handlerNames[s]
+}
+
+// signalSwitch holds the runtime enabled state of the signals of a sender, all enabled by default.
+type signalSwitch struct {
+	disabled [handlersCount]int32
+}
+
+func (s *signalSwitch) set(signal SignalType, enabled bool) {
+	if signal < 0 || int(signal) >= handlersCount {
+		return
+	}
+	var disabled int32
+	if !enabled {
+		disabled = 1
+	}
+	atomic.StoreInt32(&s.disabled[signal], disabled)
+}
+
+func (s *signalSwitch) enabled(signal SignalType) bool {
+	return atomic.LoadInt32(&s.disabled[signal]) == 0
+}
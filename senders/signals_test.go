@@ -0,0 +1,80 @@
+package senders
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wavefronthq/wavefront-sdk-go/histogram"
+)
+
+func TestSetSignalEnabled(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{
+		Host:             "localhost",
+		MetricsPort:      30000,
+		DistributionPort: 40000,
+		TracingPort:      50000,
+		EventsPort:       60000,
+	})
+	defer sender.Close()
+
+	centroids := []histogram.Centroid{{Value: 30, Count: 20}}
+	hgs := map[histogram.Granularity]bool{histogram.MINUTE: true}
+	spanLogs := []SpanLog{{Timestamp: 1554363517965, Fields: map[string]string{"event": "error"}}}
+	sendAll := func() {
+		require.NoError(t, sender.SendMetric("new-york.power.usage", 42422, 1533529977, "test_source", nil))
+		require.NoError(t, sender.SendDeltaCounter("lambda.thumbnail.generate", 10, "test_source", nil))
+		require.NoError(t, sender.SendRawLine("\"raw.metric\" 1 source=\"test_source\""))
+		require.NoError(t, sender.SendDistribution("request.latency", centroids, hgs, 1533529977, "test_source", nil))
+		require.NoError(t, sender.SendSpan("getAllUsers", 0, 343500, "test_source", testTraceId, testSpanId, nil, nil, nil, spanLogs))
+		assert.Equal(t, []error{nil}, sender.SendSpans([]Span{{Name: "getUser", Source: "test_source", TraceId: testTraceId, SpanId: testSpanId}}))
+		require.NoError(t, sender.SendEvent("event", 1533531013, 0, "test_source", nil))
+	}
+	lines := func() []int {
+		return []int{len(handlers[metricHandler].lines), len(handlers[histoHandler].lines),
+			len(handlers[spanHandler].lines), len(handlers[eventHandler].lines)}
+	}
+
+	for _, signal := range []SignalType{MetricSignal, HistogramSignal, SpanSignal, EventSignal} {
+		sender.SetSignalEnabled(signal, false)
+		before := lines()
+		sendAll()
+		after := lines()
+		for other := range before {
+			if SignalType(other) == signal {
+				assert.Equal(t, before[other], after[other], "%v should be suppressed", signal)
+			} else {
+				assert.Greater(t, after[other], before[other], "%v should be sent while %v is disabled", SignalType(other), signal)
+			}
+		}
+		sender.SetSignalEnabled(signal, true)
+
+		before = lines()
+		sendAll()
+		after = lines()
+		for other := range before {
+			assert.Greater(t, after[other], before[other], "%v should be sent once %v is enabled again", SignalType(other), signal)
+		}
+	}
+
+	assert.Equal(t, int64(3), sender.pointsSuppressed.Count())
+	assert.Equal(t, int64(1), sender.histogramsSuppressed.Count())
+	assert.Equal(t, int64(2), sender.spansSuppressed.Count())
+	assert.Equal(t, int64(1), sender.spanLogsSuppressed.Count())
+	assert.Equal(t, int64(1), sender.eventsSuppressed.Count())
+}
+
+func TestSetSignalEnabledDirect(t *testing.T) {
+	sender, err := NewSender("http://localhost:1", WithRegistryReportingDisabled())
+	require.NoError(t, err)
+	defer sender.Close()
+
+	sender.(SignalEnabler).SetSignalEnabled(SpanSignal, false)
+	require.NoError(t, sender.SendSpan("getAllUsers", 0, 343500, "test_source", testTraceId, testSpanId, nil, nil, nil, nil))
+	require.NoError(t, sender.SendMetric("new-york.power.usage", 42422, 0, "test_source", nil))
+	assert.Equal(t, 0, sender.(PendingLinesReporter).PendingLines()["spans"])
+	assert.Equal(t, 1, sender.(PendingLinesReporter).PendingLines()["points"])
+
+	// out of range signals are ignored
+	sender.(SignalEnabler).SetSignalEnabled(SignalType(42), false)
+}
@@ -0,0 +1,31 @@
+package senders
+
+import "os"
+
+// EnvSource is the environment variable of the default source of the data sent, read by all the senders
+// configured without Source nor SourceFunc.
+const EnvSource = "WAVEFRONT_SOURCE"
+
+// osHostname returns the hostname of the machine, overridden in tests.
+var osHostname = os.Hostname
+
+// resolveSource returns the default source of the data sent without source, the first non-empty of:
+// source, the result of sourceFunc (when it doesn't fail), the WAVEFRONT_SOURCE environment variable,
+// the hostname of the machine and fallback.
+func resolveSource(source string, sourceFunc func() (string, error), fallback string) string {
+	if source != "" {
+		return source
+	}
+	if sourceFunc != nil {
+		if source, err := sourceFunc(); err == nil && source != "" {
+			return source
+		}
+	}
+	if source := os.Getenv(EnvSource); source != "" {
+		return source
+	}
+	if hostname, err := osHostname(); err == nil && hostname != "" {
+		return hostname
+	}
+	return fallback
+}
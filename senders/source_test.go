@@ -0,0 +1,51 @@
+package senders
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveSource(t *testing.T) {
+	defer func(hostname func() (string, error)) {
+		osHostname = hostname
+	}(osHostname)
+	defer os.Unsetenv(EnvSource)
+
+	hostname := func() (string, error) {
+		return "host-1", nil
+	}
+	failing := func() (string, error) {
+		return "", errors.New("no hostname")
+	}
+	osHostname = hostname
+	os.Setenv(EnvSource, "env-source")
+
+	assert.Equal(t, "explicit", resolveSource("explicit", failing, "fallback"))
+	assert.Equal(t, "from-func", resolveSource("", func() (string, error) { return "from-func", nil }, "fallback"))
+	assert.Equal(t, "env-source", resolveSource("", failing, "fallback"), "a failing func falls back")
+	assert.Equal(t, "env-source", resolveSource("", func() (string, error) { return "", nil }, "fallback"), "so does an empty source")
+
+	os.Unsetenv(EnvSource)
+	assert.Equal(t, "host-1", resolveSource("", nil, "fallback"))
+
+	osHostname = failing
+	assert.Equal(t, "fallback", resolveSource("", nil, "fallback"))
+}
+
+func TestSenderSource(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", MetricsPort: 50000, Source: "app-1"})
+	defer sender.Close()
+
+	assert.Equal(t, "app-1", sender.Source())
+	require.NoError(t, sender.SendMetric("requests", 1, 1533529977, "", nil))
+	assert.Equal(t, "\"requests\" 1 1533529977 source=\"app-1\"\n", handlers[metricHandler].data())
+
+	direct, err := NewSender("http://localhost:8080", Source("app-2"))
+	require.NoError(t, err)
+	defer direct.Close()
+	assert.Equal(t, "app-2", direct.(SourceReporter).Source())
+}
@@ -0,0 +1,37 @@
+package senders
+
+import "fmt"
+
+// SendSpanWithIDs sends a tracing span whose ids are integers, as carried by Zipkin, Jaeger or OpenTelemetry:
+// a 128-bit trace id split in its high and low 64 bits, and 64-bit span ids. The ids are formatted as the UUID
+// strings expected by SendSpan, see TraceIdUUID and SpanIdUUID. A trace id with zero high bits is a 64-bit trace id.
+func SendSpanWithIDs(sender SpanSender, name string, startMillis, durationMillis int64, source string, traceIdHigh, traceIdLow uint64,
+	spanId uint64, parents, followsFrom []uint64, tags []SpanTag, spanLogs []SpanLog) error {
+	return sender.SendSpan(name, startMillis, durationMillis, source, TraceIdUUID(traceIdHigh, traceIdLow), SpanIdUUID(spanId),
+		spanIdUUIDs(parents), spanIdUUIDs(followsFrom), tags, spanLogs)
+}
+
+// TraceIdUUID returns the UUID string of a 128-bit trace id given its high and low 64 bits, the 16 bytes of the id
+// being in big-endian order: the high bits are the first 16 hex digits, e.g. 0x7b3bf470945611e8 and
+// 0x9eb6529269fb1459 give "7b3bf470-9456-11e8-9eb6-529269fb1459".
+func TraceIdUUID(high, low uint64) string {
+	return fmt.Sprintf("%08x-%04x-%04x-%04x-%012x", high>>32, (high>>16)&0xffff, high&0xffff, low>>48, low&0xffffffffffff)
+}
+
+// SpanIdUUID returns the UUID string of a 64-bit span id, the id being the low 64 bits of the UUID,
+// e.g. 0x9eb6529269fb1459 gives "00000000-0000-0000-9eb6-529269fb1459".
+func SpanIdUUID(id uint64) string {
+	return TraceIdUUID(0, id)
+}
+
+// spanIdUUIDs returns the UUID strings of the given span ids, nil when there's none.
+func spanIdUUIDs(ids []uint64) []string {
+	if len(ids) == 0 {
+		return nil
+	}
+	uuids := make([]string, len(ids))
+	for i, id := range ids {
+		uuids[i] = SpanIdUUID(id)
+	}
+	return uuids
+}
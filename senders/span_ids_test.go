@@ -0,0 +1,32 @@
+package senders
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTraceIdUUID(t *testing.T) {
+	assert.Equal(t, testTraceId, TraceIdUUID(0x7b3bf470945611e8, 0x9eb6529269fb1459))
+	// the high bits come first, each byte in big-endian order
+	assert.Equal(t, "01020304-0506-0708-090a-0b0c0d0e0f10", TraceIdUUID(0x0102030405060708, 0x090a0b0c0d0e0f10))
+	// the ids are zero padded
+	assert.Equal(t, "00000000-0000-0000-0000-000000000000", TraceIdUUID(0, 0))
+	assert.Equal(t, "00000000-0000-0001-0000-000000000002", TraceIdUUID(1, 2))
+	assert.Equal(t, "ffffffff-ffff-ffff-ffff-ffffffffffff", TraceIdUUID(^uint64(0), ^uint64(0)))
+
+	assert.Equal(t, "00000000-0000-0000-0000-00000000002a", SpanIdUUID(42))
+	assert.True(t, isUUIDFormat(SpanIdUUID(^uint64(0))))
+}
+
+func TestSendSpanWithIDs(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", TracingPort: 50000})
+	defer sender.Close()
+
+	require.NoError(t, SendSpanWithIDs(sender, "getAllUsers", 1552949776000, 343, "localhost", 0x7b3bf470945611e8, 0x9eb6529269fb1459,
+		0x0313bafe945711e8, []uint64{1}, nil, nil, nil))
+	assert.Equal(t, "\"getAllUsers\" source=\"localhost\" traceId=7b3bf470-9456-11e8-9eb6-529269fb1459 "+
+		"spanId=00000000-0000-0000-0313-bafe945711e8 parent=00000000-0000-0000-0000-000000000001 1552949776000 343\n",
+		handlers[spanHandler].data())
+}
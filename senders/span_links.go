@@ -0,0 +1,50 @@
+package senders
+
+// SpanLink references a span of the same or of another trace, with optional attributes,
+// e.g. the spans of the messages consumed by a batch job. Links complement the parents
+// and followsFrom references of a span for the relationships they can't express.
+type SpanLink struct {
+	TraceId    string
+	SpanId     string
+	Attributes map[string]string
+}
+
+const (
+	spanLinkTraceIdField = "link.traceId"
+	spanLinkSpanIdField  = "link.spanId"
+	spanLinkFieldPrefix  = "link."
+)
+
+// WithLinks returns a copy of the span linked to the given spans. The Wavefront span format having no links,
+// each link is encoded as:
+//   - a followsFrom reference to the linked span, relating both spans in the Wavefront UI,
+//   - a span log at the start of the span, with the "link.traceId" and "link.spanId" fields holding the
+//     linked span and a "link.<key>" field per attribute.
+//
+// The span logs are subject to the sender's span logs settings (DisableSpanLogs, SpanLogSampleRate),
+// the followsFrom references being always sent.
+func (s Span) WithLinks(links ...SpanLink) Span {
+	if len(links) == 0 {
+		return s
+	}
+	// copy the slices shared with the caller before appending to them
+	s.FollowsFrom = append(make([]string, 0, len(s.FollowsFrom)+len(links)), s.FollowsFrom...)
+	s.SpanLogs = append(make([]SpanLog, 0, len(s.SpanLogs)+len(links)), s.SpanLogs...)
+	for _, link := range links {
+		s.FollowsFrom = append(s.FollowsFrom, link.SpanId)
+		s.SpanLogs = append(s.SpanLogs, link.spanLog(s.StartMillis))
+	}
+	return s
+}
+
+// spanLog returns the span log encoding the link of a span started at startMillis.
+func (l SpanLink) spanLog(startMillis int64) SpanLog {
+	fields := make(map[string]string, len(l.Attributes)+2)
+	for k, v := range l.Attributes {
+		fields[spanLinkFieldPrefix+k] = v
+	}
+	fields[spanLinkTraceIdField] = l.TraceId
+	fields[spanLinkSpanIdField] = l.SpanId
+	// span logs timestamps are in microseconds
+	return SpanLog{Timestamp: startMillis * 1000, Fields: fields}
+}
@@ -0,0 +1,35 @@
+package senders
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpanWithLinks(t *testing.T) {
+	const linkedTraceId = "11111111-2222-3333-4444-555555555555"
+	const linkedSpanId = "66666666-7777-8888-9999-000000000000"
+
+	followsFrom := []string{testSpanId}
+	span := Span{Name: "batch", StartMillis: 1533531013, DurationMillis: 1, Source: "localhost",
+		TraceId: testTraceId, SpanId: testSpanId, FollowsFrom: followsFrom}
+	linked := span.WithLinks(SpanLink{TraceId: linkedTraceId, SpanId: linkedSpanId, Attributes: map[string]string{"queue": "orders"}})
+
+	assert.Equal(t, []string{testSpanId}, followsFrom, "the caller's slices are left as is")
+	assert.Nil(t, span.SpanLogs)
+	assert.Equal(t, []string{testSpanId, linkedSpanId}, linked.FollowsFrom)
+	assert.Equal(t, []SpanLog{{Timestamp: 1533531013000, Fields: map[string]string{
+		"link.traceId": linkedTraceId,
+		"link.spanId":  linkedSpanId,
+		"link.queue":   "orders",
+	}}}, linked.SpanLogs)
+	assert.Equal(t, span, span.WithLinks())
+
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", TracingPort: 50000})
+	defer sender.Close()
+	assert.Equal(t, []error{nil}, sender.SendSpans([]Span{linked}))
+
+	data := handlers[spanHandler].data()
+	assert.Contains(t, data, " followsFrom="+linkedSpanId+" ")
+	assert.Contains(t, data, "\"link.traceId\":\""+linkedTraceId+"\"")
+}
@@ -0,0 +1,89 @@
+package senders
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// spanLogBatchSize is the number of buffered span logs beyond which a batch is sent without waiting for the next flush.
+const spanLogBatchSize = 1000
+
+// spanLogBatcher buffers the encoded span logs of the spans sent, writing them in a single batch per flush
+// instead of once per span. Each encoded span log carries its trace and span IDs, so it stays associated
+// with its span when written after it.
+type spanLogBatcher struct {
+	send        func(batch string, count int) error
+	flushTicker *time.Ticker
+	done        chan struct{}
+
+	mtx  sync.Mutex
+	logs []string
+}
+
+func newSpanLogBatcher(flushInterval time.Duration, send func(batch string, count int) error) *spanLogBatcher {
+	return &spanLogBatcher{
+		send:        send,
+		flushTicker: time.NewTicker(flushInterval),
+	}
+}
+
+func (b *spanLogBatcher) start() {
+	b.done = make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-b.flushTicker.C:
+				if err := b.flush(); err != nil {
+					log.Println(err)
+				}
+			case <-b.done:
+				return
+			}
+		}
+	}()
+}
+
+// stop stops the periodic flushes and flushes the pending span logs.
+func (b *spanLogBatcher) stop() error {
+	b.flushTicker.Stop()
+	if b.done != nil {
+		close(b.done)
+	}
+	return b.flush()
+}
+
+// add buffers the encoded span logs of a span, sending the batch when it's full.
+func (b *spanLogBatcher) add(logs string) error {
+	b.mtx.Lock()
+	b.logs = append(b.logs, logs)
+	full := len(b.logs) >= spanLogBatchSize
+	b.mtx.Unlock()
+
+	if full {
+		return b.flush()
+	}
+	return nil
+}
+
+// pending returns the number of buffered span logs.
+func (b *spanLogBatcher) pending() int {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return len(b.logs)
+}
+
+// flush sends the buffered span logs in a single batch.
+func (b *spanLogBatcher) flush() error {
+	b.mtx.Lock()
+	logs := b.logs
+	b.logs = nil
+	b.mtx.Unlock()
+
+	if len(logs) == 0 {
+		return nil
+	}
+	return b.send(strings.Join(logs, ""), len(logs))
+}
@@ -0,0 +1,110 @@
+package senders
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchSpanLogs(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", TracingPort: 50000, BatchSpanLogs: true})
+	defer sender.Close()
+	spans := handlers[spanHandler]
+
+	spanLogs := []SpanLog{{Timestamp: 1554363517965, Fields: map[string]string{"event": "error"}}}
+	require.NoError(t, sender.SendSpan("getAllUsers", 0, 343500, "localhost", testTraceId, testSpanId, nil, nil, nil, spanLogs))
+	require.NoError(t, sender.SendSpan("getAllUsers", 0, 343500, "localhost", testTraceId, testSpanId, nil, nil, nil, nil))
+	errs := sender.SendSpans([]Span{
+		{Name: "getUser", DurationMillis: 1000, TraceId: testTraceId, SpanId: testSpanId, SpanLogs: spanLogs},
+	})
+	assert.Equal(t, []error{nil}, errs)
+
+	require.Len(t, spans.lines, 3, "only the span lines are written")
+	assert.NotContains(t, spans.data(), "\"logs\"")
+	assert.Equal(t, 2, sender.PendingLines()["spans"])
+
+	require.NoError(t, sender.FlushSignal(SpanSignal))
+	require.Len(t, spans.lines, 4, "the span logs are written in a single batch")
+	assert.Equal(t, 2, strings.Count(spans.lines[3], "\"traceId\":\""+testTraceId+"\""))
+	assert.Equal(t, 0, sender.PendingLines()["spans"])
+	assert.Equal(t, int64(1), sender.spanLogBatches.Count())
+	assert.Equal(t, int64(2), sender.spanLogsBatched.Count())
+
+	// nothing left to write
+	require.NoError(t, sender.Flush())
+	assert.Len(t, spans.lines, 4)
+}
+
+func TestSpanLogBatcherFull(t *testing.T) {
+	var batches []int
+	b := newSpanLogBatcher(time.Hour, func(batch string, count int) error {
+		batches = append(batches, count)
+		return nil
+	})
+	for i := 0; i < spanLogBatchSize+1; i++ {
+		require.NoError(t, b.add("{}\n"))
+	}
+	assert.Equal(t, []int{spanLogBatchSize}, batches)
+	assert.Equal(t, 1, b.pending())
+
+	require.NoError(t, b.stop())
+	assert.Equal(t, []int{spanLogBatchSize, 1}, batches)
+}
+
+func TestSpanLogsPort(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", TracingPort: 50000, SpanLogsPort: 50001})
+	sender.spanLogsHandler.Close()
+	logs := &fakeConnectionHandler{}
+	sender.spanLogsHandler = logs
+	defer sender.Close()
+	spans := handlers[spanHandler]
+
+	spanLogs := []SpanLog{{Timestamp: 1554363517965, Fields: map[string]string{"event": "error"}}}
+	require.NoError(t, sender.SendSpan("getAllUsers", 0, 343500, "localhost", testTraceId, testSpanId, nil, nil, nil, spanLogs))
+	errs := sender.SendSpans([]Span{
+		{Name: "getUser", DurationMillis: 1, Source: "localhost", TraceId: testTraceId, SpanId: testSpanId, SpanLogs: spanLogs},
+		{Name: "getUser", DurationMillis: 1, Source: "localhost", TraceId: testTraceId, SpanId: testSpanId},
+	})
+	assert.Equal(t, []error{nil, nil}, errs)
+
+	require.Len(t, spans.lines, 2)
+	assert.NotContains(t, spans.data(), "\"traceId\":", "the span logs are not written to the tracing port")
+	assert.Equal(t, 3, strings.Count(spans.data(), "\n"))
+	require.Len(t, logs.lines, 2)
+	for _, line := range logs.lines {
+		assert.True(t, strings.HasPrefix(line, "{\"traceId\":\""+testTraceId+"\""), line)
+	}
+
+	require.NoError(t, sender.FlushSignal(SpanSignal))
+	assert.Equal(t, 1, logs.flushes)
+	assert.Contains(t, sender.ConnectionStatus(), "span_logs")
+}
+
+func TestSendSpansDroppedSpanLogs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	s, err := NewSender(strings.Replace(server.URL, "http://", "http://DUMMY_TOKEN@", 1),
+		FlushIntervalSeconds(3600), MaxBufferSize(1))
+	require.NoError(t, err)
+	defer s.Close()
+	sender := s.(*wavefrontSender)
+
+	spanLogs := []SpanLog{{Timestamp: 1554363517965, Fields: map[string]string{"event": "error"}}}
+	errs := sender.SendSpans([]Span{
+		{Name: "getAllUsers", DurationMillis: 343500, Source: "localhost", TraceId: testTraceId, SpanId: testSpanId},
+		{Name: "getUser", DurationMillis: 1000, Source: "localhost", TraceId: testTraceId, SpanId: testSpanId, SpanLogs: spanLogs},
+	})
+	for _, err := range errs {
+		assert.EqualError(t, err, "buffer full, dropping 2 lines")
+	}
+	assert.Equal(t, int64(2), sender.spansDropped.Count())
+	assert.Equal(t, int64(1), sender.spanLogsDropped.Count(), "the span logs are dropped along with their spans")
+	assert.Equal(t, 0, sender.spanLogHandler.PendingLines())
+}
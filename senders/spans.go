@@ -0,0 +1,99 @@
+package senders
+
+import "fmt"
+
+// SpanTagDedupPolicy controls how span tags with duplicate keys are handled before a span is formatted.
+type SpanTagDedupPolicy int
+
+const (
+	// SpanTagsLastWins keeps only the last tag of each key. This is the default.
+	SpanTagsLastWins SpanTagDedupPolicy = iota
+	// SpanTagsFirstWins keeps only the first tag of each key.
+	SpanTagsFirstWins
+	// SpanTagsKeepAll sends all the tags, including the ones with repeated keys.
+	SpanTagsKeepAll
+)
+
+// dedupSpanTags removes the tags with duplicate keys according to the given policy.
+// The kept tags retain their relative order.
+func dedupSpanTags(tags []SpanTag, policy SpanTagDedupPolicy) []SpanTag {
+	if policy == SpanTagsKeepAll || len(tags) < 2 {
+		return tags
+	}
+
+	keep := make(map[string]int, len(tags))
+	for i, tag := range tags {
+		if _, ok := keep[tag.Key]; !ok || policy == SpanTagsLastWins {
+			keep[tag.Key] = i
+		}
+	}
+	if len(keep) == len(tags) {
+		return tags
+	}
+
+	deduped := make([]SpanTag, 0, len(keep))
+	for i, tag := range tags {
+		if keep[tag.Key] == i {
+			deduped = append(deduped, tag)
+		}
+	}
+	return deduped
+}
+
+// Span kinds, the allowed values of the span.kind tag.
+const (
+	SpanKindClient   = "client"
+	SpanKindServer   = "server"
+	SpanKindProducer = "producer"
+	SpanKindConsumer = "consumer"
+	SpanKindInternal = "internal"
+)
+
+// SpanStatusCode is the status of the operation of a span.
+type SpanStatusCode int
+
+const (
+	// SpanStatusUnset is the default status, sending no status tag.
+	SpanStatusUnset SpanStatusCode = iota
+	// SpanStatusOK marks the operation as successful.
+	SpanStatusOK
+	// SpanStatusError marks the operation as failed, the span being reported as an error span.
+	SpanStatusError
+)
+
+// Keys of the tags describing the kind and status of a span.
+const (
+	SpanKindTagKey              = "span.kind"
+	SpanStatusCodeTagKey        = "otel.status_code"
+	SpanStatusDescriptionTagKey = "otel.status_description"
+	SpanErrorTagKey             = "error"
+)
+
+// SpanKind returns the tag setting the kind of a span, one of the SpanKind* values.
+func SpanKind(kind string) (SpanTag, error) {
+	switch kind {
+	case SpanKindClient, SpanKindServer, SpanKindProducer, SpanKindConsumer, SpanKindInternal:
+		return SpanTag{Key: SpanKindTagKey, Value: kind}, nil
+	default:
+		return SpanTag{}, fmt.Errorf("invalid span kind %q", kind)
+	}
+}
+
+// SpanStatus returns the tags setting the status of a span and its optional description.
+// An error status also sets the error tag, marking the span as an error span.
+func SpanStatus(code SpanStatusCode, message string) []SpanTag {
+	var tags []SpanTag
+	switch code {
+	case SpanStatusOK:
+		tags = append(tags, SpanTag{Key: SpanStatusCodeTagKey, Value: "OK"})
+	case SpanStatusError:
+		tags = append(tags, SpanTag{Key: SpanStatusCodeTagKey, Value: "ERROR"}, SpanTag{Key: SpanErrorTagKey, Value: "true"})
+	default:
+		return nil
+	}
+	// blank tag values are rejected
+	if message != "" {
+		tags = append(tags, SpanTag{Key: SpanStatusDescriptionTagKey, Value: message})
+	}
+	return tags
+}
@@ -0,0 +1,94 @@
+package senders
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDedupSpanTags(t *testing.T) {
+	tags := []SpanTag{
+		{Key: "user", Value: "foo"},
+		{Key: "env", Value: "test"},
+		{Key: "user", Value: "bar"},
+	}
+
+	tests := []struct {
+		policy   SpanTagDedupPolicy
+		expected []SpanTag
+	}{
+		{SpanTagsLastWins, []SpanTag{{Key: "env", Value: "test"}, {Key: "user", Value: "bar"}}},
+		{SpanTagsFirstWins, []SpanTag{{Key: "user", Value: "foo"}, {Key: "env", Value: "test"}}},
+		{SpanTagsKeepAll, tags},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, dedupSpanTags(tags, test.policy), "policy %d", test.policy)
+	}
+
+	assert.Nil(t, dedupSpanTags(nil, SpanTagsLastWins))
+}
+
+func TestSpanKind(t *testing.T) {
+	tag, err := SpanKind(SpanKindServer)
+	assert.NoError(t, err)
+	assert.Equal(t, SpanTag{Key: "span.kind", Value: "server"}, tag)
+
+	for _, kind := range []string{SpanKindClient, SpanKindProducer, SpanKindConsumer, SpanKindInternal} {
+		_, err := SpanKind(kind)
+		assert.NoError(t, err, kind)
+	}
+
+	_, err = SpanKind("Server")
+	assert.Error(t, err)
+	_, err = SpanKind("")
+	assert.Error(t, err)
+}
+
+func TestSpanStatus(t *testing.T) {
+	assert.Nil(t, SpanStatus(SpanStatusUnset, "ignored"))
+	assert.Equal(t, []SpanTag{{Key: "otel.status_code", Value: "OK"}}, SpanStatus(SpanStatusOK, ""))
+	assert.Equal(t, []SpanTag{
+		{Key: "otel.status_code", Value: "ERROR"},
+		{Key: "error", Value: "true"},
+		{Key: "otel.status_description", Value: "connection refused"},
+	}, SpanStatus(SpanStatusError, "connection refused"))
+}
+
+func TestSpanSourceTag(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", TracingPort: 50000, SpanSourceTag: "host"})
+	defer sender.Close()
+	sender.defaultSource = "default"
+
+	tags := []SpanTag{{Key: "host", Value: "web-1"}}
+	require.NoError(t, sender.SendSpan("getUser", 1533529977, 1, "", testTraceId, testSpanId, nil, nil, tags, nil))
+	require.NoError(t, sender.SendSpan("getUser", 1533529977, 1, "explicit", testTraceId, testSpanId, nil, nil, tags, nil))
+	require.NoError(t, sender.SendSpan("getUser", 1533529977, 1, "", testTraceId, testSpanId, nil, nil, nil, nil))
+
+	ids := " traceId=" + testTraceId + " spanId=" + testSpanId
+	assert.Equal(t, "\"getUser\" source=\"web-1\""+ids+" \"host\"=\"web-1\" 1533529977 1\n"+
+		"\"getUser\" source=\"explicit\""+ids+" \"host\"=\"web-1\" 1533529977 1\n"+
+		"\"getUser\" source=\"default\""+ids+" 1533529977 1\n", handlers[spanHandler].data())
+}
+
+func TestSpanDuration(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{
+		Host:            "localhost",
+		TracingPort:     50000,
+		MaxSpanDuration: time.Hour,
+	})
+	defer sender.Close()
+
+	assert.EqualError(t, sender.SendSpan("getUser", 1533529977000, -5, "localhost", testTraceId, testSpanId, nil, nil, nil, nil),
+		"invalid duration -5 for span getUser: duration must not be negative")
+	assert.EqualError(t, sender.SendSpan("getUser", 1533529977000, 7200000, "localhost", testTraceId, testSpanId, nil, nil, nil, nil),
+		"invalid duration 7200000 for span getUser: duration exceeds the max of 1h0m0s")
+	require.NoError(t, sender.SendSpan("getUser", 1533529977000, 3600000, "localhost", testTraceId, testSpanId, nil, nil, nil, nil))
+	require.NoError(t, sender.SendSpan("getUser", 1533529977000, 0, "localhost", testTraceId, testSpanId, nil, nil, nil, nil))
+	assert.Len(t, handlers[spanHandler].lines, 2)
+	assert.Equal(t, int64(2), sender.spansInvalid.Count())
+
+	_, err := SpanLine("getUser", 1533529977000, -1, "localhost", testTraceId, testSpanId, nil, nil, nil, nil, "")
+	assert.Error(t, err)
+}
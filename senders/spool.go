@@ -0,0 +1,33 @@
+package senders
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/wavefronthq/wavefront-sdk-go/internal"
+)
+
+// defaultSpoolMaxBytes is the default max size of the spool file of each signal.
+const defaultSpoolMaxBytes = 64 * 1024 * 1024
+
+// createSpoolDir creates the spool directory if it doesn't exist, when set.
+func createSpoolDir(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("unable to create spool directory %s: %w", dir, err)
+	}
+	return nil
+}
+
+// newSpool returns the spool of the handler named name in dir, nil when dir isn't set.
+func newSpool(dir string, maxBytes int64, name string) *internal.Spool {
+	if dir == "" {
+		return nil
+	}
+	if maxBytes <= 0 {
+		maxBytes = defaultSpoolMaxBytes
+	}
+	return internal.NewSpool(dir, name, maxBytes)
+}
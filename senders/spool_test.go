@@ -0,0 +1,64 @@
+package senders
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxySpoolDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wavefront-spool")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	spoolDir := filepath.Join(dir, "spool")
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	port := lis.Addr().(*net.TCPAddr).Port
+	lis.Close()
+
+	// the metrics sent while the proxy is unreachable are spilled
+	sender, err := NewProxySender(&ProxyConfiguration{Host: "localhost", MetricsPort: port, SpoolDir: spoolDir, DisableInternalMetrics: true})
+	require.NoError(t, err)
+	require.NoError(t, sender.SendMetric("foo.metric", 1.2, 1533529977, "test_source", nil))
+	sender.Close()
+	spooled, err := ioutil.ReadFile(filepath.Join(spoolDir, "points.spool"))
+	require.NoError(t, err)
+	assert.Equal(t, "\"foo.metric\" 1.2 1533529977 source=\"test_source\"\n", string(spooled))
+
+	// and replayed after a successful flush once it's reachable
+	lis, err = net.Listen("tcp", lis.Addr().String())
+	require.NoError(t, err)
+	defer lis.Close()
+	received := make(chan string)
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		data, _ := ioutil.ReadAll(conn)
+		received <- string(data)
+	}()
+
+	sender, err = NewProxySender(&ProxyConfiguration{Host: "localhost", MetricsPort: port, SpoolDir: spoolDir, DisableInternalMetrics: true})
+	require.NoError(t, err)
+	require.NoError(t, sender.SendMetric("bar.metric", 3, 1533529977, "test_source", nil))
+	require.NoError(t, sender.Flush())
+	sender.Close()
+	assert.Equal(t, "\"bar.metric\" 3 1533529977 source=\"test_source\"\n\"foo.metric\" 1.2 1533529977 source=\"test_source\"\n", <-received)
+	_, err = os.Stat(filepath.Join(spoolDir, "points.spool"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestSpoolOption(t *testing.T) {
+	cfg := &configuration{}
+	Spool("/var/spool/wavefront", 1024)(cfg)
+	assert.Equal(t, "/var/spool/wavefront", cfg.SpoolDir)
+	assert.Equal(t, int64(1024), cfg.SpoolMaxBytes)
+	assert.Nil(t, newSpool("", 0, "points"))
+}
@@ -0,0 +1,110 @@
+package senders
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/wavefronthq/wavefront-sdk-go/event"
+	"github.com/wavefronthq/wavefront-sdk-go/histogram"
+)
+
+// tagError reports a tag rejected by the tag validator of a sender.
+type tagError struct {
+	key   string
+	value string
+	err   error
+}
+
+func (e *tagError) Error() string {
+	return fmt.Sprintf("invalid tag %q=%q: %v", e.key, e.value, e.err)
+}
+
+func (e *tagError) Unwrap() error {
+	return e.err
+}
+
+// tagValidatingSerializer checks the tags of each point, distribution, span and event with validate
+// before encoding them with the wrapped Serializer.
+type tagValidatingSerializer struct {
+	Serializer
+	validate func(key, value string) error
+}
+
+func (s *tagValidatingSerializer) validateTags(tags map[string]string) error {
+	for _, k := range sortedKeys(tags) {
+		if err := s.validate(k, tags[k]); err != nil {
+			return &tagError{key: k, value: tags[k], err: err}
+		}
+	}
+	return nil
+}
+
+func (s *tagValidatingSerializer) MetricLine(name string, value float64, ts int64, source string, tags map[string]string, defaultSource string) (string, error) {
+	if err := s.validateTags(tags); err != nil {
+		return "", err
+	}
+	return s.Serializer.MetricLine(name, value, ts, source, tags, defaultSource)
+}
+
+func (s *tagValidatingSerializer) HistoLine(name string, centroids histogram.Centroids, hgs map[histogram.Granularity]bool, ts int64, source string, tags map[string]string, defaultSource string) (string, error) {
+	if err := s.validateTags(tags); err != nil {
+		return "", err
+	}
+	return s.Serializer.HistoLine(name, centroids, hgs, ts, source, tags, defaultSource)
+}
+
+func (s *tagValidatingSerializer) SpanLine(name string, startMillis, durationMillis int64, source, traceId, spanId string, parents, followsFrom []string, tags []SpanTag, spanLogs []SpanLog, defaultSource string) (string, error) {
+	for _, tag := range tags {
+		if err := s.validate(tag.Key, tag.Value); err != nil {
+			return "", &tagError{key: tag.Key, value: tag.Value, err: err}
+		}
+	}
+	return s.Serializer.SpanLine(name, startMillis, durationMillis, source, traceId, spanId, parents, followsFrom, tags, spanLogs, defaultSource)
+}
+
+func (s *tagValidatingSerializer) EventLine(name string, startMillis, endMillis int64, source string, tags map[string]string, setters ...event.Option) (string, error) {
+	if err := s.validateTags(tags); err != nil {
+		return "", err
+	}
+	return s.Serializer.EventLine(name, startMillis, endMillis, source, tags, setters...)
+}
+
+// ErrInvalidData is matched, using errors.Is, by the errors returned when sending data the sender rejects
+// as invalid, e.g. a blank name, a tag refused by the TagValidator or a line exceeding MaxMetricLineBytes.
+// Sending the same data again fails the same way, so these sends are not retried.
+var ErrInvalidData = errors.New("invalid data")
+
+// invalidDataError reports data rejected as invalid by a sender, wrapping the reason.
+type invalidDataError struct {
+	err error
+}
+
+func (e *invalidDataError) Error() string {
+	return e.err.Error()
+}
+
+func (e *invalidDataError) Unwrap() error {
+	return e.err
+}
+
+func (e *invalidDataError) Is(target error) bool {
+	return target == ErrInvalidData
+}
+
+// invalidData marks err as rejecting invalid data.
+func invalidData(err error) error {
+	if err == nil || errors.Is(err, ErrInvalidData) {
+		return err
+	}
+	return &invalidDataError{err: err}
+}
+
+// invalidResult returns the error to report for data the serializer rejected with err:
+// nil when err is a tag validation error and invalid tags are skipped silently, err marked as invalid data otherwise.
+func invalidResult(err error, skipInvalidTags bool) error {
+	var te *tagError
+	if skipInvalidTags && errors.As(err, &te) {
+		return nil
+	}
+	return invalidData(err)
+}
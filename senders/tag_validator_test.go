@@ -0,0 +1,76 @@
+package senders
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wavefronthq/wavefront-sdk-go/histogram"
+)
+
+var errUppercaseKey = errors.New("tag keys must be lowercase")
+
+func lowercaseKeys(key, value string) error {
+	if strings.ToLower(key) != key {
+		return errUppercaseKey
+	}
+	return nil
+}
+
+func TestTagValidator(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{
+		Host:             "localhost",
+		MetricsPort:      50000,
+		DistributionPort: 50001,
+		TracingPort:      50002,
+		EventsPort:       50003,
+		TagValidator:     lowercaseKeys,
+	})
+	defer sender.Close()
+
+	require.NoError(t, sender.SendMetric("foo.metric", 1.2, 1533529977, "test_source", map[string]string{"env": "test"}))
+	err := sender.SendMetric("foo.metric", 1.2, 1533529977, "test_source", map[string]string{"env": "test", "Region": "us-west"})
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, errUppercaseKey))
+	assert.Equal(t, "invalid tag \"Region\"=\"us-west\": tag keys must be lowercase", err.Error())
+	assert.Equal(t, "\"foo.metric\" 1.2 1533529977 source=\"test_source\" \"env\"=\"test\"\n", handlers[metricHandler].data())
+	assert.Equal(t, int64(1), sender.pointsInvalid.Count())
+
+	assert.Error(t, sender.SendDeltaCounter("foo.count", 1, "test_source", map[string]string{"Env": "test"}))
+	hgs := map[histogram.Granularity]bool{histogram.MINUTE: true}
+	assert.Error(t, sender.SendDistribution("request.latency", []histogram.Centroid{{Value: 30, Count: 20}}, hgs, 0, "test_source", map[string]string{"Env": "test"}))
+	assert.Error(t, sender.SendSpan("getAllUsers", 0, 343500, "localhost", "7b3bf470-9456-11e8-9eb6-529269fb1459",
+		"0313bafe-9457-11e8-9eb6-529269fb1459", nil, nil, []SpanTag{{Key: "Application", Value: "Wavefront"}}, nil))
+	assert.Error(t, sender.SendEvent("event", 1533531013, 0, "localhost", map[string]string{"Env": "test"}))
+	for _, h := range []int{histoHandler, spanHandler, eventHandler} {
+		assert.Empty(t, handlers[h].data(), handlerNames[h])
+	}
+}
+
+func TestSkipInvalidTags(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{
+		Host:            "localhost",
+		MetricsPort:     50000,
+		TagValidator:    lowercaseKeys,
+		SkipInvalidTags: true,
+	})
+	defer sender.Close()
+
+	assert.NoError(t, sender.SendMetric("foo.metric", 1.2, 1533529977, "test_source", map[string]string{"Region": "us-west"}))
+	assert.Empty(t, handlers[metricHandler].data())
+	assert.Equal(t, int64(1), sender.pointsInvalid.Count())
+
+	// other invalid data still returns an error
+	assert.Error(t, sender.SendMetric("", 1.2, 1533529977, "test_source", nil))
+}
+
+func TestTagValidatorOption(t *testing.T) {
+	sender, err := NewSender("http://localhost:2878", TagValidator(lowercaseKeys), FlushIntervalSeconds(3600))
+	require.NoError(t, err)
+	defer sender.Close()
+
+	assert.Error(t, sender.SendMetric("foo.metric", 1.2, 0, "test_source", map[string]string{"Region": "us-west"}))
+	assert.Equal(t, 0, sender.(PendingLinesReporter).PendingLines()["points"])
+}
@@ -0,0 +1,43 @@
+package senders
+
+import (
+	"fmt"
+	"time"
+)
+
+// timestampError reports a metric timestamp too far in the future, which usually denotes a unit mismatch.
+type timestampError struct {
+	ts      int64
+	horizon time.Duration
+}
+
+func (e *timestampError) Error() string {
+	return fmt.Sprintf("invalid timestamp %d: more than %v in the future, check its unit", e.ts, e.horizon)
+}
+
+// metricTimestamp returns the timestamp to send for a metric: the current time (in seconds) when ts <= 0, ts otherwise.
+// An error is returned when ts is more than horizon after now.
+func metricTimestamp(ts int64, now time.Time, horizon time.Duration) (int64, error) {
+	if ts <= 0 {
+		return now.Unix(), nil
+	}
+	if timestampTime(ts).After(now.Add(horizon)) {
+		return 0, &timestampError{ts: ts, horizon: horizon}
+	}
+	return ts, nil
+}
+
+// timestampTime converts a timestamp to a time, guessing from its magnitude whether it's in
+// seconds, milliseconds, microseconds or nanoseconds, as the Wavefront proxy does.
+func timestampTime(ts int64) time.Time {
+	switch {
+	case ts < 1e12:
+		return time.Unix(ts, 0)
+	case ts < 1e15:
+		return time.Unix(0, ts*int64(time.Millisecond))
+	case ts < 1e18:
+		return time.Unix(0, ts*int64(time.Microsecond))
+	default:
+		return time.Unix(0, ts)
+	}
+}
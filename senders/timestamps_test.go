@@ -0,0 +1,122 @@
+package senders
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricTimestamp(t *testing.T) {
+	now := time.Date(2019, 3, 10, 1, 59, 30, 0, time.UTC)
+	horizon := time.Hour
+
+	tests := []struct {
+		ts       int64
+		expected int64
+		valid    bool
+	}{
+		{0, now.Unix(), true},
+		{-1, now.Unix(), true},
+		{-now.Unix(), now.Unix(), true},
+		{1, 1, true},
+		{now.Unix(), now.Unix(), true},
+		{now.Add(horizon).Unix(), now.Add(horizon).Unix(), true},
+		{now.Add(horizon).Unix() + 1, 0, false},
+		{now.Add(horizon).UnixNano() / int64(time.Millisecond), now.Add(horizon).UnixNano() / int64(time.Millisecond), true},
+		{now.Add(horizon).UnixNano()/int64(time.Millisecond) + 1, 0, false},
+		{now.UnixNano() / int64(time.Microsecond), now.UnixNano() / int64(time.Microsecond), true},
+		{now.UnixNano(), now.UnixNano(), true},
+		{now.Add(horizon).UnixNano() + 1, 0, false},
+		// the largest timestamp in seconds, absurdly far in the future
+		{999999999999, 0, false},
+	}
+
+	for _, test := range tests {
+		ts, err := metricTimestamp(test.ts, now, horizon)
+		if test.valid {
+			assert.NoError(t, err, test.ts)
+		} else {
+			assert.Error(t, err, test.ts)
+		}
+		assert.Equal(t, test.expected, ts, test.ts)
+	}
+}
+
+type fixedClock struct {
+	now time.Time
+}
+
+func (c fixedClock) Now() time.Time {
+	return c.now
+}
+
+func TestSendMetricTimestamp(t *testing.T) {
+	now := time.Date(2019, 3, 10, 1, 59, 30, 0, time.UTC)
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{
+		Host:             "localhost",
+		MetricsPort:      50000,
+		Clock:            fixedClock{now: now},
+		TimestampHorizon: time.Minute,
+	})
+	defer sender.Close()
+
+	require.NoError(t, sender.SendMetric("new-york.power.usage", 42422, 0, "localhost", nil))
+	require.NoError(t, sender.SendDeltaCounter("lambda.thumbnail.generate", 10, "localhost", nil))
+	assert.Error(t, sender.SendMetric("new-york.power.usage", 42422, now.Add(time.Hour).Unix(), "localhost", nil))
+
+	assert.Equal(t, "\"new-york.power.usage\" 42422 1552183170 source=\"localhost\"\n"+
+		"\"∆lambda.thumbnail.generate\" 10 source=\"localhost\"\n", handlers[metricHandler].data())
+	assert.Equal(t, int64(1), sender.pointsInvalid.Count())
+}
+
+func TestSendMetricNow(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{
+		Host:        "localhost",
+		MetricsPort: 50000,
+		Clock:       fixedClock{now: time.Unix(1533529977, 0)},
+	})
+	defer sender.Close()
+
+	require.NoError(t, sender.SendMetric("foo.metric", 1.2, 0, "test_source", nil))
+	require.NoError(t, sender.SendMetricNow("foo.metric", 1.2, "test_source", nil))
+	assert.Equal(t, []string{
+		"\"foo.metric\" 1.2 1533529977 source=\"test_source\"\n",
+		"\"foo.metric\" 1.2 source=\"test_source\"\n",
+	}, handlers[metricHandler].lines)
+	assert.Error(t, sender.SendMetricNow("", 1.2, "test_source", nil))
+}
+
+func TestUptime(t *testing.T) {
+	clock := &fixedClock{now: time.Unix(1533529977, 0)}
+	gauge := uptime(clock)
+	assert.Equal(t, float64(0), gauge())
+
+	clock.now = clock.now.Add(90 * time.Second)
+	assert.Equal(t, float64(90), gauge())
+}
+
+func TestLastFlushAge(t *testing.T) {
+	clock := &fixedClock{now: time.Unix(1533529977, 0)}
+	ages := newFlushAges(clock)
+	var flushes int
+	onFlush := ages.onFlush(MetricSignal, func(signal SignalType, sent, failed int, err error) {
+		flushes++
+	})
+	gauge := ages.gauge(MetricSignal)
+	assert.Equal(t, float64(0), gauge())
+
+	clock.now = clock.now.Add(30 * time.Second)
+	assert.Equal(t, float64(30), gauge())
+	onFlush(10, 0, nil)
+	assert.Equal(t, float64(0), gauge())
+
+	clock.now = clock.now.Add(45 * time.Second)
+	onFlush(0, 10, errors.New("connection refused"))
+	onFlush(0, 0, nil)
+	assert.Equal(t, float64(45), gauge())
+	assert.Equal(t, float64(75), ages.gauge(SpanSignal)())
+	assert.Equal(t, 3, flushes)
+}
@@ -0,0 +1,36 @@
+package senders
+
+import (
+	"crypto/tls"
+)
+
+// tlsConfig returns the TLS configuration of the direct sender's HTTPS requests, nil to use the default one.
+func tlsConfig(cfg *configuration) *tls.Config {
+	if cfg.TLSConfig != nil {
+		return cfg.TLSConfig.Clone()
+	}
+	if len(cfg.TLSClientCertificates) == 0 && cfg.TLSRootCAs == nil && !cfg.TLSInsecureSkipVerify {
+		return nil
+	}
+	return &tls.Config{
+		Certificates:       cfg.TLSClientCertificates,
+		RootCAs:            cfg.TLSRootCAs,
+		InsecureSkipVerify: cfg.TLSInsecureSkipVerify,
+	}
+}
+
+// proxyTLSConfig returns the TLS configuration of the connections to the proxy, nil when they're not encrypted.
+func proxyTLSConfig(cfg *ProxyConfiguration) *tls.Config {
+	if cfg.TLSConfig != nil {
+		return cfg.TLSConfig.Clone()
+	}
+	if !cfg.TLS {
+		return nil
+	}
+	return &tls.Config{
+		ServerName:         cfg.TLSServerName,
+		Certificates:       cfg.TLSClientCertificates,
+		RootCAs:            cfg.TLSRootCAs,
+		InsecureSkipVerify: cfg.TLSInsecureSkipVerify,
+	}
+}
@@ -0,0 +1,234 @@
+package senders
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTLSTestServer starts an HTTPS server with a self-signed certificate, requesting a client certificate
+// and sending the number of certificates presented by each client on the returned channel.
+func newTLSTestServer() (*httptest.Server, chan int) {
+	clientCerts := make(chan int, 10)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientCerts <- len(r.TLS.PeerCertificates)
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	server.StartTLS()
+	return server, clientCerts
+}
+
+func sendAndDrain(t *testing.T, server *httptest.Server, opts ...Option) error {
+	opts = append(opts, FlushIntervalSeconds(3600))
+	sender, err := NewSender(strings.Replace(server.URL, "https://", "https://DUMMY_TOKEN@", 1), opts...)
+	require.NoError(t, err)
+	defer sender.Close()
+
+	require.NoError(t, sender.SendMetric("foo.metric", 1.2, 0, "test_source", nil))
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	return sender.(Drainer).Drain(ctx)
+}
+
+func TestTLSRootCAs(t *testing.T) {
+	server, clientCerts := newTLSTestServer()
+	defer server.Close()
+
+	// the self-signed certificate isn't trusted by default, the handshake error is a report error
+	err := sendAndDrain(t, server)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "certificate")
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	require.NoError(t, sendAndDrain(t, server, TLSRootCAs(pool)))
+	assert.Equal(t, 0, <-clientCerts)
+}
+
+func TestTLSInsecureSkipVerify(t *testing.T) {
+	server, clientCerts := newTLSTestServer()
+	defer server.Close()
+
+	require.NoError(t, sendAndDrain(t, server, TLSInsecureSkipVerify(true)))
+	assert.Equal(t, 0, <-clientCerts)
+}
+
+func TestTLSClientCertificate(t *testing.T) {
+	server, clientCerts := newTLSTestServer()
+	defer server.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	// the test server certificate doubles as client certificate
+	require.NoError(t, sendAndDrain(t, server, TLSRootCAs(pool), TLSClientCertificate(server.TLS.Certificates[0])))
+	assert.Equal(t, 1, <-clientCerts)
+}
+
+func TestWithTLSConfig(t *testing.T) {
+	server, clientCerts := newTLSTestServer()
+	defer server.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	// the test server certificate is valid for example.com, but not for another server name
+	config := &tls.Config{RootCAs: pool, ServerName: "example.com", MinVersion: tls.VersionTLS12}
+	require.NoError(t, sendAndDrain(t, server, WithTLSConfig(config)))
+	assert.Equal(t, 0, <-clientCerts)
+
+	err := sendAndDrain(t, server, WithTLSConfig(&tls.Config{RootCAs: pool, ServerName: "wavefront.test"}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "certificate")
+
+	// the other TLS options are ignored
+	err = sendAndDrain(t, server, WithTLSConfig(&tls.Config{}), TLSRootCAs(pool))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "certificate")
+}
+
+// tlsProxyConn is a connection accepted by a test proxy: the data received and the number of client certificates.
+type tlsProxyConn struct {
+	data        string
+	clientCerts int
+}
+
+// newTLSTestProxy starts a TLS listener with the self-signed certificate of newTLSTestServer, requesting a client
+// certificate, and returns it with a pool trusting its certificate and the connections it accepted.
+func newTLSTestProxy(t *testing.T) (*net.TCPAddr, *x509.CertPool, chan tlsProxyConn, func()) {
+	server, _ := newTLSTestServer()
+	server.Close()
+	lis, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: server.TLS.Certificates, ClientAuth: tls.RequestClientCert})
+	require.NoError(t, err)
+
+	received := make(chan tlsProxyConn)
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			data, _ := ioutil.ReadAll(conn)
+			clientCerts := len(conn.(*tls.Conn).ConnectionState().PeerCertificates)
+			received <- tlsProxyConn{data: string(data), clientCerts: clientCerts}
+		}
+	}()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	return lis.Addr().(*net.TCPAddr), pool, received, func() { lis.Close() }
+}
+
+func TestProxyTLS(t *testing.T) {
+	addr, pool, received, closeProxy := newTLSTestProxy(t)
+	defer closeProxy()
+
+	for name, cfg := range map[string]ProxyConfiguration{
+		"root CAs":    {TLSRootCAs: pool},
+		"skip verify": {TLSInsecureSkipVerify: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg.Host = "127.0.0.1"
+			cfg.MetricsPort = addr.Port
+			cfg.TLS = true
+			cfg.DisableInternalMetrics = true
+			sender, err := NewProxySender(&cfg)
+			require.NoError(t, err)
+
+			require.NoError(t, sender.SendMetric("foo.metric", 1.2, 1533529977, "test_source", nil))
+			sender.Close()
+			assert.Equal(t, tlsProxyConn{data: "\"foo.metric\" 1.2 1533529977 source=\"test_source\"\n"}, <-received)
+		})
+	}
+}
+
+func TestProxyTLSClientCertificate(t *testing.T) {
+	addr, pool, received, closeProxy := newTLSTestProxy(t)
+	defer closeProxy()
+	server, _ := newTLSTestServer()
+	server.Close()
+
+	sender, err := NewProxySender(&ProxyConfiguration{
+		Host:                   "127.0.0.1",
+		MetricsPort:            addr.Port,
+		TLS:                    true,
+		TLSRootCAs:             pool,
+		TLSClientCertificates:  []tls.Certificate{server.TLS.Certificates[0]},
+		DisableInternalMetrics: true,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, sender.SendMetric("foo.metric", 1.2, 1533529977, "test_source", nil))
+	sender.Close()
+	assert.Equal(t, 1, (<-received).clientCerts)
+}
+
+func TestProxyTLSConfig(t *testing.T) {
+	addr, pool, received, closeProxy := newTLSTestProxy(t)
+	defer closeProxy()
+
+	sender, err := NewProxySender(&ProxyConfiguration{
+		Host:                   "127.0.0.1",
+		MetricsPort:            addr.Port,
+		TLSConfig:              &tls.Config{RootCAs: pool, ServerName: "example.com", MinVersion: tls.VersionTLS12},
+		DisableInternalMetrics: true,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, sender.SendMetric("foo.metric", 1.2, 1533529977, "test_source", nil))
+	sender.Close()
+	assert.Equal(t, "\"foo.metric\" 1.2 1533529977 source=\"test_source\"\n", (<-received).data)
+}
+
+func TestProxyTLSURL(t *testing.T) {
+	addr, pool, received, closeProxy := newTLSTestProxy(t)
+	defer closeProxy()
+
+	sender, err := NewSender(fmt.Sprintf("proxy://127.0.0.1:%d?tls=true", addr.Port), TLSRootCAs(pool),
+		WithRegistryReportingDisabled())
+	require.NoError(t, err)
+
+	require.NoError(t, sender.SendMetric("foo.metric", 1.2, 1533529977, "test_source", nil))
+	sender.Close()
+	assert.Equal(t, "\"foo.metric\" 1.2 1533529977 source=\"test_source\"\n", (<-received).data)
+
+	_, err = NewSender("proxy://127.0.0.1:2878?tls=maybe")
+	assert.EqualError(t, err, "invalid proxy URL of 127.0.0.1:2878: invalid tls maybe")
+}
+
+func TestProxyTLSServerName(t *testing.T) {
+	addr, pool, received, closeProxy := newTLSTestProxy(t)
+	defer closeProxy()
+
+	// the test certificate is valid for example.com
+	sender, err := NewProxySender(&ProxyConfiguration{
+		Host:                   "127.0.0.1",
+		MetricsPort:            addr.Port,
+		TLS:                    true,
+		TLSRootCAs:             pool,
+		TLSServerName:          "example.com",
+		DisableInternalMetrics: true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, sender.SendMetric("foo.metric", 1.2, 1533529977, "test_source", nil))
+	sender.Close()
+	assert.Equal(t, "\"foo.metric\" 1.2 1533529977 source=\"test_source\"\n", (<-received).data)
+
+	// the address of a Unix socket has no host to verify the certificate against
+	cfg := &ProxyConfiguration{UnixSocket: "/var/run/wavefront.sock", TLS: true}
+	assert.EqualError(t, cfg.Validate(), "TLS over a Unix socket requires TLSServerName, or a ServerName in TLSConfig")
+	cfg.TLSServerName = "proxy.corp"
+	assert.NoError(t, cfg.Validate())
+	cfg = &ProxyConfiguration{UnixSocket: "/var/run/wavefront.sock", TLSConfig: &tls.Config{InsecureSkipVerify: true}}
+	assert.NoError(t, cfg.Validate())
+}
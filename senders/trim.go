@@ -0,0 +1,34 @@
+package senders
+
+import "strings"
+
+// trimTags returns tags with the surrounding whitespace of their keys and values trimmed,
+// tags itself when there's nothing to trim.
+func trimTags(tags map[string]string) map[string]string {
+	for k, v := range tags {
+		if strings.TrimSpace(k) != k || strings.TrimSpace(v) != v {
+			trimmed := make(map[string]string, len(tags))
+			for k, v := range tags {
+				trimmed[strings.TrimSpace(k)] = strings.TrimSpace(v)
+			}
+			return trimmed
+		}
+	}
+	return tags
+}
+
+// trimSpanTags returns the span tags with the surrounding whitespace of their keys and values trimmed,
+// tags itself when there's nothing to trim.
+func trimSpanTags(tags []SpanTag) []SpanTag {
+	for i, tag := range tags {
+		if strings.TrimSpace(tag.Key) != tag.Key || strings.TrimSpace(tag.Value) != tag.Value {
+			trimmed := make([]SpanTag, len(tags))
+			copy(trimmed, tags[:i])
+			for j := i; j < len(tags); j++ {
+				trimmed[j] = SpanTag{Key: strings.TrimSpace(tags[j].Key), Value: strings.TrimSpace(tags[j].Value)}
+			}
+			return trimmed
+		}
+	}
+	return tags
+}
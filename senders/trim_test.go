@@ -0,0 +1,55 @@
+package senders
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wavefronthq/wavefront-sdk-go/histogram"
+)
+
+func TestTrimWhitespace(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{
+		Host:             "localhost",
+		MetricsPort:      50000,
+		DistributionPort: 50001,
+		TracingPort:      50002,
+		TrimWhitespace:   true,
+	})
+	defer sender.Close()
+
+	require.NoError(t, sender.SendMetric("cpu.load ", 0.5, 1533529977, "localhost", map[string]string{" env": "prod "}))
+	require.NoError(t, sender.SendMetric("cpu.load", 0.5, 1533529977, "localhost", map[string]string{"env": "prod"}))
+	metrics := handlers[metricHandler].lines
+	require.Len(t, metrics, 2)
+	assert.Equal(t, "\"cpu.load\" 0.5 1533529977 source=\"localhost\" \"env\"=\"prod\"\n", metrics[0])
+	assert.Equal(t, metrics[0], metrics[1])
+
+	centroids := []histogram.Centroid{{Value: 30, Count: 20}}
+	require.NoError(t, sender.SendDistributionG(" request.latency", centroids, 1533529977, "localhost", nil, histogram.MINUTE))
+	assert.Equal(t, "!M 1533529977 #20 30 \"request.latency\" source=\"localhost\"\n", handlers[histoHandler].data())
+
+	require.NoError(t, sender.SendSpan("getUser\t", 1533529977000, 343, "localhost", testTraceId, testSpanId, nil, nil,
+		[]SpanTag{{Key: "application", Value: "Wavefront"}, {Key: "service ", Value: " users"}}, nil))
+	assert.Contains(t, handlers[spanHandler].data(), "\"getUser\" source=\"localhost\"")
+	assert.Contains(t, handlers[spanHandler].data(), "\"application\"=\"Wavefront\" \"service\"=\"users\"")
+}
+
+func TestTrimWhitespaceDisabled(t *testing.T) {
+	sender, handlers := newTestProxySender(t, &ProxyConfiguration{Host: "localhost", MetricsPort: 50000})
+	defer sender.Close()
+
+	// the space is sanitized, the metric still being a separate series
+	require.NoError(t, sender.SendMetric("cpu.load ", 0.5, 1533529977, "localhost", nil))
+	assert.Equal(t, "\"cpu.load-\" 0.5 1533529977 source=\"localhost\"\n", handlers[metricHandler].data())
+}
+
+func TestTrimTags(t *testing.T) {
+	tags := map[string]string{"env": "prod"}
+	assert.Equal(t, tags, trimTags(tags))
+	assert.Equal(t, map[string]string{"env": "prod", "region": "us-west"}, trimTags(map[string]string{"env": "prod", " region ": "us-west\n"}))
+
+	spanTags := []SpanTag{{Key: "env", Value: "prod"}, {Key: "region ", Value: "us-west"}}
+	assert.Equal(t, []SpanTag{{Key: "env", Value: "prod"}, {Key: "region", Value: "us-west"}}, trimSpanTags(spanTags))
+	assert.Equal(t, "region ", spanTags[1].Key)
+}
@@ -21,9 +21,35 @@ type SpanLogs struct {
 	Logs    []SpanLog `json:"logs"`
 }
 
+// Span holds the arguments of a single SendSpan call, used to send spans in batches.
+type Span struct {
+	Name           string
+	StartMillis    int64
+	DurationMillis int64
+	Source         string
+	TraceId        string
+	SpanId         string
+	Parents        []string
+	FollowsFrom    []string
+	Tags           []SpanTag
+	SpanLogs       []SpanLog
+}
+
+// DistributionPoint holds the arguments of a single SendDistribution call, used to send distributions in batches.
+type DistributionPoint struct {
+	Name          string
+	Centroids     []histogram.Centroid
+	Granularities map[histogram.Granularity]bool
+	Timestamp     int64
+	Source        string
+	Tags          map[string]string
+}
+
 // MetricSender Interface for sending metrics to Wavefront
 type MetricSender interface {
 	// Sends a single metric to Wavefront with optional timestamp and tags.
+	// A timestamp <= 0 is replaced by the current time of the sender's clock. Timestamps too far in the future,
+	// usually denoting a unit mismatch, are rejected (see TimestampHorizon).
 	SendMetric(name string, value float64, ts int64, source string, tags map[string]string) error
 
 	// Sends a delta counter (counter aggregated at the Wavefront service) to Wavefront.
@@ -46,11 +72,22 @@ type SpanSender interface {
 	// Sends a tracing span to Wavefront.
 	// traceId, spanId, parentIds and preceding spanIds are expected to be UUID strings.
 	// parents and preceding spans can be empty for a root span.
-	// span tag keys can be repeated (example: "user"="foo" and "user"="bar")
+	// span tags with repeated keys (example: "user"="foo" and "user"="bar") are de-duplicated
+	// according to the sender's SpanTagDedup policy, keeping the last tag of each key by default.
 	// span logs are currently omitted
 	SendSpan(name string, startMillis, durationMillis int64, source, traceId, spanId string, parents, followsFrom []string, tags []SpanTag, spanLogs []SpanLog) error
 }
 
+// RawLineSender Interface for sending pre-formatted lines to Wavefront
+type RawLineSender interface {
+	// Sends a line already in the Wavefront metrics data format, as is, through the metrics handler.
+	// The line must not be empty nor contain line breaks, except for an optional trailing newline.
+	SendRawLine(line string) error
+
+	// Sends a batch of pre-formatted metric lines. The batch is rejected as a whole if any line is invalid.
+	SendRawLines(lines []string) error
+}
+
 // EventSender Interface for sending events to Wavefront. NOT yet supported.
 type EventSender interface {
 	// Sends an event to Wavefront with optional tags